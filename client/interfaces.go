@@ -44,6 +44,7 @@ type ContainerServer interface {
 	// Server functions
 	GetServer() (server *api.Server, ETag string, err error)
 	GetServerResources() (resources *api.Resources, err error)
+	GetConfigKeys() (keys []api.ConfigKey, err error)
 	UpdateServer(server api.ServerPut, ETag string) (err error)
 	HasExtension(extension string) (exists bool)
 	RequireAuthenticated(authenticated bool)
@@ -74,8 +75,11 @@ type ContainerServer interface {
 	DeleteContainerConsoleLog(containerName string, args *ContainerConsoleLogArgs) (err error)
 
 	GetContainerFile(containerName string, path string) (content io.ReadCloser, resp *ContainerFileResponse, err error)
+	GetContainerFileEntries(containerName string, path string) (entries []api.ContainerFileEntry, err error)
+	GetContainerFileTarball(containerName string, path string) (content io.ReadCloser, err error)
 	CreateContainerFile(containerName string, path string, args ContainerFileArgs) (err error)
 	DeleteContainerFile(containerName string, path string) (err error)
+	DeleteContainerFileRecursive(containerName string, path string) (err error)
 
 	GetContainerSnapshotNames(containerName string) (names []string, err error)
 	GetContainerSnapshots(containerName string) (snapshots []api.ContainerSnapshot, err error)
@@ -126,6 +130,12 @@ type ContainerServer interface {
 	RenameNetwork(name string, network api.NetworkPost) (err error)
 	DeleteNetwork(name string) (err error)
 
+	// Network lease functions ("network_leases" API extension)
+	GetNetworkLeases(name string) (leases []api.NetworkLease, err error)
+
+	// Network state functions ("network_state" API extension)
+	GetNetworkState(name string) (state *api.NetworkState, err error)
+
 	// Operation functions
 	GetOperationUUIDs() (uuids []string, err error)
 	GetOperations() (operations []api.Operation, err error)
@@ -137,6 +147,7 @@ type ContainerServer interface {
 	GetProfileNames() (names []string, err error)
 	GetProfiles() (profiles []api.Profile, err error)
 	GetProfile(name string) (profile *api.Profile, ETag string, err error)
+	GetProfileUsage(name string) (usage []api.ProfileUsage, err error)
 	CreateProfile(profile api.ProfilesPost) (err error)
 	UpdateProfile(name string, profile api.ProfilePut, ETag string) (err error)
 	RenameProfile(name string, profile api.ProfilePost) (err error)
@@ -332,11 +343,15 @@ type ContainerFileArgs struct {
 	// File permissions
 	Mode int
 
-	// File type (file or directory)
+	// File type (file, directory, symlink or tarball, to unpack a tar
+	// archive in the directory)
 	Type string
 
 	// File write mode (overwrite or append)
 	WriteMode string
+
+	// Extended attributes (including POSIX ACLs) to set on the file
+	Xattrs map[string]string
 }
 
 // The ContainerFileResponse struct is used as part of the response for a container file download
@@ -350,9 +365,16 @@ type ContainerFileResponse struct {
 	// File permissions
 	Mode int
 
-	// File type (file or directory)
+	// File type (file, directory or, for special files without content,
+	// block, char, fifo or socket)
 	Type string
 
 	// If a directory, the list of files inside it
 	Entries []string
+
+	// If the path is a symlink (which got followed), its target
+	Target string
+
+	// Extended attributes (including POSIX ACLs) of the file
+	Xattrs map[string]string
 }
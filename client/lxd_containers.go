@@ -478,6 +478,10 @@ func (r *ProtocolLXD) RenameContainer(name string, container api.ContainerPost)
 		return nil, fmt.Errorf("Can't ask for a migration through RenameContainer")
 	}
 
+	if container.Pool != "" && !r.HasExtension("container_pool_move") {
+		return nil, fmt.Errorf("The server is missing the required \"container_pool_move\" API extension")
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s", url.QueryEscape(name)), container, "")
 	if err != nil {
@@ -734,11 +738,25 @@ func (r *ProtocolLXD) GetContainerFile(containerName string, path string) (io.Re
 
 	// Parse the headers
 	uid, gid, mode, fileType, _ := shared.ParseLXDFileHeaders(resp.Header)
+	xattrs, err := shared.ParseLXDFileXattrsHeader(resp.Header)
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+
 	fileResp := ContainerFileResponse{
-		UID:  uid,
-		GID:  gid,
-		Mode: mode,
-		Type: fileType,
+		UID:    uid,
+		GID:    gid,
+		Mode:   mode,
+		Type:   fileType,
+		Target: resp.Header.Get("X-LXD-target"),
+		Xattrs: xattrs,
+	}
+
+	// Special files have no content
+	if shared.StringInSlice(fileResp.Type, []string{"block", "char", "fifo", "socket"}) {
+		resp.Body.Close()
+		return nil, &fileResp, nil
 	}
 
 	if fileResp.Type == "directory" {
@@ -766,6 +784,71 @@ func (r *ProtocolLXD) GetContainerFile(containerName string, path string) (io.Re
 	return resp.Body, &fileResp, err
 }
 
+// GetContainerFileEntries returns the details of the entries of the provided
+// directory of the container
+func (r *ProtocolLXD) GetContainerFileEntries(containerName string, path string) ([]api.ContainerFileEntry, error) {
+	if !r.HasExtension("file_list_details") {
+		return nil, fmt.Errorf("The server is missing the required \"file_list_details\" API extension")
+	}
+
+	entries := []api.ContainerFileEntry{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/files?path=%s&recursion=1", url.QueryEscape(containerName), url.QueryEscape(path)), nil, "", &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetContainerFileTarball retrieves the provided directory from the container,
+// with all its content, as a tar archive
+func (r *ProtocolLXD) GetContainerFileTarball(containerName string, path string) (io.ReadCloser, error) {
+	if !r.HasExtension("file_tarball") {
+		return nil, fmt.Errorf("The server is missing the required \"file_tarball\" API extension")
+	}
+
+	// Prepare the HTTP request
+	requestURL, err := shared.URLEncode(
+		fmt.Sprintf("%s/1.0/containers/%s/files", r.httpHost, url.QueryEscape(containerName)),
+		map[string]string{"path": path, "recursive": "1"})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the user agent
+	if r.httpUserAgent != "" {
+		req.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	// Send the request
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the return value for a cleaner error
+	if resp.StatusCode != http.StatusOK {
+		_, _, err := r.parseResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.Header.Get("X-LXD-type") != "tarball" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s isn't a directory", path)
+	}
+
+	return resp.Body, nil
+}
+
 // CreateContainerFile tells LXD to create a file in the container
 func (r *ProtocolLXD) CreateContainerFile(containerName string, path string, args ContainerFileArgs) error {
 	if args.Type == "directory" {
@@ -780,12 +863,24 @@ func (r *ProtocolLXD) CreateContainerFile(containerName string, path string, arg
 		}
 	}
 
+	if args.Type == "tarball" {
+		if !r.HasExtension("file_tarball_push") {
+			return fmt.Errorf("The server is missing the required \"file_tarball_push\" API extension")
+		}
+	}
+
 	if args.WriteMode == "append" {
 		if !r.HasExtension("file_append") {
 			return fmt.Errorf("The server is missing the required \"file_append\" API extension")
 		}
 	}
 
+	if len(args.Xattrs) > 0 {
+		if !r.HasExtension("file_xattrs") {
+			return fmt.Errorf("The server is missing the required \"file_xattrs\" API extension")
+		}
+	}
+
 	// Prepare the HTTP request
 	req, err := http.NewRequest("POST", fmt.Sprintf("%s/1.0/containers/%s/files?path=%s", r.httpHost, url.QueryEscape(containerName), url.QueryEscape(path)), args.Content)
 	if err != nil {
@@ -818,6 +913,15 @@ func (r *ProtocolLXD) CreateContainerFile(containerName string, path string, arg
 		req.Header.Set("X-LXD-write", args.WriteMode)
 	}
 
+	if len(args.Xattrs) > 0 {
+		xattrs, err := shared.LXDFileXattrsHeader(args.Xattrs)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("X-LXD-xattrs", xattrs)
+	}
+
 	// Send the request
 	resp, err := r.do(req)
 	if err != nil {
@@ -848,6 +952,22 @@ func (r *ProtocolLXD) DeleteContainerFile(containerName string, path string) err
 	return nil
 }
 
+// DeleteContainerFileRecursive deletes a file in the container, or a directory
+// and all its content
+func (r *ProtocolLXD) DeleteContainerFileRecursive(containerName string, path string) error {
+	if !r.HasExtension("file_delete_recursive") {
+		return fmt.Errorf("The server is missing the required \"file_delete_recursive\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/containers/%s/files?path=%s&recursive=1", url.QueryEscape(containerName), url.QueryEscape(path)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // GetContainerSnapshotNames returns a list of snapshot names for the container
 func (r *ProtocolLXD) GetContainerSnapshotNames(containerName string) ([]string, error) {
 	urls := []string{}
@@ -69,7 +69,16 @@ func (r *ProtocolLXD) GetImageSecret(fingerprint string) (string, error) {
 		return "", err
 	}
 
-	return op.Metadata["secret"].(string), nil
+	if op.Class != api.OperationClassToken {
+		return "", fmt.Errorf("Unexpected %s operation, expected a token", op.Class)
+	}
+
+	secret, ok := op.Metadata["secret"].(string)
+	if !ok {
+		return "", fmt.Errorf("The token operation is missing its secret")
+	}
+
+	return secret, nil
 }
 
 // GetPrivateImage is similar to GetImage but allows passing a secret download token
@@ -56,6 +56,23 @@ func (r *ProtocolLXD) GetProfile(name string) (*api.Profile, string, error) {
 	return &profile, etag, nil
 }
 
+// GetProfileUsage returns what the profile contributes to each of the containers using it
+func (r *ProtocolLXD) GetProfileUsage(name string) ([]api.ProfileUsage, error) {
+	if !r.HasExtension("profile_usage") {
+		return nil, fmt.Errorf("The server is missing the required \"profile_usage\" API extension")
+	}
+
+	usage := []api.ProfileUsage{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/profiles/%s/usage", url.QueryEscape(name)), nil, "", &usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
 // CreateProfile defines a new container profile
 func (r *ProtocolLXD) CreateProfile(profile api.ProfilesPost) error {
 	// Send the request
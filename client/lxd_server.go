@@ -77,3 +77,20 @@ func (r *ProtocolLXD) GetServerResources() (*api.Resources, error) {
 
 	return &resources, nil
 }
+
+// GetConfigKeys returns the well-known container configuration keys supported by the server
+func (r *ProtocolLXD) GetConfigKeys() ([]api.ConfigKey, error) {
+	if !r.HasExtension("config_keys") {
+		return nil, fmt.Errorf("The server is missing the required \"config_keys\" API extension")
+	}
+
+	keys := []api.ConfigKey{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/config-keys", nil, "", &keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
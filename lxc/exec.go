@@ -43,6 +43,10 @@ type execCmd struct {
 	forceInteractive    bool
 	forceNonInteractive bool
 	disableStdin        bool
+	user                uint
+	group               int
+	cwd                 string
+	shell               bool
 }
 
 func (c *execCmd) showByDefault() bool {
@@ -51,7 +55,8 @@ func (c *execCmd) showByDefault() bool {
 
 func (c *execCmd) usage() string {
 	return i18n.G(
-		`Usage: lxc exec [<remote>:]<container> [-t] [-T] [-n] [--mode=auto|interactive|non-interactive] [--env KEY=VALUE...] [--] <command line>
+		`Usage: lxc exec [<remote>:]<container> [-t] [-T] [-n] [--mode=auto|interactive|non-interactive] [--env KEY=VALUE...] [--user UID] [--group GID] [--cwd PATH] [--] <command line>
+       lxc exec [<remote>:]<container> --shell [-t] [-T] [-n] [--mode=auto|interactive|non-interactive] [--env KEY=VALUE...] [--user UID] [--group GID] [--cwd PATH]
 
 Execute commands in containers.
 
@@ -61,6 +66,8 @@ as arguments, for example:
 
     lxc exec <container> -- sh -c "cd /tmp && pwd"
 
+With --shell, the login shell of the user is started instead, as set in the container's /etc/passwd.
+
 Mode defaults to non-interactive, interactive mode is selected if both stdin AND stdout are terminals (stderr is ignored).`)
 }
 
@@ -70,6 +77,10 @@ func (c *execCmd) flags() {
 	gnuflag.BoolVar(&c.forceInteractive, "t", false, i18n.G("Force pseudo-terminal allocation"))
 	gnuflag.BoolVar(&c.forceNonInteractive, "T", false, i18n.G("Disable pseudo-terminal allocation"))
 	gnuflag.BoolVar(&c.disableStdin, "n", false, i18n.G("Disable stdin (reads from /dev/null)"))
+	gnuflag.UintVar(&c.user, "user", 0, i18n.G("User ID to run the command as (default 0)"))
+	gnuflag.IntVar(&c.group, "group", -1, i18n.G("Group ID to run the command as (default: primary group of the user)"))
+	gnuflag.StringVar(&c.cwd, "cwd", "", i18n.G("Directory to run the command in (default $HOME)"))
+	gnuflag.BoolVar(&c.shell, "shell", false, i18n.G("Start the login shell of the user instead of a command"))
 }
 
 func (c *execCmd) sendTermSize(control *websocket.Conn) error {
@@ -124,7 +135,11 @@ func (c *execCmd) forwardSignal(control *websocket.Conn, sig syscall.Signal) err
 }
 
 func (c *execCmd) run(conf *config.Config, args []string) error {
-	if len(args) < 2 {
+	if c.shell && len(args) != 1 {
+		return errArgs
+	}
+
+	if !c.shell && len(args) < 2 {
 		return errArgs
 	}
 
@@ -146,10 +161,22 @@ func (c *execCmd) run(conf *config.Config, args []string) error {
 		return err
 	}
 
+	if (c.user != 0 || c.group != -1 || c.cwd != "") && !d.HasExtension("container_exec_user_group_cwd") {
+		return fmt.Errorf(i18n.G("The server doesn't support setting the user, group or directory of the command"))
+	}
+
+	if c.shell && !d.HasExtension("container_exec_shell") {
+		return fmt.Errorf(i18n.G("The server doesn't support starting login shells"))
+	}
+
 	/* FIXME: Default values for HOME and USER are now handled by LXD.
 	   This code should be removed after most users upgraded.
 	*/
-	env := map[string]string{"HOME": "/root", "USER": "root"}
+	env := map[string]string{}
+	if !c.shell {
+		env["HOME"] = "/root"
+		env["USER"] = "root"
+	}
 	if myTerm, ok := c.getTERM(); ok {
 		env["TERM"] = myTerm
 	}
@@ -185,13 +212,15 @@ func (c *execCmd) run(conf *config.Config, args []string) error {
 		defer termios.Restore(cfd, oldttystate)
 	}
 
+	// Older servers only read the control socket in interactive mode
 	handler := c.controlSocketHandler
-	if !interactive {
+	if !interactive && !d.HasExtension("exec_control_noninteractive") {
 		handler = nil
 	}
 
+	// Let the server create the terminal at the size of ours, if we have one
 	var width, height int
-	if interactive {
+	if interactive && termios.IsTerminal(int(syscall.Stdout)) {
 		width, height, err = termios.GetSize(int(syscall.Stdout))
 		if err != nil {
 			return err
@@ -213,6 +242,14 @@ func (c *execCmd) run(conf *config.Config, args []string) error {
 		Environment: env,
 		Width:       width,
 		Height:      height,
+		User:        uint32(c.user),
+		Cwd:         c.cwd,
+		Shell:       c.shell,
+	}
+
+	if c.group != -1 {
+		group := uint32(c.group)
+		req.Group = &group
 	}
 
 	execArgs := lxd.ContainerExecArgs{
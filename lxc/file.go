@@ -29,6 +29,8 @@ type fileCmd struct {
 	recursive bool
 
 	mkdirs bool
+
+	xattrs bool
 }
 
 func (c *fileCmd) showByDefault() bool {
@@ -41,13 +43,13 @@ func (c *fileCmd) usage() string {
 
 Manage files in containers.
 
-lxc file pull [-r|--recursive] [<remote>:]<container>/<path> [[<remote>:]<container>/<path>...] <target path>
+lxc file pull [-r|--recursive] [--xattrs] [<remote>:]<container>/<path> [[<remote>:]<container>/<path>...] <target path>
     Pull files from containers.
 
-lxc file push [-r|--recursive] [-p|--create-dirs] [--uid=UID] [--gid=GID] [--mode=MODE] <source path> [<source path>...] [<remote>:]<container>/<path>
+lxc file push [-r|--recursive] [-p|--create-dirs] [--uid=UID] [--gid=GID] [--mode=MODE] [--xattrs] <source path> [<source path>...] [<remote>:]<container>/<path>
     Push files into containers.
 
-lxc file delete [<remote>:]<container>/<path> [[<remote>:]<container>/<path>...]
+lxc file delete [-r|--recursive] [<remote>:]<container>/<path> [[<remote>:]<container>/<path>...]
     Delete files in containers.
 
 lxc file edit [<remote>:]<container>/<path>
@@ -65,10 +67,11 @@ func (c *fileCmd) flags() {
 	gnuflag.IntVar(&c.uid, "uid", -1, i18n.G("Set the file's uid on push"))
 	gnuflag.IntVar(&c.gid, "gid", -1, i18n.G("Set the file's gid on push"))
 	gnuflag.StringVar(&c.mode, "mode", "", i18n.G("Set the file's perms on push"))
-	gnuflag.BoolVar(&c.recursive, "recursive", false, i18n.G("Recursively push or pull files"))
-	gnuflag.BoolVar(&c.recursive, "r", false, i18n.G("Recursively push or pull files"))
+	gnuflag.BoolVar(&c.recursive, "recursive", false, i18n.G("Recursively push, pull or delete files"))
+	gnuflag.BoolVar(&c.recursive, "r", false, i18n.G("Recursively push, pull or delete files"))
 	gnuflag.BoolVar(&c.mkdirs, "create-dirs", false, i18n.G("Create any directories necessary"))
 	gnuflag.BoolVar(&c.mkdirs, "p", false, i18n.G("Create any directories necessary"))
+	gnuflag.BoolVar(&c.xattrs, "xattrs", false, i18n.G("Preserve the extended attributes and ACLs of the files"))
 }
 
 func (c *fileCmd) recursivePullFile(d lxd.ContainerServer, container string, p string, targetDir string) error {
@@ -80,12 +83,28 @@ func (c *fileCmd) recursivePullFile(d lxd.ContainerServer, container string, p s
 	target := filepath.Join(targetDir, filepath.Base(p))
 	logger.Infof("Pulling %s from %s (%s)", target, p, resp.Type)
 
+	// Keep symlinks as they are rather than copying what they point to
+	if resp.Target != "" {
+		if buf != nil {
+			buf.Close()
+		}
+
+		return os.Symlink(resp.Target, target)
+	}
+
 	if resp.Type == "directory" {
 		err := os.Mkdir(target, os.FileMode(resp.Mode))
 		if err != nil {
 			return err
 		}
 
+		if c.xattrs {
+			err := fileSetXattrs(target, resp.Xattrs)
+			if err != nil {
+				return err
+			}
+		}
+
 		for _, ent := range resp.Entries {
 			nextP := path.Join(p, ent)
 
@@ -110,6 +129,14 @@ func (c *fileCmd) recursivePullFile(d lxd.ContainerServer, container string, p s
 		if err != nil {
 			return err
 		}
+
+		// Set once the content is written, which drops file capabilities
+		if c.xattrs {
+			err := fileSetXattrs(target, resp.Xattrs)
+			if err != nil {
+				return err
+			}
+		}
 	} else {
 		return fmt.Errorf(i18n.G("Unknown file type '%s'"), resp.Type)
 	}
@@ -165,6 +192,13 @@ func (c *fileCmd) recursivePushFile(d lxd.ContainerServer, container string, sou
 			args.Content = f
 		}
 
+		if c.xattrs && args.Type != "symlink" {
+			args.Xattrs, err = fileGetXattrs(p)
+			if err != nil {
+				return err
+			}
+		}
+
 		logger.Infof("Pushing %s to %s (%s)", p, targetPath, args.Type)
 		return d.CreateContainerFile(container, targetPath, args)
 	}
@@ -414,6 +448,13 @@ func (c *fileCmd) push(conf *config.Config, send_file_perms bool, args []string)
 		}
 		args.Type = "file"
 
+		if c.xattrs && f != os.Stdin {
+			args.Xattrs, err = fileGetXattrs(f.Name())
+			if err != nil {
+				return err
+			}
+		}
+
 		logger.Infof("Pushing %s to %s (%s)", f.Name(), fpath, args.Type)
 		err = d.CreateContainerFile(container, fpath, args)
 		if err != nil {
@@ -491,6 +532,10 @@ func (c *fileCmd) pull(conf *config.Config, args []string) error {
 			return fmt.Errorf(i18n.G("Can't pull a directory without --recursive"))
 		}
 
+		if buf == nil {
+			return fmt.Errorf(i18n.G("Can't pull a file of type '%s'"), resp.Type)
+		}
+
 		var targetPath string
 		if targetIsDir {
 			targetPath = path.Join(target, path.Base(pathSpec[1]))
@@ -520,6 +565,13 @@ func (c *fileCmd) pull(conf *config.Config, args []string) error {
 		if err != nil {
 			return err
 		}
+
+		if c.xattrs && f != os.Stdout {
+			err = fileSetXattrs(targetPath, resp.Xattrs)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -546,7 +598,11 @@ func (c *fileCmd) delete(conf *config.Config, args []string) error {
 			return err
 		}
 
-		err = d.DeleteContainerFile(container, pathSpec[1])
+		if c.recursive {
+			err = d.DeleteContainerFileRecursive(container, pathSpec[1])
+		} else {
+			err = d.DeleteContainerFile(container, pathSpec[1])
+		}
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,38 @@
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+func fileGetXattrs(path string) (map[string]string, error) {
+	xattrs, err := shared.GetAllXattr(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only some of them can be set in the container
+	for name := range xattrs {
+		if !shared.IsFileXattrAllowed(name) {
+			delete(xattrs, name)
+		}
+	}
+
+	return xattrs, nil
+}
+
+func fileSetXattrs(path string, xattrs map[string]string) error {
+	for name, value := range xattrs {
+		err := syscall.Setxattr(path, name, []byte(value), 0)
+		if err != nil {
+			return fmt.Errorf(i18n.G("Failed to set %s on %s: %s"), name, path, err)
+		}
+	}
+
+	return nil
+}
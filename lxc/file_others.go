@@ -0,0 +1,17 @@
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+func fileGetXattrs(path string) (map[string]string, error) {
+	return nil, fmt.Errorf(i18n.G("Extended attributes aren't supported on this platform"))
+}
+
+func fileSetXattrs(path string, xattrs map[string]string) error {
+	return fmt.Errorf(i18n.G("Extended attributes aren't supported on this platform"))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/lxc/lxd/lxc/config"
@@ -14,6 +15,7 @@ type moveCmd struct {
 	containerOnly bool
 	mode          string
 	stateless     bool
+	storage       string
 }
 
 func (c *moveCmd) showByDefault() bool {
@@ -32,6 +34,9 @@ lxc move [<remote>:]<source container> [<remote>:][<destination container>] [--c
 lxc move <old name> <new name> [--container-only]
     Rename a local container.
 
+lxc move [<remote>:]<container> --storage <pool>
+    Move a stopped container and its snapshots to another storage pool.
+
 lxc move <container>/<old snapshot name> <container>/<new snapshot name>
     Rename a snapshot.`)
 }
@@ -40,9 +45,14 @@ func (c *moveCmd) flags() {
 	gnuflag.BoolVar(&c.containerOnly, "container-only", false, i18n.G("Move the container without its snapshots"))
 	gnuflag.StringVar(&c.mode, "mode", "pull", i18n.G("Transfer mode. One of pull (default), push or relay."))
 	gnuflag.BoolVar(&c.stateless, "stateless", false, i18n.G("Copy a stateful container stateless"))
+	gnuflag.StringVar(&c.storage, "storage", "", i18n.G("Storage pool name"))
 }
 
 func (c *moveCmd) run(conf *config.Config, args []string) error {
+	if c.storage != "" && len(args) == 1 {
+		return c.moveToPool(conf, args[0])
+	}
+
 	if len(args) != 2 {
 		return errArgs
 	}
@@ -74,6 +84,14 @@ func (c *moveCmd) run(conf *config.Config, args []string) error {
 			return err
 		}
 
+		if c.storage != "" {
+			if destName != sourceName {
+				return fmt.Errorf(i18n.G("Containers can't be renamed while being moved to another storage pool"))
+			}
+
+			return c.moveToPool(conf, args[0])
+		}
+
 		if shared.IsSnapshot(sourceName) {
 			// Snapshot rename
 			srcFields := strings.SplitN(sourceName, shared.SnapshotDelimiter, 2)
@@ -96,6 +114,10 @@ func (c *moveCmd) run(conf *config.Config, args []string) error {
 		return op.Wait()
 	}
 
+	if c.storage != "" {
+		return fmt.Errorf(i18n.G("Moving to another storage pool is only supported within the same server"))
+	}
+
 	cpy := copyCmd{}
 
 	stateful := !c.stateless
@@ -111,3 +133,22 @@ func (c *moveCmd) run(conf *config.Config, args []string) error {
 	del.force = true
 	return del.run(conf, args[:1])
 }
+
+func (c *moveCmd) moveToPool(conf *config.Config, name string) error {
+	remote, containerName, err := conf.ParseRemote(name)
+	if err != nil {
+		return err
+	}
+
+	d, err := conf.GetContainerServer(remote)
+	if err != nil {
+		return err
+	}
+
+	op, err := d.RenameContainer(containerName, api.ContainerPost{Pool: c.storage})
+	if err != nil {
+		return err
+	}
+
+	return op.Wait()
+}
@@ -58,6 +58,12 @@ lxc network list [<remote>:]
 lxc network show [<remote>:]<network>
     Show details of a network.
 
+lxc network info [<remote>:]<network>
+    Show the addresses and counters of a network.
+
+lxc network list-leases [<remote>:]<network>
+    List the DHCP leases of a network.
+
 lxc network create [<remote>:]<network> [key=value...]
     Create a network.
 
@@ -143,6 +149,10 @@ func (c *networkCmd) run(conf *config.Config, args []string) error {
 		return c.doNetworkRename(client, network, args[2])
 	case "get":
 		return c.doNetworkGet(client, network, args[2:])
+	case "info":
+		return c.doNetworkInfo(client, network)
+	case "list-leases":
+		return c.doNetworkListLeases(client, network)
 	case "set":
 		return c.doNetworkSet(client, network, args[2:])
 	case "unset":
@@ -471,6 +481,34 @@ func (c *networkCmd) doNetworkGet(client lxd.ContainerServer, name string, args
 	return nil
 }
 
+func (c *networkCmd) doNetworkInfo(client lxd.ContainerServer, name string) error {
+	state, err := client.GetNetworkState(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %s\n", i18n.G("Name"), name)
+	fmt.Printf("%s: %s\n", i18n.G("MAC address"), state.Hwaddr)
+	fmt.Printf("%s: %d\n", i18n.G("MTU"), state.Mtu)
+	fmt.Printf("%s: %s\n", i18n.G("State"), state.State)
+	fmt.Printf("%s: %s\n", i18n.G("Type"), state.Type)
+
+	if len(state.Addresses) > 0 {
+		fmt.Println(i18n.G("Ips:"))
+		for _, addr := range state.Addresses {
+			fmt.Printf("  %s\t%s/%s\t%s\n", addr.Family, addr.Address, addr.Netmask, addr.Scope)
+		}
+	}
+
+	fmt.Println(i18n.G("Network usage:"))
+	fmt.Printf("  %s: %s\n", i18n.G("Bytes received"), shared.GetByteSizeString(state.Counters.BytesReceived, 2))
+	fmt.Printf("  %s: %s\n", i18n.G("Bytes sent"), shared.GetByteSizeString(state.Counters.BytesSent, 2))
+	fmt.Printf("  %s: %d\n", i18n.G("Packets received"), state.Counters.PacketsReceived)
+	fmt.Printf("  %s: %d\n", i18n.G("Packets sent"), state.Counters.PacketsSent)
+
+	return nil
+}
+
 func (c *networkCmd) doNetworkList(conf *config.Config, args []string) error {
 	var remote string
 	var err error
@@ -531,6 +569,34 @@ func (c *networkCmd) doNetworkList(conf *config.Config, args []string) error {
 	return nil
 }
 
+func (c *networkCmd) doNetworkListLeases(client lxd.ContainerServer, name string) error {
+	leases, err := client.GetNetworkLeases(name)
+	if err != nil {
+		return err
+	}
+
+	data := [][]string{}
+	for _, lease := range leases {
+		data = append(data, []string{lease.Hostname, lease.Hwaddr, lease.Address, strings.ToUpper(lease.Type), lease.Container})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(true)
+	table.SetHeader([]string{
+		i18n.G("HOSTNAME"),
+		i18n.G("MAC ADDRESS"),
+		i18n.G("IP ADDRESS"),
+		i18n.G("TYPE"),
+		i18n.G("CONTAINER")})
+	sort.Sort(byName(data))
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
 func (c *networkCmd) doNetworkSet(client lxd.ContainerServer, name string, args []string) error {
 	// we shifted @args so so it should read "<key> [<value>]"
 	if len(args) < 1 {
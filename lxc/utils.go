@@ -128,7 +128,8 @@ func (p *ProgressRenderer) UpdateOp(op api.Operation) {
 		return
 	}
 
-	for _, key := range []string{"fs_progress", "download_progress"} {
+	// Unpacking follows the download, so check for it first
+	for _, key := range []string{"create_container_from_image_unpack_progress", "create_image_unpack_progress", "fs_progress", "download_progress"} {
 		value, ok := op.Metadata[key]
 		if ok {
 			p.Update(value.(string))
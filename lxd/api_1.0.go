@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"sort"
 
 	"gopkg.in/lxc/go-lxc.v2"
 
@@ -29,6 +30,7 @@ var api10 = []Command{
 	containerExecCmd,
 	containerMetadataCmd,
 	containerMetadataTemplatesCmd,
+	containerExportCmd,
 	aliasCmd,
 	aliasesCmd,
 	eventsCmd,
@@ -43,11 +45,15 @@ var api10 = []Command{
 	operationWebsocket,
 	networksCmd,
 	networkCmd,
+	networkLeasesCmd,
+	networkStateCmd,
 	api10Cmd,
 	certificatesCmd,
 	certificateFingerprintCmd,
+	configKeysCmd,
 	profilesCmd,
 	profileCmd,
+	profileUsageCmd,
 	serverResourceCmd,
 	storagePoolsCmd,
 	storagePoolCmd,
@@ -56,6 +62,11 @@ var api10 = []Command{
 	storagePoolVolumesTypeCmd,
 	storagePoolVolumeTypeCmd,
 	serverResourceCmd,
+	clusterCmd,
+	clusterMembersCmd,
+	clusterMemberCmd,
+	clusterMemberStateCmd,
+	metricsCmd,
 }
 
 func api10Get(d *Daemon, r *http.Request) Response {
@@ -122,8 +133,17 @@ func api10Get(d *Daemon, r *http.Request) Response {
 		ServerPid:              os.Getpid(),
 		ServerVersion:          version.Version}
 
+	// Sort the storage drivers so that the environment doesn't change
+	// from one request to the next.
 	drivers := readStoragePoolDriversCache()
-	for driver, version := range drivers {
+	driverNames := []string{}
+	for driver := range drivers {
+		driverNames = append(driverNames, driver)
+	}
+	sort.Strings(driverNames)
+
+	for _, driver := range driverNames {
+		version := drivers[driver]
 		if env.Storage != "" {
 			env.Storage = env.Storage + " | " + driver
 		} else {
@@ -217,6 +237,9 @@ func doApi10Update(d *Daemon, oldConfig map[string]string, req api.ServerPut) Re
 		}
 	}
 
+	// Validate all the changes first, so that a bad key or value doesn't
+	// leave the server half reconfigured.
+	changedValues := map[string]string{}
 	for key, valueRaw := range changedConfig {
 		if valueRaw == nil {
 			valueRaw = ""
@@ -234,7 +257,18 @@ func doApi10Update(d *Daemon, oldConfig map[string]string, req api.ServerPut) Re
 			return BadRequest(fmt.Errorf("Bad server config key: '%s'", key))
 		}
 
-		err := confKey.Set(d, value)
+		err := confKey.Validate(d, value)
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		changedValues[key] = value
+	}
+
+	// Apply them, the setters take care of live reconfiguration (like
+	// re-binding the network listener).
+	for key, value := range changedValues {
+		err := daemonConfig[key].Set(d, value)
 		if err != nil {
 			return SmartError(err)
 		}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/audit"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Where the state-changing API calls get recorded, if anywhere
+var auditLock sync.Mutex
+var auditSink audit.Sink
+
+// auditSetup sets where the state-changing API calls get recorded: "file" for
+// audit.log in the log directory, rotated every 10MiB, "syslog" or "" to stop
+// recording them.
+func auditSetup(kind string) error {
+	var sink audit.Sink
+	var err error
+
+	switch kind {
+	case "file":
+		sink, err = audit.NewFileSink(shared.LogPath("audit.log"), 10*1024*1024, 5)
+	case "syslog":
+		sink, err = audit.NewSyslogSink("lxd-audit")
+	case "":
+	default:
+		err = fmt.Errorf("Unknown audit log type: %s", kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	auditLock.Lock()
+	oldSink := auditSink
+	auditSink = sink
+	auditLock.Unlock()
+
+	if oldSink != nil {
+		return oldSink.Close()
+	}
+
+	return nil
+}
+
+func auditEnabled() bool {
+	auditLock.Lock()
+	defer auditLock.Unlock()
+
+	return auditSink != nil
+}
+
+// auditEntryNew starts the audit entry of the given request.
+func auditEntryNew(r *http.Request) *audit.Entry {
+	requestor := eventRequestor(r)
+	return &audit.Entry{
+		Timestamp: time.Now(),
+		Method:    r.Method,
+		URL:       r.URL.RequestURI(),
+		Protocol:  requestor.Protocol,
+		Username:  requestor.Username,
+		Address:   requestor.Address,
+	}
+}
+
+// auditEntryBody adds a summary of the request body to its audit entry, if
+// it's JSON. It's only read once the request got allowed, so that the bodies
+// of untrusted clients don't get buffered.
+func auditEntryBody(entry *audit.Entry, r *http.Request) {
+	if !isJSONRequest(r) {
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		entry.Body = audit.Summarize(body)
+	}
+	r.Body = shared.BytesReadCloser{Buf: bytes.NewBuffer(body)}
+}
+
+// auditRecordAction records the outcome of an action started by an earlier
+// call of the requestor, at the URL of the affected object.
+func auditRecordAction(action string, source string, context map[string]interface{}, requestor *api.EventLifecycleRequestor) {
+	entry := &audit.Entry{
+		Timestamp: time.Now(),
+		URL:       source,
+		Action:    action,
+		Context:   context,
+	}
+
+	if requestor != nil {
+		entry.Protocol = requestor.Protocol
+		entry.Username = requestor.Username
+		entry.Address = requestor.Address
+	}
+
+	auditRecord(entry)
+}
+
+func auditRecord(entry *audit.Entry) {
+	auditLock.Lock()
+	defer auditLock.Unlock()
+
+	if auditSink == nil {
+		return
+	}
+
+	err := auditSink.Write(*entry)
+	if err != nil {
+		logger.Warn("Failed to write to the audit log", log.Ctx{"err": err})
+	}
+}
+
+// An http.ResponseWriter keeping track of the response status code.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *auditResponseWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,653 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var clusterCmd = Command{name: "cluster", get: clusterGet, put: clusterPut}
+var clusterMembersCmd = Command{name: "cluster/members", get: clusterMembersGet, post: clusterMembersPost}
+var clusterMemberCmd = Command{name: "cluster/members/{name}", get: clusterMemberGet, delete: clusterMemberDelete}
+var clusterMemberStateCmd = Command{name: "cluster/members/{name}/state", post: clusterMemberStatePost}
+
+var internalClusterAcceptCmd = Command{name: "cluster/accept", untrustedPost: true, post: internalClusterAccept}
+var internalClusterMembersCmd = Command{name: "cluster/members", put: internalClusterMembersPut}
+
+func clusterGet(d *Daemon, r *http.Request) Response {
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	result := api.Cluster{}
+	self := clusterSelf(d, members)
+	if self != nil {
+		result.ServerName = self.Name
+		result.Enabled = true
+	}
+
+	return SyncResponse(true, result)
+}
+
+// Bootstrap a new cluster, join an existing one or stop being clustered.
+func clusterPut(d *Daemon, r *http.Request) Response {
+	req := api.ClusterPut{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return SmartError(err)
+	}
+	self := clusterSelf(d, members)
+
+	if !req.Enabled {
+		if self == nil {
+			return EmptySyncResponse
+		}
+
+		if len(members) > 1 {
+			return BadRequest(fmt.Errorf("The other members must be removed from the cluster first"))
+		}
+
+		err = d.db.ClusterMembersReplace(nil)
+		if err != nil {
+			return SmartError(err)
+		}
+		readSavedClientCAList(d)
+
+		return EmptySyncResponse
+	}
+
+	if self != nil {
+		return BadRequest(fmt.Errorf("This server is already a member of a cluster"))
+	}
+
+	if req.ServerName == "" {
+		return BadRequest(fmt.Errorf("No server name provided"))
+	}
+
+	address, err := clusterAddress()
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Bootstrap a new cluster
+	if req.ClusterAddress == "" {
+		err = d.db.ClusterMemberAdd(db.ClusterMemberInfo{
+			Name:        req.ServerName,
+			Address:     address,
+			Certificate: string(d.serverCert.PublicKey()),
+			JoinedAt:    time.Now().UTC(),
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+
+		logger.Info("Bootstrapped a new cluster", log.Ctx{"name": req.ServerName, "address": address})
+		return EmptySyncResponse
+	}
+
+	err = clusterJoin(d, r, req, address)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	logger.Info("Joined the cluster", log.Ctx{"name": req.ServerName, "cluster": req.ClusterAddress})
+	return EmptySyncResponse
+}
+
+// Return the address the other members can reach this node at, as configured
+// in core.https_address.
+func clusterAddress() (string, error) {
+	address := daemonConfig["core.https_address"].Get()
+	if address == "" {
+		return "", fmt.Errorf("core.https_address must be set to enable clustering")
+	}
+
+	address = util.CanonicalNetworkAddress(address)
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+
+	ip := net.ParseIP(host)
+	if host == "" || (ip != nil && ip.IsUnspecified()) {
+		return "", fmt.Errorf("core.https_address must be a specific address to enable clustering")
+	}
+
+	return address, nil
+}
+
+// Ask the cluster member at the given address to accept this node, using the
+// token it issued, record the list of members it replies with and get the
+// profiles in sync with theirs.
+func clusterJoin(d *Daemon, r *http.Request, req api.ClusterPut, address string) error {
+	containers, err := d.db.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return err
+	}
+
+	networks, err := d.db.Networks()
+	if err != nil {
+		return err
+	}
+
+	pools, err := d.db.StoragePools()
+	if err != nil && err != db.NoSuchObjectError {
+		return err
+	}
+
+	client, err := cluster.Connect(util.CanonicalNetworkAddress(req.ClusterAddress), d.serverCert, req.ClusterCertificate)
+	if err != nil {
+		return err
+	}
+
+	join := internalClusterJoinPost{
+		ServerName:   req.ServerName,
+		Address:      address,
+		Certificate:  string(d.serverCert.PublicKey()),
+		Token:        req.ClusterToken,
+		Containers:   containers,
+		Networks:     networks,
+		StoragePools: pools,
+	}
+
+	response, _, err := client.RawQuery("POST", "/internal/cluster/accept", join, "")
+	if err != nil {
+		return err
+	}
+
+	accepted := internalClusterJoinResponse{}
+	err = response.MetadataAsStruct(&accepted)
+	if err != nil {
+		return err
+	}
+
+	err = d.db.ClusterMembersReplace(clusterMembersImport(accepted.Members))
+	if err != nil {
+		return err
+	}
+	readSavedClientCAList(d)
+
+	clusterProfilesSync(d, r, accepted.Profiles)
+
+	return nil
+}
+
+func clusterMembersGet(d *Daemon, r *http.Request) Response {
+	recursion := util.IsRecursionRequest(r)
+
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []api.ClusterMember{}
+	for _, member := range members {
+		if !recursion {
+			url := fmt.Sprintf("/%s/cluster/members/%s", version.APIVersion, member.Name)
+			resultString = append(resultString, url)
+			continue
+		}
+
+		resultMap = append(resultMap, clusterMemberRender(d, members, member))
+	}
+
+	if !recursion {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+// Render the given cluster member, checking whether it can be reached and is
+// healthy.
+func clusterMemberRender(d *Daemon, members []db.ClusterMemberInfo, member db.ClusterMemberInfo) api.ClusterMember {
+	result := api.ClusterMember{
+		ServerName: member.Name,
+		URL:        fmt.Sprintf("https://%s", member.Address),
+		Status:     "Online",
+		Message:    "fully operational",
+		JoinedAt:   member.JoinedAt,
+		Evacuated:  member.Evacuated,
+	}
+
+	self := clusterSelf(d, members)
+	if self != nil && self.Name == member.Name {
+		return result
+	}
+
+	client, err := clusterConnect(d, member)
+	if err != nil {
+		result.Status = "Offline"
+		result.Message = err.Error()
+		return result
+	}
+
+	_, _, err = client.RawQuery("GET", "/internal/healthz", nil, "")
+	if err != nil {
+		result.Status = "Unhealthy"
+		result.Message = err.Error()
+	}
+
+	return result
+}
+
+// Create a token which a new member can use to join the cluster.
+func clusterMembersPost(d *Daemon, r *http.Request) Response {
+	req := api.ClusterMembersPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.ServerName == "" {
+		return BadRequest(fmt.Errorf("No server name provided"))
+	}
+
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	self := clusterSelf(d, members)
+	if self == nil {
+		return BadRequest(fmt.Errorf("This server isn't a member of a cluster"))
+	}
+
+	for _, member := range members {
+		if member.Name == req.ServerName {
+			return BadRequest(fmt.Errorf("A member named '%s' already exists", req.ServerName))
+		}
+	}
+
+	secret, err := shared.RandomCryptoString()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	fingerprint, err := shared.CertFingerprintStr(self.Certificate)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	meta := shared.Jmap{}
+	meta["server_name"] = req.ServerName
+	meta["secret"] = secret
+	meta["address"] = self.Address
+	meta["fingerprint"] = fingerprint
+
+	op, err := operationCreate(operationClassToken, nil, meta, nil, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// Check whether the given join token was issued for the given member name. The
+// token gets used up, under operationsLock so that it can only be used once.
+func clusterTokenValid(name string, secret string) bool {
+	operationsLock.Lock()
+	defer operationsLock.Unlock()
+
+	for _, op := range operations {
+		if op.class != operationClassToken || op.metadata == nil {
+			continue
+		}
+
+		opSecret, ok := op.metadata["secret"].(string)
+		if !ok || op.metadata["server_name"] != name {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(opSecret), []byte(secret)) != 1 {
+			continue
+		}
+
+		// Fails if the token got used or cancelled already
+		_, err := op.Cancel()
+		return err == nil
+	}
+
+	return false
+}
+
+func clusterMemberGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, member := range members {
+		if member.Name == name {
+			return SyncResponse(true, clusterMemberRender(d, members, member))
+		}
+	}
+
+	return NotFound
+}
+
+// Remove a member from the cluster. It must not have any container left.
+func clusterMemberDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	self := clusterSelf(d, members)
+	remaining := []db.ClusterMemberInfo{}
+	var removed *db.ClusterMemberInfo
+	for i, member := range members {
+		if member.Name == name {
+			removed = &members[i]
+			continue
+		}
+
+		remaining = append(remaining, member)
+	}
+
+	if removed == nil {
+		return NotFound
+	}
+
+	var containers []string
+	if self != nil && self.Name == removed.Name {
+		containers, err = d.db.ContainersList(db.CTypeRegular)
+		if err != nil {
+			return SmartError(err)
+		}
+	} else {
+		client, err := clusterConnect(d, *removed)
+		if err == nil {
+			containers, err = client.GetContainerNames()
+		}
+
+		if err != nil {
+			logger.Warn("Removing unreachable cluster member", log.Ctx{"member": removed.Name, "err": err})
+		}
+	}
+
+	if len(containers) > 0 {
+		return BadRequest(fmt.Errorf("Member '%s' still has containers", removed.Name))
+	}
+
+	// Tell everybody, including the removed member
+	clusterNotify(d, remaining, remaining)
+	clusterNotify(d, []db.ClusterMemberInfo{*removed}, nil)
+
+	if self != nil && self.Name == removed.Name {
+		remaining = nil
+	}
+
+	err = d.db.ClusterMembersReplace(remaining)
+	if err != nil {
+		return SmartError(err)
+	}
+	readSavedClientCAList(d)
+
+	logger.Info("Removed member from the cluster", log.Ctx{"member": removed.Name})
+	return EmptySyncResponse
+}
+
+// Evacuate a cluster member, moving its containers to the other members or
+// stopping them, or restore it so that it gets new containers again.
+func clusterMemberStatePost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	var member *db.ClusterMemberInfo
+	for i := range members {
+		if members[i].Name == name {
+			member = &members[i]
+		}
+	}
+
+	if member == nil {
+		return NotFound
+	}
+
+	// The member takes care of its own containers
+	self := clusterSelf(d, members)
+	if self == nil || self.Name != member.Name {
+		return clusterForwardedResponse(d, r, *member)
+	}
+
+	req := api.ClusterMemberStatePost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	switch req.Action {
+	case "restore":
+		err = clusterEvacuatedSet(d, name, false)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		return EmptySyncResponse
+	case "evacuate":
+	default:
+		return BadRequest(fmt.Errorf("Unknown action '%s'", req.Action))
+	}
+
+	if req.Mode == "" {
+		req.Mode = "migrate"
+	}
+
+	if !shared.StringInSlice(req.Mode, []string{"migrate", "live", "stop"}) {
+		return BadRequest(fmt.Errorf("Unknown evacuation mode '%s'", req.Mode))
+	}
+
+	// Stop scheduling new containers on this member right away
+	err = clusterEvacuatedSet(d, name, true)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	run := func(op *operation) error {
+		return clusterEvacuate(d, req.Mode)
+	}
+
+	op, err := operationCreate(operationClassTask, nil, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// The request sent by a new member to an existing one, to join the cluster.
+type internalClusterJoinPost struct {
+	ServerName   string   `json:"server_name"`
+	Address      string   `json:"address"`
+	Certificate  string   `json:"certificate"`
+	Token        string   `json:"token"`
+	Containers   []string `json:"containers"`
+	Networks     []string `json:"networks"`
+	StoragePools []string `json:"storage_pools"`
+}
+
+// The reply to a new member accepted into the cluster.
+type internalClusterJoinResponse struct {
+	Members  []internalClusterMember `json:"members"`
+	Profiles []api.Profile           `json:"profiles"`
+}
+
+// Accept a new member into the cluster, if it presents a valid join token.
+func internalClusterAccept(d *Daemon, r *http.Request) Response {
+	req := internalClusterJoinPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if clusterSelf(d, members) == nil {
+		return BadRequest(fmt.Errorf("This server isn't a member of a cluster"))
+	}
+
+	// The new member must authenticate with the certificate it's joining
+	// with, and have a token issued for its name.
+	fingerprint, err := shared.CertFingerprintStr(req.Certificate)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 || shared.CertFingerprint(r.TLS.PeerCertificates[0]) != fingerprint {
+		return Forbidden
+	}
+
+	if !clusterTokenValid(req.ServerName, req.Token) {
+		logger.Warn("Bad cluster join token", log.Ctx{"name": req.ServerName, "ip": r.RemoteAddr})
+		return Forbidden
+	}
+
+	for _, member := range members {
+		if member.Name == req.ServerName {
+			return BadRequest(fmt.Errorf("A member named '%s' already exists", req.ServerName))
+		}
+
+		if member.Address == req.Address {
+			return BadRequest(fmt.Errorf("A member with address '%s' already exists", req.Address))
+		}
+
+		memberFingerprint, err := shared.CertFingerprintStr(member.Certificate)
+		if err == nil && memberFingerprint == fingerprint {
+			return BadRequest(fmt.Errorf("Member '%s' already uses this certificate", member.Name))
+		}
+	}
+
+	// Container names must be unique across the cluster
+	for _, name := range req.Containers {
+		_, err := d.db.ContainerId(name)
+		if err == nil || clusterLocate(d, members, fmt.Sprintf("/%s/containers/%s", version.APIVersion, name)) != nil {
+			return BadRequest(fmt.Errorf("A container named '%s' already exists in the cluster", name))
+		}
+	}
+
+	// The profiles are shared, so the new member needs the networks and
+	// storage pools they may refer to
+	networks, err := d.db.Networks()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, name := range networks {
+		if !shared.StringInSlice(name, req.Networks) {
+			return BadRequest(fmt.Errorf("Network '%s' must be created on the new member first", name))
+		}
+	}
+
+	pools, err := d.db.StoragePools()
+	if err != nil && err != db.NoSuchObjectError {
+		return SmartError(err)
+	}
+
+	for _, name := range pools {
+		if !shared.StringInSlice(name, req.StoragePools) {
+			return BadRequest(fmt.Errorf("Storage pool '%s' must be created on the new member first", name))
+		}
+	}
+
+	profiles := []api.Profile{}
+	names, err := d.db.Profiles()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, name := range names {
+		_, profile, err := d.db.ProfileGet(name)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		profiles = append(profiles, *profile)
+	}
+
+	err = d.db.ClusterMemberAdd(db.ClusterMemberInfo{
+		Name:        req.ServerName,
+		Address:     req.Address,
+		Certificate: req.Certificate,
+		JoinedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+	readSavedClientCAList(d)
+
+	members, err = d.db.ClusterMembers()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	clusterNotify(d, members, members, req.ServerName)
+
+	logger.Info("Accepted new cluster member", log.Ctx{"member": req.ServerName, "address": req.Address})
+	return SyncResponse(true, internalClusterJoinResponse{Members: clusterMembersExport(members), Profiles: profiles})
+}
+
+// Replace the list of cluster members with the one sent by another member.
+func internalClusterMembersPut(d *Daemon, r *http.Request) Response {
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !clusterIsMemberRequest(r, members) {
+		return Forbidden
+	}
+
+	req := []internalClusterMember{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// If this node isn't in the new list, it's been removed
+	list := clusterMembersImport(req)
+	if clusterSelf(d, list) == nil {
+		list = nil
+	}
+
+	err = d.db.ClusterMembersReplace(list)
+	if err != nil {
+		return SmartError(err)
+	}
+	readSavedClientCAList(d)
+
+	return EmptySyncResponse
+}
@@ -7,10 +7,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"gopkg.in/lxc/go-lxc.v2"
 	"gopkg.in/yaml.v2"
 
 	"github.com/lxc/lxd/lxd/db"
@@ -24,22 +27,155 @@ import (
 
 var apiInternal = []Command{
 	internalReadyCmd,
+	internalHealthzCmd,
 	internalShutdownCmd,
 	internalContainerOnStartCmd,
 	internalContainerOnStopCmd,
 	internalContainersCmd,
+	internalClusterAcceptCmd,
+	internalClusterMembersCmd,
 }
 
 func internalReady(d *Daemon, r *http.Request) Response {
 	return InternalError(fmt.Errorf("The server does not support setup mode"))
 }
 
+// Wait for the daemon to be done starting up, at most the number of seconds
+// given with the "timeout" parameter (forever by default), and check that its
+// database can be read.
 func internalWaitReady(d *Daemon, r *http.Request) Response {
-	<-d.readyChan
+	timeout, err := shared.AtoiEmptyDefault(r.FormValue("timeout"), -1)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if timeout < -1 {
+		return BadRequest(fmt.Errorf("Invalid timeout: %d", timeout))
+	}
+
+	if timeout == -1 {
+		<-d.readyChan
+	} else {
+		select {
+		case <-d.readyChan:
+		case <-time.After(time.Duration(timeout) * time.Second):
+			return Unavailable(fmt.Errorf("LXD isn't ready yet"))
+		}
+	}
+
+	err = d.db.Ping()
+	if err != nil {
+		return Unavailable(fmt.Errorf("Database unavailable: %v", err))
+	}
 
 	return EmptySyncResponse
 }
 
+// Check the health of the daemon: whether its database can be read, its
+// storage pools are available and liblxc works. Untrusted clients, like load
+// balancers, only get the HTTP status code, the trusted ones also get the
+// result of each check. Only the checks for trusted clients go as far as
+// asking the storage drivers about their pools, the ones for untrusted clients
+// don't change anything.
+func internalHealthz(d *Daemon, r *http.Request) Response {
+	trusted := d.checkTrustedClient(r) == nil
+	checks := daemonHealthChecks(d, trusted)
+
+	failed := []string{}
+	result := map[string]string{}
+	for _, name := range []string{"database", "storage", "lxc"} {
+		err := checks[name]
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			result[name] = err.Error()
+		} else {
+			result[name] = "ok"
+		}
+	}
+
+	if len(failed) > 0 {
+		if !trusted {
+			return Unavailable(fmt.Errorf("LXD is unhealthy"))
+		}
+
+		return Unavailable(fmt.Errorf("LXD is unhealthy: %s", strings.Join(failed, ", ")))
+	}
+
+	if !trusted {
+		return EmptySyncResponse
+	}
+
+	return SyncResponse(true, result)
+}
+
+// Run the health checks of the daemon, returning the error of each of them, or
+// nil if it passed. Passive checks only look at the state of the daemon.
+func daemonHealthChecks(d *Daemon, active bool) map[string]error {
+	checks := map[string]error{}
+
+	checks["database"] = d.db.Ping()
+	if checks["database"] != nil {
+		// The storage pools are listed in the database
+		checks["storage"] = fmt.Errorf("Database unavailable")
+	} else {
+		checks["storage"] = daemonHealthStorage(d, active)
+	}
+
+	checks["lxc"] = daemonHealthLXC(d)
+
+	return checks
+}
+
+// Check that all the storage pools are available: their mount point exists and,
+// for active checks, their driver is happy with them (which may mount them).
+func daemonHealthStorage(d *Daemon, active bool) error {
+	pools, err := d.db.StoragePools()
+	if err != nil && err != db.NoSuchObjectError {
+		return err
+	}
+
+	for _, name := range pools {
+		if !shared.IsDir(getStoragePoolMountPoint(name)) {
+			return fmt.Errorf("Storage pool %s: missing mount point", name)
+		}
+
+		if !active {
+			continue
+		}
+
+		pool, err := storagePoolInit(d.State(), name)
+		if err != nil {
+			return fmt.Errorf("Storage pool %s: %v", name, err)
+		}
+
+		err = pool.StoragePoolCheck()
+		if err != nil {
+			return fmt.Errorf("Storage pool %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Check that liblxc can be used with the LXD containers path.
+func daemonHealthLXC(d *Daemon) error {
+	if d.os.MockMode {
+		return nil
+	}
+
+	if !shared.IsDir(d.os.LxcPath) {
+		return fmt.Errorf("Missing containers path %s", d.os.LxcPath)
+	}
+
+	c, err := lxc.NewContainer("lxd-healthz", d.os.LxcPath)
+	if err != nil {
+		return err
+	}
+	c.Release()
+
+	return nil
+}
+
 func internalShutdown(d *Daemon, r *http.Request) Response {
 	d.shutdownChan <- true
 
@@ -93,6 +229,7 @@ func internalContainerOnStop(d *Daemon, r *http.Request) Response {
 
 var internalShutdownCmd = Command{name: "shutdown", put: internalShutdown}
 var internalReadyCmd = Command{name: "ready", put: internalReady, get: internalWaitReady}
+var internalHealthzCmd = Command{name: "healthz", untrustedGet: true, get: internalHealthz}
 var internalContainerOnStartCmd = Command{name: "containers/{id}/onstart", get: internalContainerOnStart}
 var internalContainerOnStopCmd = Command{name: "containers/{id}/onstop", get: internalContainerOnStop}
 
@@ -163,8 +300,17 @@ func internalImport(d *Daemon, r *http.Request) Response {
 			`seem to exist on any storage pool`, req.Name))
 	}
 
-	// User needs to make sure that we can access the directory where
-	// backup.yaml lives.
+	// Mount the container's volume if it isn't, which is only possible
+	// if the storage pool is known. Otherwise the user needs to make sure
+	// that we can access the directory where backup.yaml lives.
+	ourMount, mountPool, err := internalImportMount(d, containerPoolName, req.Name)
+	if err != nil {
+		return InternalError(err)
+	}
+	if ourMount {
+		defer mountPool.ContainerUmount(req.Name, containerPath(req.Name, false))
+	}
+
 	containerMntPoint := containerMntPoints[0]
 	isEmpty, err := shared.PathIsEmpty(containerMntPoint)
 	if err != nil {
@@ -569,6 +715,8 @@ func internalImport(d *Daemon, r *http.Request) Response {
 		if err != nil {
 			return SmartError(err)
 		}
+
+		containerCache.Forget(req.Name)
 	}
 
 	for _, snap := range existingSnapshots {
@@ -608,6 +756,8 @@ func internalImport(d *Daemon, r *http.Request) Response {
 			if err != nil {
 				return SmartError(err)
 			}
+
+			containerCache.Forget(snap.Name)
 		}
 
 		if csVolErr == nil {
@@ -617,7 +767,33 @@ func internalImport(d *Daemon, r *http.Request) Response {
 				return SmartError(err)
 			}
 		}
+	}
 
+	baseImage := backup.Container.Config["volatile.base_image"]
+
+	arch, err := osarch.ArchitectureId(backup.Container.Architecture)
+	if err != nil {
+		return SmartError(err)
+	}
+	_, err = containerCreateInternal(d.State(), db.ContainerArgs{
+		Architecture: arch,
+		BaseImage:    baseImage,
+		Config:       backup.Container.Config,
+		CreationDate: backup.Container.CreatedAt,
+		LastUsedDate: backup.Container.LastUsedAt,
+		Ctype:        db.CTypeRegular,
+		Devices:      backup.Container.Devices,
+		Ephemeral:    backup.Container.Ephemeral,
+		Name:         backup.Container.Name,
+		Profiles:     backup.Container.Profiles,
+		Stateful:     backup.Container.Stateful,
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// The snapshots reference their container, so get created after it
+	for _, snap := range existingSnapshots {
 		baseImage := snap.Config["volatile.base_image"]
 
 		arch, err := osarch.ArchitectureId(snap.Architecture)
@@ -656,29 +832,6 @@ func internalImport(d *Daemon, r *http.Request) Response {
 		}
 	}
 
-	baseImage := backup.Container.Config["volatile.base_image"]
-
-	arch, err := osarch.ArchitectureId(backup.Container.Architecture)
-	if err != nil {
-		return SmartError(err)
-	}
-	_, err = containerCreateInternal(d.State(), db.ContainerArgs{
-		Architecture: arch,
-		BaseImage:    baseImage,
-		Config:       backup.Container.Config,
-		CreationDate: backup.Container.CreatedAt,
-		LastUsedDate: backup.Container.LastUsedAt,
-		Ctype:        db.CTypeRegular,
-		Devices:      backup.Container.Devices,
-		Ephemeral:    backup.Container.Ephemeral,
-		Name:         backup.Container.Name,
-		Profiles:     backup.Container.Profiles,
-		Stateful:     backup.Container.Stateful,
-	})
-	if err != nil {
-		return SmartError(err)
-	}
-
 	containerPath := containerPath(req.Name, false)
 	isPrivileged := false
 	if backup.Container.Config["security.privileged"] == "" {
@@ -693,4 +846,94 @@ func internalImport(d *Daemon, r *http.Request) Response {
 	return EmptySyncResponse
 }
 
-var internalContainersCmd = Command{name: "containers", post: internalImport}
+// Return the names of the containers which have a backup.yaml file on one of
+// the storage pools but no database entry.
+func internalImportScan(d *Daemon, r *http.Request) Response {
+	known, err := d.db.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	pools, err := ioutil.ReadDir(shared.VarPath("storage-pools"))
+	if err != nil {
+		return InternalError(err)
+	}
+
+	names := []string{}
+	for _, pool := range pools {
+		containers, err := ioutil.ReadDir(shared.VarPath("storage-pools", pool.Name(), "containers"))
+		if err != nil {
+			continue
+		}
+
+		for _, ct := range containers {
+			name := ct.Name()
+			if shared.StringInSlice(name, known) || shared.StringInSlice(name, names) {
+				continue
+			}
+
+			if !internalImportHasBackup(d, pool.Name(), name) {
+				continue
+			}
+
+			names = append(names, name)
+		}
+	}
+
+	return SyncResponse(true, names)
+}
+
+// Check whether the container has a backup.yaml file on the given storage
+// pool, mounting its volume for the time of the check if needed.
+func internalImportHasBackup(d *Daemon, poolName string, name string) bool {
+	ourMount, pool, err := internalImportMount(d, poolName, name)
+	if err != nil {
+		logger.Debug("Failed to mount container volume", log.Ctx{"pool": poolName, "container": name, "err": err})
+		return false
+	}
+	if ourMount {
+		defer pool.ContainerUmount(name, containerPath(name, false))
+	}
+
+	return shared.PathExists(filepath.Join(getContainerMountPoint(poolName, name), "backup.yaml"))
+}
+
+// Mount the volume of a container which has no database entry, when its mount
+// point is empty (like for the LVM, ZFS and Ceph storage pools, where it's
+// only mounted while in use) and the storage pool is known. Returns whether it
+// got mounted, and the storage pool to unmount it from.
+func internalImportMount(d *Daemon, poolName string, name string) (bool, storage, error) {
+	empty, err := shared.PathIsEmpty(getContainerMountPoint(poolName, name))
+	if err != nil || !empty {
+		return false, nil, err
+	}
+
+	_, err = d.db.StoragePoolGetID(poolName)
+	if err == db.NoSuchObjectError {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	pool, err := storagePoolInit(d.State(), poolName)
+	if err != nil {
+		return false, nil, err
+	}
+
+	_, err = pool.StoragePoolMount()
+	if err != nil {
+		return false, nil, err
+	}
+
+	// The storage drivers only need the name of the container to mount
+	// its volume
+	ourMount, err := pool.ContainerMount(containerLXCInstantiate(d.State(), db.ContainerArgs{Name: name}))
+	if err != nil {
+		return false, nil, err
+	}
+
+	return ourMount, pool, nil
+}
+
+var internalContainersCmd = Command{name: "containers", get: internalImportScan, post: internalImport}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"gopkg.in/macaroon-bakery.v2/httpbakery"
+
+	"github.com/lxc/lxd/lxd/ratelimit"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Rate of the API requests, by client and overall
+var apiRateLimiter = ratelimit.New()
+
+// Key of the overall rate in apiRateLimiter
+const apiRateLimitGlobal = "*"
+
+// apiClientID identifies the client making the request, for the limits: the
+// fingerprint of its TLS certificate if it has one, its IP address otherwise,
+// or "unix" for the clients of the unix socket.
+func apiClientID(r *http.Request) string {
+	if r.RemoteAddr == "@" {
+		return "unix"
+	}
+
+	if r.Header.Get(httpbakery.BakeryProtocolHeader) == "" && r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return shared.CertFingerprint(r.TLS.PeerCertificates[0])
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// apiLimitsCheck checks the request against the configured limits, returning
+// the error response to send if it goes over one of them, or nil. The limits
+// are on the rate of requests (core.rate_limit and core.rate_limit_per_client)
+// and, for the requests which may start an operation, the number of operations
+// running at the same time (core.max_operations and
+// core.max_operations_per_client). Following and cancelling the operations is
+// always allowed.
+//
+// The returned function must be called once the request was handled, to
+// release the operation slot it may have reserved.
+func apiLimitsCheck(d *Daemon, r *http.Request, c Command) (Response, func()) {
+	release := func() {}
+
+	rateLimit := daemonConfig["core.rate_limit"].GetInt64()
+	clientRateLimit := daemonConfig["core.rate_limit_per_client"].GetInt64()
+	maxOperations := daemonConfig["core.max_operations"].GetInt64()
+	clientMaxOperations := daemonConfig["core.max_operations_per_client"].GetInt64()
+
+	if rateLimit == 0 && clientRateLimit == 0 && maxOperations == 0 && clientMaxOperations == 0 {
+		return nil, release
+	}
+
+	if strings.HasPrefix(c.name, "operations") {
+		return nil, release
+	}
+
+	// The requests of other cluster members were already checked by the
+	// member they were sent to
+	if r.TLS != nil {
+		members, err := d.db.ClusterMembers()
+		if err == nil && clusterIsMemberRequest(r, members) {
+			return nil, release
+		}
+	}
+
+	client := apiClientID(r)
+
+	if !apiRateLimiter.Allow(client, clientRateLimit) {
+		logger.Warn("Rejecting request over the client rate limit", log.Ctx{"client": client, "url": r.URL.RequestURI()})
+		return TooManyRequests(fmt.Errorf("Too many requests from this client, at most %d per second are allowed", clientRateLimit)), release
+	}
+
+	if !apiRateLimiter.Allow(apiRateLimitGlobal, rateLimit) {
+		logger.Warn("Rejecting request over the rate limit", log.Ctx{"client": client, "url": r.URL.RequestURI()})
+		return TooManyRequests(fmt.Errorf("Too many requests, at most %d per second are allowed", rateLimit)), release
+	}
+
+	if r.Method == "GET" || (maxOperations == 0 && clientMaxOperations == 0) {
+		return nil, release
+	}
+
+	return operationsReserve(client, maxOperations, clientMaxOperations)
+}
+
+// operationsReserve reserves an operation slot for the client, if the
+// operations which are pending or running (other than tokens) and the slots
+// already reserved stay under the given limits overall and for the client.
+// Everything is counted and the slot reserved under operationsLock, so that
+// concurrent requests can't all get through the limits. The returned function
+// releases the slot, the operation started by the request (if any) counting
+// instead once it's attributed to the client.
+func operationsReserve(client string, maxOperations int64, clientMaxOperations int64) (Response, func()) {
+	operationsLock.Lock()
+	defer operationsLock.Unlock()
+
+	total := 0
+	mine := operationsReserved[client]
+	for _, reserved := range operationsReserved {
+		total += reserved
+	}
+
+	for _, op := range operations {
+		if op.class == operationClassToken {
+			continue
+		}
+
+		op.lock.Lock()
+		active := op.status == api.Pending || op.status == api.Running
+		requestor := op.requestor
+		op.lock.Unlock()
+
+		if !active {
+			continue
+		}
+
+		total++
+		if requestor == client {
+			mine++
+		}
+	}
+
+	if clientMaxOperations > 0 && int64(mine) >= clientMaxOperations {
+		return TooManyRequests(fmt.Errorf("Too many running operations for this client, at most %d are allowed", clientMaxOperations)), func() {}
+	}
+
+	if maxOperations > 0 && int64(total) >= maxOperations {
+		return TooManyRequests(fmt.Errorf("Too many running operations, at most %d are allowed", maxOperations)), func() {}
+	}
+
+	operationsReserved[client]++
+
+	released := false
+	return nil, func() {
+		operationsLock.Lock()
+		defer operationsLock.Unlock()
+
+		if released {
+			return
+		}
+		released = true
+
+		operationsReserved[client]--
+		if operationsReserved[client] == 0 {
+			delete(operationsReserved, client)
+		}
+	}
+}
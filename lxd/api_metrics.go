@@ -0,0 +1,202 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/metrics"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Number of API requests handled since the daemon started, by method and HTTP
+// status code
+var metricsRequestsLock sync.Mutex
+var metricsRequests = map[metricsRequestKey]int64{}
+
+type metricsRequestKey struct {
+	method string
+	code   int
+}
+
+// metricsRequestRecord counts an API request with the given method, answered
+// with the given response.
+func metricsRequestRecord(method string, resp Response) {
+	code := responseStatusCode(resp)
+
+	metricsRequestsLock.Lock()
+	metricsRequests[metricsRequestKey{method: method, code: code}]++
+	metricsRequestsLock.Unlock()
+}
+
+// MetricsServer creates an http.Server serving the metrics at /1.0/metrics,
+// for the clients trusted with either a client or a metrics certificate, so
+// that scrapers don't need access to the whole API.
+func MetricsServer(d *Daemon) *http.Server {
+	mux := mux.NewRouter()
+	mux.StrictSlash(false)
+
+	mux.HandleFunc("/1.0/metrics", func(w http.ResponseWriter, r *http.Request) {
+		err := d.checkTrustedMetricsClient(r)
+		if err != nil {
+			logger.Warn("rejecting metrics request from untrusted client", log.Ctx{"ip": r.RemoteAddr})
+			Forbidden.Render(w)
+			return
+		}
+
+		if r.Method != "GET" {
+			NotImplemented.Render(w)
+			return
+		}
+
+		metricsGet(d, r).Render(w)
+	})
+
+	mux.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		NotFound.Render(w)
+	})
+
+	return &http.Server{Handler: mux}
+}
+
+// Renders metrics in the OpenMetrics text format
+type metricsResponse struct {
+	set *metrics.Set
+}
+
+func (r *metricsResponse) Render(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write([]byte(r.set.String()))
+	return err
+}
+
+func (r *metricsResponse) String() string {
+	return "metrics"
+}
+
+func metricsGet(d *Daemon, r *http.Request) Response {
+	set := metrics.NewSet()
+
+	err := metricsContainers(d, set)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	metricsDaemon(set)
+
+	return &metricsResponse{set: set}
+}
+
+var metricsCmd = Command{name: "metrics", get: metricsGet}
+
+// Add the metrics of the containers, the detailed ones only for the running
+// containers.
+func metricsContainers(d *Daemon, set *metrics.Set) error {
+	names, err := d.db.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return err
+	}
+
+	statuses := map[string]int{}
+	for _, name := range names {
+		c, err := containerLoadByName(d.State(), name)
+		if err != nil {
+			logger.Warn("Failed to load container for metrics", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		state, err := c.RenderState()
+		if err != nil {
+			logger.Warn("Failed to get container state for metrics", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		statuses[state.Status]++
+		if !c.IsRunning() {
+			continue
+		}
+
+		labels := metrics.Labels{"name": name}
+
+		// Unavailable values are reported as -1 by the container state
+		add := func(name string, kind metrics.Type, help string, value int64, labels metrics.Labels) {
+			if value < 0 {
+				return
+			}
+
+			set.Add(name, kind, help, float64(value), labels)
+		}
+
+		if state.CPU.Usage >= 0 {
+			set.Add("lxd_container_cpu_seconds", metrics.Counter, "CPU time used by the container, in seconds", float64(state.CPU.Usage)/1e9, labels)
+		}
+
+		add("lxd_container_memory_usage_bytes", metrics.Gauge, "Memory used by the container", state.Memory.Usage, labels)
+		add("lxd_container_memory_usage_peak_bytes", metrics.Gauge, "Peak memory used by the container", state.Memory.UsagePeak, labels)
+		add("lxd_container_swap_usage_bytes", metrics.Gauge, "Swap used by the container", state.Memory.SwapUsage, labels)
+		add("lxd_container_oom_kills", metrics.Counter, "Processes of the container killed by the OOM killer", state.Memory.OOMKills, labels)
+		add("lxd_container_processes", metrics.Gauge, "Processes running in the container", state.Processes, labels)
+
+		for device, disk := range state.Disk {
+			add("lxd_container_disk_usage_bytes", metrics.Gauge, "Disk space used by the container", disk.Usage, metrics.Labels{"name": name, "device": device})
+		}
+
+		for device, network := range state.Network {
+			labels := metrics.Labels{"name": name, "device": device}
+			add("lxd_container_network_receive_bytes", metrics.Counter, "Bytes received on the network interface", network.Counters.BytesReceived, labels)
+			add("lxd_container_network_transmit_bytes", metrics.Counter, "Bytes sent on the network interface", network.Counters.BytesSent, labels)
+			add("lxd_container_network_receive_packets", metrics.Counter, "Packets received on the network interface", network.Counters.PacketsReceived, labels)
+			add("lxd_container_network_transmit_packets", metrics.Counter, "Packets sent on the network interface", network.Counters.PacketsSent, labels)
+		}
+	}
+
+	for status, count := range statuses {
+		set.Add("lxd_containers", metrics.Gauge, "Number of containers", float64(count), metrics.Labels{"status": status})
+	}
+
+	return nil
+}
+
+// Add the metrics of the daemon itself.
+func metricsDaemon(set *metrics.Set) {
+	metricsRequestsLock.Lock()
+	for key, count := range metricsRequests {
+		labels := metrics.Labels{"method": key.method, "code": strconv.Itoa(key.code)}
+		set.Add("lxd_api_requests", metrics.Counter, "API requests handled, by method and HTTP status code", float64(count), labels)
+	}
+	metricsRequestsLock.Unlock()
+
+	operationsLock.Lock()
+	ops := []*operation{}
+	for _, op := range operations {
+		ops = append(ops, op)
+	}
+	operationsLock.Unlock()
+
+	counts := map[[2]string]int{}
+	for _, op := range ops {
+		op.lock.Lock()
+		counts[[2]string{op.class.String(), op.status.String()}]++
+		op.lock.Unlock()
+	}
+
+	for key, count := range counts {
+		set.Add("lxd_operations", metrics.Gauge, "Operations known to the daemon, by class and status", float64(count), metrics.Labels{"class": key[0], "status": key[1]})
+	}
+
+	memStats := runtime.MemStats{}
+	runtime.ReadMemStats(&memStats)
+
+	set.Add("lxd_goroutines", metrics.Gauge, "Number of goroutines of the daemon", float64(runtime.NumGoroutine()), nil)
+	set.Add("lxd_memory_alloc_bytes", metrics.Gauge, "Heap memory allocated by the daemon", float64(memStats.Alloc), nil)
+	set.Add("lxd_info", metrics.Gauge, "Version of the daemon", 1, metrics.Labels{"version": version.Version})
+}
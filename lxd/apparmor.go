@@ -418,11 +418,18 @@ func mkApparmorNamespace(c container, namespace string) error {
 	return nil
 }
 
+// AAEnabled returns whether the container should be confined by its own
+// AppArmor profile (security.apparmor, enabled by default).
+func AAEnabled(c container) bool {
+	value := c.ExpandedConfig()["security.apparmor"]
+	return value == "" || shared.IsTrue(value)
+}
+
 // Ensure that the container's policy is loaded into the kernel so the
 // container can boot.
 func AALoadProfile(c container) error {
 	state := c.DaemonState()
-	if !state.OS.AppArmorAdmin {
+	if !state.OS.AppArmorAdmin || !AAEnabled(c) {
 		return nil
 	}
 
@@ -467,13 +474,19 @@ func AALoadProfile(c container) error {
 }
 
 // Ensure that the container's policy namespace is unloaded to free kernel
-// memory. This does not delete the policy from disk or cache.
+// memory. This does not delete the policy from disk or cache, unless
+// security.apparmor got turned off since it was loaded.
 func AADestroy(c container) error {
 	state := c.DaemonState()
 	if !state.OS.AppArmorAdmin {
 		return nil
 	}
 
+	enabled := AAEnabled(c)
+	if !enabled && !shared.PathExists(path.Join(aaPath, "profiles", AAProfileShort(c))) {
+		return nil
+	}
+
 	if state.OS.AppArmorStacking && !state.OS.AppArmorStacked {
 		p := path.Join("/sys/kernel/security/apparmor/policy/namespaces", AANamespace(c))
 		if err := os.Remove(p); err != nil {
@@ -481,13 +494,18 @@ func AADestroy(c container) error {
 		}
 	}
 
-	return runApparmor(APPARMOR_CMD_UNLOAD, c)
+	err := runApparmor(APPARMOR_CMD_UNLOAD, c)
+	if !enabled {
+		AADeleteProfile(c)
+	}
+
+	return err
 }
 
 // Parse the profile without loading it into the kernel.
 func AAParseProfile(c container) error {
 	state := c.DaemonState()
-	if !state.OS.AppArmorAvailable {
+	if !state.OS.AppArmorAvailable || !AAEnabled(c) {
 		return nil
 	}
 
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Entry records a state-changing API call, or the outcome of an action started
+// by an earlier call, like the exit status of a command run in a container.
+type Entry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Method    string    `json:"method,omitempty"`
+	URL       string    `json:"url"`
+
+	// Identity of the client
+	Protocol string `json:"protocol"`
+	Username string `json:"username,omitempty"`
+	Address  string `json:"address"`
+
+	// Summary of the request body, see Summarize
+	Body string `json:"body,omitempty"`
+
+	// HTTP status code of the response and, for background operations,
+	// the URL of the operation
+	Status   int    `json:"status,omitempty"`
+	Location string `json:"location,omitempty"`
+
+	// For the outcome of actions, the action and its details, as in their
+	// lifecycle event
+	Action  string                 `json:"action,omitempty"`
+	Context map[string]interface{} `json:"context,omitempty"`
+}
+
+// Sink is where audit entries get written.
+type Sink interface {
+	Write(entry Entry) error
+	Close() error
+}
+
+// The longest request body summary, in bytes.
+const summaryMaxLength = 512
+
+// Summarize returns a summary of the given JSON request body suitable for the
+// audit log: the values of keys looking like secrets are redacted and the
+// result is truncated.
+func Summarize(body []byte) string {
+	var data interface{}
+	err := json.Unmarshal(body, &data)
+	if err != nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	err = encoder.Encode(redact(data))
+	if err != nil {
+		return ""
+	}
+
+	summary := strings.TrimSuffix(buf.String(), "\n")
+	if len(summary) > summaryMaxLength {
+		return summary[:summaryMaxLength] + "..."
+	}
+
+	return summary
+}
+
+// Replace the values of all the keys mentioning a password or a secret.
+func redact(data interface{}) interface{} {
+	switch value := data.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			key := strings.ToLower(k)
+			if strings.Contains(key, "password") || strings.Contains(key, "secret") {
+				value[k] = "<redacted>"
+				continue
+			}
+
+			value[k] = redact(v)
+		}
+	case []interface{}:
+		for i, v := range value {
+			value[i] = redact(v)
+		}
+	}
+
+	return data
+}
@@ -0,0 +1,56 @@
+package audit_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lxc/lxd/lxd/audit"
+	"github.com/lxc/lxd/shared"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	body := `{"config": {"core.trust_password": "foo", "core.https_address": ":8443"}, "password": "bar"}`
+	summary := audit.Summarize([]byte(body))
+
+	assert.NotContains(t, summary, "foo")
+	assert.NotContains(t, summary, "bar")
+	assert.Contains(t, summary, `"core.https_address":":8443"`)
+	assert.Contains(t, summary, `"password":"<redacted>"`)
+
+	// Long bodies get truncated
+	summary = audit.Summarize([]byte(`{"description": "` + strings.Repeat("x", 1000) + `"}`))
+	assert.Len(t, summary, 515)
+
+	// Non JSON bodies aren't summarized
+	assert.Equal(t, "", audit.Summarize([]byte("foo")))
+}
+
+func TestFileSink_Rotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-audit-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	sink, err := audit.NewFileSink(path, 200, 2)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	for i := 0; i < 10; i++ {
+		err := sink.Write(audit.Entry{Method: "POST", URL: "/1.0/containers", Status: 202})
+		require.NoError(t, err)
+	}
+
+	assert.True(t, shared.PathExists(path))
+	assert.True(t, shared.PathExists(path+".1"))
+	assert.True(t, shared.PathExists(path+".2"))
+	assert.False(t, shared.PathExists(path+".3"))
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"url":"/1.0/containers"`)
+}
@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"os"
+	"sync"
+)
+
+// NewFileSink returns a sink writing the entries as JSON lines to the file at
+// the given path. Once the file grows past maxSize bytes, it gets rotated,
+// keeping at most the given number of old files (path.1 being the newest).
+func NewFileSink(path string, maxSize int64, keep int) (Sink, error) {
+	s := &fileSink{path: path, maxSize: maxSize, keep: keep}
+
+	err := s.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+type fileSink struct {
+	path    string
+	maxSize int64
+	keep    int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func (s *fileSink) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		err := s.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+func (s *fileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// Shift the old files, dropping the oldest one, and start a new file.
+func (s *fileSink) rotate() error {
+	err := s.file.Close()
+	if err != nil {
+		return err
+	}
+
+	for i := s.keep - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if s.keep > 0 {
+		err = os.Rename(s.path, s.path+".1")
+	} else {
+		err = os.Remove(s.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+// NewSyslogSink returns a sink sending the entries as JSON to the local syslog
+// daemon, using the authpriv facility.
+func NewSyslogSink(tag string) (Sink, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTHPRIV, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogSink{writer: writer}, nil
+}
+
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func (s *syslogSink) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return s.writer.Info(string(line))
+}
+
+func (s *syslogSink) Close() error {
+	return s.writer.Close()
+}
@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"gopkg.in/macaroon-bakery.v2/httpbakery"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/util"
@@ -17,8 +19,34 @@ import (
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Types of trusted certificates, as stored in the database. Metrics
+// certificates only give access to the metrics endpoint.
+const (
+	certificateTypeClient  = 1
+	certificateTypeMetrics = 2
 )
 
+var certificateTypeNames = map[int]string{
+	certificateTypeClient:  "client",
+	certificateTypeMetrics: "metrics",
+}
+
+// Return the database code of the certificate type with the given name, or an
+// error if it's unknown.
+func certificateTypeParse(name string) (int, error) {
+	for code, typeName := range certificateTypeNames {
+		if typeName == name {
+			return code, nil
+		}
+	}
+
+	return -1, fmt.Errorf("Unknown certificate type %s", name)
+}
+
 func certificatesGet(d *Daemon, r *http.Request) Response {
 	recursion := util.IsRecursionRequest(r)
 
@@ -30,24 +58,17 @@ func certificatesGet(d *Daemon, r *http.Request) Response {
 			return SmartError(err)
 		}
 		for _, baseCert := range baseCerts {
-			resp := api.Certificate{}
-			resp.Fingerprint = baseCert.Fingerprint
-			resp.Certificate = baseCert.Certificate
-			resp.Name = baseCert.Name
-			if baseCert.Type == 1 {
-				resp.Type = "client"
-			} else {
-				resp.Type = "unknown"
-			}
-			certResponses = append(certResponses, resp)
+			certResponses = append(certResponses, certificateRender(baseCert))
 		}
 		return SyncResponse(true, certResponses)
 	}
 
 	body := []string{}
-	for _, cert := range d.clientCerts {
-		fingerprint := fmt.Sprintf("/%s/certificates/%s", version.APIVersion, shared.CertFingerprint(&cert))
-		body = append(body, fingerprint)
+	for _, certs := range [][]x509.Certificate{d.clientCerts, d.metricsCerts} {
+		for _, cert := range certs {
+			fingerprint := fmt.Sprintf("/%s/certificates/%s", version.APIVersion, shared.CertFingerprint(&cert))
+			body = append(body, fingerprint)
+		}
 	}
 
 	return SyncResponse(true, body)
@@ -55,6 +76,13 @@ func certificatesGet(d *Daemon, r *http.Request) Response {
 
 func readSavedClientCAList(d *Daemon) {
 	d.clientCerts = []x509.Certificate{}
+	d.metricsCerts = []x509.Certificate{}
+	restrictions := map[string]*db.CertInfo{}
+	defer func() {
+		d.clientCertRestrictionsLock.Lock()
+		d.clientCertRestrictions = restrictions
+		d.clientCertRestrictionsLock.Unlock()
+	}()
 
 	dbCerts, err := d.db.CertificatesGet()
 	if err != nil {
@@ -74,15 +102,146 @@ func readSavedClientCAList(d *Daemon) {
 			logger.Infof("Error reading certificate for %s: %s", dbCert.Name, err)
 			continue
 		}
+
+		if dbCert.Type == certificateTypeMetrics {
+			d.metricsCerts = append(d.metricsCerts, *cert)
+			continue
+		}
+		d.clientCerts = append(d.clientCerts, *cert)
+
+		if dbCert.ReadOnly || len(dbCert.Containers) > 0 {
+			restrictions[dbCert.Fingerprint] = dbCert
+		}
+	}
+
+	// The members of the cluster trust each other
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		logger.Infof("Error reading cluster members from database: %s", err)
+		return
+	}
+
+	for _, member := range members {
+		certBlock, _ := pem.Decode([]byte(member.Certificate))
+		if certBlock == nil {
+			logger.Infof("Error decoding certificate of cluster member %s", member.Name)
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(certBlock.Bytes)
+		if err != nil {
+			logger.Infof("Error reading certificate of cluster member %s: %s", member.Name, err)
+			continue
+		}
 		d.clientCerts = append(d.clientCerts, *cert)
 	}
 }
 
-func saveCert(dbObj *db.Node, host string, cert *x509.Certificate) error {
+// Return the restrictions of the trusted client certificate the request was
+// made with, or nil if it has none.
+func certificateRestrictions(d *Daemon, r *http.Request) *db.CertInfo {
+	if r.TLS == nil {
+		return nil
+	}
+
+	if d.externalAuth != nil && r.Header.Get(httpbakery.BakeryProtocolHeader) != "" {
+		return nil
+	}
+
+	d.clientCertRestrictionsLock.RLock()
+	defer d.clientCertRestrictionsLock.RUnlock()
+
+	for _, cert := range r.TLS.PeerCertificates {
+		restrictions := d.clientCertRestrictions[shared.CertFingerprint(cert)]
+		if restrictions != nil {
+			return restrictions
+		}
+	}
+
+	return nil
+}
+
+// Check whether the request is allowed by the restrictions of the trusted
+// client certificate it was made with. Read-only certificates can only issue
+// GET requests. Certificates restricted to some containers can only access
+// those containers (including their snapshots, files and so on), the
+// operations on those containers and the events filtered on one of them.
+func certificateRestrictionsCheck(d *Daemon, r *http.Request, c Command) bool {
+	restrictions := certificateRestrictions(d, r)
+	if restrictions == nil {
+		return true
+	}
+
+	if restrictions.ReadOnly && r.Method != "GET" {
+		return false
+	}
+
+	if len(restrictions.Containers) == 0 {
+		return true
+	}
+
+	switch {
+	case c.name == "":
+		return r.Method == "GET"
+	case c.name == "events":
+		return shared.StringInSlice(r.FormValue("container"), restrictions.Containers)
+	case c.name == "operations":
+		// The list is filtered by operationsAPIGet
+		return true
+	case strings.HasPrefix(c.name, "operations/{id}"):
+		return certificateAllowsOperation(d, restrictions, mux.Vars(r)["id"])
+	case strings.HasPrefix(c.name, "containers/{name}"):
+		return shared.StringInSlice(mux.Vars(r)["name"], restrictions.Containers)
+	}
+
+	return false
+}
+
+// Check whether the restrictions allow access to the operation with the given
+// ID, that is whether all the containers it's about are allowed.
+func certificateAllowsOperation(d *Daemon, restrictions *db.CertInfo, id string) bool {
+	op, err := operationGet(id)
+	if err == nil {
+		return certificateAllowsResources(restrictions, op.resources)
+	}
+
+	info, err := d.db.OperationGet(id)
+	if err != nil {
+		return false
+	}
+
+	return certificateAllowsResources(restrictions, info.Resources)
+}
+
+// Check whether the restrictions allow access to an operation with the given
+// resources.
+func certificateAllowsResources(restrictions *db.CertInfo, resources map[string][]string) bool {
+	if restrictions == nil || len(restrictions.Containers) == 0 {
+		return true
+	}
+
+	names := resources["containers"]
+	if len(names) == 0 {
+		return false
+	}
+
+	for _, name := range names {
+		name, _, _ = containerGetParentAndSnapshotName(name)
+		if !shared.StringInSlice(name, restrictions.Containers) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func saveCert(dbObj *db.Node, host string, cert *x509.Certificate, certType int, readOnly bool, containers []string) error {
 	baseCert := new(db.CertInfo)
 	baseCert.Fingerprint = shared.CertFingerprint(cert)
-	baseCert.Type = 1
+	baseCert.Type = certType
 	baseCert.Name = host
+	baseCert.ReadOnly = readOnly
+	baseCert.Containers = containers
 	baseCert.Certificate = string(
 		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
 	)
@@ -100,22 +259,34 @@ func certificatesPost(d *Daemon, r *http.Request) Response {
 	// Access check
 	secret := daemonConfig["core.trust_password"].Get()
 	if d.checkTrustedClient(r) != nil && util.PasswordCheck(secret, req.Password) != nil {
+		logger.Warn("Rejected trust request with a bad password", log.Ctx{"ip": r.RemoteAddr})
 		return Forbidden
 	}
 
-	if req.Type != "client" {
-		return BadRequest(fmt.Errorf("Unknown request type %s", req.Type))
+	certType, err := certificateTypeParse(req.Type)
+	if err != nil {
+		return BadRequest(err)
 	}
 
 	// Extract the certificate
 	var cert *x509.Certificate
 	var name string
 	if req.Certificate != "" {
-		data, err := base64.StdEncoding.DecodeString(req.Certificate)
-		if err != nil {
-			return BadRequest(err)
+		var data []byte
+
+		// The certificate is either PEM encoded or base64 encoded DER
+		block, _ := pem.Decode([]byte(req.Certificate))
+		if block != nil {
+			data = block.Bytes
+		} else {
+			var err error
+			data, err = base64.StdEncoding.DecodeString(req.Certificate)
+			if err != nil {
+				return BadRequest(err)
+			}
 		}
 
+		var err error
 		cert, err = x509.ParseCertificate(data)
 		if err != nil {
 			return BadRequest(err)
@@ -133,23 +304,28 @@ func certificatesPost(d *Daemon, r *http.Request) Response {
 		}
 
 		name = remoteHost
+		if req.Name != "" {
+			name = req.Name
+		}
 	} else {
 		return BadRequest(fmt.Errorf("Can't use TLS data on non-TLS link"))
 	}
 
 	fingerprint := shared.CertFingerprint(cert)
-	for _, existingCert := range d.clientCerts {
-		if fingerprint == shared.CertFingerprint(&existingCert) {
-			return BadRequest(fmt.Errorf("Certificate already in trust store"))
+	for _, certs := range [][]x509.Certificate{d.clientCerts, d.metricsCerts} {
+		for _, existingCert := range certs {
+			if fingerprint == shared.CertFingerprint(&existingCert) {
+				return BadRequest(fmt.Errorf("Certificate already in trust store"))
+			}
 		}
 	}
 
-	err := saveCert(d.db, name, cert)
+	err = saveCert(d.db, name, cert, certType, req.ReadOnly, req.Containers)
 	if err != nil {
 		return SmartError(err)
 	}
 
-	d.clientCerts = append(d.clientCerts, *cert)
+	readSavedClientCAList(d)
 
 	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/certificates/%s", version.APIVersion, fingerprint))
 }
@@ -168,23 +344,27 @@ func certificateFingerprintGet(d *Daemon, r *http.Request) Response {
 }
 
 func doCertificateGet(db *db.Node, fingerprint string) (api.Certificate, error) {
-	resp := api.Certificate{}
-
 	dbCertInfo, err := db.CertificateGet(fingerprint)
 	if err != nil {
-		return resp, err
+		return api.Certificate{}, err
 	}
 
-	resp.Fingerprint = dbCertInfo.Fingerprint
-	resp.Certificate = dbCertInfo.Certificate
-	resp.Name = dbCertInfo.Name
-	if dbCertInfo.Type == 1 {
-		resp.Type = "client"
-	} else {
+	return certificateRender(dbCertInfo), nil
+}
+
+func certificateRender(info *db.CertInfo) api.Certificate {
+	resp := api.Certificate{}
+	resp.Fingerprint = info.Fingerprint
+	resp.Certificate = info.Certificate
+	resp.Name = info.Name
+	resp.ReadOnly = info.ReadOnly
+	resp.Containers = info.Containers
+	resp.Type = certificateTypeNames[info.Type]
+	if resp.Type == "" {
 		resp.Type = "unknown"
 	}
 
-	return resp, nil
+	return resp
 }
 
 func certificateFingerprintPut(d *Daemon, r *http.Request) Response {
@@ -241,18 +421,43 @@ func certificateFingerprintPatch(d *Daemon, r *http.Request) Response {
 		req.Type = value
 	}
 
+	// Get restrictions
+	readOnly, err := reqRaw.GetBool("read_only")
+	if err == nil {
+		req.ReadOnly = readOnly
+	}
+
+	_, ok := reqRaw["containers"]
+	if ok {
+		containers, ok := reqRaw["containers"].([]interface{})
+		if !ok {
+			return BadRequest(fmt.Errorf("Invalid containers list"))
+		}
+
+		req.Containers = []string{}
+		for _, container := range containers {
+			name, ok := container.(string)
+			if !ok {
+				return BadRequest(fmt.Errorf("Invalid containers list"))
+			}
+			req.Containers = append(req.Containers, name)
+		}
+	}
+
 	return doCertificateUpdate(d, fingerprint, req.Writable())
 }
 
 func doCertificateUpdate(d *Daemon, fingerprint string, req api.CertificatePut) Response {
-	if req.Type != "client" {
-		return BadRequest(fmt.Errorf("Unknown request type %s", req.Type))
+	certType, err := certificateTypeParse(req.Type)
+	if err != nil {
+		return BadRequest(err)
 	}
 
-	err := d.db.CertUpdate(fingerprint, req.Name, 1)
+	err = d.db.CertUpdate(fingerprint, req.Name, certType, req.ReadOnly, req.Containers)
 	if err != nil {
 		return SmartError(err)
 	}
+	readSavedClientCAList(d)
 
 	return EmptySyncResponse
 }
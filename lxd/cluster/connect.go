@@ -0,0 +1,45 @@
+package cluster
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// Connect returns a client connected to the cluster member at the given
+// address, authenticating with the server certificate of this node.
+//
+// The member's own server certificate, PEM encoded, is used to verify its
+// identity.
+func Connect(address string, cert *shared.CertInfo, memberCert string) (lxd.ContainerServer, error) {
+	args := &lxd.ConnectionArgs{
+		TLSServerCert: memberCert,
+		TLSClientCert: string(cert.PublicKey()),
+		TLSClientKey:  string(cert.PrivateKey()),
+		UserAgent:     version.UserAgent,
+	}
+
+	return lxd.ConnectLXD(fmt.Sprintf("https://%s", address), args)
+}
+
+// Transport returns an HTTP transport for sending raw requests to the cluster
+// member with the given certificate, for example when forwarding the requests
+// of a client.
+func Transport(cert *shared.CertInfo, memberCert string) (*http.Transport, error) {
+	config, err := shared.GetTLSConfigMem(string(cert.PublicKey()), string(cert.PrivateKey()), "", memberCert, false)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig:     config,
+		Dial:                shared.RFC3493Dialer,
+		TLSHandshakeTimeout: 5 * time.Second,
+	}
+
+	return transport, nil
+}
@@ -0,0 +1,103 @@
+package cluster_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The member certificate is used to verify the server and this node's own
+// server certificate is presented as client certificate.
+func TestConnect(t *testing.T) {
+	memberCert, cert, cleanup := newCerts(t)
+	defer cleanup()
+
+	fingerprints := []string{}
+	server := newServer(memberCert, func(r *http.Request) {
+		for _, peer := range r.TLS.PeerCertificates {
+			fingerprints = append(fingerprints, shared.CertFingerprint(peer))
+		}
+	})
+	defer server.Close()
+
+	client, err := cluster.Connect(server.Listener.Addr().String(), cert, string(memberCert.PublicKey()))
+	require.NoError(t, err)
+
+	_, _, err = client.GetServer()
+	require.NoError(t, err)
+
+	fingerprint, err := shared.CertFingerprintStr(string(cert.PublicKey()))
+	require.NoError(t, err)
+	assert.Contains(t, fingerprints, fingerprint)
+
+	// Members with another certificate are refused
+	_, err = cluster.Connect(server.Listener.Addr().String(), cert, string(cert.PublicKey()))
+	assert.Error(t, err)
+}
+
+func TestTransport(t *testing.T) {
+	memberCert, cert, cleanup := newCerts(t)
+	defer cleanup()
+
+	server := newServer(memberCert, func(r *http.Request) {})
+	defer server.Close()
+
+	transport, err := cluster.Transport(cert, string(memberCert.PublicKey()))
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: transport}
+	response, err := client.Get(fmt.Sprintf("https://%s/1.0", server.Listener.Addr()))
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+// Returns two freshly generated server certificates, one for the cluster member
+// to connect to and one for this node.
+func newCerts(t *testing.T) (*shared.CertInfo, *shared.CertInfo, func()) {
+	dir, err := ioutil.TempDir("", "lxd-cluster-test-")
+	require.NoError(t, err)
+
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+
+	memberCert, err := shared.KeyPairAndCA(dir, "member", shared.CertServer)
+	require.NoError(t, err)
+
+	cert, err := shared.KeyPairAndCA(dir, "server", shared.CertServer)
+	require.NoError(t, err)
+
+	return memberCert, cert, cleanup
+}
+
+// Returns a minimal stub for the LXD RESTful API server, using the given
+// certificate and passing the requests to the given hook.
+func newServer(cert *shared.CertInfo, hook func(r *http.Request)) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/1.0", func(w http.ResponseWriter, r *http.Request) {
+		hook(r)
+		w.Header().Set("Content-Type", "application/json")
+		util.WriteJSON(w, api.ResponseRaw{Response: api.Response{Type: api.SyncResponse, StatusCode: http.StatusOK}, Metadata: api.Server{}}, false)
+	})
+
+	server := httptest.NewUnstartedServer(mux)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert.KeyPair()},
+		ClientAuth:   tls.RequireAnyClientCert,
+	}
+	server.StartTLS()
+
+	return server
+}
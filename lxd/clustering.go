@@ -0,0 +1,836 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Timeout of the queries to the other cluster members while handling a
+// request, short enough for an unreachable member not to stall it.
+const clusterQueryTimeout = 3 * time.Second
+
+// Timeout of the changes to the shared configuration applied on the other
+// cluster members, which may take a while to set up networks and storage.
+const clusterChangeTimeout = 60 * time.Second
+
+// Headers carrying the original requestor of the requests forwarded to other
+// cluster members.
+const (
+	clusterForwardedAddressHeader  = "X-LXD-forwarded-address"
+	clusterForwardedProtocolHeader = "X-LXD-forwarded-protocol"
+	clusterForwardedUsernameHeader = "X-LXD-forwarded-username"
+)
+
+// A cluster member as exchanged between the members over the internal API.
+type internalClusterMember struct {
+	ServerName  string    `json:"server_name"`
+	Address     string    `json:"address"`
+	Certificate string    `json:"certificate"`
+	JoinedAt    time.Time `json:"joined_at"`
+	Evacuated   bool      `json:"evacuated"`
+}
+
+func clusterMembersExport(members []db.ClusterMemberInfo) []internalClusterMember {
+	result := []internalClusterMember{}
+	for _, member := range members {
+		result = append(result, internalClusterMember{
+			ServerName:  member.Name,
+			Address:     member.Address,
+			Certificate: member.Certificate,
+			JoinedAt:    member.JoinedAt,
+			Evacuated:   member.Evacuated,
+		})
+	}
+
+	return result
+}
+
+func clusterMembersImport(members []internalClusterMember) []db.ClusterMemberInfo {
+	result := []db.ClusterMemberInfo{}
+	for _, member := range members {
+		result = append(result, db.ClusterMemberInfo{
+			Name:        member.ServerName,
+			Address:     member.Address,
+			Certificate: member.Certificate,
+			JoinedAt:    member.JoinedAt,
+			Evacuated:   member.Evacuated,
+		})
+	}
+
+	return result
+}
+
+// Return the entry of this node in the given list of cluster members, or nil
+// if it's not there.
+func clusterSelf(d *Daemon, members []db.ClusterMemberInfo) *db.ClusterMemberInfo {
+	if len(members) == 0 {
+		return nil
+	}
+
+	fingerprint, err := shared.CertFingerprintStr(string(d.serverCert.PublicKey()))
+	if err != nil {
+		return nil
+	}
+
+	for i := range members {
+		memberFingerprint, err := shared.CertFingerprintStr(members[i].Certificate)
+		if err == nil && memberFingerprint == fingerprint {
+			return &members[i]
+		}
+	}
+
+	return nil
+}
+
+// Return the name of this node in the cluster, or an empty string if it's not
+// clustered.
+func clusterServerName(d *Daemon) string {
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return ""
+	}
+
+	self := clusterSelf(d, members)
+	if self == nil {
+		return ""
+	}
+
+	return self.Name
+}
+
+// Check whether the given request was made by another member of the cluster,
+// which happens when forwarding requests. Those must be handled locally.
+func clusterIsMemberRequest(r *http.Request, members []db.ClusterMemberInfo) bool {
+	if r.TLS == nil {
+		return false
+	}
+
+	for _, member := range members {
+		fingerprint, err := shared.CertFingerprintStr(member.Certificate)
+		if err != nil {
+			continue
+		}
+
+		for _, cert := range r.TLS.PeerCertificates {
+			if shared.CertFingerprint(cert) == fingerprint {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Connect to the given cluster member.
+func clusterConnect(d *Daemon, member db.ClusterMemberInfo) (lxd.ContainerServer, error) {
+	return cluster.Connect(member.Address, d.serverCert, member.Certificate)
+}
+
+// Send the given list of members to all the other members of the cluster,
+// except those in skip. Members which can't be reached are only logged about.
+func clusterNotify(d *Daemon, members []db.ClusterMemberInfo, list []db.ClusterMemberInfo, skip ...string) {
+	self := clusterSelf(d, members)
+	for _, member := range members {
+		if (self != nil && member.Name == self.Name) || shared.StringInSlice(member.Name, skip) {
+			continue
+		}
+
+		client, err := clusterConnect(d, member)
+		if err == nil {
+			_, _, err = client.RawQuery("PUT", "/internal/cluster/members", clusterMembersExport(list), "")
+		}
+
+		if err != nil {
+			logger.Warn("Failed to notify cluster member", log.Ctx{"member": member.Name, "err": err})
+		}
+	}
+}
+
+// Find which of the other cluster members successfully answers a GET request
+// to the given API path. Returns nil if none does. The members are queried in
+// parallel, so that the ones which can't be reached only delay the answer by
+// clusterQueryTimeout.
+func clusterLocate(d *Daemon, members []db.ClusterMemberInfo, path string) *db.ClusterMemberInfo {
+	self := clusterSelf(d, members)
+
+	// Buffered so that the queries still running once a member is found
+	// don't block
+	found := make(chan *db.ClusterMemberInfo, len(members))
+	queried := 0
+	for i := range members {
+		if self != nil && members[i].Name == self.Name {
+			continue
+		}
+
+		queried++
+		go func(member *db.ClusterMemberInfo) {
+			if clusterHas(d, *member, path) {
+				found <- member
+			} else {
+				found <- nil
+			}
+		}(&members[i])
+	}
+
+	for ; queried > 0; queried-- {
+		member := <-found
+		if member != nil {
+			return member
+		}
+	}
+
+	return nil
+}
+
+// Check whether the given cluster member successfully answers a GET request to
+// the given API path.
+func clusterHas(d *Daemon, member db.ClusterMemberInfo, path string) bool {
+	transport, err := cluster.Transport(d.serverCert, member.Certificate)
+	if err != nil {
+		return false
+	}
+
+	client := &http.Client{Transport: transport, Timeout: clusterQueryTimeout}
+	response, err := client.Get(fmt.Sprintf("https://%s%s", member.Address, path))
+	if err != nil {
+		logger.Debug("Failed to query cluster member", log.Ctx{"member": member.Name, "err": err})
+		return false
+	}
+	response.Body.Close()
+
+	return response.StatusCode == http.StatusOK
+}
+
+// Keep the original requestor of the requests forwarded by the other cluster
+// members, so that they're handled as if made to this member directly, and
+// drop it from the other requests, which can't be trusted with it.
+func clusterForwardedRequest(d *Daemon, r *http.Request) {
+	address := r.Header.Get(clusterForwardedAddressHeader)
+	if address == "" {
+		return
+	}
+
+	members, err := d.db.ClusterMembers()
+	if err == nil && clusterIsMemberRequest(r, members) {
+		r.RemoteAddr = address
+		return
+	}
+
+	for _, header := range []string{clusterForwardedAddressHeader, clusterForwardedProtocolHeader, clusterForwardedUsernameHeader} {
+		r.Header.Del(header)
+	}
+}
+
+// Forward the requests about the containers (and their operations) which live
+// on other cluster members to them. Returns true if the request got forwarded.
+func clusterForward(d *Daemon, w http.ResponseWriter, r *http.Request, c Command) bool {
+	var path string
+	switch {
+	case strings.HasPrefix(c.name, "containers/{name}"):
+		name := mux.Vars(r)["name"]
+		_, err := d.db.ContainerId(name)
+		if err == nil {
+			return false
+		}
+
+		path = fmt.Sprintf("/%s/containers/%s", version.APIVersion, name)
+	case strings.HasPrefix(c.name, "operations/{id}"):
+		id := mux.Vars(r)["id"]
+		_, err := operationGet(id)
+		if err == nil {
+			return false
+		}
+
+		_, err = d.db.OperationGet(id)
+		if err == nil {
+			return false
+		}
+
+		path = fmt.Sprintf("/%s/operations/%s", version.APIVersion, id)
+	default:
+		return false
+	}
+
+	members, err := d.db.ClusterMembers()
+	if err != nil || len(members) == 0 || clusterIsMemberRequest(r, members) {
+		return false
+	}
+
+	// If no member knows about it either, let the local handler report it
+	member := clusterLocate(d, members, path)
+	if member == nil {
+		return false
+	}
+
+	clusterForwardedResponse(d, r, *member).Render(w)
+	return true
+}
+
+// Forwarded response, relaying the response of another cluster member to the
+// request.
+type forwardedResponse struct {
+	d      *Daemon
+	req    *http.Request
+	member db.ClusterMemberInfo
+}
+
+func (r *forwardedResponse) Render(w http.ResponseWriter) error {
+	transport, err := cluster.Transport(r.d.serverCert, r.member.Certificate)
+	if err != nil {
+		return InternalError(err).Render(w)
+	}
+
+	logger.Debug("Forwarding request to cluster member", log.Ctx{"member": r.member.Name, "method": r.req.Method, "url": r.req.URL.RequestURI()})
+
+	// Tell the member who the request comes from, since it only sees
+	// this node
+	requestor := eventRequestor(r.req)
+	r.req.Header.Set(clusterForwardedAddressHeader, requestor.Address)
+	r.req.Header.Set(clusterForwardedProtocolHeader, requestor.Protocol)
+	r.req.Header.Set(clusterForwardedUsernameHeader, requestor.Username)
+
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "https", Host: r.member.Address})
+	proxy.Transport = transport
+
+	// The member sets its own content type
+	w.Header().Del("Content-Type")
+	proxy.ServeHTTP(w, r.req)
+
+	return nil
+}
+
+func (r *forwardedResponse) String() string {
+	return fmt.Sprintf("forwarded to %s", r.member.Name)
+}
+
+func clusterForwardedResponse(d *Daemon, r *http.Request, member db.ClusterMemberInfo) Response {
+	return &forwardedResponse{d: d, req: r, member: member}
+}
+
+// Check whether the given API command manages the configuration shared by all
+// the cluster members: profiles, networks and storage pools. The volumes of the
+// storage pools are local to each member.
+func clusterShared(c Command) bool {
+	if strings.HasPrefix(c.name, "storage-pools/{name}/volumes") || strings.HasPrefix(c.name, "storage-pools/{pool}/volumes") {
+		return false
+	}
+
+	for _, name := range []string{"profiles", "networks", "storage-pools"} {
+		if c.name == name || strings.HasPrefix(c.name, name+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A change to the configuration shared by the cluster members, to be applied
+// by the other members once applied by the one which got it from the client.
+type clusterChange struct {
+	method string
+	path   string
+	body   []byte
+}
+
+// Return the change made by the given request to the configuration shared by
+// the cluster members, or nil if it doesn't make one. The changes sent by the
+// other members, which already applied them, are only applied locally.
+func clusterChangeRead(d *Daemon, r *http.Request, c Command) (*clusterChange, error) {
+	if r.Method == "GET" || !clusterShared(c) {
+		return nil, nil
+	}
+
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members) < 2 || clusterIsMemberRequest(r, members) {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = shared.BytesReadCloser{Buf: bytes.NewBuffer(body)}
+
+	return &clusterChange{method: r.Method, path: r.URL.RequestURI(), body: body}, nil
+}
+
+// Apply the given change to the shared configuration on all the other cluster
+// members, on behalf of the requestor of the given request. Members which fail
+// to apply it are only logged about.
+func clusterChangePropagate(d *Daemon, r *http.Request, change *clusterChange) {
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		logger.Warn("Failed to get the cluster members", log.Ctx{"err": err})
+		return
+	}
+
+	self := clusterSelf(d, members)
+	requestor := eventRequestor(r)
+	for _, member := range members {
+		if self != nil && member.Name == self.Name {
+			continue
+		}
+
+		err := clusterChangeApply(d, member, change, requestor)
+		if err != nil {
+			logger.Warn("Failed to apply change on cluster member", log.Ctx{"member": member.Name, "method": change.method, "url": change.path, "err": err})
+		}
+	}
+}
+
+// Apply the given change to the shared configuration on the given cluster
+// member.
+func clusterChangeApply(d *Daemon, member db.ClusterMemberInfo, change *clusterChange, requestor *api.EventLifecycleRequestor) error {
+	transport, err := cluster.Transport(d.serverCert, member.Certificate)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(change.method, fmt.Sprintf("https://%s%s", member.Address, change.path), bytes.NewReader(change.body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(clusterForwardedAddressHeader, requestor.Address)
+	req.Header.Set(clusterForwardedProtocolHeader, requestor.Protocol)
+	req.Header.Set(clusterForwardedUsernameHeader, requestor.Username)
+
+	client := &http.Client{Transport: transport, Timeout: clusterChangeTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		response := api.Response{}
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil || response.Error == "" {
+			return fmt.Errorf("Failed with status %d", resp.StatusCode)
+		}
+
+		return fmt.Errorf("%s", response.Error)
+	}
+
+	return nil
+}
+
+// Apply the profiles of the cluster this node just joined, and send the
+// profiles only this node has to the other members, so that they all end up
+// with the same ones.
+func clusterProfilesSync(d *Daemon, r *http.Request, profiles []api.Profile) {
+	names := []string{}
+	for _, profile := range profiles {
+		names = append(names, profile.Name)
+
+		id, current, err := d.db.ProfileGet(profile.Name)
+		if err == sql.ErrNoRows {
+			_, err = d.db.ProfileCreate(profile.Name, profile.Description, profile.Config, profile.Devices)
+		} else if err == nil {
+			resp := doProfileUpdate(d, profile.Name, id, current, profile.Writable())
+			if errResp, ok := resp.(*errorResponse); ok {
+				err = fmt.Errorf("%s", errResp.msg)
+			}
+		}
+
+		if err != nil {
+			logger.Warn("Failed to apply the cluster profile", log.Ctx{"profile": profile.Name, "err": err})
+		}
+	}
+
+	local, err := d.db.Profiles()
+	if err != nil {
+		logger.Warn("Failed to get the profiles", log.Ctx{"err": err})
+		return
+	}
+
+	for _, name := range local {
+		if shared.StringInSlice(name, names) {
+			continue
+		}
+
+		_, profile, err := d.db.ProfileGet(name)
+		if err != nil {
+			logger.Warn("Failed to get the profile", log.Ctx{"profile": name, "err": err})
+			continue
+		}
+
+		body, err := json.Marshal(api.ProfilesPost{Name: name, ProfilePut: profile.Writable()})
+		if err != nil {
+			logger.Warn("Failed to encode the profile", log.Ctx{"profile": name, "err": err})
+			continue
+		}
+
+		clusterChangePropagate(d, r, &clusterChange{method: "POST", path: fmt.Sprintf("/%s/profiles", version.APIVersion), body: body})
+	}
+}
+
+// Return the cluster member with the given name, or the one picked by
+// clusterSchedule if no name is given.
+func clusterTarget(d *Daemon, members []db.ClusterMemberInfo, name string) (*db.ClusterMemberInfo, error) {
+	if name == "" {
+		member := clusterSchedule(d, members)
+		if member == nil {
+			return nil, fmt.Errorf("No cluster member is available")
+		}
+
+		return member, nil
+	}
+
+	for i := range members {
+		if members[i].Name != name {
+			continue
+		}
+
+		if members[i].Evacuated {
+			return nil, fmt.Errorf("Cluster member '%s' is evacuated", name)
+		}
+
+		return &members[i], nil
+	}
+
+	return nil, fmt.Errorf("No cluster member named '%s'", name)
+}
+
+// Return the cluster member the given container (or snapshot) lives on, which
+// is the one making copies of it.
+func clusterCopyTarget(d *Daemon, members []db.ClusterMemberInfo, name string) (*db.ClusterMemberInfo, error) {
+	_, err := d.db.ContainerId(name)
+	if err == nil {
+		self := clusterSelf(d, members)
+		if self == nil {
+			return nil, fmt.Errorf("This server isn't a member of the cluster")
+		}
+
+		return self, nil
+	}
+
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	member := clusterLocate(d, members, eventContainerSource(name))
+	if member == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	return member, nil
+}
+
+// Pick the cluster member a new container should be created on: the one with
+// the fewest containers, this node winning ties. Evacuated members and those
+// which can't be reached or are unhealthy are left out. Returns nil if no member is available.
+func clusterSchedule(d *Daemon, members []db.ClusterMemberInfo) *db.ClusterMemberInfo {
+	self := clusterSelf(d, members)
+
+	var chosen *db.ClusterMemberInfo
+	least := -1
+	consider := func(member *db.ClusterMemberInfo, count int) {
+		if least == -1 || count < least {
+			chosen = member
+			least = count
+		}
+	}
+
+	if self != nil && !self.Evacuated {
+		names, err := d.db.ContainersList(db.CTypeRegular)
+		if err == nil {
+			consider(self, len(names))
+		}
+	}
+
+	for i, member := range members {
+		if member.Evacuated || (self != nil && member.Name == self.Name) {
+			continue
+		}
+
+		client, err := clusterConnect(d, member)
+		if err != nil {
+			logger.Debug("Failed to connect to cluster member", log.Ctx{"member": member.Name, "err": err})
+			continue
+		}
+
+		_, _, err = client.RawQuery("GET", "/internal/healthz", nil, "")
+		if err != nil {
+			logger.Debug("Skipping unhealthy cluster member", log.Ctx{"member": member.Name, "err": err})
+			continue
+		}
+
+		names, err := client.GetContainerNames()
+		if err != nil {
+			continue
+		}
+
+		consider(&members[i], len(names))
+	}
+
+	return chosen
+}
+
+// Add the containers of the other cluster members to the given result of a
+// GET request to /1.0/containers. Members which can't be reached are skipped.
+func clusterContainersMerge(d *Daemon, r *http.Request, result interface{}, recursion bool) interface{} {
+	members, err := d.db.ClusterMembers()
+	if err != nil || len(members) == 0 || clusterIsMemberRequest(r, members) {
+		return result
+	}
+
+	self := clusterSelf(d, members)
+	if self == nil {
+		return result
+	}
+
+	if recursion {
+		for _, c := range result.([]*api.Container) {
+			c.Location = self.Name
+		}
+	}
+
+	for _, member := range members {
+		if member.Name == self.Name {
+			continue
+		}
+
+		client, err := clusterConnect(d, member)
+		if err != nil {
+			logger.Warn("Failed to list the containers of cluster member", log.Ctx{"member": member.Name, "err": err})
+			continue
+		}
+
+		if !recursion {
+			names, err := client.GetContainerNames()
+			if err != nil {
+				logger.Warn("Failed to list the containers of cluster member", log.Ctx{"member": member.Name, "err": err})
+				continue
+			}
+
+			for _, name := range names {
+				url := fmt.Sprintf("/%s/containers/%s", version.APIVersion, name)
+				result = append(result.([]string), url)
+			}
+
+			continue
+		}
+
+		containers, err := client.GetContainers()
+		if err != nil {
+			logger.Warn("Failed to list the containers of cluster member", log.Ctx{"member": member.Name, "err": err})
+			continue
+		}
+
+		for i := range containers {
+			containers[i].Location = member.Name
+			result = append(result.([]*api.Container), &containers[i])
+		}
+	}
+
+	return result
+}
+
+// Mark the cluster member with the given name as evacuated or not, and tell
+// the other members.
+func clusterEvacuatedSet(d *Daemon, name string, evacuated bool) error {
+	err := d.db.ClusterMemberSetEvacuated(name, evacuated)
+	if err != nil {
+		return err
+	}
+
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return err
+	}
+
+	clusterNotify(d, members, members)
+	return nil
+}
+
+// Move all the containers of this node to the other cluster members, or only
+// stop them if the mode is "stop". In "live" mode, the running containers are
+// live-migrated, otherwise they're stopped, moved and started again.
+func clusterEvacuate(d *Daemon, mode string) error {
+	names, err := d.db.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		c, err := containerLoadByName(d.State(), name)
+		if err != nil {
+			return err
+		}
+
+		if mode == "stop" {
+			if !c.IsRunning() {
+				continue
+			}
+
+			err = c.Shutdown(time.Minute)
+			if err != nil {
+				err = c.Stop(false)
+			}
+		} else {
+			err = clusterContainerMove(d, c, mode == "live")
+		}
+
+		if err != nil {
+			return fmt.Errorf("Failed to evacuate container '%s': %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Move the given container to the least loaded of the other cluster members.
+func clusterContainerMove(d *Daemon, c container, live bool) error {
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return err
+	}
+
+	self := clusterSelf(d, members)
+	if self == nil {
+		return fmt.Errorf("This server isn't a member of a cluster")
+	}
+
+	target := clusterSchedule(d, members)
+	if target == nil || target.Name == self.Name {
+		return fmt.Errorf("No other cluster member is available")
+	}
+
+	source, err := clusterConnect(d, *self)
+	if err != nil {
+		return err
+	}
+
+	dest, err := clusterConnect(d, *target)
+	if err != nil {
+		return err
+	}
+
+	running := c.IsRunning()
+	if running && !live {
+		err = c.Shutdown(time.Minute)
+		if err != nil {
+			err = c.Stop(false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	ct, _, err := source.GetContainer(c.Name())
+	if err != nil {
+		return err
+	}
+
+	op, err := dest.CopyContainer(source, *ct, &lxd.ContainerCopyArgs{Live: live})
+	if err == nil {
+		err = op.Wait()
+	}
+
+	if err != nil {
+		// Leave the container where it was
+		if running && !c.IsRunning() {
+			c.Start(false)
+		}
+
+		return err
+	}
+
+	logger.Info("Moved container to another cluster member", log.Ctx{"container": c.Name(), "member": target.Name})
+
+	if c.IsRunning() {
+		err = c.Stop(false)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Delete()
+	if err != nil {
+		return err
+	}
+
+	if running && !live {
+		op, err := dest.UpdateContainerState(c.Name(), api.ContainerStatePut{Action: "start", Timeout: -1}, "")
+		if err != nil {
+			return err
+		}
+
+		return op.Wait()
+	}
+
+	return nil
+}
+
+// Return the cluster member with the given URL, or nil if there's none.
+func clusterMemberByURL(d *Daemon, server string) *db.ClusterMemberInfo {
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return nil
+	}
+
+	for i := range members {
+		if fmt.Sprintf("https://%s", members[i].Address) == strings.TrimSuffix(server, "/") {
+			return &members[i]
+		}
+	}
+
+	return nil
+}
+
+// Find the cluster member having the image with the given fingerprint or
+// alias, if this node doesn't have it. Returns nil if this node has it or if
+// no member does.
+func clusterImageLocate(d *Daemon, source api.ContainerSource) *db.ClusterMemberInfo {
+	var path string
+	if source.Fingerprint != "" {
+		_, _, err := d.db.ImageGet(source.Fingerprint, false, false)
+		if err == nil {
+			return nil
+		}
+
+		path = fmt.Sprintf("/%s/images/%s", version.APIVersion, source.Fingerprint)
+	} else if source.Alias != "" {
+		_, _, err := d.db.ImageAliasGet(source.Alias, true)
+		if err == nil {
+			return nil
+		}
+
+		path = fmt.Sprintf("/%s/images/aliases/%s", version.APIVersion, source.Alias)
+	} else {
+		return nil
+	}
+
+	members, err := d.db.ClusterMembers()
+	if err != nil || len(members) == 0 {
+		return nil
+	}
+
+	return clusterLocate(d, members, path)
+}
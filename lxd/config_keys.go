@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// /1.0/config-keys
+// List the well-known container configuration keys
+func configKeysGet(d *Daemon, r *http.Request) Response {
+	names := []string{}
+	for name := range shared.KnownContainerConfigKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	keys := []api.ConfigKey{}
+	for _, name := range names {
+		key := shared.KnownContainerConfigKeys[name]
+		keys = append(keys, api.ConfigKey{
+			Name:        name,
+			Type:        key.Type,
+			Default:     key.Default,
+			LiveUpdate:  key.LiveUpdate,
+			Description: key.Description,
+		})
+	}
+
+	return SyncResponse(true, keys)
+}
+
+var configKeysCmd = Command{name: "config-keys", get: configKeysGet}
@@ -3,9 +3,13 @@ package main
 import (
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,7 +21,9 @@ import (
 	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/cancel"
 	"github.com/lxc/lxd/shared/idmap"
+	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/osarch"
 )
 
@@ -67,6 +73,22 @@ func containerValidConfigKey(os *sys.OS, key string, value string) error {
 	if key == "raw.lxc" {
 		return lxcValidConfig(value)
 	}
+	if key == "raw.idmap" {
+		_, err := parseRawIdmap(value)
+		return err
+	}
+	if strings.HasPrefix(key, "linux.sysctl.") {
+		sysctl := strings.TrimPrefix(key, "linux.sysctl.")
+		if !containerSysctlNamespaced(sysctl) {
+			return fmt.Errorf("The sysctl \"%s\" isn't namespaced and can't be set per container", sysctl)
+		}
+	}
+	if key == "limits.cpu.nodes" && value != "" {
+		_, err := deviceNumaNodeCPUs(value)
+		if err != nil {
+			return err
+		}
+	}
 	if key == "security.syscalls.blacklist_compat" {
 		for _, arch := range os.Architectures {
 			if arch == osarch.ARCH_64BIT_INTEL_X86 ||
@@ -82,6 +104,22 @@ func containerValidConfigKey(os *sys.OS, key string, value string) error {
 
 var containerNetworkLimitKeys = []string{"limits.max", "limits.ingress", "limits.egress"}
 
+// containerSysctlNamespaced returns whether the given sysctl is namespaced
+// and so can be set per container through linux.sysctl.*.
+func containerSysctlNamespaced(sysctl string) bool {
+	for _, prefix := range []string{"net.", "fs.mqueue.", "kernel.msg", "kernel.sem", "kernel.shm"} {
+		if strings.HasPrefix(sysctl, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containerHugepageSizes lists the page sizes configurable through the
+// limits.hugepages.* keys, named the way the hugetlb CGroup names them.
+var containerHugepageSizes = []string{"64KB", "1MB", "2MB", "1GB"}
+
 func containerValidDeviceConfigKey(t, k string) bool {
 	if k == "type" {
 		return true
@@ -162,6 +200,10 @@ func containerValidDeviceConfigKey(t, k string) bool {
 			return true
 		case "recursive":
 			return true
+		case "propagation":
+			return true
+		case "shift":
+			return true
 		case "pool":
 			return true
 		default:
@@ -266,6 +308,172 @@ func containerValidConfig(os *sys.OS, config map[string]string, profile bool, ex
 	return nil
 }
 
+// containerExpandConfig merges the config of the given profiles, in the
+// order they're applied, and the container's local config. A key set by a
+// later profile overrides the one from an earlier profile and the local
+// config overrides all profiles.
+func containerExpandConfig(profiles []map[string]string, local map[string]string) map[string]string {
+	config := map[string]string{}
+
+	for _, profileConfig := range profiles {
+		for k, v := range profileConfig {
+			config[k] = v
+		}
+	}
+
+	for k, v := range local {
+		config[k] = v
+	}
+
+	return config
+}
+
+// containerExpandDevices merges the devices of the given profiles, in the
+// order they're applied, and the container's local devices. Devices are
+// identified by name and a device with the same name as an earlier one
+// replaces it as a whole, its properties aren't merged.
+func containerExpandDevices(profiles []types.Devices, local types.Devices) types.Devices {
+	devices := types.Devices{}
+
+	for _, profileDevices := range profiles {
+		for k, v := range profileDevices {
+			devices[k] = v
+		}
+	}
+
+	for k, v := range local {
+		devices[k] = v
+	}
+
+	return devices
+}
+
+// containerCheckVolatile rejects any volatile key being set or modified by a
+// user request. Those keys are managed by LXD, removing one is allowed and
+// will have LXD generate a new value when it needs one.
+func containerCheckVolatile(oldConfig map[string]string, newConfig map[string]string) error {
+	for k, v := range newConfig {
+		if !strings.HasPrefix(k, "volatile.") {
+			continue
+		}
+
+		if v != "" && oldConfig[k] != v {
+			return fmt.Errorf("Volatile keys are read-only.")
+		}
+	}
+
+	return nil
+}
+
+// containerCheckPrivileged enforces the core.privileged_containers policy,
+// rejecting requests which would result in a privileged container (through
+// its own config or its profiles) when the client isn't allowed to create
+// one. Clients with a certificate restricted to some containers never are.
+func containerCheckPrivileged(d *Daemon, r *http.Request, config map[string]string, profiles []string) error {
+	restrictions := certificateRestrictions(d, r)
+	restricted := restrictions != nil && len(restrictions.Containers) > 0
+
+	policy := daemonConfig["core.privileged_containers"].Get()
+	if !restricted && (policy == "allow" || (policy == "local" && r.RemoteAddr == "@")) {
+		return nil
+	}
+
+	if profiles == nil {
+		profiles = []string{"default"}
+	}
+
+	// Later profiles override earlier ones and the local config wins
+	expanded := map[string]string{}
+	for _, name := range profiles {
+		_, profile, err := d.db.ProfileGet(name)
+		if err != nil {
+			return err
+		}
+
+		for k, v := range profile.Config {
+			expanded[k] = v
+		}
+	}
+
+	for k, v := range config {
+		expanded[k] = v
+	}
+
+	key := containerPrivilegedKey(expanded)
+	if key == "" {
+		return nil
+	}
+
+	if restricted {
+		return fmt.Errorf("Privileged containers (%s) can't be setup with a restricted client certificate", key)
+	}
+
+	if policy == "local" {
+		return fmt.Errorf("Privileged containers (%s) can only be setup through the local unix socket", key)
+	}
+
+	return fmt.Errorf("Privileged containers (%s) are disabled on this server", key)
+}
+
+// containerCheckRestrictedDevices rejects requests from clients with a
+// certificate restricted to some containers which would add or change a device
+// of the container, directly or through its profiles, other than a nic. The
+// other devices (like disks and unix devices) give access to the host.
+func containerCheckRestrictedDevices(d *Daemon, r *http.Request, c container, devices types.Devices, profiles []string) error {
+	restrictions := certificateRestrictions(d, r)
+	if restrictions == nil || len(restrictions.Containers) == 0 {
+		return nil
+	}
+
+	profileDevices := []types.Devices{}
+	for _, name := range profiles {
+		_, profile, err := d.db.ProfileGet(name)
+		if err != nil {
+			return err
+		}
+
+		profileDevices = append(profileDevices, profile.Devices)
+	}
+
+	current := c.ExpandedDevices()
+	for name, m := range containerExpandDevices(profileDevices, devices) {
+		if shared.StringInSlice(m["type"], []string{"nic", "none"}) || current.Contains(name, m) {
+			continue
+		}
+
+		return fmt.Errorf("Device '%s' can't be added or changed with a restricted client certificate, only nic devices can", name)
+	}
+
+	return nil
+}
+
+// containerPrivilegedKey returns the first key of the given expanded config
+// which makes the container privileged, or gives it a way to act as root on
+// the host, or an empty string if there's none.
+func containerPrivilegedKey(config map[string]string) string {
+	keys := []string{}
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := config[k]
+		switch {
+		case k == "security.privileged" && shared.IsTrue(v):
+			return k
+		case k == "security.apparmor" && v != "" && !shared.IsTrue(v):
+			return k
+		case (k == "raw.lxc" || k == "raw.idmap") && v != "":
+			return k
+		case strings.HasPrefix(k, "hooks.") && v != "":
+			return k
+		}
+	}
+
+	return ""
+}
+
 func isRootDiskDevice(device types.Device) bool {
 	if device["type"] == "disk" && device["path"] == "/" && device["source"] == "" {
 		return true
@@ -305,129 +513,241 @@ func containerValidDevices(db *db.Node, devices types.Devices, profile bool, exp
 	var diskDevicePaths []string
 	// Check each device individually
 	for name, m := range devices {
-		if m["type"] == "" {
-			return fmt.Errorf("Missing device type for device '%s'", name)
+		if m["type"] == "disk" && !expanded {
+			if shared.StringInSlice(m["path"], diskDevicePaths) {
+				return fmt.Errorf("More than one disk device uses the same path: %s.", m["path"])
+			}
+
+			diskDevicePaths = append(diskDevicePaths, m["path"])
 		}
 
-		if !shared.StringInSlice(m["type"], []string{"disk", "gpu", "infiniband", "nic", "none", "unix-block", "unix-char", "usb"}) {
-			return fmt.Errorf("Invalid device type for device '%s'", name)
+		err := containerValidDevice(db, m)
+		if err != nil {
+			return fmt.Errorf("Invalid device \"%s\": %s", name, err)
 		}
+	}
 
-		for k := range m {
-			if !containerValidDeviceConfigKey(m["type"], k) {
-				return fmt.Errorf("Invalid device configuration key for %s: %s", m["type"], k)
-			}
+	// Checks on the expanded config
+	if expanded {
+		_, _, err := containerGetRootDiskDevice(devices)
+		if err != nil {
+			return err
 		}
+	}
 
-		if m["type"] == "nic" {
-			if m["nictype"] == "" {
-				return fmt.Errorf("Missing nic type")
-			}
+	return nil
+}
 
-			if !shared.StringInSlice(m["nictype"], []string{"bridged", "macvlan", "p2p", "physical", "sriov"}) {
-				return fmt.Errorf("Bad nic type: %s", m["nictype"])
-			}
+// containerValidDevice validates the type and properties of a single device.
+func containerValidDevice(db *db.Node, m types.Device) error {
+	if m["type"] == "" {
+		return fmt.Errorf("Missing device type")
+	}
+
+	if !shared.StringInSlice(m["type"], []string{"disk", "gpu", "infiniband", "nic", "none", "unix-block", "unix-char", "usb"}) {
+		return fmt.Errorf("Invalid device type: %s", m["type"])
+	}
+
+	for k := range m {
+		if !containerValidDeviceConfigKey(m["type"], k) {
+			return fmt.Errorf("Invalid device configuration key for %s: %s", m["type"], k)
+		}
+	}
+
+	for _, key := range []string{"optional", "readonly", "recursive", "required", "security.mac_filtering", "shift"} {
+		err := shared.IsBool(m[key])
+		if err != nil {
+			return fmt.Errorf("Invalid value for property \"%s\": %s", key, m[key])
+		}
+	}
+
+	for _, key := range []string{"uid", "gid", "major", "minor"} {
+		err := shared.IsUint32(m[key])
+		if err != nil {
+			return fmt.Errorf("Invalid value for property \"%s\": %s", key, m[key])
+		}
+	}
+
+	if m["mode"] != "" {
+		_, err := deviceModeOct(m["mode"])
+		if err != nil {
+			return fmt.Errorf("Invalid value for property \"mode\": %s", m["mode"])
+		}
+	}
+
+	if m["type"] == "nic" {
+		if m["nictype"] == "" {
+			return fmt.Errorf("Missing nic type")
+		}
+
+		if !shared.StringInSlice(m["nictype"], []string{"bridged", "ipvlan", "macvlan", "p2p", "physical", "routed", "sriov"}) {
+			return fmt.Errorf("Bad nic type: %s", m["nictype"])
+		}
 
-			if shared.StringInSlice(m["nictype"], []string{"bridged", "macvlan", "physical", "sriov"}) && m["parent"] == "" {
-				return fmt.Errorf("Missing parent for %s type nic", m["nictype"])
+		if shared.StringInSlice(m["nictype"], []string{"bridged", "ipvlan", "macvlan", "physical", "sriov"}) && m["parent"] == "" {
+			return fmt.Errorf("Missing parent for %s type nic", m["nictype"])
+		}
+
+		if shared.StringInSlice(m["nictype"], []string{"ipvlan", "routed"}) && m["ipv4.address"] == "" && m["ipv6.address"] == "" {
+			return fmt.Errorf("%s nics require ipv4.address or ipv6.address to be set", m["nictype"])
+		}
+
+		if m["hwaddr"] != "" {
+			_, err := net.ParseMAC(m["hwaddr"])
+			if err != nil {
+				return fmt.Errorf("Invalid MAC address: %s", m["hwaddr"])
 			}
-		} else if m["type"] == "infiniband" {
-			if m["nictype"] == "" {
-				return fmt.Errorf("Missing nic type")
+		}
+
+		if m["mtu"] != "" {
+			mtu, err := strconv.Atoi(m["mtu"])
+			if err != nil || mtu < 68 {
+				return fmt.Errorf("Invalid MTU: %s", m["mtu"])
 			}
+		}
 
-			if !shared.StringInSlice(m["nictype"], []string{"physical", "sriov"}) {
-				return fmt.Errorf("Bad nic type: %s", m["nictype"])
+		if m["vlan"] != "" {
+			vlan, err := strconv.Atoi(m["vlan"])
+			if err != nil || vlan < 1 || vlan > 4094 {
+				return fmt.Errorf("Invalid VLAN ID: %s", m["vlan"])
 			}
+		}
 
-			if m["parent"] == "" {
-				return fmt.Errorf("Missing parent for %s type nic", m["nictype"])
+		for _, key := range containerNetworkLimitKeys {
+			if m[key] == "" {
+				continue
 			}
-		} else if m["type"] == "disk" {
-			if !expanded && !shared.StringInSlice(m["path"], diskDevicePaths) {
-				diskDevicePaths = append(diskDevicePaths, m["path"])
-			} else if !expanded {
-				return fmt.Errorf("More than one disk device uses the same path: %s.", m["path"])
+
+			if !shared.StringInSlice(m["nictype"], []string{"bridged", "p2p", "routed"}) {
+				return fmt.Errorf("Network limits are only supported on bridged, p2p and routed interfaces")
 			}
 
-			if m["path"] == "" {
-				return fmt.Errorf("Disk entry is missing the required \"path\" property.")
+			_, err := shared.ParseBitSizeString(m[key])
+			if err != nil {
+				return fmt.Errorf("Invalid value for nic %s: %s", key, err)
 			}
+		}
+	} else if m["type"] == "infiniband" {
+		if m["nictype"] == "" {
+			return fmt.Errorf("Missing nic type")
+		}
 
-			if m["source"] == "" && m["path"] != "/" {
-				return fmt.Errorf("Disk entry is missing the required \"source\" property.")
+		if !shared.StringInSlice(m["nictype"], []string{"physical", "sriov"}) {
+			return fmt.Errorf("Bad nic type: %s", m["nictype"])
+		}
+
+		if m["parent"] == "" {
+			return fmt.Errorf("Missing parent for %s type nic", m["nictype"])
+		}
+	} else if m["type"] == "disk" {
+		if m["path"] == "" {
+			return fmt.Errorf("Disk entry is missing the required \"path\" property.")
+		}
+
+		if m["source"] == "" && m["path"] != "/" {
+			return fmt.Errorf("Disk entry is missing the required \"source\" property.")
+		}
+
+		if m["path"] == "/" && m["source"] != "" {
+			return fmt.Errorf("Root disk entry may not have a \"source\" property set.")
+		}
+
+		if m["size"] != "" && m["path"] != "/" {
+			return fmt.Errorf("Only the root disk may have a size quota.")
+		}
+
+		for _, key := range []string{"limits.max", "limits.read", "limits.write"} {
+			_, _, _, _, err := deviceParseDiskLimit(m[key], "")
+			if err != nil {
+				return fmt.Errorf("Invalid value for disk property \"%s\": %s", key, err)
 			}
+		}
+
+		if (m["path"] == "/" || !shared.IsDir(m["source"])) && m["recursive"] != "" {
+			return fmt.Errorf("The recursive option is only supported for additional bind-mounted paths.")
+		}
 
-			if m["path"] == "/" && m["source"] != "" {
-				return fmt.Errorf("Root disk entry may not have a \"source\" property set.")
+		if m["propagation"] != "" {
+			if m["path"] == "/" {
+				return fmt.Errorf("The propagation option is only supported for additional disks.")
 			}
 
-			if m["size"] != "" && m["path"] != "/" {
-				return fmt.Errorf("Only the root disk may have a size quota.")
+			_, ok := deviceDiskPropagation[m["propagation"]]
+			if !ok {
+				return fmt.Errorf("Invalid value for disk property \"propagation\": %s", m["propagation"])
 			}
+		}
 
-			if (m["path"] == "/" || !shared.IsDir(m["source"])) && m["recursive"] != "" {
-				return fmt.Errorf("The recursive option is only supported for additional bind-mounted paths.")
+		if shared.IsTrue(m["shift"]) {
+			if m["path"] == "/" {
+				return fmt.Errorf("The shift option is only supported for additional disks.")
 			}
 
-			if m["pool"] != "" {
-				if filepath.IsAbs(m["source"]) {
-					return fmt.Errorf("Storage volumes cannot be specified as absolute paths.")
-				}
+			if m["pool"] == "" && shared.PathExists(m["source"]) && !shared.IsDir(m["source"]) && !deviceIsBlockdev(m["source"]) {
+				return fmt.Errorf("The shift option is only supported for directories and block devices.")
+			}
+		}
 
-				_, err := db.StoragePoolGetID(m["pool"])
-				if err != nil {
-					return fmt.Errorf("The \"%s\" storage pool doesn't exist.", m["pool"])
-				}
+		if m["pool"] != "" {
+			if filepath.IsAbs(m["source"]) {
+				return fmt.Errorf("Storage volumes cannot be specified as absolute paths.")
 			}
 
-		} else if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
-			if m["source"] == "" && m["path"] == "" {
-				return fmt.Errorf("Unix device entry is missing the required \"source\" or \"path\" property.")
+			_, err := db.StoragePoolGetID(m["pool"])
+			if err != nil {
+				return fmt.Errorf("The \"%s\" storage pool doesn't exist.", m["pool"])
 			}
+		}
 
-			if m["major"] == "" || m["minor"] == "" {
-				srcPath, exist := m["source"]
-				if !exist {
-					srcPath = m["path"]
-				}
-				if !shared.PathExists(srcPath) {
-					return fmt.Errorf("The device path doesn't exist on the host and major/minor wasn't specified.")
-				}
+	} else if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
+		if m["source"] == "" && m["path"] == "" {
+			return fmt.Errorf("Unix device entry is missing the required \"source\" or \"path\" property.")
+		}
 
-				dType, _, _, err := deviceGetAttributes(srcPath)
-				if err != nil {
-					return err
-				}
+		if m["major"] == "" || m["minor"] == "" {
+			srcPath, exist := m["source"]
+			if !exist {
+				srcPath = m["path"]
+			}
+			if !shared.PathExists(srcPath) {
+				return fmt.Errorf("The device path doesn't exist on the host and major/minor wasn't specified.")
+			}
 
-				if m["type"] == "unix-char" && dType != "c" {
-					return fmt.Errorf("Path specified for unix-char device is a block device.")
-				}
+			dType, _, _, err := deviceGetAttributes(srcPath)
+			if err != nil {
+				return err
+			}
 
-				if m["type"] == "unix-block" && dType != "b" {
-					return fmt.Errorf("Path specified for unix-block device is a character device.")
-				}
+			if m["type"] == "unix-char" && dType != "c" {
+				return fmt.Errorf("Path specified for unix-char device is a block device.")
 			}
-		} else if m["type"] == "usb" {
-			if m["vendorid"] == "" {
-				return fmt.Errorf("Missing vendorid for USB device.")
+
+			if m["type"] == "unix-block" && dType != "b" {
+				return fmt.Errorf("Path specified for unix-block device is a character device.")
 			}
-		} else if m["type"] == "gpu" {
-			// Probably no checks needed, since we allow users to
-			// pass in all GPUs.
-		} else if m["type"] == "none" {
-			continue
-		} else {
-			return fmt.Errorf("Invalid device type: %s", m["type"])
 		}
-	}
+	} else if m["type"] == "usb" {
+		if m["vendorid"] == "" {
+			return fmt.Errorf("Missing vendorid for USB device.")
+		}
 
-	// Checks on the expanded config
-	if expanded {
-		_, _, err := containerGetRootDiskDevice(devices)
-		if err != nil {
-			return err
+		for _, key := range []string{"vendorid", "productid"} {
+			if m[key] == "" {
+				continue
+			}
+
+			_, err := strconv.ParseUint(m[key], 16, 16)
+			if err != nil || len(m[key]) != 4 {
+				return fmt.Errorf("Invalid %s for USB device: %s.", key, m[key])
+			}
 		}
+	} else if m["type"] == "gpu" {
+		// Probably no checks needed, since we allow users to
+		// pass in all GPUs.
+	} else if m["type"] == "none" {
+		return nil
+	} else {
+		return fmt.Errorf("Invalid device type: %s", m["type"])
 	}
 
 	return nil
@@ -464,9 +784,10 @@ type container interface {
 
 	// File handling
 	FileExists(path string) error
-	FilePull(srcpath string, dstpath string) (int64, int64, os.FileMode, string, []string, error)
-	FilePush(type_ string, srcpath string, dstpath string, uid int64, gid int64, mode int, write string) error
+	FilePull(srcpath string, dstpath string) (int64, int64, os.FileMode, string, string, []api.ContainerFileEntry, map[string]string, error)
+	FilePush(type_ string, srcpath string, dstpath string, uid int64, gid int64, mode int, write string, xattrs map[string]string) error
 	FileRemove(path string) error
+	FileRemoveAll(path string) error
 
 	// Console - Allocate and run a console tty.
 	//
@@ -488,7 +809,7 @@ type container interface {
 	         *      (the PID returned in the first return argument). It can however
 	         *      be used to e.g. forward signals.)
 	*/
-	Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool) (*exec.Cmd, int, int, error)
+	Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool, cwd string, uid uint32, gid uint32) (*exec.Cmd, int, int, error)
 
 	// Status
 	Render() (interface{}, interface{}, error)
@@ -544,53 +865,77 @@ type container interface {
 
 // Loader functions
 func containerCreateAsEmpty(d *Daemon, args db.ContainerArgs) (container, error) {
+	revert := revertSteps{}
+	defer revert.Fail()
+
 	// Create the container
 	c, err := containerCreateInternal(d.State(), args)
 	if err != nil {
 		return nil, err
 	}
+	revert.Add(func() { c.Delete() })
 
 	// Now create the empty storage
 	err = c.Storage().ContainerCreate(c)
 	if err != nil {
-		d.db.ContainerRemove(args.Name)
 		return nil, err
 	}
 
 	// Apply any post-storage configuration
 	err = containerConfigureInternal(c)
 	if err != nil {
-		c.Delete()
 		return nil, err
 	}
 
+	revert.Success()
 	return c, nil
 }
 
 func containerCreateEmptySnapshot(s *state.State, args db.ContainerArgs) (container, error) {
-	// Create the snapshot
+	revert := revertSteps{}
+	defer revert.Fail()
+
+	// Create the snapshot. Deleting it fails if its storage wasn't
+	// created, so make sure its record goes away anyway.
 	c, err := containerCreateInternal(s, args)
 	if err != nil {
 		return nil, err
 	}
+	revert.Add(func() {
+		s.DB.ContainerRemove(args.Name)
+		containerCache.Forget(args.Name)
+	})
+	revert.Add(func() { c.Delete() })
 
 	// Now create the empty snapshot
 	err = c.Storage().ContainerSnapshotCreateEmpty(c)
 	if err != nil {
-		s.DB.ContainerRemove(args.Name)
 		return nil, err
 	}
 
+	revert.Success()
 	return c, nil
 }
 
-func containerCreateFromImage(s *state.State, args db.ContainerArgs, hash string) (container, error) {
+func containerCreateFromImage(s *state.State, args db.ContainerArgs, hash string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) (container, error) {
 	// Get the image properties
 	_, img, err := s.DB.ImageGet(hash, false, false)
 	if err != nil {
 		return nil, err
 	}
 
+	// Check that the host can run the image
+	architecture, err := osarch.ArchitectureId(img.Architecture)
+	if err != nil {
+		return nil, err
+	}
+
+	if !shared.IntInSlice(architecture, s.OS.Architectures) {
+		return nil, fmt.Errorf("The image architecture %s isn't supported by this host", img.Architecture)
+	}
+
+	args.Architecture = architecture
+
 	// Set the "image.*" keys
 	if img.Properties != nil {
 		for k, v := range img.Properties {
@@ -601,47 +946,52 @@ func containerCreateFromImage(s *state.State, args db.ContainerArgs, hash string
 	// Set the BaseImage field (regardless of previous value)
 	args.BaseImage = hash
 
+	revert := revertSteps{}
+	defer revert.Fail()
+
 	// Create the container
 	c, err := containerCreateInternal(s, args)
 	if err != nil {
 		return nil, err
 	}
+	revert.Add(func() { c.Delete() })
 
 	err = s.DB.ImageLastAccessUpdate(hash, time.Now().UTC())
 	if err != nil {
-		s.DB.ContainerRemove(args.Name)
 		return nil, fmt.Errorf("Error updating image last use date: %s", err)
 	}
 
 	// Now create the storage from an image
-	err = c.Storage().ContainerCreateFromImage(c, hash)
+	err = c.Storage().ContainerCreateFromImage(c, hash, tracker, canceler)
 	if err != nil {
-		s.DB.ContainerRemove(args.Name)
 		return nil, err
 	}
 
 	// Apply any post-storage configuration
 	err = containerConfigureInternal(c)
 	if err != nil {
-		c.Delete()
 		return nil, err
 	}
 
+	revert.Success()
 	return c, nil
 }
 
 func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContainer container, containerOnly bool) (container, error) {
+	revert := revertSteps{}
+	defer revert.Fail()
+
 	// Create the container.
 	ct, err := containerCreateInternal(s, args)
 	if err != nil {
 		return nil, err
 	}
+	revert.Add(func() { ct.Delete() })
 
 	csList := []*container{}
 	if !containerOnly {
 		snapshots, err := sourceContainer.Snapshots()
 		if err != nil {
-			s.DB.ContainerRemove(args.Name)
 			return nil, err
 		}
 
@@ -649,9 +999,21 @@ func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContaine
 		for i, snap := range snapshots {
 			fields := strings.SplitN(snap.Name(), shared.SnapshotDelimiter, 2)
 			newSnapName := fmt.Sprintf("%s/%s", ct.Name(), fields[1])
+
+			// Don't carry over the MAC addresses of the source, the
+			// copy will get its own on first start.
+			snapConfig := map[string]string{}
+			for k, v := range snap.LocalConfig() {
+				if strings.HasPrefix(k, "volatile.") && strings.HasSuffix(k, ".hwaddr") {
+					continue
+				}
+
+				snapConfig[k] = v
+			}
+
 			csArgs := db.ContainerArgs{
 				Architecture: snap.Architecture(),
-				Config:       snap.LocalConfig(),
+				Config:       snapConfig,
 				Ctype:        db.CTypeSnapshot,
 				Devices:      snap.LocalDevices(),
 				Ephemeral:    snap.IsEphemeral(),
@@ -664,6 +1026,11 @@ func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContaine
 			if err != nil {
 				return nil, err
 			}
+			revert.Add(func() {
+				s.DB.ContainerRemove(newSnapName)
+				containerCache.Forget(newSnapName)
+			})
+			revert.Add(func() { cs.Delete() })
 
 			csList[i] = &cs
 		}
@@ -672,17 +1039,12 @@ func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContaine
 	// Now clone the storage.
 	err = ct.Storage().ContainerCopy(ct, sourceContainer, containerOnly)
 	if err != nil {
-		for _, v := range csList {
-			s.DB.ContainerRemove((*v).Name())
-		}
-		s.DB.ContainerRemove(args.Name)
 		return nil, err
 	}
 
 	// Apply any post-storage configuration.
 	err = containerConfigureInternal(ct)
 	if err != nil {
-		ct.Delete()
 		return nil, err
 	}
 
@@ -691,16 +1053,19 @@ func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContaine
 			// Apply any post-storage configuration.
 			err = containerConfigureInternal(*cs)
 			if err != nil {
-				(*cs).Delete()
 				return nil, err
 			}
 		}
 	}
 
+	revert.Success()
 	return ct, nil
 }
 
 func containerCreateAsSnapshot(s *state.State, args db.ContainerArgs, sourceContainer container) (container, error) {
+	revert := revertSteps{}
+	defer revert.Fail()
+
 	// Deal with state
 	if args.Stateful {
 		if !sourceContainer.IsRunning() {
@@ -717,6 +1082,7 @@ func containerCreateAsSnapshot(s *state.State, args db.ContainerArgs, sourceCont
 		if err != nil {
 			return nil, err
 		}
+		revert.Add(func() { os.RemoveAll(stateDir) })
 
 		/* TODO: ideally we would freeze here and unfreeze below after
 		 * we've copied the filesystem, to make sure there are no
@@ -740,21 +1106,25 @@ func containerCreateAsSnapshot(s *state.State, args db.ContainerArgs, sourceCont
 
 		err = sourceContainer.Migrate(&criuMigrationArgs)
 		if err != nil {
-			os.RemoveAll(sourceContainer.StatePath())
 			return nil, err
 		}
 	}
 
-	// Create the snapshot
+	// Create the snapshot. Deleting it fails if its storage wasn't
+	// created, so make sure its record goes away anyway.
 	c, err := containerCreateInternal(s, args)
 	if err != nil {
 		return nil, err
 	}
+	revert.Add(func() {
+		s.DB.ContainerRemove(args.Name)
+		containerCache.Forget(args.Name)
+	})
+	revert.Add(func() { c.Delete() })
 
 	// Clone the container
 	err = sourceContainer.Storage().ContainerSnapshotCreate(c, sourceContainer)
 	if err != nil {
-		s.DB.ContainerRemove(args.Name)
 		return nil, err
 	}
 
@@ -768,7 +1138,6 @@ func containerCreateAsSnapshot(s *state.State, args db.ContainerArgs, sourceCont
 
 	err = writeBackupFile(sourceContainer)
 	if err != nil {
-		c.Delete()
 		return nil, err
 	}
 
@@ -777,6 +1146,7 @@ func containerCreateAsSnapshot(s *state.State, args db.ContainerArgs, sourceCont
 		os.RemoveAll(sourceContainer.StatePath())
 	}
 
+	revert.Success()
 	return c, nil
 }
 
@@ -844,6 +1214,9 @@ func containerCreateInternal(s *state.State, args db.ContainerArgs) (container,
 		}
 	}
 
+	revert := revertSteps{}
+	defer revert.Fail()
+
 	// Create the container entry
 	id, err := s.DB.ContainerCreate(args)
 	if err != nil {
@@ -856,6 +1229,10 @@ func containerCreateInternal(s *state.State, args db.ContainerArgs) (container,
 		}
 		return nil, err
 	}
+	revert.Add(func() {
+		s.DB.ContainerRemove(args.Name)
+		containerCache.Forget(args.Name)
+	})
 
 	// Wipe any existing log for this container name
 	os.RemoveAll(shared.LogPath(args.Name))
@@ -865,7 +1242,6 @@ func containerCreateInternal(s *state.State, args db.ContainerArgs) (container,
 	// Read the timestamp from the database
 	dbArgs, err := s.DB.ContainerGet(args.Name)
 	if err != nil {
-		s.DB.ContainerRemove(args.Name)
 		return nil, err
 	}
 	args.CreationDate = dbArgs.CreationDate
@@ -874,10 +1250,10 @@ func containerCreateInternal(s *state.State, args db.ContainerArgs) (container,
 	// Setup the container struct and finish creation (storage and idmap)
 	c, err := containerLXCCreate(s, args)
 	if err != nil {
-		s.DB.ContainerRemove(args.Name)
 		return nil, err
 	}
 
+	revert.Success()
 	return c, nil
 }
 
@@ -938,11 +1314,27 @@ func containerLoadById(s *state.State, id int) (container, error) {
 }
 
 func containerLoadByName(s *state.State, name string) (container, error) {
+	entry, generation := containerCache.Get(name)
+	if entry != nil {
+		return containerLXCLoadCached(s, *entry), nil
+	}
+
 	// Get the DB record
 	args, err := s.DB.ContainerGet(name)
 	if err != nil {
 		return nil, err
 	}
 
-	return containerLXCLoad(s, args)
+	c, err := containerLXCLoad(s, args)
+	if err != nil {
+		return nil, err
+	}
+
+	containerCache.Add(generation, containerLoadCacheEntry{
+		args:            args,
+		expandedConfig:  c.ExpandedConfig(),
+		expandedDevices: c.ExpandedDevices(),
+	})
+
+	return c, nil
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+)
+
+// Cache of the database records of the containers and of their configuration
+// expanded with their profiles, by name, so that loading a container for an API
+// request doesn't need to query the database every time.
+//
+// The entries must be dropped whenever the records of a container or of the
+// profiles change, with Forget or Clear.
+var containerCache = newContainerLoadCache()
+
+type containerLoadCache struct {
+	lock sync.Mutex
+
+	entries map[string]containerLoadCacheEntry
+
+	// Increased every time entries are dropped, so that records read from
+	// the database before they changed don't get added back.
+	generation uint64
+}
+
+type containerLoadCacheEntry struct {
+	args            db.ContainerArgs
+	expandedConfig  map[string]string
+	expandedDevices types.Devices
+}
+
+func newContainerLoadCache() *containerLoadCache {
+	return &containerLoadCache{entries: map[string]containerLoadCacheEntry{}}
+}
+
+// Get returns a copy of the cached entry of the given container, if any, and
+// the current generation of the cache, to pass to Add after loading the
+// records from the database.
+func (c *containerLoadCache) Get(name string) (*containerLoadCacheEntry, uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[name]
+	if !ok {
+		return nil, c.generation
+	}
+
+	entry = entry.copy()
+	return &entry, c.generation
+}
+
+// Add caches the records of a container loaded from the database, unless
+// entries were dropped since the given generation.
+func (c *containerLoadCache) Add(generation uint64, entry containerLoadCacheEntry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	c.entries[entry.args.Name] = entry.copy()
+}
+
+// Forget drops the entries of the given container and of its snapshots.
+func (c *containerLoadCache) Forget(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.generation++
+	for key := range c.entries {
+		if key == name || strings.HasPrefix(key, name+shared.SnapshotDelimiter) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Clear drops all the entries, for changes affecting any container, like the
+// ones to profiles.
+func (c *containerLoadCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.generation++
+	c.entries = map[string]containerLoadCacheEntry{}
+}
+
+// Return a deep copy of the entry, since the containers modify their
+// configuration in place.
+func (e containerLoadCacheEntry) copy() containerLoadCacheEntry {
+	args := e.args
+	args.Config = copyStringMap(e.args.Config)
+	args.Devices = copyDevices(e.args.Devices)
+	args.Profiles = append([]string{}, e.args.Profiles...)
+
+	return containerLoadCacheEntry{
+		args:            args,
+		expandedConfig:  copyStringMap(e.expandedConfig),
+		expandedDevices: copyDevices(e.expandedDevices),
+	}
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	result := map[string]string{}
+	for k, v := range m {
+		result[k] = v
+	}
+
+	return result
+}
+
+func copyDevices(devices types.Devices) types.Devices {
+	if devices == nil {
+		return nil
+	}
+
+	result := types.Devices{}
+	for name, device := range devices {
+		result[name] = copyStringMap(device)
+	}
+
+	return result
+}
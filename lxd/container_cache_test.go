@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+)
+
+func newTestContainerLoadCacheEntry(name string) containerLoadCacheEntry {
+	return containerLoadCacheEntry{
+		args: db.ContainerArgs{
+			Name:     name,
+			Config:   map[string]string{"limits.cpu": "1"},
+			Devices:  types.Devices{"root": {"type": "disk", "path": "/"}},
+			Profiles: []string{"default"},
+		},
+		expandedConfig:  map[string]string{"limits.cpu": "1"},
+		expandedDevices: types.Devices{"root": {"type": "disk", "path": "/"}},
+	}
+}
+
+// The cached entries are copies, which can be modified by the callers.
+func TestContainerLoadCache_Get(t *testing.T) {
+	cache := newContainerLoadCache()
+
+	entry, generation := cache.Get("c1")
+	assert.Nil(t, entry)
+
+	cache.Add(generation, newTestContainerLoadCacheEntry("c1"))
+
+	entry, _ = cache.Get("c1")
+	require.NotNil(t, entry)
+	entry.args.Config["limits.cpu"] = "2"
+	entry.expandedDevices["root"]["path"] = "/foo"
+
+	entry, _ = cache.Get("c1")
+	require.NotNil(t, entry)
+	assert.Equal(t, "1", entry.args.Config["limits.cpu"])
+	assert.Equal(t, "/", entry.expandedDevices["root"]["path"])
+}
+
+// Records loaded before entries got dropped are not cached.
+func TestContainerLoadCache_AddStale(t *testing.T) {
+	cache := newContainerLoadCache()
+
+	_, generation := cache.Get("c1")
+	cache.Forget("c1")
+	cache.Add(generation, newTestContainerLoadCacheEntry("c1"))
+
+	entry, _ := cache.Get("c1")
+	assert.Nil(t, entry)
+}
+
+// Forgetting a container drops its snapshots too, but not the other
+// containers.
+func TestContainerLoadCache_Forget(t *testing.T) {
+	cache := newContainerLoadCache()
+
+	for _, name := range []string{"c1", "c1/snap0", "c10"} {
+		_, generation := cache.Get(name)
+		cache.Add(generation, newTestContainerLoadCacheEntry(name))
+	}
+
+	cache.Forget("c1")
+
+	entry, _ := cache.Get("c1")
+	assert.Nil(t, entry)
+	entry, _ = cache.Get("c1/snap0")
+	assert.Nil(t, entry)
+	entry, _ = cache.Get("c10")
+	assert.NotNil(t, entry)
+
+	cache.Clear()
+
+	entry, _ = cache.Get("c10")
+	assert.Nil(t, entry)
+}
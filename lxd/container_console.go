@@ -78,6 +78,7 @@ func (s *consoleWs) Connect(op *operation, r *http.Request, w http.ResponseWrite
 			if err != nil {
 				return err
 			}
+			shared.WebsocketKeepAlive(conn, true)
 
 			s.connsLock.Lock()
 			s.conns[fd] = conn
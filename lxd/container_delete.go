@@ -18,8 +18,15 @@ func containerDelete(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("container is running"))
 	}
 
+	requestor := eventRequestor(r)
 	rmct := func(op *operation) error {
-		return c.Delete()
+		err := c.Delete()
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-deleted", eventContainerSource(name), nil, requestor)
+		return nil
 	}
 
 	resources := map[string][]string{}
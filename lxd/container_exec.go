@@ -12,6 +12,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
@@ -28,9 +29,14 @@ type execWs struct {
 	command   []string
 	container container
 	env       map[string]string
-
-	rootUid          int64
-	rootGid          int64
+	cwd       string
+	uid       uint32
+	gid       uint32
+	timeout   int
+	requestor *api.EventLifecycleRequestor
+
+	ptyUid           int64
+	ptyGid           int64
 	conns            map[int]*websocket.Conn
 	connsLock        sync.Mutex
 	allConnected     chan bool
@@ -39,6 +45,15 @@ type execWs struct {
 	fds              map[int]string
 	width            int
 	height           int
+
+	// Detachable sessions keep running when the client's connection drops,
+	// and the client can attach to them again.
+	detachable bool
+	running    bool
+	finished   bool
+	pid        int
+	reattach   chan *websocket.Conn
+	detached   chan bool
 }
 
 func (s *execWs) Metadata() interface{} {
@@ -66,8 +81,22 @@ func (s *execWs) Connect(op *operation, r *http.Request, w http.ResponseWriter)
 			if err != nil {
 				return err
 			}
+			shared.WebsocketKeepAlive(conn, true)
 
 			s.connsLock.Lock()
+			if s.finished {
+				s.connsLock.Unlock()
+				conn.Close()
+				return fmt.Errorf("The command is done running")
+			}
+
+			if s.detachable && s.running {
+				old := s.conns[fd]
+				s.conns[fd] = conn
+				s.connsLock.Unlock()
+
+				return s.attachAgain(fd, old, conn)
+			}
 			s.conns[fd] = conn
 			s.connsLock.Unlock()
 
@@ -95,9 +124,44 @@ func (s *execWs) Connect(op *operation, r *http.Request, w http.ResponseWriter)
 	return os.ErrPermission
 }
 
+// Attach a new connection of the client to a running detachable session, in
+// place of the old one.
+func (s *execWs) attachAgain(fd int, old *websocket.Conn, conn *websocket.Conn) error {
+	logger.Debugf("Attaching again to exec session, fd %d", fd)
+
+	if old != nil {
+		old.Close()
+	}
+
+	// The session may end while waiting for it to take the connection
+	if fd == -1 {
+		select {
+		case s.controlConnected <- true:
+		case <-s.detached:
+			conn.Close()
+			return fmt.Errorf("The command is done running")
+		}
+
+		return nil
+	}
+
+	select {
+	case s.reattach <- conn:
+	case <-s.detached:
+		conn.Close()
+		return fmt.Errorf("The command is done running")
+	}
+
+	return nil
+}
+
 func (s *execWs) Do(op *operation) error {
 	<-s.allConnected
 
+	s.connsLock.Lock()
+	s.running = true
+	s.connsLock.Unlock()
+
 	var err error
 	var ttys []*os.File
 	var ptys []*os.File
@@ -109,7 +173,7 @@ func (s *execWs) Do(op *operation) error {
 	if s.interactive {
 		ttys = make([]*os.File, 1)
 		ptys = make([]*os.File, 1)
-		ptys[0], ttys[0], err = shared.OpenPty(s.rootUid, s.rootGid)
+		ptys[0], ttys[0], err = shared.OpenPty(s.ptyUid, s.ptyGid)
 		if err != nil {
 			return err
 		}
@@ -118,8 +182,16 @@ func (s *execWs) Do(op *operation) error {
 		stdout = ttys[0]
 		stderr = ttys[0]
 
-		if s.width > 0 && s.height > 0 {
-			shared.SetSize(int(ptys[0].Fd()), s.width, s.height)
+		// Create the terminal at the size of the client's one, so that
+		// the command's first output fits it
+		width, height := s.width, s.height
+		if width == 0 || height == 0 {
+			width, height = execDefaultWidth, execDefaultHeight
+		}
+
+		err = shared.SetSize(int(ptys[0].Fd()), width, height)
+		if err != nil {
+			logger.Debugf("Failed to set window size to: %dx%d", width, height)
 		}
 	} else {
 		ttys = make([]*os.File, 3)
@@ -141,90 +213,114 @@ func (s *execWs) Do(op *operation) error {
 	attachedChildIsDead := make(chan bool, 1)
 	var wgEOF sync.WaitGroup
 
-	if s.interactive {
-		wgEOF.Add(1)
-		go func() {
-			attachedChildPid := <-attachedChildIsBorn
-			select {
-			case <-s.controlConnected:
-				break
+	// The control socket is used to forward signals, as well as window size
+	// changes in interactive mode
+	go func() {
+		attachedChildPid := <-attachedChildIsBorn
+		select {
+		case <-s.controlConnected:
+			break
 
-			case <-controlExit:
-				return
+		case <-controlExit:
+			return
+		}
+
+		for {
+			s.connsLock.Lock()
+			conn := s.conns[-1]
+			s.connsLock.Unlock()
+
+			mt, r, err := conn.NextReader()
+			if s.detachable && (mt == websocket.CloseMessage || err != nil) {
+				// Wait for the client to attach again
+				select {
+				case <-s.controlConnected:
+					continue
+				case <-controlExit:
+					return
+				}
 			}
 
-			for {
-				s.connsLock.Lock()
-				conn := s.conns[-1]
-				s.connsLock.Unlock()
+			if mt == websocket.CloseMessage {
+				break
+			}
 
-				mt, r, err := conn.NextReader()
-				if mt == websocket.CloseMessage {
+			if err != nil {
+				logger.Debugf("Got error getting next reader %s", err)
+				er, ok := err.(*websocket.CloseError)
+				abnormal := ok && er.Code == websocket.CloseAbnormalClosure
+				if !abnormal && !shared.IsWebsocketTimeout(err) {
 					break
 				}
 
+				// If an abnormal closure occurred, or the client stopped
+				// answering pings, kill the attached process.
+				err := syscall.Kill(attachedChildPid, syscall.SIGKILL)
 				if err != nil {
-					logger.Debugf("Got error getting next reader %s", err)
-					er, ok := err.(*websocket.CloseError)
-					if !ok {
-						break
-					}
-
-					if er.Code != websocket.CloseAbnormalClosure {
-						break
-					}
-
-					// If an abnormal closure occurred, kill the attached process.
-					err := syscall.Kill(attachedChildPid, syscall.SIGKILL)
-					if err != nil {
-						logger.Debugf("Failed to send SIGKILL to pid %d.", attachedChildPid)
-					} else {
-						logger.Debugf("Sent SIGKILL to pid %d.", attachedChildPid)
-					}
-					return
+					logger.Debugf("Failed to send SIGKILL to pid %d.", attachedChildPid)
+				} else {
+					logger.Debugf("Sent SIGKILL to pid %d.", attachedChildPid)
 				}
+				return
+			}
 
-				buf, err := ioutil.ReadAll(r)
-				if err != nil {
-					logger.Debugf("Failed to read message %s", err)
-					break
+			buf, err := ioutil.ReadAll(r)
+			if err != nil {
+				logger.Debugf("Failed to read message %s", err)
+				break
+			}
+
+			command := api.ContainerExecControl{}
+
+			if err := json.Unmarshal(buf, &command); err != nil {
+				logger.Debugf("Failed to unmarshal control socket command: %s", err)
+				continue
+			}
+
+			if command.Command == "window-resize" {
+				if !s.interactive {
+					continue
 				}
 
-				command := api.ContainerExecControl{}
+				winchWidth, err := strconv.Atoi(command.Args["width"])
+				if err != nil {
+					logger.Debugf("Unable to extract window width: %s", err)
+					continue
+				}
 
-				if err := json.Unmarshal(buf, &command); err != nil {
-					logger.Debugf("Failed to unmarshal control socket command: %s", err)
+				winchHeight, err := strconv.Atoi(command.Args["height"])
+				if err != nil {
+					logger.Debugf("Unable to extract window height: %s", err)
 					continue
 				}
 
-				if command.Command == "window-resize" {
-					winchWidth, err := strconv.Atoi(command.Args["width"])
-					if err != nil {
-						logger.Debugf("Unable to extract window width: %s", err)
-						continue
-					}
-
-					winchHeight, err := strconv.Atoi(command.Args["height"])
-					if err != nil {
-						logger.Debugf("Unable to extract window height: %s", err)
-						continue
-					}
-
-					err = shared.SetSize(int(ptys[0].Fd()), winchWidth, winchHeight)
-					if err != nil {
-						logger.Debugf("Failed to set window size to: %dx%d", winchWidth, winchHeight)
-						continue
-					}
-				} else if command.Command == "signal" {
-					if err := syscall.Kill(attachedChildPid, syscall.Signal(command.Signal)); err != nil {
-						logger.Debugf("Failed forwarding signal '%s' to PID %d.", command.Signal, attachedChildPid)
-						continue
-					}
-					logger.Debugf("Forwarded signal '%d' to PID %d.", command.Signal, attachedChildPid)
+				err = shared.SetSize(int(ptys[0].Fd()), winchWidth, winchHeight)
+				if err != nil {
+					logger.Debugf("Failed to set window size to: %dx%d", winchWidth, winchHeight)
+					continue
+				}
+			} else if command.Command == "signal" {
+				if err := syscall.Kill(attachedChildPid, syscall.Signal(command.Signal)); err != nil {
+					logger.Debugf("Failed forwarding signal '%d' to PID %d.", command.Signal, attachedChildPid)
+					continue
 				}
+				logger.Debugf("Forwarded signal '%d' to PID %d.", command.Signal, attachedChildPid)
 			}
-		}()
+		}
+	}()
 
+	if s.interactive && s.detachable {
+		wgEOF.Add(1)
+		go func() {
+			s.connsLock.Lock()
+			conn := s.conns[0]
+			s.connsLock.Unlock()
+
+			s.mirrorDetachable(conn, ptys[0], attachedChildIsDead)
+			wgEOF.Done()
+		}()
+	} else if s.interactive {
+		wgEOF.Add(1)
 		go func() {
 			s.connsLock.Lock()
 			conn := s.conns[0]
@@ -265,22 +361,22 @@ func (s *execWs) Do(op *operation) error {
 		}
 	}
 
+	timedOut := false
 	finisher := func(cmdResult int, cmdErr error) error {
 		for _, tty := range ttys {
 			tty.Close()
 		}
 
 		s.connsLock.Lock()
+		s.running = false
+		s.finished = true
 		conn := s.conns[-1]
 		s.connsLock.Unlock()
 
-		if conn == nil {
-			if s.interactive {
-				controlExit <- true
-			}
-		} else {
+		if conn != nil {
 			conn.Close()
 		}
+		close(controlExit)
 
 		attachedChildIsDead <- true
 
@@ -291,6 +387,12 @@ func (s *execWs) Do(op *operation) error {
 		}
 
 		metadata := shared.Jmap{"return": cmdResult}
+		if timedOut {
+			metadata["timed_out"] = true
+		}
+
+		execRecord(s.container, s.command, s.uid, s.requestor, cmdResult, timedOut)
+
 		err = op.UpdateMetadata(metadata)
 		if err != nil {
 			return err
@@ -299,16 +401,21 @@ func (s *execWs) Do(op *operation) error {
 		return cmdErr
 	}
 
-	cmd, _, attachedPid, err := s.container.Exec(s.command, s.env, stdin, stdout, stderr, false)
+	cmd, _, attachedPid, err := s.container.Exec(s.command, s.env, stdin, stdout, stderr, false, s.cwd, s.uid, s.gid)
 	if err != nil {
+		execRecord(s.container, s.command, s.uid, s.requestor, -1, false)
 		return err
 	}
 
-	if s.interactive {
-		attachedChildIsBorn <- attachedPid
-	}
+	attachedChildIsBorn <- attachedPid
+
+	s.connsLock.Lock()
+	s.pid = attachedPid
+	s.connsLock.Unlock()
 
+	stopTimeout := execTimeout(attachedPid, s.timeout)
 	err = cmd.Wait()
+	timedOut = stopTimeout()
 	if err == nil {
 		return finisher(0, nil)
 	}
@@ -329,6 +436,194 @@ func (s *execWs) Do(op *operation) error {
 	return finisher(-1, nil)
 }
 
+// Size of the terminal of interactive sessions for which the client didn't
+// give its own
+const execDefaultWidth = 80
+const execDefaultHeight = 24
+
+// Maximum amount of output of a detachable session kept while no client is
+// attached to it, to be sent when one attaches again.
+const execMissedOutputSize = 128 * 1024
+
+// Delay after which the command of a detachable session no client attached
+// to again gets killed, ending the operation.
+var execDetachTimeout = 10 * time.Minute
+
+// Mirror the pty of a detachable session to the connection of the client
+// attached to it, starting with the given one, until the command exits. The
+// pty is kept open when the connection drops, so that the client can attach
+// again with a new connection and get the latest output it missed, unless it
+// doesn't within execDetachTimeout.
+func (s *execWs) mirrorDetachable(conn *websocket.Conn, pty *os.File, exited chan bool) {
+	in := shared.ExecReaderToChannel(pty, -1, exited, int(pty.Fd()))
+	dropped := make(chan *websocket.Conn)
+	missed := []byte{}
+
+	// Running while no client is attached
+	var detachTimer *time.Timer
+	var detachTimeout <-chan time.Time
+
+	detach := func() {
+		conn.Close()
+		conn = nil
+
+		detachTimer = time.NewTimer(execDetachTimeout)
+		detachTimeout = detachTimer.C
+	}
+
+	// Copy the input of the connection to the pty, until it drops
+	attach := func(conn *websocket.Conn) {
+		go func() {
+			if !execMirrorInput(conn, pty) {
+				return
+			}
+
+			select {
+			case dropped <- conn:
+			case <-s.detached:
+			}
+		}()
+	}
+
+	send := func(conn *websocket.Conn, buf []byte) error {
+		w, err := conn.NextWriter(websocket.BinaryMessage)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(buf)
+		w.Close()
+		return err
+	}
+
+	logger.Debugf("Starting to mirror detachable websocket")
+	attach(conn)
+
+	for {
+		select {
+		case buf, ok := <-in:
+			if !ok {
+				if conn != nil {
+					conn.WriteMessage(websocket.TextMessage, []byte{})
+					closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+					conn.WriteMessage(websocket.CloseMessage, closeMsg)
+					conn.Close()
+				}
+
+				if detachTimer != nil {
+					detachTimer.Stop()
+				}
+
+				pty.Close()
+				close(s.detached)
+				logger.Debugf("Finished to mirror detachable websocket")
+				return
+			}
+
+			if conn != nil {
+				err := send(conn, buf)
+				if err == nil {
+					continue
+				}
+
+				logger.Debugf("Client detached from exec session: %s", err)
+				detach()
+			}
+
+			missed = append(missed, buf...)
+			if len(missed) > execMissedOutputSize {
+				missed = missed[len(missed)-execMissedOutputSize:]
+			}
+
+		case c := <-dropped:
+			if c == conn {
+				logger.Debugf("Client detached from exec session")
+				detach()
+			}
+
+		case c := <-s.reattach:
+			if detachTimer != nil {
+				detachTimer.Stop()
+				detachTimer = nil
+				detachTimeout = nil
+			}
+
+			conn = c
+			attach(conn)
+
+			if len(missed) > 0 {
+				err := send(conn, missed)
+				if err != nil {
+					detach()
+					continue
+				}
+
+				missed = []byte{}
+			}
+
+		case <-detachTimeout:
+			detachTimer = nil
+			detachTimeout = nil
+
+			// Killing the command ends the session, closing the
+			// pty and finishing the operation
+			s.connsLock.Lock()
+			pid := s.pid
+			s.connsLock.Unlock()
+
+			if pid > 0 {
+				logger.Debugf("No client attached to exec session, sending SIGKILL to PID %d.", pid)
+				syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+	}
+}
+
+// Copy the input received on the given connection to the pty, until the
+// client is done sending it, in which case it returns false, or the
+// connection drops, in which case it returns true.
+func execMirrorInput(conn *websocket.Conn, pty *os.File) bool {
+	for {
+		mt, r, err := conn.NextReader()
+		if err != nil {
+			return true
+		}
+
+		if mt == websocket.CloseMessage {
+			return true
+		}
+
+		if mt == websocket.TextMessage {
+			return false
+		}
+
+		buf, err := ioutil.ReadAll(r)
+		if err != nil {
+			return true
+		}
+
+		_, err = pty.Write(buf)
+		if err != nil {
+			logger.Debugf("Error writing to the pty: %s", err)
+			return false
+		}
+	}
+}
+
+// execGroup returns the group to run a command as: the given one or, if none
+// was given, the primary group of the user from its passwd entry (if found).
+func execGroup(group *uint32, entry *passwdEntry) uint32 {
+	if group != nil {
+		return *group
+	}
+
+	if entry == nil {
+		return 0
+	}
+
+	return entry.gid
+}
+
 func containerExecPost(d *Daemon, r *http.Request) Response {
 	name := mux.Vars(r)["name"]
 	c, err := containerLoadByName(d.State(), name)
@@ -354,6 +649,22 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	if post.Timeout < 0 {
+		return BadRequest(fmt.Errorf("Invalid timeout: %d", post.Timeout))
+	}
+
+	if post.Width < 0 || post.Height < 0 {
+		return BadRequest(fmt.Errorf("Invalid terminal size: %dx%d", post.Width, post.Height))
+	}
+
+	if post.Detachable && (!post.Interactive || !post.WaitForWS) {
+		return BadRequest(fmt.Errorf("Only interactive sessions with websockets can be detachable"))
+	}
+
+	if post.Shell && len(post.Command) > 0 {
+		return BadRequest(fmt.Errorf("No command can be given in shell mode"))
+	}
+
 	env := map[string]string{}
 
 	for k, v := range c.ExpandedConfig() {
@@ -377,24 +688,87 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		}
 	}
 
-	// Set default value for HOME
-	_, ok = env["HOME"]
-	if !ok {
-		env["HOME"] = "/root"
+	// The passwd entry of the user running the command, for the defaults
+	// below
+	_, hasHome := env["HOME"]
+	_, hasUser := env["USER"]
+	var entry *passwdEntry
+	if post.Shell || !hasHome || !hasUser || (post.Group == nil && post.User != 0) {
+		entry, err = containerPasswdLookup(c, post.User)
+		if err != nil {
+			logger.Debugf("Failed to look up user %d in container %s: %s", post.User, c.Name(), err)
+		}
 	}
 
-	// Set default value for USER
-	_, ok = env["USER"]
-	if !ok {
-		env["USER"] = "root"
+	// Run the command as part of the primary group of the user unless
+	// told otherwise
+	gid := execGroup(post.Group, entry)
+
+	// In shell mode, start the login shell of the user
+	if post.Shell {
+		shell := "/bin/sh"
+		if entry != nil && entry.shell != "" {
+			shell = entry.shell
+		}
+
+		post.Command = []string{shell, "-l"}
+
+		_, ok := env["SHELL"]
+		if !ok {
+			env["SHELL"] = shell
+		}
+	}
+
+	// Set default values for HOME and USER, from the passwd entry of the
+	// user running the command
+	if !hasHome || !hasUser {
+		home := "/"
+		user := ""
+		if post.User == 0 {
+			home = "/root"
+			user = "root"
+		}
+
+		if entry != nil {
+			home = entry.home
+			user = entry.name
+		}
+
+		if !hasHome {
+			env["HOME"] = home
+		}
+
+		if !hasUser && user != "" {
+			env["USER"] = user
+		}
 	}
 
-	// Set default value for USER
+	// Login shells also get LOGNAME
+	_, ok = env["LOGNAME"]
+	if !ok && post.Shell && env["USER"] != "" {
+		env["LOGNAME"] = env["USER"]
+	}
+
+	// Set default value for TERM
+	_, ok = env["TERM"]
+	if !ok && post.Interactive {
+		env["TERM"] = "xterm"
+	}
+
+	// Set default value for LANG
 	_, ok = env["LANG"]
 	if !ok {
 		env["LANG"] = "C.UTF-8"
 	}
 
+	// Run the command from the home directory unless told otherwise
+	cwd := post.Cwd
+	if cwd == "" {
+		cwd = env["HOME"]
+	}
+
+	requestor := eventRequestor(r)
+
 	if post.WaitForWS {
 		ws := &execWs{}
 		ws.fds = map[int]string{}
@@ -404,8 +778,11 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 			return InternalError(err)
 		}
 
+		// The pty belongs to the user running the command
+		ws.ptyUid = int64(post.User)
+		ws.ptyGid = int64(gid)
 		if idmapset != nil {
-			ws.rootUid, ws.rootGid = idmapset.ShiftIntoNs(0, 0)
+			ws.ptyUid, ws.ptyGid = idmapset.ShiftIntoNs(ws.ptyUid, ws.ptyGid)
 		}
 
 		ws.conns = map[int]*websocket.Conn{}
@@ -428,6 +805,14 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		ws.command = post.Command
 		ws.container = c
 		ws.env = env
+		ws.cwd = cwd
+		ws.uid = post.User
+		ws.gid = gid
+		ws.timeout = post.Timeout
+		ws.requestor = requestor
+		ws.detachable = post.Detachable
+		ws.reattach = make(chan *websocket.Conn)
+		ws.detached = make(chan bool)
 
 		ws.width = post.Width
 		ws.height = post.Height
@@ -446,24 +831,25 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 	run := func(op *operation) error {
 		var cmdErr error
 		var cmdResult int
+		var timedOut bool
 		metadata := shared.Jmap{}
 
 		if post.RecordOutput {
 			// Prepare stdout and stderr recording
-			stdout, err := os.OpenFile(filepath.Join(c.LogPath(), fmt.Sprintf("exec_%s.stdout", op.id)), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+			stdout, err := os.OpenFile(filepath.Join(c.LogPath(), fmt.Sprintf("exec_%s.stdout", op.id)), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 			if err != nil {
 				return err
 			}
 			defer stdout.Close()
 
-			stderr, err := os.OpenFile(filepath.Join(c.LogPath(), fmt.Sprintf("exec_%s.stderr", op.id)), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+			stderr, err := os.OpenFile(filepath.Join(c.LogPath(), fmt.Sprintf("exec_%s.stderr", op.id)), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 			if err != nil {
 				return err
 			}
 			defer stderr.Close()
 
 			// Run the command
-			_, cmdResult, _, cmdErr = c.Exec(post.Command, env, nil, stdout, stderr, true)
+			cmdResult, timedOut, cmdErr = execRun(c, post, env, cwd, stdout, stderr)
 
 			// Update metadata with the right URLs
 			metadata["return"] = cmdResult
@@ -472,10 +858,16 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 				"2": fmt.Sprintf("/%s/containers/%s/logs/%s", version.APIVersion, c.Name(), filepath.Base(stderr.Name())),
 			}
 		} else {
-			_, cmdResult, _, cmdErr = c.Exec(post.Command, env, nil, nil, nil, true)
+			cmdResult, timedOut, cmdErr = execRun(c, post, env, cwd, nil, nil)
 			metadata["return"] = cmdResult
 		}
 
+		if timedOut {
+			metadata["timed_out"] = true
+		}
+
+		execRecord(c, post.Command, post.User, requestor, cmdResult, timedOut)
+
 		err = op.UpdateMetadata(metadata)
 		if err != nil {
 			logger.Error("error updating metadata for cmd", log.Ctx{"err": err, "cmd": post.Command})
@@ -494,3 +886,111 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 
 	return OperationResponse(op)
 }
+
+// Record that a command was run in the container on behalf of the requestor,
+// with its exit status (-1 if it couldn't be started), as a lifecycle event
+// and in the audit log.
+func execRecord(c container, command []string, uid uint32, requestor *api.EventLifecycleRequestor, cmdResult int, timedOut bool) {
+	context := map[string]interface{}{
+		"command": command,
+		"user":    uid,
+		"return":  cmdResult,
+	}
+	if timedOut {
+		context["timed_out"] = true
+	}
+
+	source := eventContainerSource(c.Name())
+	eventSendLifecycle("container-exec", source, context, requestor)
+	auditRecordAction("container-exec", source, context, requestor)
+}
+
+// Run the command of a non-interactive exec request without websockets,
+// returning its exit status and whether it timed out.
+func execRun(c container, post api.ContainerExecPost, env map[string]string, cwd string, stdout *os.File, stderr *os.File) (int, bool, error) {
+	if post.Timeout == 0 {
+		_, cmdResult, _, err := c.Exec(post.Command, env, nil, stdout, stderr, true, cwd, post.User, gid)
+		return cmdResult, false, err
+	}
+
+	cmd, _, attachedPid, err := c.Exec(post.Command, env, nil, stdout, stderr, false, cwd, post.User, gid)
+	if err != nil {
+		return -1, false, err
+	}
+
+	stopTimeout := execTimeout(attachedPid, post.Timeout)
+	err = cmd.Wait()
+	timedOut := stopTimeout()
+	if err == nil {
+		return 0, timedOut, nil
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if ok {
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		if ok {
+			return status.ExitStatus(), timedOut, nil
+		}
+	}
+
+	return -1, timedOut, err
+}
+
+// Delay between the SIGTERM sent to a command which timed out and the SIGKILL
+// sent if it's still running.
+var execKillDelay = 10 * time.Second
+
+// execTimeout sends SIGTERM to the process with the given PID once the given
+// number of seconds elapsed, then SIGKILL if it's still running after
+// execKillDelay. A timeout of zero means no timeout.
+//
+// The returned function must be called once the process is done, to stop the
+// timers, and tells whether the process timed out.
+func execTimeout(pid int, timeout int) func() bool {
+	if timeout == 0 {
+		return func() bool { return false }
+	}
+
+	lock := sync.Mutex{}
+	done := false
+	timedOut := false
+	var kill *time.Timer
+
+	term := time.AfterFunc(time.Duration(timeout)*time.Second, func() {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if done {
+			return
+		}
+
+		timedOut = true
+		logger.Debugf("Command timed out, sending SIGTERM to PID %d.", pid)
+		syscall.Kill(pid, syscall.SIGTERM)
+
+		kill = time.AfterFunc(execKillDelay, func() {
+			lock.Lock()
+			defer lock.Unlock()
+
+			if done {
+				return
+			}
+
+			logger.Debugf("Command still running, sending SIGKILL to PID %d.", pid)
+			syscall.Kill(pid, syscall.SIGKILL)
+		})
+	})
+
+	return func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+
+		done = true
+		term.Stop()
+		if kill != nil {
+			kill.Stop()
+		}
+
+		return timedOut
+	}
+}
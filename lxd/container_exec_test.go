@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A process still running after the timeout gets terminated.
+func TestExecTimeout(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+
+	stop := execTimeout(cmd.Process.Pid, 1)
+	start := time.Now()
+	err := cmd.Wait()
+
+	assert.Error(t, err)
+	assert.True(t, stop())
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+// A process done before the timeout isn't signaled.
+func TestExecTimeout_Done(t *testing.T) {
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Start())
+
+	stop := execTimeout(cmd.Process.Pid, 1)
+	require.NoError(t, cmd.Wait())
+	assert.False(t, stop())
+}
+
+// Commands run as part of the primary group of their user, unless given one.
+func TestExecGroup(t *testing.T) {
+	entry, err := passwdLookup(strings.NewReader(testPasswd), 1000)
+	require.NoError(t, err)
+
+	root := uint32(0)
+	other := uint32(27)
+	assert.Equal(t, uint32(1000), execGroup(nil, entry))
+	assert.Equal(t, uint32(0), execGroup(&root, entry))
+	assert.Equal(t, uint32(27), execGroup(&other, entry))
+	assert.Equal(t, uint32(0), execGroup(nil, nil))
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/idmap"
+	"github.com/lxc/lxd/shared/osarch"
+)
+
+// containerExportIndex is stored as index.yaml at the top of the tarballs of
+// container exports. Plain exports then carry the files of each snapshot
+// under snapshots/<name>/ and the ones of the container under container/,
+// while optimized exports carry the send streams of the storage backend in
+// snapshots/<name>.bin and container.bin.
+type containerExportIndex struct {
+	Backend   string                   `yaml:"backend"`
+	Optimized bool                     `yaml:"optimized"`
+	Container *api.Container           `yaml:"container"`
+	Snapshots []*api.ContainerSnapshot `yaml:"snapshots"`
+}
+
+func containerExportGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	c, err := containerLoadByName(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if c.IsRunning() {
+		return BadRequest(fmt.Errorf("Only stopped containers can be exported"))
+	}
+
+	// Optimized exports need a backend with native send streams
+	optimized := shared.IsTrue(r.FormValue("optimized"))
+	if optimized {
+		migrationType := c.Storage().MigrationType()
+		if migrationType != MigrationFSType_ZFS && migrationType != MigrationFSType_BTRFS {
+			return BadRequest(fmt.Errorf("Optimized exports aren't supported by the %s storage driver", c.Storage().GetStorageTypeName()))
+		}
+	}
+
+	tarball, err := containerExport(c, optimized)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	files := []fileResponseEntry{{path: tarball, filename: fmt.Sprintf("%s.tar", name)}}
+	return FileResponse(r, files, nil, true)
+}
+
+// containerExport writes the export of the container and its snapshots to a
+// temporary file and returns its path.
+func containerExport(c container, optimized bool) (string, error) {
+	snapshots, err := c.Snapshots()
+	if err != nil {
+		return "", err
+	}
+
+	index := containerExportIndex{
+		Backend:   c.Storage().GetStorageTypeName(),
+		Optimized: optimized,
+	}
+
+	ct, _, err := c.Render()
+	if err != nil {
+		return "", err
+	}
+	index.Container = ct.(*api.Container)
+
+	for _, snap := range snapshots {
+		st, _, err := snap.Render()
+		if err != nil {
+			return "", err
+		}
+		index.Snapshots = append(index.Snapshots, st.(*api.ContainerSnapshot))
+	}
+
+	data, err := yaml.Marshal(&index)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile(shared.VarPath(), "lxd_export_")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	success := false
+	defer func() {
+		if !success {
+			os.Remove(f.Name())
+		}
+	}()
+
+	tw := tar.NewWriter(f)
+
+	hdr := &tar.Header{
+		Name:    "index.yaml",
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+
+	err = tw.WriteHeader(hdr)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tw.Write(data)
+	if err != nil {
+		return "", err
+	}
+
+	if optimized {
+		dir, err := ioutil.TempDir(shared.VarPath(), "lxd_export_")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(dir)
+
+		err = c.Storage().ContainerExportOptimized(c, snapshots, dir)
+		if err != nil {
+			return "", err
+		}
+
+		err = tarStoreTree(tw, dir, "")
+		if err != nil {
+			return "", err
+		}
+	} else {
+		for _, snap := range snapshots {
+			_, snapName, _ := containerGetParentAndSnapshotName(snap.Name())
+			err := containerExportFiles(tw, snap, filepath.Join("snapshots", snapName))
+			if err != nil {
+				return "", err
+			}
+		}
+
+		err = containerExportFiles(tw, c, "container")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return "", err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	success = true
+	return f.Name(), nil
+}
+
+// containerExportFiles stores the files of the container or snapshot in the
+// tarball under the given prefix, keeping their ownership as found on disk.
+func containerExportFiles(tw *tar.Writer, c container, prefix string) error {
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	path, err := filepath.EvalSymlinks(c.Path())
+	if err != nil {
+		return err
+	}
+
+	return tarStoreTree(tw, path, prefix)
+}
+
+// tarStoreTree stores the content of the directory in the tarball under the
+// given prefix.
+func tarStoreTree(tw *tar.Writer, dir string, prefix string) error {
+	linkmap := map[uint64]string{}
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Join(prefix, rel)
+		if name == "." {
+			return nil
+		}
+
+		return tarStoreFile(linkmap, name, tw, path, fi, nil)
+	})
+}
+
+// containerExportReadIndex reads the index at the top of the tarball of a
+// container export.
+func containerExportReadIndex(tarball string) (*containerExportIndex, error) {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid container export: %v", err)
+	}
+
+	if hdr.Name != "index.yaml" {
+		return nil, fmt.Errorf("Invalid container export: missing index.yaml")
+	}
+
+	data, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	index := containerExportIndex{}
+	err = yaml.Unmarshal(data, &index)
+	if err != nil {
+		return nil, err
+	}
+
+	if index.Container == nil || index.Container.Name == "" {
+		return nil, fmt.Errorf("Invalid container export: missing container")
+	}
+
+	return &index, nil
+}
+
+func createFromExport(d *Daemon, r *http.Request) Response {
+	// Save the export, its index has to be checked before it gets
+	// unpacked
+	f, err := ioutil.TempFile(shared.VarPath(), "lxd_import_")
+	if err != nil {
+		return InternalError(err)
+	}
+	tarball := f.Name()
+
+	_, err = io.Copy(f, r.Body)
+	f.Close()
+	if err != nil {
+		os.Remove(tarball)
+		return InternalError(err)
+	}
+
+	index, err := containerExportReadIndex(tarball)
+	if err != nil {
+		os.Remove(tarball)
+		return BadRequest(err)
+	}
+
+	err = containerExportValidate(d, r, index)
+	if err != nil {
+		os.Remove(tarball)
+		return BadRequest(err)
+	}
+
+	_, err = d.db.ContainerId(index.Container.Name)
+	if err == nil {
+		os.Remove(tarball)
+		return BadRequest(fmt.Errorf("Container '%s' already exists", index.Container.Name))
+	}
+
+	// Container names are unique across the cluster, exports get imported
+	// on the member they're sent to
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		os.Remove(tarball)
+		return SmartError(err)
+	}
+
+	if len(members) > 0 && !clusterIsMemberRequest(r, members) {
+		member := clusterLocate(d, members, eventContainerSource(index.Container.Name))
+		if member != nil {
+			os.Remove(tarball)
+			return BadRequest(fmt.Errorf("A container named '%s' already exists on cluster member '%s'", index.Container.Name, member.Name))
+		}
+	}
+
+	requestor := eventRequestor(r)
+	run := func(op *operation) error {
+		defer os.Remove(tarball)
+
+		err := containerImport(d, tarball, index)
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-created", eventContainerSource(index.Container.Name), nil, requestor)
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{index.Container.Name}
+
+	op, err := operationCreate(operationClassTask, resources, nil, run, nil, nil)
+	if err != nil {
+		os.Remove(tarball)
+		return InternalError(err)
+	}
+	op.opType = operationTypeContainerCreate
+
+	return OperationResponse(op)
+}
+
+// containerExportValidate checks the container and snapshots of an export the
+// way the ones of creation requests are, before anything gets unpacked. Their
+// volatile keys are dropped on import, apart from the base image.
+func containerExportValidate(d *Daemon, r *http.Request, index *containerExportIndex) error {
+	pools, err := d.db.StoragePools()
+	if err != nil || len(pools) == 0 {
+		return fmt.Errorf("No storage pool found. Please create a new storage pool.")
+	}
+
+	err = containerValidName(index.Container.Name)
+	if err != nil {
+		return err
+	}
+
+	config := containerExportConfig(index.Container.Config)
+	err = containerValidConfig(d.os, config, false, false)
+	if err != nil {
+		return err
+	}
+
+	err = containerValidDevices(d.db, index.Container.Devices, false, false)
+	if err != nil {
+		return err
+	}
+
+	err = containerCheckPrivileged(d, r, config, index.Container.Profiles)
+	if err != nil {
+		return err
+	}
+
+	for _, snap := range index.Snapshots {
+		// The snapshot names are used as paths in the tarball
+		_, snapName, isSnapshot := containerGetParentAndSnapshotName(snap.Name)
+		if !isSnapshot || snapName == "" || strings.Contains(snapName, "/") || strings.Contains(snapName, "..") {
+			return fmt.Errorf("Invalid snapshot name '%s'", snap.Name)
+		}
+
+		// Snapshots can be restored, so they're held to the same
+		// policies as their container
+		config := containerExportConfig(snap.Config)
+		err = containerValidConfig(d.os, config, false, false)
+		if err != nil {
+			return err
+		}
+
+		err = containerValidDevices(d.db, snap.Devices, false, false)
+		if err != nil {
+			return err
+		}
+
+		err = containerCheckPrivileged(d, r, config, snap.Profiles)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// containerImport creates the container and its snapshots from the tarball of
+// an export.
+func containerImport(d *Daemon, tarball string, index *containerExportIndex) error {
+	dir, err := ioutil.TempDir(shared.VarPath(), "lxd_import_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = shared.RunCommand("tar", "-C", dir, "--numeric-owner", "--xattrs", "--xattrs-include=*", "-xf", tarball)
+	if err != nil {
+		return err
+	}
+
+	// The files are still shifted for the idmap of the exported container
+	var srcIdmap *idmap.IdmapSet
+	if index.Container.Config["volatile.last_state.idmap"] != "" {
+		srcIdmap, err = idmapsetFromString(index.Container.Config["volatile.last_state.idmap"])
+		if err != nil {
+			return err
+		}
+	}
+
+	architecture, err := osarch.ArchitectureId(index.Container.Architecture)
+	if err != nil {
+		return err
+	}
+
+	args := db.ContainerArgs{
+		Architecture: architecture,
+		Config:       containerExportConfig(index.Container.Config),
+		Ctype:        db.CTypeRegular,
+		Description:  index.Container.Description,
+		Devices:      index.Container.Devices,
+		Ephemeral:    index.Container.Ephemeral,
+		Name:         index.Container.Name,
+		Profiles:     index.Container.Profiles,
+	}
+
+	c, err := containerCreateAsEmpty(d, args)
+	if err != nil {
+		return err
+	}
+
+	revert := revertSteps{}
+	defer revert.Fail()
+	revert.Add(func() { c.Delete() })
+
+	if index.Optimized {
+		if c.Storage().GetStorageTypeName() != index.Backend {
+			return fmt.Errorf("The optimized export needs a %s storage pool, not %s", index.Backend, c.Storage().GetStorageTypeName())
+		}
+
+		snapshots := []container{}
+		for _, snap := range index.Snapshots {
+			args, err := containerExportSnapshotArgs(c, snap)
+			if err != nil {
+				return err
+			}
+
+			s, err := containerCreateEmptySnapshot(d.State(), args)
+			if err != nil {
+				return err
+			}
+			snapshots = append(snapshots, s)
+		}
+
+		err = c.Storage().ContainerImportOptimized(c, snapshots, dir)
+		if err != nil {
+			return err
+		}
+	} else {
+		ourStart, err := c.StorageStart()
+		if err != nil {
+			return err
+		}
+		if ourStart {
+			defer c.StorageStop()
+		}
+
+		// Snapshots are made from the container, getting the files of
+		// each one in turn
+		for _, snap := range index.Snapshots {
+			_, snapName, _ := containerGetParentAndSnapshotName(snap.Name)
+			output, err := rsyncLocalCopy(filepath.Join(dir, "snapshots", snapName), c.Path(), "")
+			if err != nil {
+				return fmt.Errorf("Failed to rsync snapshot: %s: %s", output, err)
+			}
+
+			args, err := containerExportSnapshotArgs(c, snap)
+			if err != nil {
+				return err
+			}
+
+			_, err = containerCreateAsSnapshot(d.State(), args, c)
+			if err != nil {
+				return err
+			}
+		}
+
+		output, err := rsyncLocalCopy(filepath.Join(dir, "container"), c.Path(), "")
+		if err != nil {
+			return fmt.Errorf("Failed to rsync container: %s: %s", output, err)
+		}
+	}
+
+	err = ShiftIfNecessary(c, srcIdmap)
+	if err != nil {
+		return err
+	}
+
+	revert.Success()
+	return nil
+}
+
+// containerExportSnapshotArgs returns the arguments to create the exported
+// snapshot for the given container. Its state isn't part of exports, so it's
+// never stateful.
+func containerExportSnapshotArgs(c container, snap *api.ContainerSnapshot) (db.ContainerArgs, error) {
+	architecture, err := osarch.ArchitectureId(snap.Architecture)
+	if err != nil {
+		return db.ContainerArgs{}, err
+	}
+
+	_, snapName, _ := containerGetParentAndSnapshotName(snap.Name)
+	args := db.ContainerArgs{
+		Architecture: architecture,
+		Config:       containerExportConfig(snap.Config),
+		Ctype:        db.CTypeSnapshot,
+		Devices:      snap.Devices,
+		Ephemeral:    snap.Ephemeral,
+		Name:         fmt.Sprintf("%s%s%s", c.Name(), shared.SnapshotDelimiter, snapName),
+		Profiles:     snap.Profiles,
+	}
+
+	// Keep the root disk of the snapshot on the storage pool of its
+	// container
+	rootDiskDeviceKey, _, _ := containerGetRootDiskDevice(args.Devices)
+	if rootDiskDeviceKey != "" {
+		_, rootDiskDevice, err := containerGetRootDiskDevice(c.ExpandedDevices())
+		if err != nil {
+			return db.ContainerArgs{}, err
+		}
+		args.Devices[rootDiskDeviceKey]["pool"] = rootDiskDevice["pool"]
+	}
+
+	return args, nil
+}
+
+// containerExportConfig returns the exported configuration without the
+// volatile keys, apart from the base image.
+func containerExportConfig(config map[string]string) map[string]string {
+	result := map[string]string{}
+	for key, value := range config {
+		if strings.HasPrefix(key, "volatile.") && key != "volatile.base_image" {
+			continue
+		}
+
+		result[key] = value
+	}
+
+	return result
+}
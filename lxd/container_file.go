@@ -7,10 +7,15 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 )
 
 func containerFileHandler(d *Daemon, r *http.Request) Response {
@@ -52,7 +57,7 @@ func containerFileGet(c container, path string, r *http.Request) Response {
 	defer temp.Close()
 
 	// Pull the file from the container
-	uid, gid, mode, type_, dirEnts, err := c.FilePull(path, temp.Name())
+	uid, gid, mode, type_, target, dirEnts, xattrs, err := c.FilePull(path, temp.Name())
 	if err != nil {
 		os.Remove(temp.Name())
 		return SmartError(err)
@@ -65,6 +70,19 @@ func containerFileGet(c container, path string, r *http.Request) Response {
 		"X-LXD-type": type_,
 	}
 
+	// Symlinks are followed, but their target is reported too
+	if target != "" {
+		headers["X-LXD-target"] = target
+	}
+
+	if len(xattrs) > 0 {
+		headers["X-LXD-xattrs"], err = shared.LXDFileXattrsHeader(xattrs)
+		if err != nil {
+			os.Remove(temp.Name())
+			return InternalError(err)
+		}
+	}
+
 	if type_ == "file" {
 		// Make a file response struct
 		files := make([]fileResponseEntry, 1)
@@ -75,7 +93,37 @@ func containerFileGet(c container, path string, r *http.Request) Response {
 		return FileResponse(r, files, headers, true)
 	} else if type_ == "directory" {
 		os.Remove(temp.Name())
-		return SyncResponseHeaders(true, dirEnts, headers)
+
+		if shared.IsTrue(r.FormValue("recursive")) {
+			return &fileTarballResponse{
+				container: c,
+				path:      path,
+				uid:       uid,
+				gid:       gid,
+				mode:      mode,
+				xattrs:    xattrs,
+				entries:   dirEnts,
+			}
+		}
+
+		if util.IsRecursionRequest(r) {
+			if dirEnts == nil {
+				dirEnts = []api.ContainerFileEntry{}
+			}
+
+			return SyncResponseHeaders(true, dirEnts, headers)
+		}
+
+		names := []string{}
+		for _, ent := range dirEnts {
+			names = append(names, ent.Name)
+		}
+
+		return SyncResponseHeaders(true, names, headers)
+	} else if shared.StringInSlice(type_, []string{"block", "char", "fifo", "socket"}) {
+		// Special files only have their details reported
+		os.Remove(temp.Name())
+		return SyncResponseHeaders(true, nil, headers)
 	} else {
 		os.Remove(temp.Name())
 		return InternalError(fmt.Errorf("bad file type %s", type_))
@@ -90,6 +138,16 @@ func containerFilePut(c container, path string, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Bad file write mode: %s", write))
 	}
 
+	xattrs, err := shared.ParseLXDFileXattrsHeader(r.Header)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = fileXattrsCheck(xattrs)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	if type_ == "file" {
 		// Write file content to a tempfile
 		temp, err := ioutil.TempFile("", "lxd_forkputfile_")
@@ -107,7 +165,7 @@ func containerFilePut(c container, path string, r *http.Request) Response {
 		}
 
 		// Transfer the file into the container
-		err = c.FilePush("file", temp.Name(), path, uid, gid, mode, write)
+		err = c.FilePush("file", temp.Name(), path, uid, gid, mode, write, xattrs)
 		if err != nil {
 			return InternalError(err)
 		}
@@ -119,27 +177,65 @@ func containerFilePut(c container, path string, r *http.Request) Response {
 			return InternalError(err)
 		}
 
-		err = c.FilePush("symlink", string(target), path, uid, gid, mode, write)
+		err = c.FilePush("symlink", string(target), path, uid, gid, mode, write, xattrs)
 		if err != nil {
 			return InternalError(err)
 		}
 		return EmptySyncResponse
 	} else if type_ == "directory" {
-		err := c.FilePush("directory", "", path, uid, gid, mode, write)
+		err := c.FilePush("directory", "", path, uid, gid, mode, write, xattrs)
 		if err != nil {
 			return InternalError(err)
 		}
 		return EmptySyncResponse
+	} else if type_ == "tarball" {
+		err := containerFileUnpackTarball(c, path, r.Body)
+		if err != nil {
+			return SmartError(err)
+		}
+		return EmptySyncResponse
 	} else {
 		return BadRequest(fmt.Errorf("Bad file type: %s", type_))
 	}
 }
 
 func containerFileDelete(c container, path string, r *http.Request) Response {
-	err := c.FileRemove(path)
+	var err error
+	if shared.IsTrue(r.FormValue("recursive")) {
+		err = c.FileRemoveAll(path)
+	} else {
+		err = c.FileRemove(path)
+	}
 	if err != nil {
 		return SmartError(err)
 	}
 
 	return EmptySyncResponse
 }
+
+// Parse the details of a directory entry reported by forkgetfile: its type,
+// size, uid, gid, mode and modification time.
+func fileEntryStatParse(line string) (*api.ContainerFileEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("Invalid directory entry details: %s", line)
+	}
+
+	numbers := make([]int64, 5)
+	for i, field := range fields[1:] {
+		number, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		numbers[i] = number
+	}
+
+	return &api.ContainerFileEntry{
+		Type:         fields[0],
+		Size:         numbers[0],
+		UID:          numbers[1],
+		GID:          numbers[2],
+		Mode:         int(numbers[3]),
+		ModifiedDate: time.Unix(numbers[4], 0).UTC(),
+	}, nil
+}
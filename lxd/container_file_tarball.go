@@ -0,0 +1,248 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Streams a directory of a container and its content as a tar archive, with
+// the ownership and permissions of the files as seen in the container.
+//
+// Like for the rest of the files API, every directory and file is pulled out
+// of the container on its own, so that the kernel resolves the paths inside
+// the container and symlinks can't be used to escape from it.
+type fileTarballResponse struct {
+	container container
+	path      string
+
+	// Details of the directory, already pulled by the request handler
+	uid     int64
+	gid     int64
+	mode    os.FileMode
+	xattrs  map[string]string
+	entries []api.ContainerFileEntry
+}
+
+func (r *fileTarballResponse) Render(w http.ResponseWriter) error {
+	temp, err := ioutil.TempFile("", "lxd_forkgetfile_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("X-LXD-type", "tarball")
+	w.WriteHeader(http.StatusOK)
+
+	tw := tar.NewWriter(w)
+
+	dir := &tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     "./",
+		Mode:     int64(r.mode),
+		Uid:      int(r.uid),
+		Gid:      int(r.gid),
+		ModTime:  time.Now(),
+		Xattrs:   r.xattrs,
+	}
+
+	err = fileTarballDir(r.container, tw, temp.Name(), r.path, dir, r.entries)
+	if err != nil {
+		// The response status is already sent, so the best we can do
+		// is to leave the archive truncated.
+		logger.Errorf("Failed to send %s of container %s as a tarball: %s", r.path, r.container.Name(), err)
+		return nil
+	}
+
+	return tw.Close()
+}
+
+func (r *fileTarballResponse) String() string {
+	return fmt.Sprintf("tarball of %s", r.path)
+}
+
+// Write the header of the directory at the given path and its entries to the
+// archive, recursively.
+func fileTarballDir(c container, tw *tar.Writer, temp string, path string, dir *tar.Header, entries []api.ContainerFileEntry) error {
+	err := tw.WriteHeader(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		entryPath := filepath.Join(path, entry.Name)
+
+		hdr := &tar.Header{
+			Name:    dir.Name + entry.Name,
+			Mode:    int64(entry.Mode),
+			Uid:     int(entry.UID),
+			Gid:     int(entry.GID),
+			ModTime: entry.ModifiedDate,
+		}
+
+		switch entry.Type {
+		case "directory":
+			_, _, _, type_, _, subEntries, xattrs, err := c.FilePull(entryPath, temp)
+			if err != nil {
+				return err
+			}
+
+			if type_ != "directory" {
+				return fmt.Errorf("%s changed while being sent", entryPath)
+			}
+
+			hdr.Typeflag = tar.TypeDir
+			hdr.Xattrs = xattrs
+			hdr.Name += "/"
+
+			err = fileTarballDir(c, tw, temp, entryPath, hdr, subEntries)
+			if err != nil {
+				return err
+			}
+		case "file":
+			hdr.Typeflag = tar.TypeReg
+
+			err := fileTarballFile(c, tw, temp, entryPath, hdr)
+			if err != nil {
+				return err
+			}
+		case "symlink":
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = entry.Target
+
+			err := tw.WriteHeader(hdr)
+			if err != nil {
+				return err
+			}
+		default:
+			logger.Debugf("Skipping %s of type %s in tarball", entryPath, entry.Type)
+		}
+	}
+
+	return nil
+}
+
+// Write the regular file at the given path to the archive, with its content.
+func fileTarballFile(c container, tw *tar.Writer, temp string, path string, hdr *tar.Header) error {
+	// The file gets pulled in place, so drop the content of the previous one
+	err := os.Truncate(temp, 0)
+	if err != nil {
+		return err
+	}
+
+	_, _, _, type_, _, _, xattrs, err := c.FilePull(path, temp)
+	if err != nil {
+		return err
+	}
+
+	if type_ != "file" {
+		return fmt.Errorf("%s changed while being sent", path)
+	}
+
+	hdr.Xattrs = xattrs
+
+	f, err := os.Open(temp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	hdr.Size = fi.Size()
+
+	err = tw.WriteHeader(hdr)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(tw, f, hdr.Size)
+	return err
+}
+
+// Unpack the tar archive into the directory at the given path in the
+// container. Like for the rest of the files API, every entry is pushed into
+// the container on its own, with its ownership mapped for the container.
+// Directories, regular files and symlinks are unpacked, other types of entries
+// are skipped.
+func containerFileUnpackTarball(c container, path string, r io.Reader) error {
+	temp, err := ioutil.TempFile("", "lxd_forkputfile_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(hdr.Name)
+		if name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("Invalid path in tarball: %s", hdr.Name)
+		}
+
+		target := filepath.Join(path, name)
+		uid := int64(hdr.Uid)
+		gid := int64(hdr.Gid)
+		// Keep the setuid, setgid and sticky bits
+		mode := int(hdr.Mode & 07777)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = c.FilePush("directory", "", target, uid, gid, mode, "overwrite", hdr.Xattrs)
+		case tar.TypeReg, tar.TypeRegA:
+			err = fileTarballWriteTemp(temp, tr)
+			if err != nil {
+				return err
+			}
+
+			err = c.FilePush("file", temp.Name(), target, uid, gid, mode, "overwrite", hdr.Xattrs)
+		case tar.TypeSymlink:
+			err = c.FilePush("symlink", hdr.Linkname, target, uid, gid, mode, "overwrite", nil)
+		default:
+			logger.Debugf("Skipping %s of type %c in tarball", hdr.Name, hdr.Typeflag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Replace the content of the temporary file with the given one.
+func fileTarballWriteTemp(temp *os.File, r io.Reader) error {
+	err := temp.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = temp.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(temp, r)
+	return err
+}
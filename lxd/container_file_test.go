@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileEntryStatParse(t *testing.T) {
+	ent, err := fileEntryStatParse("symlink 12 1000 1000 511 1514764800")
+	require.NoError(t, err)
+
+	assert.Equal(t, "symlink", ent.Type)
+	assert.Equal(t, int64(12), ent.Size)
+	assert.Equal(t, int64(1000), ent.UID)
+	assert.Equal(t, int64(1000), ent.GID)
+	assert.Equal(t, 0777, ent.Mode)
+	assert.Equal(t, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), ent.ModifiedDate)
+}
+
+func TestFileEntryStatParse_Invalid(t *testing.T) {
+	_, err := fileEntryStatParse("file 12 1000")
+	assert.Error(t, err)
+
+	_, err = fileEntryStatParse("file 12 1000 x 420 0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/idmap"
+)
+
+// Layout of the POSIX ACL extended attributes: a version header followed by
+// entries made of a tag, permissions and, for named users and groups, an id.
+const (
+	aclXattrAccess  = "system.posix_acl_access"
+	aclXattrDefault = "system.posix_acl_default"
+	aclHeaderSize   = 4
+	aclEntrySize    = 8
+	aclTagUser      = 0x02
+	aclTagGroup     = 0x08
+)
+
+// Layout of the file capabilities extended attribute. Version 3 adds the id
+// of the root user of the user namespace the capabilities apply to.
+const (
+	capXattr         = "security.capability"
+	capRevisionMask  = 0xFF000000
+	capRevision2     = 0x02000000
+	capRevision3     = 0x03000000
+	capRevision2Size = 20
+	capRevision3Size = 24
+)
+
+// Check that only the extended attributes allowed by shared.IsFileXattrAllowed
+// are set.
+func fileXattrsCheck(xattrs map[string]string) error {
+	for name := range xattrs {
+		if !shared.IsFileXattrAllowed(name) {
+			return fmt.Errorf("Extended attribute \"%s\" can't be set", name)
+		}
+	}
+
+	return nil
+}
+
+// Parse an extended attribute reported by forkgetfile: its hex encoded name
+// and value.
+func fileXattrParse(line string) (string, string, error) {
+	fields := strings.Split(line, " ")
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("Invalid extended attribute: %s", line)
+	}
+
+	name, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return "", "", err
+	}
+
+	value, err := hex.DecodeString(fields[1])
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(name), string(value), nil
+}
+
+// Write the extended attributes in the format expected by forkputfile.
+func fileXattrsWrite(w io.Writer, xattrs map[string]string) error {
+	for name, value := range xattrs {
+		_, err := fmt.Fprintf(w, "%x %x\n", name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Map the ids in the ACLs and file capabilities, as seen in the container, to
+// the ones on the host, for containers which aren't running (running ones get
+// them mapped by the kernel).
+func fileXattrsShiftIntoNs(idmapset *idmap.IdmapSet, xattrs map[string]string) (map[string]string, error) {
+	shifted := map[string]string{}
+	for name, value := range xattrs {
+		switch name {
+		case aclXattrAccess, aclXattrDefault:
+			acl, err := fileACLShift(value, idmapset.ShiftIntoNs, true)
+			if err != nil {
+				return nil, fmt.Errorf("Bad %s: %s", name, err)
+			}
+
+			value = acl
+		case capXattr:
+			rootUid, _ := idmapset.ShiftIntoNs(0, 0)
+			if len(value) == capRevision3Size {
+				rootUid, _ = idmapset.ShiftIntoNs(int64(binary.LittleEndian.Uint32([]byte(value[capRevision2Size:]))), 0)
+			}
+
+			if rootUid == -1 {
+				return nil, fmt.Errorf("The file capabilities are for a user which isn't mapped in the container")
+			}
+
+			caps, err := fileCapsSetRoot(value, rootUid)
+			if err != nil {
+				return nil, err
+			}
+
+			value = caps
+		}
+
+		shifted[name] = value
+	}
+
+	return shifted, nil
+}
+
+// Map the ids in the ACLs and file capabilities to the ones seen in the
+// container, the way the kernel does for running containers. File
+// capabilities for a user namespace outside of the container are dropped.
+func fileXattrsShiftFromNs(idmapset *idmap.IdmapSet, xattrs map[string]string) map[string]string {
+	if xattrs == nil {
+		return nil
+	}
+
+	shifted := map[string]string{}
+	for name, value := range xattrs {
+		switch name {
+		case aclXattrAccess, aclXattrDefault:
+			acl, err := fileACLShift(value, idmapset.ShiftFromNs, false)
+			if err != nil {
+				continue
+			}
+
+			value = acl
+		case capXattr:
+			if len(value) != capRevision3Size {
+				break
+			}
+
+			rootUid, _ := idmapset.ShiftFromNs(int64(binary.LittleEndian.Uint32([]byte(value[capRevision2Size:]))), 0)
+			if rootUid == -1 {
+				continue
+			}
+
+			// Capabilities for the root user of the container are
+			// seen as plain ones from it
+			if rootUid == 0 {
+				rootUid = -1
+			}
+
+			caps, err := fileCapsSetRoot(value, rootUid)
+			if err != nil {
+				continue
+			}
+
+			value = caps
+		}
+
+		shifted[name] = value
+	}
+
+	return shifted
+}
+
+// Shift the ids of the named user and group entries of the ACL. Unmapped ids
+// are refused if strict, and replaced with -1 otherwise.
+func fileACLShift(value string, shift func(int64, int64) (int64, int64), strict bool) (string, error) {
+	acl := []byte(value)
+	if len(acl) < aclHeaderSize || (len(acl)-aclHeaderSize)%aclEntrySize != 0 {
+		return "", fmt.Errorf("Invalid ACL")
+	}
+
+	for i := aclHeaderSize; i < len(acl); i += aclEntrySize {
+		tag := binary.LittleEndian.Uint16(acl[i:])
+		id := int64(binary.LittleEndian.Uint32(acl[i+4:]))
+
+		switch tag {
+		case aclTagUser:
+			id, _ = shift(id, -1)
+		case aclTagGroup:
+			_, id = shift(-1, id)
+		default:
+			continue
+		}
+
+		if id == -1 && strict {
+			return "", fmt.Errorf("The id %d isn't mapped in the container", binary.LittleEndian.Uint32(acl[i+4:]))
+		}
+
+		binary.LittleEndian.PutUint32(acl[i+4:], uint32(id))
+	}
+
+	return string(acl), nil
+}
+
+// Turn the file capabilities into version 3 ones for the given root user, or
+// with -1, into version 2 ones, which apply to the root user of the user
+// namespace the file is seen from.
+func fileCapsSetRoot(value string, rootUid int64) (string, error) {
+	if len(value) != capRevision2Size && len(value) != capRevision3Size {
+		return "", fmt.Errorf("Invalid file capabilities")
+	}
+
+	caps := []byte(value[:capRevision2Size])
+	magic := binary.LittleEndian.Uint32(caps) &^ capRevisionMask
+	if rootUid == -1 {
+		binary.LittleEndian.PutUint32(caps, magic|capRevision2)
+		return string(caps), nil
+	}
+
+	binary.LittleEndian.PutUint32(caps, magic|capRevision3)
+	root := make([]byte, 4)
+	binary.LittleEndian.PutUint32(root, uint32(rootUid))
+
+	return string(append(caps, root...)), nil
+}
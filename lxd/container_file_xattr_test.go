@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/idmap"
+)
+
+var testFileIdmapSet = &idmap.IdmapSet{Idmap: []idmap.IdmapEntry{
+	{Isuid: true, Isgid: true, Hostid: 100000, Nsid: 0, Maprange: 65536},
+}}
+
+// Return an ACL with alternate named user and group entries for the given ids.
+func newTestFileACL(ids ...uint32) string {
+	acl := make([]byte, aclHeaderSize+aclEntrySize*len(ids))
+	binary.LittleEndian.PutUint32(acl, 2)
+	for i, id := range ids {
+		tag := uint16(aclTagUser)
+		if i%2 == 1 {
+			tag = aclTagGroup
+		}
+
+		binary.LittleEndian.PutUint16(acl[aclHeaderSize+aclEntrySize*i:], tag)
+		binary.LittleEndian.PutUint32(acl[aclHeaderSize+aclEntrySize*i+4:], id)
+	}
+
+	return string(acl)
+}
+
+func TestFileXattrParse(t *testing.T) {
+	name, value, err := fileXattrParse("757365722e666f6f 00626172")
+	require.NoError(t, err)
+
+	assert.Equal(t, "user.foo", name)
+	assert.Equal(t, "\x00bar", value)
+
+	_, _, err = fileXattrParse("757365722e666f6f")
+	assert.Error(t, err)
+}
+
+// Only user attributes, file capabilities and ACLs can be set.
+func TestFileXattrsCheck(t *testing.T) {
+	assert.NoError(t, fileXattrsCheck(map[string]string{
+		aclXattrAccess:  "",
+		aclXattrDefault: "",
+		capXattr:        "",
+		"user.foo":      "bar",
+	}))
+
+	for _, name := range []string{"trusted.foo", "security.selinux", "security.ima", "system.foo"} {
+		assert.Error(t, fileXattrsCheck(map[string]string{name: "bar"}), name)
+	}
+}
+
+// ACL ids and file capabilities get mapped for the host, and back.
+func TestFileXattrsShift(t *testing.T) {
+	caps := make([]byte, capRevision2Size)
+	binary.LittleEndian.PutUint32(caps, capRevision2|1)
+
+	xattrs := map[string]string{
+		aclXattrAccess: newTestFileACL(1000, 1001),
+		capXattr:       string(caps),
+		"user.foo":     "bar",
+	}
+
+	shifted, err := fileXattrsShiftIntoNs(testFileIdmapSet, xattrs)
+	require.NoError(t, err)
+
+	assert.Equal(t, newTestFileACL(101000, 101001), shifted[aclXattrAccess])
+	assert.Len(t, shifted[capXattr], capRevision3Size)
+	assert.Equal(t, uint32(capRevision3|1), binary.LittleEndian.Uint32([]byte(shifted[capXattr])))
+	assert.Equal(t, uint32(100000), binary.LittleEndian.Uint32([]byte(shifted[capXattr][capRevision2Size:])))
+	assert.Equal(t, "bar", shifted["user.foo"])
+
+	assert.Equal(t, xattrs, fileXattrsShiftFromNs(testFileIdmapSet, shifted))
+}
+
+func TestFileXattrsShiftIntoNs_Unmapped(t *testing.T) {
+	_, err := fileXattrsShiftIntoNs(testFileIdmapSet, map[string]string{
+		aclXattrDefault: newTestFileACL(70000),
+	})
+	assert.Error(t, err)
+}
+
+// Unmapped ACL ids are seen as -1 and capabilities for users outside of the
+// container are dropped.
+func TestFileXattrsShiftFromNs_Unmapped(t *testing.T) {
+	caps := make([]byte, capRevision3Size)
+	binary.LittleEndian.PutUint32(caps, capRevision3)
+
+	shifted := fileXattrsShiftFromNs(testFileIdmapSet, map[string]string{
+		aclXattrAccess: newTestFileACL(1000),
+		capXattr:       string(caps),
+	})
+
+	assert.Equal(t, map[string]string{aclXattrAccess: newTestFileACL(0xffffffff)}, shifted)
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared/api"
 )
 
 func containerGet(d *Daemon, r *http.Request) Response {
@@ -18,5 +20,11 @@ func containerGet(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	// Tell which cluster member the container lives on
+	ct, ok := state.(*api.Container)
+	if ok {
+		ct.Location = clusterServerName(d)
+	}
+
 	return SyncResponseETag(true, state, etag)
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// How long a hook may run before it gets killed.
+var containerHookTimeout = 30 * time.Second
+
+// containerRunHook runs the host command configured for the given lifecycle
+// hook (hooks.<hook> key) of the container, if any. The command is passed the
+// container name and the hook through the LXD_CONTAINER_NAME and LXD_HOOK
+// environment variables. Only commands found in the core.hooks_path directory
+// are run, and they get killed after containerHookTimeout. HTTP callbacks
+// get called through containerCallHook instead.
+func containerRunHook(c container, hook string) error {
+	path := c.ExpandedConfig()[fmt.Sprintf("hooks.%s", hook)]
+	if path == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return containerCallHook(c, hook, path)
+	}
+
+	command, err := containerHookPath(path)
+	if err != nil {
+		return fmt.Errorf("Failed to run the %s hook \"%s\": %v", hook, path, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), containerHookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("LXD_CONTAINER_NAME=%s", c.Name()),
+		fmt.Sprintf("LXD_HOOK=%s", hook))
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("The %s hook \"%s\" timed out after %s", hook, path, containerHookTimeout)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to run the %s hook \"%s\": %v (%s)", hook, path, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// containerCallHook sends a POST request with the container name and the hook
+// to the given HTTP callback, which must answer with a 2xx status within
+// containerHookTimeout.
+func containerCallHook(c container, hook string, url string) error {
+	body, err := json.Marshal(map[string]string{"container": c.Name(), "hook": hook})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: containerHookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Failed to call the %s hook \"%s\": %v", hook, url, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("The %s hook \"%s\" failed with status %d", hook, url, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// containerHookPath resolves the path of a hook command, making sure it's in
+// the core.hooks_path directory (after following symlinks).
+func containerHookPath(path string) (string, error) {
+	dir := daemonConfig["core.hooks_path"].Get()
+	if dir == "" {
+		return "", fmt.Errorf("Hooks are disabled, core.hooks_path isn't set")
+	}
+
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	command, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(command, dir+"/") {
+		return "", fmt.Errorf("The command isn't in core.hooks_path (%s)", dir)
+	}
+
+	return command, nil
+}
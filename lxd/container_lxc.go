@@ -291,10 +291,14 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 
 	logger.Info("Creating container", ctxMap)
 
+	// Undo everything done so far if any step fails
+	revert := revertSteps{}
+	defer revert.Fail()
+	revert.Add(func() { c.Delete() })
+
 	// Load the config
 	err := c.init()
 	if err != nil {
-		c.Delete()
 		logger.Error("Failed creating container", ctxMap)
 		return nil, err
 	}
@@ -302,14 +306,12 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	// Validate expanded config
 	err = containerValidConfig(s.OS, c.expandedConfig, false, true)
 	if err != nil {
-		c.Delete()
 		logger.Error("Failed creating container", ctxMap)
 		return nil, err
 	}
 
 	err = containerValidDevices(s.DB, c.expandedDevices, false, true)
 	if err != nil {
-		c.Delete()
 		logger.Error("Failed creating container", ctxMap)
 		return nil, err
 	}
@@ -317,12 +319,10 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	// Retrieve the container's storage pool
 	_, rootDiskDevice, err := containerGetRootDiskDevice(c.expandedDevices)
 	if err != nil {
-		c.Delete()
 		return nil, err
 	}
 
 	if rootDiskDevice["pool"] == "" {
-		c.Delete()
 		return nil, fmt.Errorf("The container's root device is missing the pool property.")
 	}
 
@@ -331,7 +331,6 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	// Get the storage pool ID for the container
 	poolID, pool, err := s.DB.StoragePoolGet(storagePool)
 	if err != nil {
-		c.Delete()
 		return nil, err
 	}
 
@@ -345,15 +344,13 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	// Create a new database entry for the container's storage volume
 	_, err = s.DB.StoragePoolVolumeCreate(args.Name, "", storagePoolVolumeTypeContainer, poolID, volumeConfig)
 	if err != nil {
-		c.Delete()
 		return nil, err
 	}
+	revert.Add(func() { s.DB.StoragePoolVolumeDelete(args.Name, storagePoolVolumeTypeContainer, poolID) })
 
 	// Initialize the container storage
 	cStorage, err := storagePoolVolumeContainerCreateInit(s, storagePool, args.Name)
 	if err != nil {
-		c.Delete()
-		s.DB.StoragePoolVolumeDelete(args.Name, storagePoolVolumeTypeContainer, poolID)
 		logger.Error("Failed to initialize container storage", ctxMap)
 		return nil, err
 	}
@@ -373,7 +370,6 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 		)
 
 		if err != nil {
-			c.Delete()
 			logger.Error("Failed creating container", ctxMap)
 			return nil, err
 		}
@@ -383,7 +379,6 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	if idmap != nil {
 		idmapBytes, err := json.Marshal(idmap.Idmap)
 		if err != nil {
-			c.Delete()
 			logger.Error("Failed creating container", ctxMap)
 			return nil, err
 		}
@@ -394,14 +389,12 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 
 	err = c.ConfigKeySet("volatile.idmap.next", jsonIdmap)
 	if err != nil {
-		c.Delete()
 		logger.Error("Failed creating container", ctxMap)
 		return nil, err
 	}
 
 	err = c.ConfigKeySet("volatile.idmap.base", fmt.Sprintf("%v", base))
 	if err != nil {
-		c.Delete()
 		logger.Error("Failed creating container", ctxMap)
 		return nil, err
 	}
@@ -413,7 +406,6 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	if c.localConfig["volatile.last_state.idmap"] == "" {
 		err = c.ConfigKeySet("volatile.last_state.idmap", jsonIdmap)
 		if err != nil {
-			c.Delete()
 			logger.Error("Failed creating container", ctxMap)
 			return nil, err
 		}
@@ -422,7 +414,6 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	// Re-run init to update the idmap
 	err = c.init()
 	if err != nil {
-		c.Delete()
 		logger.Error("Failed creating container", ctxMap)
 		return nil, err
 	}
@@ -430,7 +421,6 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	// Update MAAS
 	err = c.maasUpdate(false)
 	if err != nil {
-		c.Delete()
 		logger.Error("Failed creating container", ctxMap)
 		return nil, err
 	}
@@ -440,12 +430,35 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 
 	logger.Info("Created container", ctxMap)
 
+	revert.Success()
 	return c, nil
 }
 
 func containerLXCLoad(s *state.State, args db.ContainerArgs) (container, error) {
-	// Create the container struct
-	c := &containerLXC{
+	c := containerLXCInstantiate(s, args)
+
+	// Load the config.
+	err := c.init()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// containerLXCLoadCached creates the container struct from a cache entry,
+// without expanding its configuration again.
+func containerLXCLoadCached(s *state.State, entry containerLoadCacheEntry) container {
+	c := containerLXCInstantiate(s, entry.args)
+	c.expandedConfig = entry.expandedConfig
+	c.expandedDevices = entry.expandedDevices
+
+	return c
+}
+
+// Create the container struct from its database records
+func containerLXCInstantiate(s *state.State, args db.ContainerArgs) *containerLXC {
+	return &containerLXC{
 		state:        s,
 		db:           s.DB,
 		id:           args.Id,
@@ -461,14 +474,6 @@ func containerLXCLoad(s *state.State, args db.ContainerArgs) (container, error)
 		localDevices: args.Devices,
 		stateful:     args.Stateful,
 	}
-
-	// Load the config.
-	err := c.init()
-	if err != nil {
-		return nil, err
-	}
-
-	return c, nil
 }
 
 // The LXC container driver
@@ -592,32 +597,37 @@ func parseRawIdmap(value string) ([]idmap.IdmapEntry, error) {
 		}
 
 		base, err := strconv.ParseInt(entries[0], 10, 64)
-		if err != nil {
-			return -1, -1, err
+		if err != nil || base < 0 {
+			return -1, -1, fmt.Errorf("invalid raw.idmap range %s", r)
 		}
 
 		size := int64(1)
 		if len(entries) > 1 {
 			size, err = strconv.ParseInt(entries[1], 10, 64)
 			if err != nil {
-				return -1, -1, err
+				return -1, -1, fmt.Errorf("invalid raw.idmap range %s", r)
 			}
 
 			size -= base
 			size += 1
 		}
 
+		if size < 1 {
+			return -1, -1, fmt.Errorf("invalid raw.idmap range %s", r)
+		}
+
 		return base, size, nil
 	}
 
 	ret := idmap.IdmapSet{}
 
 	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
 
-		entries := strings.Split(line, " ")
+		entries := strings.Fields(line)
 		if len(entries) != 3 {
 			return nil, fmt.Errorf("invalid raw.idmap line %s", line)
 		}
@@ -760,12 +770,14 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 			continue
 		}
 
-		cBase := int64(0)
-		if container.ExpandedConfig()["volatile.idmap.base"] != "" {
-			cBase, err = strconv.ParseInt(container.ExpandedConfig()["volatile.idmap.base"], 10, 64)
-			if err != nil {
-				return nil, 0, err
-			}
+		// Containers which weren't allocated a range yet don't use any
+		if container.ExpandedConfig()["volatile.idmap.base"] == "" {
+			continue
+		}
+
+		cBase, err := strconv.ParseInt(container.ExpandedConfig()["volatile.idmap.base"], 10, 64)
+		if err != nil {
+			return nil, 0, err
 		}
 
 		cSize, err := idmapSize(state, container.ExpandedConfig()["security.idmap.isolated"], container.ExpandedConfig()["security.idmap.size"])
@@ -778,39 +790,18 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 
 	sort.Sort(mapentries)
 
-	for i := range mapentries {
-		if i == 0 {
-			if mapentries[0].Hostid < offset+size {
-				offset = mapentries[0].Hostid + mapentries[0].Maprange
-				continue
-			}
-
-			set, err := mkIdmap(offset, size)
-			if err != nil && err == idmap.ErrHostIdIsSubId {
-				return nil, 0, err
-			}
-
-			return set, offset, nil
-		}
-
-		if mapentries[i-1].Hostid+mapentries[i-1].Maprange > offset {
-			offset = mapentries[i-1].Hostid + mapentries[i-1].Maprange
-			continue
+	// Find the first gap large enough for the new range
+	for _, entry := range mapentries {
+		if offset+size <= entry.Hostid {
+			break
 		}
 
-		offset = mapentries[i-1].Hostid + mapentries[i-1].Maprange
-		if offset+size < mapentries[i].Hostid {
-			set, err := mkIdmap(offset, size)
-			if err != nil && err == idmap.ErrHostIdIsSubId {
-				return nil, 0, err
-			}
-
-			return set, offset, nil
+		if entry.Hostid+entry.Maprange > offset {
+			offset = entry.Hostid + entry.Maprange
 		}
-		offset = mapentries[i].Hostid + mapentries[i].Maprange
 	}
 
-	if offset+size < state.OS.IdmapSet.Idmap[0].Hostid+state.OS.IdmapSet.Idmap[0].Maprange {
+	if offset+size <= state.OS.IdmapSet.Idmap[0].Hostid+state.OS.IdmapSet.Idmap[0].Maprange {
 		set, err := mkIdmap(offset, size)
 		if err != nil && err == idmap.ErrHostIdIsSubId {
 			return nil, 0, err
@@ -981,16 +972,31 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 	}
 
-	// For lxcfs
-	templateConfDir := os.Getenv("LXD_LXC_TEMPLATE_CONFIG")
-	if templateConfDir == "" {
-		templateConfDir = "/usr/share/lxc/config"
-	}
+	// For lxcfs, checked at every start as it may be started after LXD
+	if daemonConfig["core.lxcfs"].GetBool() {
+		templateConfDir := os.Getenv("LXD_LXC_TEMPLATE_CONFIG")
+		if templateConfDir == "" {
+			templateConfDir = "/usr/share/lxc/config"
+		}
 
-	if shared.PathExists(fmt.Sprintf("%s/common.conf.d/", templateConfDir)) {
-		err = lxcSetConfigItem(cc, "lxc.include", fmt.Sprintf("%s/common.conf.d/", templateConfDir))
-		if err != nil {
-			return err
+		if shared.PathExists(fmt.Sprintf("%s/common.conf.d/", templateConfDir)) {
+			err = lxcSetConfigItem(cc, "lxc.include", fmt.Sprintf("%s/common.conf.d/", templateConfDir))
+			if err != nil {
+				return err
+			}
+		} else {
+			// No LXC hook for lxcfs, bind-mount its /proc files directly
+			for _, name := range []string{"cpuinfo", "diskstats", "meminfo", "stat", "swaps", "uptime"} {
+				path := filepath.Join("/var/lib/lxcfs/proc", name)
+				if !shared.PathExists(path) {
+					continue
+				}
+
+				err = lxcSetConfigItem(cc, "lxc.mount.entry", fmt.Sprintf("%s proc/%s none bind,optional 0 0", path, name))
+				if err != nil {
+					return err
+				}
+			}
 		}
 	}
 
@@ -1096,6 +1102,12 @@ func (c *containerLXC) initLXC(config bool) error {
 			if err != nil {
 				return err
 			}
+		} else if !AAEnabled(c) {
+			// AppArmor confinement was turned off for this container
+			err := lxcSetConfigItem(cc, "lxc.apparmor.profile", "unconfined")
+			if err != nil {
+				return err
+			}
 		} else {
 			// If not currently confined, use the container's profile
 			profile := AAProfileFull(c)
@@ -1152,6 +1164,31 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 	}
 
+	// Setup NVIDIA runtime
+	if shared.IsTrue(c.expandedConfig["nvidia.runtime"]) {
+		hookDir := os.Getenv("LXD_LXC_HOOK")
+		if hookDir == "" {
+			hookDir = "/usr/share/lxc/hooks"
+		}
+
+		hookPath := filepath.Join(hookDir, "nvidia")
+		if !shared.PathExists(hookPath) {
+			return fmt.Errorf("The NVIDIA LXC hook couldn't be found")
+		}
+
+		_, err := exec.LookPath("nvidia-container-cli")
+		if err != nil {
+			return fmt.Errorf("The NVIDIA container tools couldn't be found")
+		}
+
+		for _, item := range nvidiaRuntimeConfig(c.expandedConfig, hookPath) {
+			err = lxcSetConfigItem(cc, item[0], item[1])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// Memory limits
 	if c.state.OS.CGroupMemoryController {
 		memory := c.expandedConfig["limits.memory"]
@@ -1229,6 +1266,15 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 	}
 
+	// NUMA nodes
+	cpuNodes := c.expandedConfig["limits.cpu.nodes"]
+	if cpuNodes != "" && c.state.OS.CGroupCPUsetController {
+		err = lxcSetConfigItem(cc, "lxc.cgroup.cpuset.mems", cpuNodes)
+		if err != nil {
+			return err
+		}
+	}
+
 	// CPU limits
 	cpuPriority := c.expandedConfig["limits.cpu.priority"]
 	cpuAllowance := c.expandedConfig["limits.cpu.allowance"]
@@ -1349,6 +1395,45 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 	}
 
+	// Hugepages
+	if c.state.OS.CGroupHugetlbController {
+		for _, pageSize := range containerHugepageSizes {
+			value := c.expandedConfig[fmt.Sprintf("limits.hugepages.%s", pageSize)]
+			if value == "" {
+				continue
+			}
+
+			limitFile := fmt.Sprintf("hugetlb.%s.limit_in_bytes", pageSize)
+			if !shared.PathExists(filepath.Join("/sys/fs/cgroup/hugetlb", limitFile)) {
+				return fmt.Errorf("Hugepages of size %s aren't supported on this system", pageSize)
+			}
+
+			valueInt, err := shared.ParseByteSizeString(value)
+			if err != nil {
+				return err
+			}
+
+			err = lxcSetConfigItem(cc, fmt.Sprintf("lxc.cgroup.%s", limitFile), fmt.Sprintf("%d", valueInt))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Setup the sysctls
+	for k, v := range c.expandedConfig {
+		if strings.HasPrefix(k, "linux.sysctl.") {
+			if !util.RuntimeLiblxcVersionAtLeast(3, 0, 0) {
+				return fmt.Errorf("linux.sysctl.* requires liblxc >= 3.0")
+			}
+
+			err = lxcSetConfigItem(cc, fmt.Sprintf("lxc.sysctl.%s", strings.TrimPrefix(k, "linux.sysctl.")), v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// Setup process limits
 	for k, v := range c.expandedConfig {
 		if strings.HasPrefix(k, "limits.kernel.") {
@@ -1395,11 +1480,25 @@ func (c *containerLXC) initLXC(config bool) error {
 			}
 
 			// Interface type specific configuration
-			if shared.StringInSlice(m["nictype"], []string{"bridged", "p2p"}) {
+			if shared.StringInSlice(m["nictype"], []string{"bridged", "p2p", "routed"}) {
 				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.type", networkKeyPrefix, networkidx), "veth")
 				if err != nil {
 					return err
 				}
+			} else if m["nictype"] == "ipvlan" {
+				if !util.RuntimeLiblxcVersionAtLeast(3, 2, 0) {
+					return fmt.Errorf("ipvlan nics require liblxc >= 3.2")
+				}
+
+				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.type", networkKeyPrefix, networkidx), "ipvlan")
+				if err != nil {
+					return err
+				}
+
+				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.ipvlan.mode", networkKeyPrefix, networkidx), "l3s")
+				if err != nil {
+					return err
+				}
 			} else if m["nictype"] == "physical" || m["nictype"] == "sriov" {
 				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.type", networkKeyPrefix, networkidx), "phys")
 				if err != nil {
@@ -1432,7 +1531,7 @@ func (c *containerLXC) initLXC(config bool) error {
 				if err != nil {
 					return err
 				}
-			} else if shared.StringInSlice(m["nictype"], []string{"macvlan", "physical"}) {
+			} else if shared.StringInSlice(m["nictype"], []string{"ipvlan", "macvlan", "physical"}) {
 				err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.link", networkKeyPrefix, networkidx), networkGetHostDevice(m["parent"], m["vlan"]))
 				if err != nil {
 					return err
@@ -1443,8 +1542,8 @@ func (c *containerLXC) initLXC(config bool) error {
 			vethName := ""
 			if m["host_name"] != "" && m["nictype"] != "sriov" {
 				vethName = m["host_name"]
-			} else if shared.IsTrue(m["security.mac_filtering"]) {
-				// We need a known device name for MAC filtering
+			} else if shared.IsTrue(m["security.mac_filtering"]) || m["nictype"] == "routed" {
+				// We need a known device name for MAC filtering and routing
 				vethName = deviceNextVeth()
 			}
 
@@ -1479,6 +1578,62 @@ func (c *containerLXC) initLXC(config bool) error {
 				}
 			}
 
+			// Static addresses (managed bridges hand them out through DHCP)
+			if m["ipv4.address"] != "" || m["ipv6.address"] != "" {
+				managed := false
+				if m["nictype"] == "bridged" {
+					networks, err := c.db.Networks()
+					if err != nil {
+						return err
+					}
+
+					managed = shared.StringInSlice(m["parent"], networks)
+				}
+
+				if !managed {
+					for _, family := range []string{"ipv4", "ipv6"} {
+						if m[family+".address"] == "" {
+							continue
+						}
+
+						key := fmt.Sprintf("%s.%d.%s.address", networkKeyPrefix, networkidx, family)
+						if networkKeyPrefix == "lxc.network" {
+							key = fmt.Sprintf("%s.%d.%s", networkKeyPrefix, networkidx, family)
+						}
+
+						// Routed and ipvlan nics get host routes for each address
+						if !shared.StringInSlice(m["nictype"], []string{"ipvlan", "routed"}) {
+							err = lxcSetConfigItem(cc, key, m[family+".address"])
+							if err != nil {
+								return err
+							}
+
+							continue
+						}
+
+						for _, addr := range networkRoutedAddresses(m[family+".address"], family) {
+							err = lxcSetConfigItem(cc, key, addr)
+							if err != nil {
+								return err
+							}
+						}
+
+						gateway := "dev"
+						if m["nictype"] == "routed" {
+							gateway = networkRoutedGatewayV4
+							if family == "ipv6" {
+								gateway = networkRoutedGatewayV6
+							}
+						}
+
+						err = lxcSetConfigItem(cc, fmt.Sprintf("%s.%d.%s.gateway", networkKeyPrefix, networkidx, family), gateway)
+						if err != nil {
+							return err
+						}
+					}
+				}
+			}
+
 			// bump network index
 			networkidx++
 		} else if m["type"] == "disk" {
@@ -1497,6 +1652,7 @@ func (c *containerLXC) initLXC(config bool) error {
 			isOptional := shared.IsTrue(m["optional"])
 			isReadOnly := shared.IsTrue(m["readonly"])
 			isRecursive := shared.IsTrue(m["recursive"])
+			isShifted := shared.IsTrue(m["shift"]) && !c.IsPrivileged()
 
 			// If we want to mount a storage volume from a storage
 			// pool we created via our storage api, we are always
@@ -1553,17 +1709,32 @@ func (c *containerLXC) initLXC(config bool) error {
 					rbind = "r"
 				}
 
+				if m["propagation"] != "" {
+					options = append(options, m["propagation"])
+				}
+
 				if isFile {
 					options = append(options, "create=file")
 				} else {
 					options = append(options, "create=dir")
 				}
 
-				err = lxcSetConfigItem(cc, "lxc.mount.entry",
-					fmt.Sprintf("%s %s none %sbind,%s",
-						shared.EscapePathFstab(sourceDevPath),
-						shared.EscapePathFstab(relativeDestPath), rbind,
-						strings.Join(options, ",")))
+				if isShifted {
+					// Mount the shiftfs mark set up on the host
+					// side from within the container's namespace
+					options = append(options, "passthrough=3")
+					err = lxcSetConfigItem(cc, "lxc.mount.entry",
+						fmt.Sprintf("%s %s shiftfs %s",
+							shared.EscapePathFstab(sourceDevPath),
+							shared.EscapePathFstab(relativeDestPath),
+							strings.Join(options, ",")))
+				} else {
+					err = lxcSetConfigItem(cc, "lxc.mount.entry",
+						fmt.Sprintf("%s %s none %sbind,%s",
+							shared.EscapePathFstab(sourceDevPath),
+							shared.EscapePathFstab(relativeDestPath), rbind,
+							strings.Join(options, ",")))
+				}
 				if err != nil {
 					return err
 				}
@@ -1617,52 +1788,55 @@ func (c *containerLXC) initStorage() error {
 	return nil
 }
 
-// Config handling
-func (c *containerLXC) expandConfig() error {
-	config := map[string]string{}
+// nvidiaRuntimeConfig returns the LXC config items, as key and value pairs,
+// which have the given NVIDIA hook pass the libraries into the container.
+func nvidiaRuntimeConfig(config map[string]string, hookPath string) [][2]string {
+	nvidiaDriver := config["nvidia.driver.capabilities"]
+	if nvidiaDriver == "" {
+		nvidiaDriver = "compute,utility"
+	}
 
-	// Apply all the profiles
-	for _, name := range c.profiles {
-		profileConfig, err := c.db.ProfileConfig(name)
-		if err != nil {
-			return err
-		}
+	return [][2]string{
+		// The devices themselves are passed through gpu devices
+		{"lxc.environment", "NVIDIA_VISIBLE_DEVICES=none"},
+		{"lxc.environment", fmt.Sprintf("NVIDIA_DRIVER_CAPABILITIES=%s", nvidiaDriver)},
+		{"lxc.hook.mount", hookPath},
+	}
+}
 
-		for k, v := range profileConfig {
-			config[k] = v
-		}
+// Config handling
+func (c *containerLXC) expandConfig() error {
+	configs, err := c.db.ProfilesConfig(c.profiles)
+	if err != nil {
+		return err
 	}
 
-	// Stick the local config on top
-	for k, v := range c.localConfig {
-		config[k] = v
+	profiles := []map[string]string{}
+	for _, name := range c.profiles {
+		profiles = append(profiles, configs[name])
 	}
 
-	c.expandedConfig = config
+	c.expandedConfig = containerExpandConfig(profiles, c.localConfig)
 	return nil
 }
 
 func (c *containerLXC) expandDevices() error {
-	devices := types.Devices{}
+	devices, err := c.db.ProfilesDevices(c.profiles)
+	if err != nil {
+		return err
+	}
 
-	// Apply all the profiles
+	profiles := []types.Devices{}
 	for _, p := range c.profiles {
-		profileDevices, err := c.db.Devices(p, true)
-		if err != nil {
-			return err
+		profileDevices, ok := devices[p]
+		if !ok {
+			profileDevices = types.Devices{}
 		}
 
-		for k, v := range profileDevices {
-			devices[k] = v
-		}
+		profiles = append(profiles, profileDevices)
 	}
 
-	// Stick local devices on top
-	for k, v := range c.localDevices {
-		devices[k] = v
-	}
-
-	c.expandedDevices = devices
+	c.expandedDevices = containerExpandDevices(profiles, c.localDevices)
 	return nil
 }
 
@@ -1780,6 +1954,8 @@ func (c *containerLXC) startCommon() (string, error) {
 			return "", err
 		}
 
+		containerCache.Forget(c.name)
+
 		// Remove the volatile key from the in-memory configs
 		delete(c.localConfig, "volatile.apply_quota")
 		delete(c.expandedConfig, "volatile.apply_quota")
@@ -1880,6 +2056,7 @@ func (c *containerLXC) startCommon() (string, error) {
 	c.removeUnixDevices()
 	c.removeDiskDevices()
 	c.removeNetworkFilters()
+	c.removeNetworkRoutes()
 
 	var usbs []usbDevice
 	var gpus []gpuDevice
@@ -1917,16 +2094,22 @@ func (c *containerLXC) startCommon() (string, error) {
 				}
 			}
 
+			found := false
 			for _, usb := range usbs {
 				if usb.vendor != m["vendorid"] || (m["productid"] != "" && usb.product != m["productid"]) {
 					continue
 				}
 
+				found = true
 				err := c.setupUnixDevice(fmt.Sprintf("unix.%s", k), m, usb.major, usb.minor, usb.path, shared.IsTrue(m["required"]))
 				if err != nil {
 					return "", err
 				}
 			}
+
+			if !found && shared.IsTrue(m["required"]) {
+				return "", fmt.Errorf("Required USB device \"%s\" isn't present", k)
+			}
 		} else if m["type"] == "gpu" {
 			if gpus == nil {
 				gpus, nvidiaDevices, err = deviceLoadGpu()
@@ -2203,6 +2386,8 @@ func (c *containerLXC) startCommon() (string, error) {
 		return "", fmt.Errorf("Error updating last used: %v", err)
 	}
 
+	containerCache.Forget(c.name)
+
 	return configPath, nil
 }
 
@@ -2233,6 +2418,12 @@ func (c *containerLXC) Start(stateful bool) error {
 		return err
 	}
 
+	// Run the user's pre-start hook, failing it aborts the start
+	err = containerRunHook(c, "pre-start")
+	if err != nil {
+		return err
+	}
+
 	ctxMap = log.Ctx{"name": c.name,
 		"action":    op.action,
 		"created":   c.creationDate,
@@ -2272,7 +2463,10 @@ func (c *containerLXC) Start(stateful bool) error {
 			return err
 		}
 
+		containerCache.Forget(c.name)
+
 		logger.Info("Started container", ctxMap)
+		c.runPostStartHook()
 
 		return err
 	} else if c.stateful {
@@ -2287,6 +2481,8 @@ func (c *containerLXC) Start(stateful bool) error {
 		if err != nil {
 			return err
 		}
+
+		containerCache.Forget(c.name)
 	}
 
 	// Start the LXC container
@@ -2339,10 +2535,20 @@ func (c *containerLXC) Start(stateful bool) error {
 	}
 
 	logger.Info("Started container", ctxMap)
+	c.runPostStartHook()
 
 	return nil
 }
 
+// runPostStartHook runs the user's post-start hook, only logging failures as
+// the container is already running by then.
+func (c *containerLXC) runPostStartHook() {
+	err := containerRunHook(c, "post-start")
+	if err != nil {
+		logger.Error("Failed to run post-start hook", log.Ctx{"container": c.Name(), "err": err})
+	}
+}
+
 func (c *containerLXC) OnStart() error {
 	// Make sure we can't call go-lxc functions by mistake
 	c.fromHook = true
@@ -2375,6 +2581,18 @@ func (c *containerLXC) OnStart() error {
 			return err
 		}
 
+		// Seed cloud-init on first boot
+		if c.localConfig[key] == "create" {
+			err = c.cloudInitSeed()
+			if err != nil {
+				AADestroy(c)
+				if ourStart {
+					c.StorageStop()
+				}
+				return err
+			}
+		}
+
 		// Remove the volatile key from the DB
 		err := c.db.ContainerConfigRemove(c.id, key)
 		if err != nil {
@@ -2384,6 +2602,8 @@ func (c *containerLXC) OnStart() error {
 			}
 			return err
 		}
+
+		containerCache.Forget(c.name)
 	}
 
 	err = c.templateApplyNow("start")
@@ -2422,19 +2642,53 @@ func (c *containerLXC) OnStart() error {
 
 		go func(c *containerLXC, name string, m types.Device) {
 			c.fromHook = false
-			err = c.setNetworkLimits(name, m)
+			err := c.setNetworkLimits(name, m)
 			if err != nil {
 				logger.Error("Failed to apply network limits", log.Ctx{"container": c.name, "err": err})
 			}
 		}(c, name, m)
 	}
 
+	// Apply host side routes
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "nic" || m["nictype"] != "routed" {
+			continue
+		}
+
+		go func(c *containerLXC, name string, m types.Device) {
+			c.fromHook = false
+			err := c.setNetworkRoutes(name, m)
+			if err != nil {
+				logger.Error("Failed to apply network routes", log.Ctx{"container": c.name, "err": err})
+			}
+		}(c, name, m)
+	}
+
+	// Apply bridge VLANs
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "nic" || m["nictype"] != "bridged" || m["vlan"] == "" {
+			continue
+		}
+
+		go func(c *containerLXC, name string, m types.Device) {
+			c.fromHook = false
+			err := c.setNetworkVlan(name, m)
+			if err != nil {
+				logger.Error("Failed to apply network VLAN", log.Ctx{"container": c.name, "err": err})
+			}
+		}(c, name, m)
+	}
+
 	// Record current state
 	err = c.db.ContainerSetState(c.id, "RUNNING")
 	if err != nil {
 		return err
 	}
 
+	containerCache.Forget(c.name)
+
 	return nil
 }
 
@@ -2501,6 +2755,8 @@ func (c *containerLXC) Stop(stateful bool) error {
 			return err
 		}
 
+		containerCache.Forget(c.name)
+
 		op.Done(nil)
 		logger.Info("Stopped container", ctxMap)
 		return nil
@@ -2667,6 +2923,15 @@ func (c *containerLXC) OnStop(target string) error {
 			logger.Error("Unable to remove network filters", log.Ctx{"container": c.Name(), "err": err})
 		}
 
+		// Clean the proxy neighbour entries of the routed nics
+		c.removeNetworkRoutes()
+
+		// Run the user's post-stop hook
+		err = containerRunHook(c, "post-stop")
+		if err != nil {
+			logger.Error("Failed to run post-stop hook", log.Ctx{"container": c.Name(), "err": err})
+		}
+
 		// Reboot the container
 		if target == "reboot" {
 			// Start the container again
@@ -2683,9 +2948,19 @@ func (c *containerLXC) OnStop(target string) error {
 			logger.Error("Failed to set container state", log.Ctx{"container": c.Name(), "err": err})
 		}
 
+		containerCache.Forget(c.name)
+
+		// Stops requested through the API have their own event
+		if op == nil {
+			eventSendLifecycle("container-shutdown", eventContainerSource(c.name), nil, nil)
+		}
+
 		// Destroy ephemeral containers
 		if c.ephemeral {
 			err = c.Delete()
+			if err == nil {
+				eventSendLifecycle("container-deleted", eventContainerSource(c.name), nil, nil)
+			}
 		}
 	}(c, target, op)
 
@@ -2963,12 +3238,28 @@ func (c *containerLXC) Restore(sourceContainer container, stateful bool) error {
 	// Restore the configuration
 	args := db.ContainerArgs{
 		Architecture: sourceContainer.Architecture(),
-		Config:       sourceContainer.LocalConfig(),
+		Config:       map[string]string{},
 		Devices:      sourceContainer.LocalDevices(),
 		Ephemeral:    sourceContainer.IsEphemeral(),
 		Profiles:     sourceContainer.Profiles(),
 	}
 
+	for k, v := range sourceContainer.LocalConfig() {
+		args.Config[k] = v
+	}
+
+	// Keep the current MAC addresses if the snapshot doesn't record any
+	for k, v := range c.localConfig {
+		if !strings.HasPrefix(k, "volatile.") || !strings.HasSuffix(k, ".hwaddr") {
+			continue
+		}
+
+		_, ok := args.Config[k]
+		if !ok {
+			args.Config[k] = v
+		}
+	}
+
 	err = c.Update(args, false)
 	if err != nil {
 		logger.Error("Failed restoring container configuration", ctxMap)
@@ -3042,6 +3333,7 @@ func (c *containerLXC) cleanup() {
 	c.removeUnixDevices()
 	c.removeDiskDevices()
 	c.removeNetworkFilters()
+	c.removeNetworkRoutes()
 
 	// Remove the security profiles
 	AADeleteProfile(c)
@@ -3113,6 +3405,8 @@ func (c *containerLXC) Delete() error {
 		return err
 	}
 
+	containerCache.Forget(c.Name())
+
 	// Remove the database entry for the pool device
 	if c.storage != nil {
 		// Get the name of the storage pool the container is attached to. This
@@ -3213,6 +3507,8 @@ func (c *containerLXC) Rename(newName string) error {
 		return err
 	}
 
+	containerCache.Forget(oldName)
+
 	// Rename storage volume for the container.
 	poolID, _, _ := c.storage.GetContainerPoolInfo()
 	err = c.db.StoragePoolVolumeRename(oldName, newName, storagePoolVolumeTypeContainer, poolID)
@@ -3239,6 +3535,8 @@ func (c *containerLXC) Rename(newName string) error {
 				return err
 			}
 
+			containerCache.Forget(sname)
+
 			// Rename storage volume for the snapshot.
 			err = c.db.StoragePoolVolumeRename(sname, newSnapshotName, storagePoolVolumeTypeContainer, poolID)
 			if err != nil {
@@ -3759,6 +4057,11 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		for _, key := range changedConfig {
 			value := c.expandedConfig[key]
 
+			// Anything else will be applied on next container start
+			if !shared.ConfigKeyLiveUpdate(key) {
+				continue
+			}
+
 			if key == "raw.apparmor" || key == "security.nesting" {
 				// Update the AppArmor profile
 				err = AALoadProfile(c)
@@ -3969,12 +4272,33 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 						}
 					}
 				}
-			} else if key == "limits.network.priority" {
-				err := c.setNetworkPriority()
+			} else if key == "limits.network.priority" {
+				err := c.setNetworkPriority()
+				if err != nil {
+					return err
+				}
+			} else if key == "limits.cpu" {
+				// Trigger a scheduler re-run
+				deviceTaskSchedulerTrigger("container", c.name, "changed")
+			} else if key == "limits.cpu.nodes" {
+				// Skip if no cpuset CGroup
+				if !c.state.OS.CGroupCPUsetController {
+					continue
+				}
+
+				// Restore the host's memory nodes when unset
+				if value == "" {
+					value, err = cGroupGet("cpuset", "/", "cpuset.mems")
+					if err != nil {
+						return err
+					}
+				}
+
+				err = c.CGroupSet("cpuset.mems", value)
 				if err != nil {
 					return err
 				}
-			} else if key == "limits.cpu" {
+
 				// Trigger a scheduler re-run
 				deviceTaskSchedulerTrigger("container", c.name, "changed")
 			} else if key == "limits.cpu.priority" || key == "limits.cpu.allowance" {
@@ -4024,6 +4348,30 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 						return err
 					}
 				}
+			} else if strings.HasPrefix(key, "limits.hugepages.") {
+				if !c.state.OS.CGroupHugetlbController {
+					continue
+				}
+
+				pageSize := strings.TrimPrefix(key, "limits.hugepages.")
+				limitFile := fmt.Sprintf("hugetlb.%s.limit_in_bytes", pageSize)
+
+				if value == "" {
+					err = c.CGroupSet(limitFile, "-1")
+					if err != nil {
+						return err
+					}
+				} else {
+					valueInt, err := shared.ParseByteSizeString(value)
+					if err != nil {
+						return err
+					}
+
+					err = c.CGroupSet(limitFile, fmt.Sprintf("%d", valueInt))
+					if err != nil {
+						return err
+					}
+				}
 			}
 		}
 
@@ -4154,6 +4502,14 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 					return err
 				}
 
+				// Apply network limits
+				if m["limits.max"] != "" || m["limits.ingress"] != "" || m["limits.egress"] != "" {
+					err = c.setNetworkLimits(k, m)
+					if err != nil {
+						return err
+					}
+				}
+
 				// Plugin in all character devices
 				if m["type"] == "infiniband" {
 					key := m["parent"]
@@ -4387,6 +4743,8 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		return err
 	}
 
+	containerCache.Forget(c.name)
+
 	/* we can call Update in some cases when the directory doesn't exist
 	 * yet before container creation; this is okay, because at the end of
 	 * container creation we write the backup file, so let's not worry about
@@ -5080,6 +5438,80 @@ func (c *containerLXC) templateApplyNow(trigger string) error {
 	return nil
 }
 
+// cloudInitSeed writes the user.user-data, user.vendor-data, user.meta-data
+// and user.network-config keys to a NoCloud seed in the container's rootfs.
+// Files already rendered by the image templates are left untouched and
+// images without cloud-init are skipped.
+func (c *containerLXC) cloudInitSeed() error {
+	seedKeys := []string{"user.user-data", "user.vendor-data", "user.meta-data", "user.network-config"}
+
+	found := false
+	for _, key := range seedKeys {
+		if c.expandedConfig[key] != "" {
+			found = true
+			break
+		}
+	}
+
+	if !found || !shared.PathExists(filepath.Join(c.RootfsPath(), "etc", "cloud")) {
+		return nil
+	}
+
+	files := map[string]string{
+		"meta-data": fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n%s", c.name, c.name, c.expandedConfig["user.meta-data"]),
+		"user-data": "#cloud-config\n",
+	}
+
+	if c.expandedConfig["user.user-data"] != "" {
+		files["user-data"] = c.expandedConfig["user.user-data"]
+	}
+
+	if c.expandedConfig["user.vendor-data"] != "" {
+		files["vendor-data"] = c.expandedConfig["user.vendor-data"]
+	}
+
+	if c.expandedConfig["user.network-config"] != "" {
+		files["network-config"] = c.expandedConfig["user.network-config"]
+	}
+
+	// Get the right uid and gid for the container
+	uid := int64(0)
+	gid := int64(0)
+	if !c.IsPrivileged() {
+		idmapset, err := c.IdmapSet()
+		if err != nil {
+			return err
+		}
+
+		uid, gid = idmapset.ShiftIntoNs(0, 0)
+	}
+
+	seedPath := filepath.Join(c.RootfsPath(), "var", "lib", "cloud", "seed", "nocloud-net")
+	err := shared.MkdirAllOwner(seedPath, 0755, int(uid), int(gid))
+	if err != nil {
+		return err
+	}
+
+	for name, content := range files {
+		fullpath := filepath.Join(seedPath, name)
+		if shared.PathExists(fullpath) {
+			continue
+		}
+
+		err := ioutil.WriteFile(fullpath, []byte(content), 0600)
+		if err != nil {
+			return err
+		}
+
+		err = os.Chown(fullpath, int(uid), int(gid))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (c *containerLXC) FileExists(path string) error {
 	// Setup container storage if needed
 	var ourStart bool
@@ -5126,14 +5558,14 @@ func (c *containerLXC) FileExists(path string) error {
 	return nil
 }
 
-func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, os.FileMode, string, []string, error) {
+func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, os.FileMode, string, string, []api.ContainerFileEntry, map[string]string, error) {
 	var ourStart bool
 	var err error
 	// Setup container storage if needed
 	if !c.IsRunning() {
 		ourStart, err = c.StorageStart()
 		if err != nil {
-			return -1, -1, 0, "", nil, err
+			return -1, -1, 0, "", "", nil, nil, err
 		}
 	}
 
@@ -5151,7 +5583,7 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 	if !c.IsRunning() && ourStart {
 		_, err := c.StorageStop()
 		if err != nil {
-			return -1, -1, 0, "", nil, err
+			return -1, -1, 0, "", "", nil, nil, err
 		}
 	}
 
@@ -5159,7 +5591,10 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 	gid := int64(-1)
 	mode := -1
 	type_ := "unknown"
-	var dirEnts []string
+	target := ""
+	var dirEnts []api.ContainerFileEntry
+	var entStat *api.ContainerFileEntry
+	var xattrs map[string]string
 	var errStr string
 
 	// Process forkgetfile response
@@ -5177,17 +5612,17 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 		if strings.HasPrefix(line, "errno: ") {
 			errno := strings.TrimPrefix(line, "errno: ")
 			if errno == "2" {
-				return -1, -1, 0, "", nil, os.ErrNotExist
+				return -1, -1, 0, "", "", nil, nil, os.ErrNotExist
 			}
 
-			return -1, -1, 0, "", nil, fmt.Errorf(errStr)
+			return -1, -1, 0, "", "", nil, nil, fmt.Errorf(errStr)
 		}
 
 		// Extract the uid
 		if strings.HasPrefix(line, "uid: ") {
 			uid, err = strconv.ParseInt(strings.TrimPrefix(line, "uid: "), 10, 64)
 			if err != nil {
-				return -1, -1, 0, "", nil, err
+				return -1, -1, 0, "", "", nil, nil, err
 			}
 
 			continue
@@ -5197,7 +5632,7 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 		if strings.HasPrefix(line, "gid: ") {
 			gid, err = strconv.ParseInt(strings.TrimPrefix(line, "gid: "), 10, 64)
 			if err != nil {
-				return -1, -1, 0, "", nil, err
+				return -1, -1, 0, "", "", nil, nil, err
 			}
 
 			continue
@@ -5207,20 +5642,63 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 		if strings.HasPrefix(line, "mode: ") {
 			mode, err = strconv.Atoi(strings.TrimPrefix(line, "mode: "))
 			if err != nil {
-				return -1, -1, 0, "", nil, err
+				return -1, -1, 0, "", "", nil, nil, err
 			}
 
 			continue
 		}
 
+		// Extract the target of the symlink at the path
+		if strings.HasPrefix(line, "target: ") {
+			target = strings.TrimPrefix(line, "target: ")
+			target = strings.Replace(target, "\x00", "\n", -1)
+			continue
+		}
+
 		if strings.HasPrefix(line, "type: ") {
 			type_ = strings.TrimPrefix(line, "type: ")
 			continue
 		}
 
+		// Extract the extended attributes
+		if strings.HasPrefix(line, "xattr: ") {
+			name, value, err := fileXattrParse(strings.TrimPrefix(line, "xattr: "))
+			if err != nil {
+				return -1, -1, 0, "", "", nil, nil, err
+			}
+
+			if xattrs == nil {
+				xattrs = map[string]string{}
+			}
+			xattrs[name] = value
+			continue
+		}
+
+		// Extract the details of the directory entry on the next line
+		if strings.HasPrefix(line, "entry_stat: ") {
+			entStat, err = fileEntryStatParse(strings.TrimPrefix(line, "entry_stat: "))
+			if err != nil {
+				return -1, -1, 0, "", "", nil, nil, err
+			}
+
+			continue
+		}
+
+		if strings.HasPrefix(line, "entry_target: ") && entStat != nil {
+			entStat.Target = strings.TrimPrefix(line, "entry_target: ")
+			entStat.Target = strings.Replace(entStat.Target, "\x00", "\n", -1)
+			continue
+		}
+
 		if strings.HasPrefix(line, "entry: ") {
-			ent := strings.TrimPrefix(line, "entry: ")
-			ent = strings.Replace(ent, "\x00", "\n", -1)
+			ent := api.ContainerFileEntry{Type: "unknown"}
+			if entStat != nil {
+				ent = *entStat
+				entStat = nil
+			}
+
+			ent.Name = strings.TrimPrefix(line, "entry: ")
+			ent.Name = strings.Replace(ent.Name, "\x00", "\n", -1)
 			dirEnts = append(dirEnts, ent)
 			continue
 		}
@@ -5229,44 +5707,92 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 	}
 
 	if err != nil {
-		return -1, -1, 0, "", nil, err
+		return -1, -1, 0, "", "", nil, nil, err
 	}
 
 	// Unmap uid and gid if needed
 	if !c.IsRunning() {
 		idmapset, err := c.LastIdmapSet()
 		if err != nil {
-			return -1, -1, 0, "", nil, err
+			return -1, -1, 0, "", "", nil, nil, err
 		}
 
 		if idmapset != nil {
 			uid, gid = idmapset.ShiftFromNs(uid, gid)
+			for i := range dirEnts {
+				dirEnts[i].UID, dirEnts[i].GID = idmapset.ShiftFromNs(dirEnts[i].UID, dirEnts[i].GID)
+			}
+
+			xattrs = fileXattrsShiftFromNs(idmapset, xattrs)
 		}
 	}
 
-	return uid, gid, os.FileMode(mode), type_, dirEnts, nil
+	return uid, gid, os.FileMode(mode), type_, target, dirEnts, xattrs, nil
 }
 
-func (c *containerLXC) FilePush(type_ string, srcpath string, dstpath string, uid int64, gid int64, mode int, write string) error {
+func (c *containerLXC) FilePush(type_ string, srcpath string, dstpath string, uid int64, gid int64, mode int, write string, xattrs map[string]string) error {
 	var rootUid int64
 	var rootGid int64
 	var errStr string
 
-	// Map uid and gid if needed
-	if !c.IsRunning() {
-		idmapset, err := c.LastIdmapSet()
+	// Tarball entries come with their extended attributes too
+	err := fileXattrsCheck(xattrs)
+	if err != nil {
+		return err
+	}
+
+	idmapset, err := c.LastIdmapSet()
+	if err != nil {
+		return err
+	}
+
+	if idmapset != nil {
+		// The ownership is the one seen in the container, so it has to
+		// be mapped, otherwise the file would end up owned by root
+		// (or fail to be created).
+		hostUid, hostGid := idmapset.ShiftIntoNs(uid, gid)
+		if uid != -1 && hostUid == -1 {
+			return fmt.Errorf("The uid %d isn't mapped in the container", uid)
+		}
+
+		if gid != -1 && hostGid == -1 {
+			return fmt.Errorf("The gid %d isn't mapped in the container", gid)
+		}
+
+		// Map uid and gid if needed, running containers get them
+		// mapped by their user namespace
+		if !c.IsRunning() {
+			uid, gid = hostUid, hostGid
+			rootUid, rootGid = idmapset.ShiftIntoNs(0, 0)
+
+			// Same for the ids in ACLs and file capabilities
+			xattrs, err = fileXattrsShiftIntoNs(idmapset, xattrs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// The extended attributes are passed through a file, since they can
+	// be large and binary
+	xattrsPath := ""
+	if len(xattrs) > 0 {
+		temp, err := ioutil.TempFile("", "lxd_forkputfile_xattrs_")
 		if err != nil {
 			return err
 		}
+		defer os.Remove(temp.Name())
 
-		if idmapset != nil {
-			uid, gid = idmapset.ShiftIntoNs(uid, gid)
-			rootUid, rootGid = idmapset.ShiftIntoNs(0, 0)
+		err = fileXattrsWrite(temp, xattrs)
+		temp.Close()
+		if err != nil {
+			return err
 		}
+
+		xattrsPath = temp.Name()
 	}
 
 	var ourStart bool
-	var err error
 	// Setup container storage if needed
 	if !c.IsRunning() {
 		ourStart, err = c.StorageStart()
@@ -5296,6 +5822,7 @@ func (c *containerLXC) FilePush(type_ string, srcpath string, dstpath string, ui
 		fmt.Sprintf("%d", rootGid),
 		fmt.Sprintf("%d", int(os.FileMode(defaultMode)&os.ModePerm)),
 		write,
+		xattrsPath,
 	)
 
 	// Tear down container storage if needed
@@ -5336,6 +5863,14 @@ func (c *containerLXC) FilePush(type_ string, srcpath string, dstpath string, ui
 }
 
 func (c *containerLXC) FileRemove(path string) error {
+	return c.fileRemove(path, false)
+}
+
+func (c *containerLXC) FileRemoveAll(path string) error {
+	return c.fileRemove(path, true)
+}
+
+func (c *containerLXC) fileRemove(path string, recursive bool) error {
 	var errStr string
 	var ourStart bool
 	var err error
@@ -5348,6 +5883,11 @@ func (c *containerLXC) FileRemove(path string) error {
 		}
 	}
 
+	mode := "single"
+	if recursive {
+		mode = "recursive"
+	}
+
 	// Remove the file from the container
 	out, err := shared.RunCommand(
 		c.state.OS.ExecPath,
@@ -5355,6 +5895,7 @@ func (c *containerLXC) FileRemove(path string) error {
 		c.RootfsPath(),
 		fmt.Sprintf("%d", c.InitPID()),
 		path,
+		mode,
 	)
 
 	// Tear down container storage if needed
@@ -5422,14 +5963,14 @@ func (c *containerLXC) ConsoleLog(opts lxc.ConsoleLogOptions) (string, error) {
 	return string(msg), nil
 }
 
-func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool) (*exec.Cmd, int, int, error) {
+func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool, cwd string, uid uint32, gid uint32) (*exec.Cmd, int, int, error) {
 	envSlice := []string{}
 
 	for k, v := range env {
 		envSlice = append(envSlice, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	args := []string{c.state.OS.ExecPath, "forkexec", c.name, c.state.OS.LxcPath, filepath.Join(c.LogPath(), "lxc.conf")}
+	args := []string{c.state.OS.ExecPath, "forkexec", c.name, c.state.OS.LxcPath, filepath.Join(c.LogPath(), "lxc.conf"), cwd, fmt.Sprintf("%d", uid), fmt.Sprintf("%d", gid)}
 
 	args = append(args, "--")
 	args = append(args, "env")
@@ -5589,6 +6130,12 @@ func (c *containerLXC) memoryState() api.ContainerStateMemory {
 		}
 	}
 
+	// Processes killed by the OOM killer
+	oomKills, err := containerOOMKillsGet(c)
+	if err == nil {
+		memory.OOMKills = oomKills
+	}
+
 	return memory
 }
 
@@ -5674,6 +6221,22 @@ func (c *containerLXC) processesState() int64 {
 }
 
 func (c *containerLXC) tarStoreFile(linkmap map[uint64]string, offset int, tw *tar.Writer, path string, fi os.FileInfo) error {
+	// Unshift the id under /rootfs/ for unpriv containers
+	var idmapset *idmap.IdmapSet
+	if !c.IsPrivileged() && strings.HasPrefix(path[offset:], "/rootfs") {
+		var err error
+		idmapset, err = c.IdmapSet()
+		if err != nil {
+			return err
+		}
+	}
+
+	return tarStoreFile(linkmap, path[offset:], tw, path, fi, idmapset)
+}
+
+// tarStoreFile writes the file at the given path to the tarball under the
+// given name, unshifting its ownership with the idmap set if one is given.
+func tarStoreFile(linkmap map[uint64]string, name string, tw *tar.Writer, path string, fi os.FileInfo, idmapset *idmap.IdmapSet) error {
 	var err error
 	var major, minor, nlink int
 	var ino uint64
@@ -5696,7 +6259,7 @@ func (c *containerLXC) tarStoreFile(linkmap map[uint64]string, offset int, tw *t
 		return fmt.Errorf("failed to create tar info header: %s", err)
 	}
 
-	hdr.Name = path[offset:]
+	hdr.Name = name
 	if fi.IsDir() || fi.Mode()&os.ModeSymlink == os.ModeSymlink {
 		hdr.Size = 0
 	} else {
@@ -5708,13 +6271,7 @@ func (c *containerLXC) tarStoreFile(linkmap map[uint64]string, offset int, tw *t
 		return fmt.Errorf("failed to get file stat: %s", err)
 	}
 
-	// Unshift the id under /rootfs/ for unpriv containers
-	if !c.IsPrivileged() && strings.HasPrefix(hdr.Name, "/rootfs") {
-		idmapset, err := c.IdmapSet()
-		if err != nil {
-			return err
-		}
-
+	if idmapset != nil {
 		huid, hgid := idmapset.ShiftFromNs(int64(hdr.Uid), int64(hdr.Gid))
 		hdr.Uid = int(huid)
 		hdr.Gid = int(hgid)
@@ -6036,7 +6593,7 @@ func (c *containerLXC) createUnixDevice(prefix string, m types.Device) ([]string
 		}
 		f.Close()
 
-		err = deviceMountDisk(srcPath, devPath, false, false)
+		err = deviceMountDisk(srcPath, devPath, false, false, "")
 		if err != nil {
 			return nil, err
 		}
@@ -6522,6 +7079,14 @@ func (c *containerLXC) createNetworkDevice(name string, m types.Device) (string,
 				return "", fmt.Errorf("Failed to add interface to bridge: %s", err)
 			}
 
+			if m["vlan"] != "" {
+				err = networkSetInterfaceVlan(m["parent"], n1, m["vlan"])
+				if err != nil {
+					deviceRemoveInterface(n2)
+					return "", fmt.Errorf("Failed to set VLAN on bridge port: %s", err)
+				}
+			}
+
 			// Attempt to disable IPv6 on the host side interface
 			networkSysctl(fmt.Sprintf("ipv6/conf/%s/disable_ipv6", n1), "1")
 		}
@@ -6639,6 +7204,7 @@ func (c *containerLXC) fillSriovNetworkDevice(name string, m types.Device, reser
 
 	// Check if any VFs are already enabled
 	nicName := ""
+	vfID := -1
 	for i := 0; i < sriovNum; i++ {
 		if !shared.PathExists(fmt.Sprintf("/sys/class/net/%s/device/virtfn%d/net", m["parent"], i)) {
 			continue
@@ -6666,6 +7232,7 @@ func (c *containerLXC) fillSriovNetworkDevice(name string, m types.Device, reser
 			}
 
 			nicName = ent.Name()
+			vfID = i
 			break
 		}
 
@@ -6687,7 +7254,7 @@ func (c *containerLXC) fillSriovNetworkDevice(name string, m types.Device, reser
 		}
 
 		// use next free VF index
-		for i := sriovNum + 1; i < sriovTotal; i++ {
+		for i := sriovNum; i < sriovTotal; i++ {
 			vf := fmt.Sprintf("/sys/class/net/%s/device/virtfn%d/net", m["parent"], i)
 			ents, err := ioutil.ReadDir(vf)
 			if err != nil {
@@ -6705,6 +7272,7 @@ func (c *containerLXC) fillSriovNetworkDevice(name string, m types.Device, reser
 
 			// found a free one
 			nicName = ents[0].Name()
+			vfID = i
 			break
 		}
 	}
@@ -6713,6 +7281,31 @@ func (c *containerLXC) fillSriovNetworkDevice(name string, m types.Device, reser
 		return nil, fmt.Errorf("All virtual functions on device \"%s\" are already in use", name)
 	}
 
+	// Set the MAC address and VLAN of the VF from the parent so that
+	// they can't be changed from within the container.
+	if m["type"] == "nic" && vfID >= 0 {
+		filled, err := c.fillNetworkDevice(name, m)
+		if err != nil {
+			return nil, err
+		}
+
+		args := []string{"link", "set", "dev", m["parent"], "vf", strconv.Itoa(vfID)}
+		if filled["hwaddr"] != "" {
+			args = append(args, "mac", filled["hwaddr"])
+		}
+
+		if m["vlan"] != "" {
+			args = append(args, "vlan", m["vlan"])
+		}
+
+		if len(args) > 6 {
+			out, err := shared.RunCommand("ip", args...)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to configure virtual function %d of \"%s\": %s", vfID, m["parent"], out)
+			}
+		}
+	}
+
 	newDevice["host_name"] = nicName
 	configKey := fmt.Sprintf("volatile.%s.host_name", name)
 	c.localConfig[configKey] = nicName
@@ -6807,11 +7400,13 @@ func (c *containerLXC) fillNetworkDevice(name string, m types.Device) (types.Dev
 			return err
 		}
 
+		containerCache.Forget(c.name)
+
 		return nil
 	}
 
 	// Fill in the MAC address
-	if m["nictype"] != "physical" && m["hwaddr"] == "" && m["type"] != "infiniband" {
+	if !shared.StringInSlice(m["nictype"], []string{"ipvlan", "physical"}) && m["hwaddr"] == "" && m["type"] != "infiniband" {
 		configKey := fmt.Sprintf("volatile.%s.hwaddr", name)
 		volatileHwaddr := c.localConfig[configKey]
 		if volatileHwaddr == "" {
@@ -6964,6 +7559,10 @@ func (c *containerLXC) insertNetworkDevice(name string, m types.Device) (types.D
 		return nil, fmt.Errorf("Parent device '%s' doesn't exist", m["parent"])
 	}
 
+	if shared.StringInSlice(m["nictype"], []string{"ipvlan", "routed"}) {
+		return nil, fmt.Errorf("%s nics can't be added to a running container", m["nictype"])
+	}
+
 	// Return empty list if not running
 	if !c.IsRunning() {
 		return nil, fmt.Errorf("Can't insert device into stopped container")
@@ -7052,6 +7651,12 @@ func (c *containerLXC) createDiskDevice(name string, m types.Device) (string, er
 	isOptional := shared.IsTrue(m["optional"])
 	isReadOnly := shared.IsTrue(m["readonly"])
 	isRecursive := shared.IsTrue(m["recursive"])
+	isShifted := shared.IsTrue(m["shift"]) && !c.IsPrivileged()
+
+	// Shifting requires kernel support
+	if isShifted && !util.HasFilesystem("shiftfs") {
+		return "", fmt.Errorf("The shift option requires shiftfs support in the kernel")
+	}
 
 	isFile := false
 	if m["pool"] == "" {
@@ -7160,11 +7765,20 @@ func (c *containerLXC) createDiskDevice(name string, m types.Device) (string, er
 	}
 
 	// Mount the fs
-	err := deviceMountDisk(srcPath, devPath, isReadOnly, isRecursive)
+	err := deviceMountDisk(srcPath, devPath, isReadOnly, isRecursive, m["propagation"])
 	if err != nil {
 		return "", err
 	}
 
+	// Mark the mount for shifting into the container's namespace
+	if isShifted {
+		err = syscall.Mount(devPath, devPath, "shiftfs", 0, "mark,passthrough=3")
+		if err != nil {
+			syscall.Unmount(devPath, syscall.MNT_DETACH)
+			return "", fmt.Errorf("Unable to mark %s for shifting: %s", devPath, err)
+		}
+	}
+
 	return devPath, nil
 }
 
@@ -7176,6 +7790,11 @@ func (c *containerLXC) insertDiskDevice(name string, m types.Device) error {
 
 	isRecursive := shared.IsTrue(m["recursive"])
 
+	// Shifted mounts must be setup within the container's user namespace
+	if shared.IsTrue(m["shift"]) && !c.IsPrivileged() {
+		return fmt.Errorf("Shifted disks can't be added to a running container")
+	}
+
 	// Create the device on the host
 	devPath, err := c.createDiskDevice(name, m)
 	if err != nil {
@@ -7267,6 +7886,14 @@ func (c *containerLXC) removeDiskDevice(name string, m types.Device) error {
 		return err
 	}
 
+	// Shifted disks have their shiftfs mark on top of the bind-mount
+	if shared.IsMountPoint(devPath) {
+		err = syscall.Unmount(devPath, syscall.MNT_DETACH)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Remove the host side
 	err = os.Remove(devPath)
 	if err != nil {
@@ -7295,8 +7922,12 @@ func (c *containerLXC) removeDiskDevices() error {
 			continue
 		}
 
-		// Always try to unmount the host side
+		// Always try to unmount the host side (possibly twice for
+		// shifted disks)
 		_ = syscall.Unmount(filepath.Join(c.DevicesPath(), f.Name()), syscall.MNT_DETACH)
+		if shared.IsMountPoint(filepath.Join(c.DevicesPath(), f.Name())) {
+			_ = syscall.Unmount(filepath.Join(c.DevicesPath(), f.Name()), syscall.MNT_DETACH)
+		}
 
 		// Remove the entry
 		diskPath := filepath.Join(c.DevicesPath(), f.Name())
@@ -7348,13 +7979,15 @@ func (c *containerLXC) getDiskLimits() (map[string]deviceBlockLimit, error) {
 		}
 
 		// Apply max limit
+		readLimit := m["limits.read"]
+		writeLimit := m["limits.write"]
 		if m["limits.max"] != "" {
-			m["limits.read"] = m["limits.max"]
-			m["limits.write"] = m["limits.max"]
+			readLimit = m["limits.max"]
+			writeLimit = m["limits.max"]
 		}
 
 		// Parse the user input
-		readBps, readIops, writeBps, writeIops, err := deviceParseDiskLimit(m["limits.read"], m["limits.write"])
+		readBps, readIops, writeBps, writeIops, err := deviceParseDiskLimit(readLimit, writeLimit)
 		if err != nil {
 			return nil, err
 		}
@@ -7549,8 +8182,8 @@ func (c *containerLXC) getHostInterface(name string) string {
 
 func (c *containerLXC) setNetworkLimits(name string, m types.Device) error {
 	// We can only do limits on some network type
-	if m["nictype"] != "bridged" && m["nictype"] != "p2p" {
-		return fmt.Errorf("Network limits are only supported on bridged and p2p interfaces")
+	if !shared.StringInSlice(m["nictype"], []string{"bridged", "p2p", "routed"}) {
+		return fmt.Errorf("Network limits are only supported on bridged, p2p and routed interfaces")
 	}
 
 	// Check that the container is running
@@ -7561,7 +8194,7 @@ func (c *containerLXC) setNetworkLimits(name string, m types.Device) error {
 	// Fill in some fields from volatile
 	m, err := c.fillNetworkDevice(name, m)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	// Look for the host side interface name
@@ -7630,6 +8263,112 @@ func (c *containerLXC) setNetworkLimits(name string, m types.Device) error {
 	return nil
 }
 
+func (c *containerLXC) setNetworkRoutes(name string, m types.Device) error {
+	// Check that the container is running
+	if !c.IsRunning() {
+		return fmt.Errorf("Can't set network routes on stopped container")
+	}
+
+	// Fill in some fields from volatile
+	m, err := c.fillNetworkDevice(name, m)
+	if err != nil {
+		return err
+	}
+
+	// Look for the host side interface name
+	veth := c.getHostInterface(m["name"])
+	if veth == "" {
+		return fmt.Errorf("LXC doesn't know about this device and the host_name property isn't set, can't find host side veth name")
+	}
+
+	for _, family := range []string{"ipv4", "ipv6"} {
+		if m[family+".address"] == "" {
+			continue
+		}
+
+		ipFlag := "-4"
+		gateway := networkRoutedGatewayV4 + "/32"
+		if family == "ipv6" {
+			ipFlag = "-6"
+			gateway = networkRoutedGatewayV6 + "/64"
+		}
+
+		// Give the host side the address the container uses as its gateway
+		out, err := shared.RunCommand("ip", ipFlag, "addr", "add", gateway, "dev", veth)
+		if err != nil {
+			return fmt.Errorf("Failed to add gateway address to %s: %s", veth, out)
+		}
+
+		if family == "ipv6" && m["parent"] != "" {
+			err = networkSysctl(fmt.Sprintf("ipv6/conf/%s/proxy_ndp", m["parent"]), "1")
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, addr := range networkRoutedAddresses(m[family+".address"], family) {
+			out, err := shared.RunCommand("ip", ipFlag, "route", "add", addr, "dev", veth)
+			if err != nil {
+				return fmt.Errorf("Failed to add route to %s: %s", addr, out)
+			}
+
+			// Answer ARP/NDP requests for the address on the parent
+			if m["parent"] != "" {
+				ip := strings.SplitN(addr, "/", 2)[0]
+				out, err = shared.RunCommand("ip", ipFlag, "neigh", "replace", "proxy", ip, "dev", m["parent"])
+				if err != nil {
+					return fmt.Errorf("Failed to add proxy neighbour entry for %s: %s", ip, out)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *containerLXC) removeNetworkRoutes() {
+	for _, k := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[k]
+		if m["type"] != "nic" || m["nictype"] != "routed" || m["parent"] == "" {
+			continue
+		}
+
+		// The routes went away with the veth, only the proxy entries remain
+		for _, family := range []string{"ipv4", "ipv6"} {
+			ipFlag := "-4"
+			if family == "ipv6" {
+				ipFlag = "-6"
+			}
+
+			for _, addr := range networkRoutedAddresses(m[family+".address"], family) {
+				ip := strings.SplitN(addr, "/", 2)[0]
+				shared.RunCommand("ip", ipFlag, "neigh", "del", "proxy", ip, "dev", m["parent"])
+			}
+		}
+	}
+}
+
+func (c *containerLXC) setNetworkVlan(name string, m types.Device) error {
+	// Check that the container is running
+	if !c.IsRunning() {
+		return fmt.Errorf("Can't set network VLAN on stopped container")
+	}
+
+	// Fill in some fields from volatile
+	m, err := c.fillNetworkDevice(name, m)
+	if err != nil {
+		return err
+	}
+
+	// Look for the host side interface name
+	veth := c.getHostInterface(m["name"])
+	if veth == "" {
+		return fmt.Errorf("LXC doesn't know about this device and the host_name property isn't set, can't find host side veth name")
+	}
+
+	return networkSetInterfaceVlan(m["parent"], veth, m["vlan"])
+}
+
 // Various state query functions
 func (c *containerLXC) IsStateful() bool {
 	return c.stateful
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/logger"
+	"golang.org/x/net/context"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// The OOM kill counters last seen for each running container, so that only
+// new kills get reported. The kills which happened before LXD started are
+// only recorded.
+var containerOOMKillsLock sync.Mutex
+var containerOOMKills map[string]int64
+
+func containerOOMTask(s *state.State) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		err := containerOOMCheck(ctx, s)
+		if err != nil {
+			logger.Error("Failed to check for OOM kills", log.Ctx{"err": err})
+		}
+	}
+
+	return f, task.Every(10 * time.Second)
+}
+
+// containerOOMCheck goes through all the running containers and reports
+// those whose processes got killed by the OOM killer since the last check.
+func containerOOMCheck(ctx context.Context, s *state.State) error {
+	if !s.OS.CGroupMemoryController {
+		return nil
+	}
+
+	names, err := s.DB.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return err
+	}
+
+	containerOOMKillsLock.Lock()
+	defer containerOOMKillsLock.Unlock()
+
+	seen := map[string]int64{}
+	for _, name := range names {
+		select {
+		case <-ctx.Done():
+			return nil // Context expired
+		default:
+		}
+
+		c, err := containerLoadByName(s, name)
+		if err != nil || !c.IsRunning() {
+			continue
+		}
+
+		kills, err := containerOOMKillsGet(c)
+		if err != nil {
+			continue
+		}
+		seen[name] = kills
+
+		if containerOOMKills == nil {
+			continue
+		}
+
+		// The counter starts over whenever the container is restarted
+		last := containerOOMKills[name]
+		if kills <= last {
+			continue
+		}
+
+		logger.Warn("Container processes killed by the OOM killer", log.Ctx{"container": name, "oom_kills": kills, "new": kills - last})
+		eventSendLifecycle("container-oom", eventContainerSource(name), map[string]interface{}{"oom_kills": kills}, nil)
+	}
+
+	containerOOMKills = seen
+	return nil
+}
+
+// containerOOMKillsGet returns how many processes of the container got killed
+// by the OOM killer since it was started.
+func containerOOMKillsGet(c container) (int64, error) {
+	value, err := c.CGroupGet("memory.oom_control")
+	if err != nil {
+		return -1, err
+	}
+
+	return parseOOMControl(value)
+}
+
+// parseOOMControl extracts the OOM kill counter from the content of the
+// memory.oom_control cgroup file. Kernels older than 4.13 don't have it.
+func parseOOMControl(content string) (int64, error) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "oom_kill" {
+			continue
+		}
+
+		return strconv.ParseInt(fields[1], 10, 64)
+	}
+
+	return -1, fmt.Errorf("No OOM kill counter in memory.oom_control")
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOOMControl(t *testing.T) {
+	kills, err := parseOOMControl("oom_kill_disable 0\nunder_oom 0\noom_kill 3")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), kills)
+
+	// Kernels before 4.13 don't count the kills
+	_, err = parseOOMControl("oom_kill_disable 0\nunder_oom 0")
+	assert.Error(t, err)
+}
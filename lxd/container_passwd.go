@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// An entry of the /etc/passwd file of a container
+type passwdEntry struct {
+	name  string
+	uid   uint32
+	gid   uint32
+	home  string
+	shell string
+}
+
+// containerPasswdLookup returns the entry of the user with the given uid in
+// the /etc/passwd file of the container, or nil if there's none.
+func containerPasswdLookup(c container, uid uint32) (*passwdEntry, error) {
+	temp, err := ioutil.TempFile("", "lxd_passwd_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	_, _, _, type_, _, _, _, err := c.FilePull("/etc/passwd", temp.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	if type_ != "file" {
+		return nil, fmt.Errorf("/etc/passwd isn't a file")
+	}
+
+	return passwdLookup(temp, uid)
+}
+
+// Find the entry of the user with the given uid in the given passwd file,
+// skipping malformed lines.
+func passwdLookup(r io.Reader, uid uint32) (*passwdEntry, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) != 7 {
+			continue
+		}
+
+		entryUid, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil || uint32(entryUid) != uid {
+			continue
+		}
+
+		entryGid, err := strconv.ParseUint(fields[3], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		return &passwdEntry{
+			name:  fields[0],
+			uid:   uid,
+			gid:   uint32(entryGid),
+			home:  fields[5],
+			shell: fields[6],
+		}, nil
+	}
+
+	return nil, scanner.Err()
+}
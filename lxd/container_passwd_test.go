@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPasswd = `root:x:0:0:root:/root:/bin/bash
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+broken:x:1000
+ubuntu:x:1000:1000:Ubuntu:/home/ubuntu:/bin/zsh
+`
+
+func TestPasswdLookup(t *testing.T) {
+	entry, err := passwdLookup(strings.NewReader(testPasswd), 1000)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	assert.Equal(t, "ubuntu", entry.name)
+	assert.Equal(t, uint32(1000), entry.gid)
+	assert.Equal(t, "/home/ubuntu", entry.home)
+	assert.Equal(t, "/bin/zsh", entry.shell)
+}
+
+func TestPasswdLookup_NotFound(t *testing.T) {
+	entry, err := passwdLookup(strings.NewReader(testPasswd), 1001)
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
@@ -100,6 +100,32 @@ func containerPatch(d *Daemon, r *http.Request) Response {
 		}
 	}
 
+	// Validate the new configuration
+	err = containerValidConfig(d.os, req.Config, false, false)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = containerValidDevices(d.db, req.Devices, false, false)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = containerCheckVolatile(c.LocalConfig(), req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = containerCheckPrivileged(d, r, req.Config, req.Profiles)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = containerCheckRestrictedDevices(d, r, c, req.Devices, req.Profiles)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	// Update container configuration
 	args := db.ContainerArgs{
 		Architecture: architecture,
@@ -114,5 +140,7 @@ func containerPatch(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	eventSendLifecycle("container-updated", eventContainerSource(name), nil, eventRequestor(r))
+
 	return EmptySyncResponse
 }
@@ -3,13 +3,19 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
 
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
 )
 
 func containerPost(d *Daemon, r *http.Request) Response {
@@ -57,7 +63,7 @@ func containerPost(d *Daemon, r *http.Request) Response {
 
 		if req.Target != nil {
 			// Push mode
-			err := ws.ConnectTarget(*req.Target)
+			err := ws.ConnectTarget(*req.Target, d.proxy)
 			if err != nil {
 				return InternalError(err)
 			}
@@ -79,14 +85,50 @@ func containerPost(d *Daemon, r *http.Request) Response {
 		return OperationResponse(op)
 	}
 
+	if req.Pool != "" {
+		if req.Name != "" && req.Name != name {
+			return BadRequest(fmt.Errorf("Renaming and moving a container to another storage pool can't be done at the same time"))
+		}
+
+		if c.IsRunning() {
+			return BadRequest(fmt.Errorf("Only stopped containers can be moved to another storage pool"))
+		}
+
+		_, err := d.db.StoragePoolGetID(req.Pool)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		run := func(op *operation) error {
+			return containerMoveToPool(d.State(), c, req.Pool)
+		}
+
+		resources := map[string][]string{}
+		resources["containers"] = []string{name}
+
+		op, err := operationCreate(operationClassTask, resources, nil, run, nil, nil)
+		if err != nil {
+			return InternalError(err)
+		}
+
+		return OperationResponse(op)
+	}
+
 	// Check that the name isn't already in use
 	id, _ := d.db.ContainerId(req.Name)
 	if id > 0 {
 		return Conflict
 	}
 
+	requestor := eventRequestor(r)
 	run := func(*operation) error {
-		return c.Rename(req.Name)
+		err := c.Rename(req.Name)
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-renamed", eventContainerSource(req.Name), map[string]interface{}{"old_name": name}, requestor)
+		return nil
 	}
 
 	resources := map[string][]string{}
@@ -99,3 +141,157 @@ func containerPost(d *Daemon, r *http.Request) Response {
 
 	return OperationResponse(op)
 }
+
+// containerMoveToPool relocates a stopped container and its snapshots to
+// another storage pool. A copy is created under a temporary name on the target
+// pool, the data is transferred with rsync, the source is removed and the copy
+// finally takes over the original name.
+func containerMoveToPool(s *state.State, c container, poolName string) error {
+	rootDiskDeviceKey, rootDiskDevice, err := containerGetRootDiskDevice(c.ExpandedDevices())
+	if err != nil {
+		return err
+	}
+
+	if rootDiskDevice["pool"] == poolName {
+		return fmt.Errorf("The container is already on storage pool \"%s\"", poolName)
+	}
+
+	// Point the root disk device to the new pool, adding a local one if
+	// the root disk device currently comes from a profile.
+	withPool := func(devices types.Devices) types.Devices {
+		newDevices := types.Devices{}
+		for k, v := range devices {
+			newDevices[k] = v
+		}
+
+		localRootDiskDeviceKey, localRootDiskDevice, err := containerGetRootDiskDevice(newDevices)
+		if err != nil {
+			localRootDiskDeviceKey = rootDiskDeviceKey
+			localRootDiskDevice = rootDiskDevice
+		}
+
+		device := types.Device{}
+		for k, v := range localRootDiskDevice {
+			device[k] = v
+		}
+		device["pool"] = poolName
+		newDevices[localRootDiskDeviceKey] = device
+
+		return newDevices
+	}
+
+	name := c.Name()
+	tmpName := fmt.Sprintf("lxd-move-of-%s", uuid.NewRandom().String())
+
+	args := db.ContainerArgs{
+		Architecture: c.Architecture(),
+		Config:       c.LocalConfig(),
+		Ctype:        db.CTypeRegular,
+		Description:  c.Description(),
+		Devices:      withPool(c.LocalDevices()),
+		Ephemeral:    c.IsEphemeral(),
+		Name:         tmpName,
+		Profiles:     c.Profiles(),
+	}
+
+	revert := revertSteps{}
+	defer revert.Fail()
+
+	target, err := containerCreateInternal(s, args)
+	if err != nil {
+		return err
+	}
+	revert.Add(func() { target.Delete() })
+
+	err = target.Storage().ContainerCreate(target)
+	if err != nil {
+		return err
+	}
+
+	transfer := func(source container) error {
+		ourStart, err := source.StorageStart()
+		if err != nil {
+			return err
+		}
+		if ourStart {
+			defer source.StorageStop()
+		}
+
+		logger.Debugf("Transferring \"%s\" to storage pool \"%s\"", source.Name(), poolName)
+		output, err := rsyncLocalCopy(source.Path(), target.Path(), "")
+		if err != nil {
+			return fmt.Errorf("Failed to rsync container: %s: %s", output, err)
+		}
+
+		return nil
+	}
+
+	// Snapshots are transferred oldest first, each one being synced into
+	// the target container and then snapshotted there.
+	transferAll := func() error {
+		snapshots, err := c.Snapshots()
+		if err != nil {
+			return err
+		}
+
+		for _, snap := range snapshots {
+			err := transfer(snap)
+			if err != nil {
+				return err
+			}
+
+			_, snapOnlyName, _ := containerGetParentAndSnapshotName(snap.Name())
+			snapArgs := db.ContainerArgs{
+				Architecture: snap.Architecture(),
+				Config:       snap.LocalConfig(),
+				Ctype:        db.CTypeSnapshot,
+				Description:  snap.Description(),
+				Devices:      withPool(snap.LocalDevices()),
+				Ephemeral:    snap.IsEphemeral(),
+				Name:         fmt.Sprintf("%s%s%s", tmpName, shared.SnapshotDelimiter, snapOnlyName),
+				Profiles:     snap.Profiles(),
+			}
+
+			_, err = containerCreateAsSnapshot(s, snapArgs, target)
+			if err != nil {
+				return err
+			}
+		}
+
+		return transfer(c)
+	}
+
+	ourStart, err := target.StorageStart()
+	if err != nil {
+		return err
+	}
+
+	err = transferAll()
+	if ourStart {
+		target.StorageStop()
+	}
+	if err != nil {
+		return err
+	}
+
+	err = containerConfigureInternal(target)
+	if err != nil {
+		return err
+	}
+
+	// Drop the source and let the copy take over its name. The source may
+	// be partly gone even if deleting it fails, so from there on the copy
+	// must be kept.
+	revert.Success()
+	err = c.Delete()
+	if err != nil {
+		return fmt.Errorf("Failed to delete the container from its former storage pool, its copy was kept as \"%s\": %s", tmpName, err)
+	}
+
+	err = target.Rename(name)
+	if err != nil {
+		return fmt.Errorf("The container was moved but couldn't be renamed from \"%s\" back to \"%s\": %s", tmpName, name, err)
+	}
+
+	return nil
+}
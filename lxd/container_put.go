@@ -46,7 +46,34 @@ func containerPut(d *Daemon, r *http.Request) Response {
 	}
 
 	var do func(*operation) error
+	requestor := eventRequestor(r)
 	if configRaw.Restore == "" {
+		// Validate the new configuration before starting the operation
+		err = containerValidConfig(d.os, configRaw.Config, false, false)
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		err = containerValidDevices(d.db, configRaw.Devices, false, false)
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		err = containerCheckVolatile(c.LocalConfig(), configRaw.Config)
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		err = containerCheckPrivileged(d, r, configRaw.Config, configRaw.Profiles)
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		err = containerCheckRestrictedDevices(d, r, c, configRaw.Devices, configRaw.Profiles)
+		if err != nil {
+			return BadRequest(err)
+		}
+
 		// Update container configuration
 		do = func(op *operation) error {
 			args := db.ContainerArgs{
@@ -63,12 +90,19 @@ func containerPut(d *Daemon, r *http.Request) Response {
 				return err
 			}
 
+			eventSendLifecycle("container-updated", eventContainerSource(name), nil, requestor)
 			return nil
 		}
 	} else {
 		// Snapshot Restore
 		do = func(op *operation) error {
-			return containerSnapRestore(d.State(), name, configRaw.Restore, configRaw.Stateful)
+			err := containerSnapRestore(d.State(), name, configRaw.Restore, configRaw.Stateful)
+			if err != nil {
+				return err
+			}
+
+			eventSendLifecycle("container-restored", eventContainerSource(name), map[string]interface{}{"snapshot": configRaw.Restore}, requestor)
+			return nil
 		}
 	}
 
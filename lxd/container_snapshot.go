@@ -102,6 +102,7 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 		shared.SnapshotDelimiter +
 		req.Name
 
+	requestor := eventRequestor(r)
 	snapshot := func(op *operation) error {
 		args := db.ContainerArgs{
 			Name:         fullName,
@@ -120,6 +121,7 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 			return err
 		}
 
+		eventSendLifecycle("container-snapshot-created", eventContainerSource(fullName), nil, requestor)
 		return nil
 	}
 
@@ -153,7 +155,7 @@ func snapshotHandler(d *Daemon, r *http.Request) Response {
 	case "POST":
 		return snapshotPost(d, r, sc, containerName)
 	case "DELETE":
-		return snapshotDelete(sc, snapshotName)
+		return snapshotDelete(r, sc, snapshotName)
 	default:
 		return NotFound
 	}
@@ -223,7 +225,7 @@ func snapshotPost(d *Daemon, r *http.Request, sc container, containerName string
 
 		if req.Target != nil {
 			// Push mode
-			err := ws.ConnectTarget(*req.Target)
+			err := ws.ConnectTarget(*req.Target, d.proxy)
 			if err != nil {
 				return InternalError(err)
 			}
@@ -263,8 +265,16 @@ func snapshotPost(d *Daemon, r *http.Request, sc container, containerName string
 		return Conflict
 	}
 
+	oldName := sc.Name()
+	requestor := eventRequestor(r)
 	rename := func(op *operation) error {
-		return sc.Rename(fullName)
+		err := sc.Rename(fullName)
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-snapshot-renamed", eventContainerSource(fullName), map[string]interface{}{"old_name": oldName}, requestor)
+		return nil
 	}
 
 	resources := map[string][]string{}
@@ -278,9 +288,16 @@ func snapshotPost(d *Daemon, r *http.Request, sc container, containerName string
 	return OperationResponse(op)
 }
 
-func snapshotDelete(sc container, name string) Response {
+func snapshotDelete(r *http.Request, sc container, name string) Response {
+	requestor := eventRequestor(r)
 	remove := func(op *operation) error {
-		return sc.Delete()
+		err := sc.Delete()
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-snapshot-deleted", eventContainerSource(sc.Name()), nil, requestor)
+		return nil
 	}
 
 	resources := map[string][]string{}
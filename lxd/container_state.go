@@ -156,10 +156,29 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("unknown action %s", raw.Action))
 	}
 
+	action := map[shared.ContainerAction]string{
+		shared.Start:    "container-started",
+		shared.Stop:     "container-stopped",
+		shared.Restart:  "container-restarted",
+		shared.Freeze:   "container-paused",
+		shared.Unfreeze: "container-resumed",
+	}[shared.ContainerAction(raw.Action)]
+
+	requestor := eventRequestor(r)
+	run := func(op *operation) error {
+		err := do(op)
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle(action, eventContainerSource(name), nil, requestor)
+		return nil
+	}
+
 	resources := map[string][]string{}
 	resources["containers"] = []string{name}
 
-	op, err := operationCreate(operationClassTask, resources, nil, do, nil, nil)
+	op, err := operationCreate(operationClassTask, resources, nil, run, nil, nil)
 	if err != nil {
 		return InternalError(err)
 	}
@@ -3,12 +3,14 @@ package main
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
+	"github.com/lxc/lxd/shared/osarch"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -156,6 +158,14 @@ func (suite *containerTestSuite) TestContainer_Path_Regular() {
 }
 
 func (suite *containerTestSuite) TestContainer_Path_Snapshot() {
+	// Parent of the snapshot
+	parent, err := containerCreateInternal(suite.d.State(), db.ContainerArgs{
+		Ctype: db.CTypeRegular,
+		Name:  "test",
+	})
+	suite.Req.Nil(err)
+	defer parent.Delete()
+
 	// Snapshot
 	args := db.ContainerArgs{
 		Ctype:     db.CTypeSnapshot,
@@ -317,6 +327,42 @@ func (suite *containerTestSuite) TestContainer_findIdmap_mixed() {
 	}
 }
 
+func (suite *containerTestSuite) TestContainer_findIdmap_unallocated() {
+	c1, err := containerCreateInternal(suite.d.State(), db.ContainerArgs{
+		Ctype: db.CTypeRegular,
+		Name:  "isol-1",
+		Config: map[string]string{
+			"security.idmap.isolated": "true",
+		},
+	})
+	suite.Req.Nil(err)
+	defer c1.Delete()
+
+	// A container without an allocated range must not be accounted for
+	err = c1.ConfigKeySet("volatile.idmap.base", "")
+	suite.Req.Nil(err)
+
+	c2, err := containerCreateInternal(suite.d.State(), db.ContainerArgs{
+		Ctype: db.CTypeRegular,
+		Name:  "isol-2",
+		Config: map[string]string{
+			"security.idmap.isolated": "true",
+		},
+	})
+	suite.Req.Nil(err)
+	defer c2.Delete()
+
+	map2, err := c2.(*containerLXC).NextIdmapSet()
+	suite.Req.Nil(err)
+
+	host := suite.d.os.IdmapSet.Idmap[0]
+
+	for i := 0; i < 2; i++ {
+		suite.Req.Equal(host.Hostid+65536, map2.Idmap[i].Hostid, "hostids don't match")
+		suite.Req.Equal(int64(65536), map2.Idmap[i].Maprange, "incorrect maprange")
+	}
+}
+
 func (suite *containerTestSuite) TestContainer_findIdmap_raw() {
 	c1, err := containerCreateInternal(suite.d.State(), db.ContainerArgs{
 		Ctype: db.CTypeRegular,
@@ -353,6 +399,18 @@ func (suite *containerTestSuite) TestContainer_findIdmap_raw() {
 	}
 }
 
+func (suite *containerTestSuite) TestContainer_parseRawIdmap() {
+	entries, err := parseRawIdmap("both 1000 1000\n uid  2000-2009 3000-3009 \n\ngid 50 60")
+	suite.Req.Nil(err)
+	suite.Req.Len(entries, 3)
+	suite.Req.Equal(int64(10), entries[1].Maprange)
+
+	for _, invalid := range []string{"both 1000", "both 1000-999 1000-999", "both -1 1000", "both 1000-1010 1000-1020", "foo 1000 1000"} {
+		_, err := parseRawIdmap(invalid)
+		suite.Req.NotNil(err, "%s should have been rejected", invalid)
+	}
+}
+
 func (suite *containerTestSuite) TestContainer_findIdmap_maxed() {
 	maps := []*idmap.IdmapSet{}
 
@@ -394,6 +452,150 @@ func (suite *containerTestSuite) TestContainer_findIdmap_maxed() {
 	}
 }
 
+func (suite *containerTestSuite) TestContainer_SeccompBlacklist() {
+	c, err := containerCreateInternal(suite.d.State(), db.ContainerArgs{
+		Ctype: db.CTypeRegular,
+		Name:  "seccomp",
+		Config: map[string]string{
+			"security.syscalls.blacklist": "ptrace errno 1",
+		},
+	})
+	suite.Req.Nil(err)
+	defer c.Delete()
+
+	profile, err := getSeccompProfileContent(c)
+	suite.Req.Nil(err)
+	suite.Req.Contains(profile, DEFAULT_SECCOMP_POLICY)
+	suite.Req.Contains(profile, "[all]\nptrace errno 1")
+}
+
+func (suite *containerTestSuite) TestContainer_ValidDevices_Invalid() {
+	invalid := []types.Device{
+		{"nictype": "p2p"},
+		{"type": "bogus"},
+		{"type": "nic", "nictype": "p2p", "bogus": "1"},
+		{"type": "nic", "nictype": "p2p", "hwaddr": "bogus"},
+		{"type": "nic", "nictype": "p2p", "mtu": "-1"},
+		{"type": "disk", "path": "/mnt", "source": "/tmp", "readonly": "bogus"},
+		{"type": "usb", "vendorid": "1d6b", "mode": "999"},
+		{"type": "usb", "vendorid": "1d6b", "uid": "-1"},
+	}
+
+	for _, m := range invalid {
+		err := containerValidDevices(suite.d.db, types.Devices{"dev0": m}, false, false)
+		suite.Req.NotNil(err, "%v should have been rejected", m)
+		suite.Req.Contains(err.Error(), "\"dev0\"")
+	}
+
+	err := containerValidDevices(suite.d.db, types.Devices{"dev0": {"type": "nic", "nictype": "p2p", "hwaddr": "00:16:3e:00:00:01", "mtu": "1400"}}, false, false)
+	suite.Req.Nil(err)
+}
+
+func (suite *containerTestSuite) TestContainer_CheckVolatile() {
+	old := map[string]string{"volatile.eth0.hwaddr": "00:16:3e:00:00:01", "limits.cpu": "1"}
+
+	err := containerCheckVolatile(old, map[string]string{"volatile.eth0.hwaddr": "00:16:3e:00:00:01", "limits.cpu": "2"})
+	suite.Req.Nil(err)
+
+	err = containerCheckVolatile(old, map[string]string{"limits.cpu": "1"})
+	suite.Req.Nil(err, "Removing a volatile key should be allowed")
+
+	err = containerCheckVolatile(old, map[string]string{"volatile.eth0.hwaddr": "00:16:3e:00:00:02"})
+	suite.Req.NotNil(err, "Modifying a volatile key should fail")
+
+	err = containerCheckVolatile(old, map[string]string{"volatile.eth1.hwaddr": "00:16:3e:00:00:03"})
+	suite.Req.NotNil(err, "Adding a volatile key should fail")
+}
+
+func (suite *containerTestSuite) TestContainer_ExpandConfig() {
+	profiles := []map[string]string{
+		{"limits.cpu": "1", "limits.memory": "1GB"},
+		{"limits.cpu": "2", "security.nesting": "true"},
+	}
+	local := map[string]string{"security.nesting": "false"}
+
+	config := containerExpandConfig(profiles, local)
+	suite.Req.Equal(map[string]string{
+		"limits.cpu":       "2",
+		"limits.memory":    "1GB",
+		"security.nesting": "false",
+	}, config)
+}
+
+func (suite *containerTestSuite) TestContainer_ExpandDevices() {
+	profiles := []types.Devices{
+		{
+			"eth0": types.Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr0", "mtu": "1400"},
+			"root": types.Device{"type": "disk", "path": "/", "pool": "default"},
+		},
+		{
+			"eth0": types.Device{"type": "nic", "nictype": "p2p"},
+			"mnt":  types.Device{"type": "disk", "path": "/mnt", "source": "/srv"},
+		},
+	}
+	local := types.Devices{
+		"mnt": types.Device{"type": "disk", "path": "/mnt", "source": "/opt"},
+	}
+
+	devices := containerExpandDevices(profiles, local)
+	suite.Req.Len(devices, 3)
+
+	// Later profiles replace the whole device, properties aren't merged
+	suite.Req.Equal(types.Device{"type": "nic", "nictype": "p2p"}, devices["eth0"])
+
+	// Local devices override all profiles
+	suite.Req.Equal("/opt", devices["mnt"]["source"])
+
+	suite.Req.Equal("default", devices["root"]["pool"])
+}
+
+// Containers can only be created from images the host can run.
+func (suite *containerTestSuite) TestContainer_CreateFromImage_Architecture() {
+	architectures := suite.d.os.Architectures
+	suite.d.os.Architectures = []int{osarch.ARCH_64BIT_INTEL_X86}
+	defer func() { suite.d.os.Architectures = architectures }()
+
+	images := map[string]string{"abcd": "x86_64", "ef01": "s390x", "2345": "foo"}
+	for fingerprint, architecture := range images {
+		err := suite.d.db.ImageInsert(fingerprint, "foo.xz", 1, false, false, architecture, time.Now(), time.Now(), map[string]string{})
+		suite.Req.Nil(err)
+		id, _, err := suite.d.db.ImageGet(fingerprint, false, true)
+		suite.Req.Nil(err)
+		defer suite.d.db.ImageDelete(id)
+	}
+
+	args := db.ContainerArgs{
+		Config: map[string]string{},
+		Ctype:  db.CTypeRegular,
+		Name:   "testFoo",
+	}
+
+	c, err := containerCreateFromImage(suite.d.State(), args, "abcd", nil, nil)
+	suite.Req.Nil(err)
+	defer c.Delete()
+	suite.Req.Equal(osarch.ARCH_64BIT_INTEL_X86, c.Architecture())
+
+	args.Name = "testBar"
+	_, err = containerCreateFromImage(suite.d.State(), args, "ef01", nil, nil)
+	suite.Req.EqualError(err, "The image architecture s390x isn't supported by this host")
+
+	// Images of unknown architectures can be imported, but not used
+	_, err = containerCreateFromImage(suite.d.State(), args, "2345", nil, nil)
+	suite.Req.NotNil(err)
+}
+
+func (suite *containerTestSuite) TestContainer_NvidiaRuntimeConfig() {
+	config := map[string]string{"nvidia.runtime": "true"}
+	suite.Req.Equal([][2]string{
+		{"lxc.environment", "NVIDIA_VISIBLE_DEVICES=none"},
+		{"lxc.environment", "NVIDIA_DRIVER_CAPABILITIES=compute,utility"},
+		{"lxc.hook.mount", "/usr/share/lxc/hooks/nvidia"},
+	}, nvidiaRuntimeConfig(config, "/usr/share/lxc/hooks/nvidia"))
+
+	config["nvidia.driver.capabilities"] = "video"
+	suite.Req.Contains(nvidiaRuntimeConfig(config, "/usr/share/lxc/hooks/nvidia"), [2]string{"lxc.environment", "NVIDIA_DRIVER_CAPABILITIES=video"})
+}
+
 func TestContainerTestSuite(t *testing.T) {
 	suite.Run(t, new(containerTestSuite))
 }
@@ -81,6 +81,11 @@ var containerMetadataTemplatesCmd = Command{
 	delete: containerMetadataTemplatesDelete,
 }
 
+var containerExportCmd = Command{
+	name: "containers/{name}/export",
+	get:  containerExportGet,
+}
+
 type containerAutostartList []container
 
 func (slice containerAutostartList) Len() int {
@@ -203,6 +208,8 @@ func containersShutdown(s *state.State) error {
 		return err
 	}
 
+	containerCache.Clear()
+
 	var lastPriority int = 0
 
 	if len(containers) != 0 {
@@ -3,9 +3,9 @@ package main
 import (
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/db/query"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared/api"
@@ -14,23 +14,21 @@ import (
 )
 
 func containersGet(d *Daemon, r *http.Request) Response {
-	for i := 0; i < 100; i++ {
-		result, err := doContainersGet(d.State(), util.IsRecursionRequest(r))
-		if err == nil {
-			return SyncResponse(true, result)
-		}
-		if !db.IsDbLockedError(err) {
-			logger.Debugf("DBERR: containersGet: error %q", err)
-			return SmartError(err)
-		}
-		// 1 s may seem drastic, but we really don't want to thrash
-		// perhaps we should use a random amount
-		time.Sleep(100 * time.Millisecond)
+	recursion := util.IsRecursionRequest(r)
+
+	var result interface{}
+	err := query.Retry(func() error {
+		var err error
+		result, err = doContainersGet(d.State(), recursion)
+		return err
+	})
+	if err != nil {
+		logger.Debugf("DBERR: containersGet: error %q", err)
+		return SmartError(err)
 	}
 
-	logger.Debugf("DBERR: containersGet, db is locked")
-	logger.Debugf(logger.GetStack())
-	return InternalError(fmt.Errorf("DB is locked"))
+	result = clusterContainersMerge(d, r, result, recursion)
+	return SyncResponse(true, result)
 }
 
 func doContainersGet(s *state.State, recursion bool) (interface{}, error) {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
@@ -15,16 +16,28 @@ import (
 	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/cancel"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/osarch"
+	"github.com/lxc/lxd/shared/version"
 
 	log "github.com/lxc/lxd/shared/log15"
 )
 
-func createFromImage(d *Daemon, req *api.ContainersPost) Response {
+func createFromImage(d *Daemon, r *http.Request, req *api.ContainersPost) Response {
 	var hash string
 	var err error
 
+	// Images which only exist on another cluster member get fetched from it
+	if req.Source.Server == "" {
+		member := clusterImageLocate(d, req.Source)
+		if member != nil {
+			req.Source.Server = fmt.Sprintf("https://%s", member.Address)
+			req.Source.Protocol = "lxd"
+			req.Source.Certificate = member.Certificate
+		}
+	}
+
 	if req.Source.Fingerprint != "" {
 		hash = req.Source.Fingerprint
 	} else if req.Source.Alias != "" {
@@ -84,6 +97,7 @@ func createFromImage(d *Daemon, req *api.ContainersPost) Response {
 		return BadRequest(fmt.Errorf("Must specify one of alias, fingerprint or properties for init from image"))
 	}
 
+	requestor := eventRequestor(r)
 	run := func(op *operation) error {
 		args := db.ContainerArgs{
 			Config:    req.Config,
@@ -114,8 +128,19 @@ func createFromImage(d *Daemon, req *api.ContainersPost) Response {
 			return err
 		}
 
-		_, err = containerCreateFromImage(d.State(), args, info.Fingerprint)
-		return err
+		// Allow cancelling the image extraction
+		if op.canceler == nil {
+			op.canceler = cancel.NewCanceler()
+		}
+
+		tracker := StorageProgressTracker(op, "create_container_from_image_unpack_progress", "Unpack")
+		_, err = containerCreateFromImage(d.State(), args, info.Fingerprint, tracker, op.canceler)
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-created", eventContainerSource(req.Name), nil, requestor)
+		return nil
 	}
 
 	resources := map[string][]string{}
@@ -125,11 +150,12 @@ func createFromImage(d *Daemon, req *api.ContainersPost) Response {
 	if err != nil {
 		return InternalError(err)
 	}
+	op.opType = operationTypeContainerCreate
 
 	return OperationResponse(op)
 }
 
-func createFromNone(d *Daemon, req *api.ContainersPost) Response {
+func createFromNone(d *Daemon, r *http.Request, req *api.ContainersPost) Response {
 	args := db.ContainerArgs{
 		Config:    req.Config,
 		Ctype:     db.CTypeRegular,
@@ -147,9 +173,15 @@ func createFromNone(d *Daemon, req *api.ContainersPost) Response {
 		args.Architecture = architecture
 	}
 
+	requestor := eventRequestor(r)
 	run := func(op *operation) error {
 		_, err := containerCreateAsEmpty(d, args)
-		return err
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-created", eventContainerSource(req.Name), nil, requestor)
+		return nil
 	}
 
 	resources := map[string][]string{}
@@ -159,11 +191,12 @@ func createFromNone(d *Daemon, req *api.ContainersPost) Response {
 	if err != nil {
 		return InternalError(err)
 	}
+	op.opType = operationTypeContainerCreate
 
 	return OperationResponse(op)
 }
 
-func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
+func createFromMigration(d *Daemon, r *http.Request, req *api.ContainersPost) Response {
 	// Validate migration mode
 	if req.Source.Mode != "pull" && req.Source.Mode != "push" {
 		return NotImplemented
@@ -317,7 +350,7 @@ func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
 		}
 
 		if ps.MigrationType() == MigrationFSType_RSYNC {
-			c, err = containerCreateFromImage(d.State(), args, req.Source.BaseImage)
+			c, err = containerCreateFromImage(d.State(), args, req.Source.BaseImage, nil, nil)
 			if err != nil {
 				return InternalError(err)
 			}
@@ -359,7 +392,8 @@ func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
 		Url: req.Source.Operation,
 		Dialer: websocket.Dialer{
 			TLSClientConfig: config,
-			NetDial:         shared.RFC3493Dialer},
+			NetDial:         shared.RFC3493Dialer,
+			Proxy:           d.proxy},
 		Container:     c,
 		Secrets:       req.Source.Websockets,
 		Push:          push,
@@ -373,6 +407,7 @@ func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
 		return InternalError(err)
 	}
 
+	requestor := eventRequestor(r)
 	run := func(op *operation) error {
 		// And finally run the migration.
 		err = sink.Do(op)
@@ -388,6 +423,8 @@ func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
 			return err
 		}
 
+		eventSendLifecycle("container-created", eventContainerSource(req.Name), nil, requestor)
+
 		if !migrationArgs.Live {
 			if req.Config["volatile.last_state.power"] == "RUNNING" {
 				return c.Start(false)
@@ -402,21 +439,25 @@ func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
 
 	var op *operation
 	if push {
-		op, err = operationCreate(operationClassWebsocket, resources, sink.Metadata(), run, nil, sink.Connect)
+		op, err = operationCreate(operationClassWebsocket, resources, sink.Metadata(), run, sink.Cancel, sink.Connect)
 		if err != nil {
 			return InternalError(err)
 		}
 	} else {
-		op, err = operationCreate(operationClassTask, resources, nil, run, nil, nil)
+		op, err = operationCreate(operationClassTask, resources, nil, run, sink.Cancel, nil)
 		if err != nil {
 			return InternalError(err)
 		}
 	}
+	op.opType = operationTypeContainerCreate
+
+	// Let the rsync transfers get killed if the migration is cancelled
+	op.canceler = cancel.NewCanceler()
 
 	return OperationResponse(op)
 }
 
-func createFromCopy(d *Daemon, req *api.ContainersPost) Response {
+func createFromCopy(d *Daemon, r *http.Request, req *api.ContainersPost) Response {
 	if req.Source.Source == "" {
 		return BadRequest(fmt.Errorf("must specify a source container"))
 	}
@@ -469,6 +510,12 @@ func createFromCopy(d *Daemon, req *api.ContainersPost) Response {
 		req.Profiles = source.Profiles()
 	}
 
+	// Check the resulting config, including what came from the source
+	err = containerCheckPrivileged(d, r, req.Config, req.Profiles)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	if req.Stateful {
 		sourceName, _, _ := containerGetParentAndSnapshotName(source.Name())
 		if sourceName != req.Name {
@@ -491,11 +538,14 @@ func createFromCopy(d *Daemon, req *api.ContainersPost) Response {
 		Stateful:     req.Stateful,
 	}
 
+	requestor := eventRequestor(r)
 	run := func(op *operation) error {
 		_, err := containerCreateAsCopy(d.State(), args, source, req.Source.ContainerOnly)
 		if err != nil {
 			return err
 		}
+
+		eventSendLifecycle("container-created", eventContainerSource(req.Name), nil, requestor)
 		return nil
 	}
 
@@ -506,6 +556,7 @@ func createFromCopy(d *Daemon, req *api.ContainersPost) Response {
 	if err != nil {
 		return InternalError(err)
 	}
+	op.opType = operationTypeContainerCreate
 
 	return OperationResponse(op)
 }
@@ -513,6 +564,11 @@ func createFromCopy(d *Daemon, req *api.ContainersPost) Response {
 func containersPost(d *Daemon, r *http.Request) Response {
 	logger.Debugf("Responding to container create")
 
+	// Container exports are sent as they are
+	if r.Header.Get("Content-Type") == "application/octet-stream" {
+		return createFromExport(d, r)
+	}
+
 	req := api.ContainersPost{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return BadRequest(err)
@@ -570,15 +626,98 @@ func containersPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Invalid container name: '%s' is reserved for snapshots", shared.SnapshotDelimiter))
 	}
 
+	// Volatile keys are managed by LXD, only copies and migrations carry them over
+	if req.Source.Type == "image" || req.Source.Type == "none" {
+		for k := range req.Config {
+			if strings.HasPrefix(k, "volatile.") {
+				return BadRequest(fmt.Errorf("Volatile keys are read-only."))
+			}
+		}
+	}
+
+	// Enforce the policy on the requested config before the request gets
+	// forwarded, it's checked again against the full config once the
+	// profiles (and for copies, the config of the source) are known.
+	err = containerCheckPrivileged(d, r, req.Config, []string{})
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Container names are unique across the cluster, and containers get
+	// created on the requested member or on the least loaded one
+	members, err := d.db.ClusterMembers()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	target := r.URL.Query().Get("target")
+	if target != "" && len(members) == 0 {
+		return BadRequest(fmt.Errorf("This server isn't a member of a cluster"))
+	}
+
+	if len(members) > 0 && !clusterIsMemberRequest(r, members) {
+		member := clusterLocate(d, members, fmt.Sprintf("/%s/containers/%s", version.APIVersion, req.Name))
+		if member != nil {
+			return BadRequest(fmt.Errorf("A container named '%s' already exists on cluster member '%s'", req.Name, member.Name))
+		}
+
+		self := clusterSelf(d, members)
+		if req.Source.Type == "copy" {
+			// Copies are made by the member of the source container
+			member, err = clusterCopyTarget(d, members, req.Source.Source)
+			if err != nil {
+				return SmartError(err)
+			}
+
+			if target != "" && target != member.Name {
+				return BadRequest(fmt.Errorf("Containers can only be copied on the cluster member of their source ('%s')", member.Name))
+			}
+		} else {
+			member, err = clusterTarget(d, members, target)
+			if err != nil {
+				return BadRequest(err)
+			}
+		}
+
+		if self == nil || member.Name != self.Name {
+			body, err := json.Marshal(req)
+			if err != nil {
+				return InternalError(err)
+			}
+
+			r.Body = shared.BytesReadCloser{Buf: bytes.NewBuffer(body)}
+			r.ContentLength = int64(len(body))
+			r.Header.Set("Content-Type", "application/json")
+
+			return clusterForwardedResponse(d, r, *member)
+		}
+	}
+
+	// Validate the configuration before starting the operation
+	err = containerValidConfig(d.os, req.Config, false, false)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = containerValidDevices(d.db, req.Devices, false, false)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = containerCheckPrivileged(d, r, req.Config, req.Profiles)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	switch req.Source.Type {
 	case "image":
-		return createFromImage(d, &req)
+		return createFromImage(d, r, &req)
 	case "none":
-		return createFromNone(d, &req)
+		return createFromNone(d, r, &req)
 	case "migration":
-		return createFromMigration(d, &req)
+		return createFromMigration(d, r, &req)
 	case "copy":
-		return createFromCopy(d, &req)
+		return createFromCopy(d, r, &req)
 	default:
 		return BadRequest(fmt.Errorf("unknown source type %s", req.Source.Type))
 	}
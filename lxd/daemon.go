@@ -13,18 +13,21 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/juju/idmclient"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/pborman/uuid"
 	"golang.org/x/net/context"
 	"gopkg.in/macaroon-bakery.v2/bakery"
 	"gopkg.in/macaroon-bakery.v2/bakery/checkers"
 	"gopkg.in/macaroon-bakery.v2/bakery/identchecker"
 	"gopkg.in/macaroon-bakery.v2/httpbakery"
 
+	"github.com/lxc/lxd/lxd/audit"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/endpoints"
 	"github.com/lxc/lxd/lxd/maas"
@@ -34,6 +37,7 @@ import (
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/logging"
 	"github.com/lxc/lxd/shared/version"
 
 	log "github.com/lxc/lxd/shared/log15"
@@ -48,6 +52,18 @@ type Daemon struct {
 	readyChan    chan bool
 	shutdownChan chan bool
 
+	// Set to 1 once the daemon started shutting down, changes through the
+	// API are then refused.
+	shuttingDown int32
+
+	// Restrictions of the trusted certificates which have some, by
+	// fingerprint.
+	clientCertRestrictions     map[string]*db.CertInfo
+	clientCertRestrictionsLock sync.RWMutex
+
+	// Certificates only trusted by the metrics endpoint.
+	metricsCerts []x509.Certificate
+
 	// Tasks registry for long-running background tasks.
 	tasks task.Group
 
@@ -59,6 +75,10 @@ type Daemon struct {
 	config    *DaemonConfig
 	endpoints *endpoints.Endpoints
 
+	// Key pair used by the network endpoint, and to authenticate with the
+	// other cluster members.
+	serverCert *shared.CertInfo
+
 	proxy func(req *http.Request) (*url.URL, error)
 
 	externalAuth *externalAuth
@@ -129,6 +149,25 @@ func (d *Daemon) checkTrustedClient(r *http.Request) error {
 	return fmt.Errorf("unauthorized")
 }
 
+// Check whether the request to the metrics endpoint comes from a client
+// trusted with either a client or a metrics certificate.
+func (d *Daemon) checkTrustedMetricsClient(r *http.Request) error {
+	if d.checkTrustedClient(r) == nil {
+		return nil
+	}
+
+	if r.TLS == nil {
+		return fmt.Errorf("no TLS")
+	}
+
+	for i := range r.TLS.PeerCertificates {
+		if util.CheckTrustState(*r.TLS.PeerCertificates[i], d.metricsCerts) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unauthorized")
+}
+
 // Return the bakery operations implied by the given HTTP request
 func getBakeryOps(r *http.Request) []bakery.Op {
 	return []bakery.Op{{
@@ -194,27 +233,85 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c Command) {
 	restAPI.HandleFunc(uri, func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
+		// Tag the log messages about the request with an ID, to tell
+		// them apart from the ones of the requests handled concurrently
+		requestLogger := logging.AddContext(logger.Log, log.Ctx{"subsystem": "api", "request": uuid.NewRandom().String()})
+
+		// Handle the requests forwarded by other cluster members as
+		// if they were made by their original requestor
+		clusterForwardedRequest(d, r)
+
+		// Record the state-changing calls in the audit log, including
+		// the ones which get refused
+		var auditEntry *audit.Entry
+		if r.Method != "GET" && auditEnabled() {
+			auditEntry = auditEntryNew(r)
+			auditWriter := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			w = auditWriter
+
+			defer func() {
+				auditEntry.Status = auditWriter.status
+				auditEntry.Location = auditWriter.Header().Get("Location")
+				auditRecord(auditEntry)
+			}()
+		}
+
 		untrustedOk := (r.Method == "GET" && c.untrustedGet) || (r.Method == "POST" && c.untrustedPost)
 		err := d.checkTrustedClient(r)
 		if err == nil {
-			logger.Debug(
+			requestLogger.Debug(
 				"handling",
 				log.Ctx{"method": r.Method, "url": r.URL.RequestURI(), "ip": r.RemoteAddr})
 		} else if untrustedOk && r.Header.Get("X-LXD-authenticated") == "" {
-			logger.Debug(
+			requestLogger.Debug(
 				fmt.Sprintf("allowing untrusted %s", r.Method),
 				log.Ctx{"url": r.URL.RequestURI(), "ip": r.RemoteAddr})
 		} else if derr, ok := err.(*bakery.DischargeRequiredError); ok {
 			writeMacaroonsRequiredResponse(d.externalAuth.bakery, r, w, derr)
 			return
 		} else {
-			logger.Warn(
+			requestLogger.Warn(
 				"rejecting request from untrusted client",
 				log.Ctx{"ip": r.RemoteAddr})
+			metricsRequestRecord(r.Method, Forbidden)
 			Forbidden.Render(w)
 			return
 		}
 
+		if err == nil && !certificateRestrictionsCheck(d, r, c) {
+			requestLogger.Warn(
+				"rejecting request not allowed by the client certificate restrictions",
+				log.Ctx{"method": r.Method, "url": r.URL.RequestURI(), "ip": r.RemoteAddr})
+			metricsRequestRecord(r.Method, Forbidden)
+			Forbidden.Render(w)
+			return
+		}
+
+		// Enforce the configured limits, except on the internal API
+		// used by LXD itself
+		releaseOperationSlot := func() {}
+		if version != "internal" {
+			resp, release := apiLimitsCheck(d, r, c)
+			releaseOperationSlot = release
+			defer release()
+			if resp != nil {
+				metricsRequestRecord(r.Method, resp)
+				resp.Render(w)
+				return
+			}
+		}
+
+		// Only let clients follow and cancel the operations in flight
+		// while shutting down.
+		if r.Method != "GET" && !strings.HasPrefix(c.name, "operations") && atomic.LoadInt32(&d.shuttingDown) == 1 {
+			Unavailable(fmt.Errorf("LXD is shutting down")).Render(w)
+			return
+		}
+
+		if auditEntry != nil {
+			auditEntryBody(auditEntry, r)
+		}
+
 		if debug && r.Method != "GET" && isJSONRequest(r) {
 			newBody := &bytes.Buffer{}
 			captured := &bytes.Buffer{}
@@ -228,6 +325,20 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c Command) {
 			shared.DebugJson(captured)
 		}
 
+		// Forward the requests about containers living on other
+		// cluster members to them
+		if clusterForward(d, w, r, c) {
+			return
+		}
+
+		// The changes to the configuration shared by the cluster
+		// members get applied by all of them
+		change, err := clusterChangeRead(d, r, c)
+		if err != nil {
+			InternalError(err).Render(w)
+			return
+		}
+
 		var resp Response
 		resp = NotImplemented
 
@@ -256,10 +367,27 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c Command) {
 			resp = NotFound
 		}
 
+		if change != nil && responseStatusCode(resp) < http.StatusBadRequest {
+			clusterChangePropagate(d, r, change)
+		}
+
+		// Remember who started the operation, for the concurrency
+		// limits
+		opResp, ok := resp.(*operationResponse)
+		if ok {
+			opResp.op.lock.Lock()
+			opResp.op.requestor = apiClientID(r)
+			opResp.op.lock.Unlock()
+		}
+		releaseOperationSlot()
+
+		metricsRequestRecord(r.Method, resp)
+		requestLogger.Debug("handled", log.Ctx{"status": responseStatusCode(resp)})
+
 		if err := resp.Render(w); err != nil {
 			err := InternalError(err).Render(w)
 			if err != nil {
-				logger.Errorf("Failed writing error for error, giving up")
+				requestLogger.Error("Failed writing error for error, giving up")
 			}
 		}
 
@@ -357,6 +485,7 @@ func (d *Daemon) init() error {
 	if err != nil {
 		return err
 	}
+	operationsDB = d.db
 
 	/* Load all config values from the database */
 	err = daemonConfigInit(d.db.DB())
@@ -398,6 +527,22 @@ func (d *Daemon) init() error {
 		daemonConfig["core.proxy_ignore_hosts"].Get(),
 	)
 
+	/* Switch to the logging settings of the server config, if any */
+	err = loggingSetup(
+		daemonConfig["core.log_level"].Get(),
+		daemonConfig["core.log_targets"].Get(),
+		daemonConfig["core.log_format"].Get(),
+	)
+	if err != nil {
+		return err
+	}
+
+	/* Start recording the API calls, if enabled */
+	err = auditSetup(daemonConfig["core.audit_log"].Get())
+	if err != nil {
+		return err
+	}
+
 	/* Setup some mounts (nice to have) */
 	if !d.os.MockMode {
 		// Attempt to mount the shmounts tmpfs
@@ -433,6 +578,7 @@ func (d *Daemon) init() error {
 	if err != nil {
 		return err
 	}
+	d.serverCert = certInfo
 
 	config := &endpoints.Config{
 		Dir:                  d.os.VarDir,
@@ -441,6 +587,8 @@ func (d *Daemon) init() error {
 		DevLxdServer:         DevLxdServer(d),
 		LocalUnixSocketGroup: d.config.Group,
 		NetworkAddress:       daemonConfig["core.https_address"].Get(),
+		MetricsServer:        MetricsServer(d),
+		MetricsAddress:       daemonConfig["core.metrics_address"].Get(),
 	}
 	d.endpoints, err = endpoints.Up(config)
 	if err != nil {
@@ -466,6 +614,9 @@ func (d *Daemon) Ready() error {
 	/* Auto-update instance types */
 	d.tasks.Add(instanceRefreshTypesTask(d))
 
+	/* Watch for OOM kills */
+	d.tasks.Add(containerOOMTask(d.State()))
+
 	// FIXME: There's no hard reason for which we should not run tasks in
 	//        mock mode. However it requires that we tweak the tasks so
 	//        they exit gracefully without blocking (something we should
@@ -478,6 +629,12 @@ func (d *Daemon) Ready() error {
 
 	s := d.State()
 
+	/* Clean up after the operations interrupted by the last shutdown */
+	err := operationsRecover(s)
+	if err != nil {
+		logger.Error("Failed to recover interrupted operations", log.Ctx{"err": err})
+	}
+
 	/* Restore containers */
 	containersRestart(s)
 
@@ -533,6 +690,10 @@ func (d *Daemon) Stop() error {
 			syscall.Unmount(shared.VarPath("shmounts"), syscall.MNT_DETACH)
 
 			logger.Infof("Done unmounting temporary filesystems")
+
+			logger.Infof("Unmounting storage pools")
+			storagePoolsShutdown(d.State())
+			logger.Infof("Done unmounting storage pools")
 		} else {
 			logger.Debugf(
 				"Not unmounting temporary filesystems (containers are still running)")
@@ -542,6 +703,9 @@ func (d *Daemon) Stop() error {
 		trackError(d.db.Close())
 	}
 
+	trackError(auditSetup(""))
+	trackError(loggingSetup("", "", ""))
+
 	logger.Infof("Saving simplestreams cache")
 	trackError(imageSaveStreamCache(d.os))
 	logger.Infof("Saved simplestreams cache")
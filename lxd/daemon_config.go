@@ -1,20 +1,18 @@
 package main
 
 import (
-	"crypto/rand"
 	"database/sql"
-	"encoding/hex"
 	"fmt"
-	"io"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 
 	log "github.com/lxc/lxd/shared/log15"
-	"golang.org/x/crypto/scrypt"
 
 	dbapi "github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
 )
@@ -180,6 +178,8 @@ func (k *daemonConfigKey) GetInt64() int64 {
 func daemonConfigInit(db *sql.DB) error {
 	// Set all the keys
 	daemonConfig = map[string]*daemonConfigKey{
+		"core.audit_log":                 {valueType: "string", validValues: []string{"file", "syslog"}, setter: daemonConfigSetAudit},
+		"core.hooks_path":                {valueType: "string", validator: daemonConfigValidateHooksPath},
 		"core.https_address":             {valueType: "string", setter: daemonConfigSetAddress},
 		"core.https_allowed_headers":     {valueType: "string"},
 		"core.https_allowed_methods":     {valueType: "string"},
@@ -189,7 +189,17 @@ func daemonConfigInit(db *sql.DB) error {
 		"core.proxy_https":               {valueType: "string", setter: daemonConfigSetProxy},
 		"core.proxy_ignore_hosts":        {valueType: "string", setter: daemonConfigSetProxy},
 		"core.trust_password":            {valueType: "string", hiddenValue: true, setter: daemonConfigSetPassword},
+		"core.lxcfs":                     {valueType: "bool", defaultValue: "true"},
+		"core.log_format":                {valueType: "string", validValues: []string{"logfmt", "json"}, setter: daemonConfigSetLogging},
+		"core.log_level":                 {valueType: "string", validValues: []string{"debug", "info", "warn", "error"}, setter: daemonConfigSetLogging},
+		"core.log_targets":               {valueType: "string", validator: daemonConfigValidateLogTargets, setter: daemonConfigSetLogging},
 		"core.macaroon.endpoint":         {valueType: "string", setter: daemonConfigSetMacaroonEndpoint},
+		"core.max_operations":            {valueType: "int", validator: daemonConfigValidateLimit},
+		"core.max_operations_per_client": {valueType: "int", validator: daemonConfigValidateLimit},
+		"core.metrics_address":           {valueType: "string", setter: daemonConfigSetMetricsAddress},
+		"core.privileged_containers":     {valueType: "string", defaultValue: "allow", validValues: []string{"allow", "local", "deny"}},
+		"core.rate_limit":                {valueType: "int", validator: daemonConfigValidateLimit},
+		"core.rate_limit_per_client":     {valueType: "int", validator: daemonConfigValidateLimit},
 
 		"images.auto_update_cached":    {valueType: "bool", defaultValue: "true"},
 		"images.auto_update_interval":  {valueType: "int", defaultValue: "6", trigger: daemonConfigTriggerAutoUpdateInterval},
@@ -257,25 +267,61 @@ func daemonConfigSetPassword(d *Daemon, key string, value string) (string, error
 	}
 
 	// Hash the password
-	buf := make([]byte, 32)
-	_, err := io.ReadFull(rand.Reader, buf)
+	return util.PasswordHash(value)
+}
+
+func daemonConfigSetAddress(d *Daemon, key string, value string) (string, error) {
+	err := d.endpoints.NetworkUpdateAddress(value)
 	if err != nil {
 		return "", err
 	}
 
-	hash, err := scrypt.Key([]byte(value), buf, 1<<14, 8, 1, 64)
+	return value, nil
+}
+
+func daemonConfigSetMetricsAddress(d *Daemon, key string, value string) (string, error) {
+	err := d.endpoints.MetricsUpdateAddress(value)
 	if err != nil {
 		return "", err
 	}
 
-	buf = append(buf, hash...)
-	value = hex.EncodeToString(buf)
+	return value, nil
+}
+
+func daemonConfigSetLogging(d *Daemon, key string, value string) (string, error) {
+	// Get the current config
+	config := map[string]string{}
+	for _, name := range []string{"core.log_level", "core.log_targets", "core.log_format"} {
+		config[name] = daemonConfig[name].Get()
+	}
+
+	// Apply the change
+	config[key] = value
+
+	err := loggingSetup(config["core.log_level"], config["core.log_targets"], config["core.log_format"])
+	if err != nil {
+		return "", err
+	}
 
 	return value, nil
 }
 
-func daemonConfigSetAddress(d *Daemon, key string, value string) (string, error) {
-	err := d.endpoints.NetworkUpdateAddress(value)
+func daemonConfigValidateLogTargets(d *Daemon, key string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	for _, target := range strings.Split(value, ",") {
+		if !shared.StringInSlice(strings.TrimSpace(target), loggingTargets) {
+			return fmt.Errorf("Invalid log target '%s', only the following values are allowed: %s", target, loggingTargets)
+		}
+	}
+
+	return nil
+}
+
+func daemonConfigSetAudit(d *Daemon, key string, value string) (string, error) {
+	err := auditSetup(value)
 	if err != nil {
 		return "", err
 	}
@@ -353,6 +399,39 @@ func daemonConfigTriggerAutoUpdateInterval(d *Daemon, key string, value string)
 	d.taskAutoUpdate.Reset()
 }
 
+func daemonConfigValidateLimit(d *Daemon, key string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	if limit < 0 {
+		return fmt.Errorf("Invalid value for %s, it must be positive or 0 for no limit: %d", key, limit)
+	}
+
+	return nil
+}
+
+func daemonConfigValidateHooksPath(d *Daemon, key string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if !filepath.IsAbs(value) {
+		return fmt.Errorf("Invalid value for %s, it must be an absolute path: %s", key, value)
+	}
+
+	if !shared.IsDir(value) {
+		return fmt.Errorf("Invalid value for %s, it must be a directory: %s", key, value)
+	}
+
+	return nil
+}
+
 func daemonConfigValidateCompression(d *Daemon, key string, value string) error {
 	if value == "none" {
 		return nil
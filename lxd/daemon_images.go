@@ -102,6 +102,13 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 	var remote lxd.ImageServer
 	var info *api.Image
 
+	// Allow cancelling the download and the image extraction
+	var canceler *cancel.Canceler
+	if op != nil {
+		canceler = cancel.NewCanceler()
+		op.canceler = canceler
+	}
+
 	// Default protocol is LXD
 	if protocol == "" {
 		protocol = "lxd"
@@ -198,12 +205,17 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 			return nil, fmt.Errorf("The requested image couldn't be found.")
 		}
 	} else if protocol == "lxd" {
-		// Setup LXD client
-		remote, err = lxd.ConnectPublicLXD(server, &lxd.ConnectionArgs{
-			TLSServerCert: certificate,
-			UserAgent:     version.UserAgent,
-			Proxy:         d.proxy,
-		})
+		// Setup LXD client, the other cluster members trusting this node
+		member := clusterMemberByURL(d, server)
+		if member != nil {
+			remote, err = clusterConnect(d, *member)
+		} else {
+			remote, err = lxd.ConnectPublicLXD(server, &lxd.ConnectionArgs{
+				TLSServerCert: certificate,
+				UserAgent:     version.UserAgent,
+				Proxy:         d.proxy,
+			})
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -270,7 +282,7 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 		// Import the image in the pool
 		logger.Debugf("Image does not exist on storage pool \"%s\".", storagePool)
 
-		err = imageCreateInPool(d, info, storagePool)
+		err = imageCreateInPool(d, info, storagePool, StorageProgressTracker(op, "create_image_unpack_progress", "Unpack"), canceler)
 		if err != nil {
 			logger.Debugf("Failed to create image on storage pool \"%s\": %s.", storagePool, err)
 			return nil, err
@@ -359,11 +371,6 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 		}
 	}
 
-	var canceler *cancel.Canceler
-	if op != nil {
-		canceler = cancel.NewCanceler()
-		op.canceler = canceler
-	}
 
 	if protocol == "lxd" || protocol == "simplestreams" {
 		// Create the target files
@@ -554,7 +561,7 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 
 	// Import into the requested storage pool
 	if storagePool != "" {
-		err = imageCreateInPool(d, info, storagePool)
+		err = imageCreateInPool(d, info, storagePool, StorageProgressTracker(op, "create_image_unpack_progress", "Unpack"), canceler)
 		if err != nil {
 			return nil, err
 		}
@@ -1,5 +1,11 @@
 package db
 
+import (
+	"database/sql"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
 // CertInfo is here to pass the certificates content
 // from the database around
 type CertInfo struct {
@@ -8,13 +14,18 @@ type CertInfo struct {
 	Type        int
 	Name        string
 	Certificate string
+
+	// Restrictions on what the certificate gives access to. If Containers
+	// isn't empty, only those containers can be accessed.
+	ReadOnly   bool
+	Containers []string
 }
 
 // CertificatesGet returns all certificates from the DB as CertBaseInfo objects.
 func (n *Node) CertificatesGet() (certs []*CertInfo, err error) {
 	rows, err := dbQuery(
 		n.db,
-		"SELECT id, fingerprint, type, name, certificate FROM certificates",
+		"SELECT id, fingerprint, type, name, certificate, read_only FROM certificates",
 	)
 	if err != nil {
 		return certs, err
@@ -30,9 +41,18 @@ func (n *Node) CertificatesGet() (certs []*CertInfo, err error) {
 			&cert.Type,
 			&cert.Name,
 			&cert.Certificate,
+			&cert.ReadOnly,
 		)
 		certs = append(certs, cert)
 	}
+	rows.Close()
+
+	for _, cert := range certs {
+		cert.Containers, err = n.certificateContainers(cert.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return certs, nil
 }
@@ -45,29 +65,72 @@ func (n *Node) CertificatesGet() (certs []*CertInfo, err error) {
 func (n *Node) CertificateGet(fingerprint string) (cert *CertInfo, err error) {
 	cert = new(CertInfo)
 
-	inargs := []interface{}{fingerprint + "%"}
+	inargs := []interface{}{query.LikePrefix(fingerprint)}
 	outfmt := []interface{}{
 		&cert.ID,
 		&cert.Fingerprint,
 		&cert.Type,
 		&cert.Name,
 		&cert.Certificate,
+		&cert.ReadOnly,
 	}
 
-	query := `
+	q := `
 		SELECT
-			id, fingerprint, type, name, certificate
+			id, fingerprint, type, name, certificate, read_only
 		FROM
 			certificates
-		WHERE fingerprint LIKE ?`
+		WHERE fingerprint LIKE ? ESCAPE '\'`
+
+	if err = dbQueryRowScan(n.db, q, inargs, outfmt); err != nil {
+		return nil, err
+	}
 
-	if err = dbQueryRowScan(n.db, query, inargs, outfmt); err != nil {
+	cert.Containers, err = n.certificateContainers(cert.ID)
+	if err != nil {
 		return nil, err
 	}
 
 	return cert, err
 }
 
+// Return the names of the containers the certificate with the given ID is
+// restricted to.
+func (n *Node) certificateContainers(id int) ([]string, error) {
+	inargs := []interface{}{id}
+	var name string
+	outfmt := []interface{}{name}
+
+	results, err := queryScan(n.db, "SELECT name FROM certificates_containers WHERE certificate_id=? ORDER BY name", inargs, outfmt)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, r := range results {
+		names = append(names, r[0].(string))
+	}
+
+	return names, nil
+}
+
+// Replace the containers the certificate with the given ID is restricted to.
+func certificateContainersSet(tx *sql.Tx, id int64, containers []string) error {
+	_, err := tx.Exec("DELETE FROM certificates_containers WHERE certificate_id=?", id)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range containers {
+		_, err = tx.Exec("INSERT INTO certificates_containers (certificate_id, name) VALUES (?, ?)", id, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // CertSave stores a CertBaseInfo object in the db,
 // it will ignore the ID field from the CertInfo.
 func (n *Node) CertSave(cert *CertInfo) error {
@@ -80,25 +143,39 @@ func (n *Node) CertSave(cert *CertInfo) error {
 				fingerprint,
 				type,
 				name,
-				certificate
-			) VALUES (?, ?, ?, ?)`,
+				certificate,
+				read_only
+			) VALUES (?, ?, ?, ?, ?)`,
 	)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(
+	result, err := stmt.Exec(
 		cert.Fingerprint,
 		cert.Type,
 		cert.Name,
 		cert.Certificate,
+		cert.ReadOnly,
 	)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = certificateContainersSet(tx, id, cert.Containers)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	return TxCommit(tx)
 }
 
@@ -112,13 +189,28 @@ func (n *Node) CertDelete(fingerprint string) error {
 	return nil
 }
 
-func (n *Node) CertUpdate(fingerprint string, certName string, certType int) error {
+// CertUpdate updates the name, type and restrictions of the certificate with
+// the given fingerprint.
+func (n *Node) CertUpdate(fingerprint string, certName string, certType int, readOnly bool, containers []string) error {
 	tx, err := begin(n.db)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec("UPDATE certificates SET name=?, type=? WHERE fingerprint=?", certName, certType, fingerprint)
+	_, err = tx.Exec("UPDATE certificates SET name=?, type=?, read_only=? WHERE fingerprint=?", certName, certType, readOnly, fingerprint)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM certificates WHERE fingerprint=?", fingerprint).Scan(&id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = certificateContainersSet(tx, id, containers)
 	if err != nil {
 		tx.Rollback()
 		return err
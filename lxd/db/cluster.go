@@ -0,0 +1,133 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ClusterMemberInfo holds information about a member of the cluster this
+// node belongs to, including the node itself.
+type ClusterMemberInfo struct {
+	ID          int64
+	Name        string
+	Address     string // Network address, as set in core.https_address
+	Certificate string // PEM encoded server certificate
+	JoinedAt    time.Time
+
+	// Evacuated members don't get any new container.
+	Evacuated bool
+}
+
+// ClusterMembers returns all the members of the cluster, sorted by name. The
+// list is empty if the node isn't clustered.
+func (n *Node) ClusterMembers() ([]ClusterMemberInfo, error) {
+	rows, err := dbQuery(n.db, "SELECT id, name, address, certificate, joined_at, evacuated FROM cluster_members ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []ClusterMemberInfo{}
+	for rows.Next() {
+		member := ClusterMemberInfo{}
+		err := rows.Scan(&member.ID, &member.Name, &member.Address, &member.Certificate, &member.JoinedAt, &member.Evacuated)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// ClusterMemberGet returns the cluster member with the given name.
+func (n *Node) ClusterMemberGet(name string) (*ClusterMemberInfo, error) {
+	member := ClusterMemberInfo{}
+	inargs := []interface{}{name}
+	outfmt := []interface{}{&member.ID, &member.Name, &member.Address, &member.Certificate, &member.JoinedAt, &member.Evacuated}
+
+	err := dbQueryRowScan(n.db, "SELECT id, name, address, certificate, joined_at, evacuated FROM cluster_members WHERE name=?", inargs, outfmt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NoSuchObjectError
+		}
+
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// ClusterMemberAdd adds a new member to the cluster.
+func (n *Node) ClusterMemberAdd(member ClusterMemberInfo) error {
+	_, err := exec(n.db, "INSERT INTO cluster_members (name, address, certificate, joined_at, evacuated) VALUES (?, ?, ?, ?, ?)",
+		member.Name, member.Address, member.Certificate, member.JoinedAt, member.Evacuated)
+	return err
+}
+
+// ClusterMemberRemove removes the member with the given name from the
+// cluster.
+func (n *Node) ClusterMemberRemove(name string) error {
+	result, err := exec(n.db, "DELETE FROM cluster_members WHERE name=?", name)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return NoSuchObjectError
+	}
+
+	return nil
+}
+
+// ClusterMemberSetEvacuated marks the member with the given name as evacuated
+// or not.
+func (n *Node) ClusterMemberSetEvacuated(name string, evacuated bool) error {
+	result, err := exec(n.db, "UPDATE cluster_members SET evacuated=? WHERE name=?", evacuated, name)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return NoSuchObjectError
+	}
+
+	return nil
+}
+
+// ClusterMembersReplace replaces the whole list of cluster members, as
+// received from another member.
+func (n *Node) ClusterMembersReplace(members []ClusterMemberInfo) error {
+	tx, err := begin(n.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM cluster_members")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, member := range members {
+		_, err = tx.Exec("INSERT INTO cluster_members (name, address, certificate, joined_at, evacuated) VALUES (?, ?, ?, ?, ?)",
+			member.Name, member.Address, member.Certificate, member.JoinedAt, member.Evacuated)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return TxCommit(tx)
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/lxc/lxd/lxd/types"
@@ -59,7 +60,7 @@ func (n *Node) ContainerName(id int) (string, error) {
 	name := ""
 	arg1 := []interface{}{id}
 	arg2 := []interface{}{&name}
-	err := dbQueryRowScan(n.db, q, arg1, arg2)
+	err := n.stmtQueryRowScan(q, arg1, arg2)
 	return name, err
 }
 
@@ -68,7 +69,7 @@ func (n *Node) ContainerId(name string) (int, error) {
 	id := -1
 	arg1 := []interface{}{name}
 	arg2 := []interface{}{&id}
-	err := dbQueryRowScan(n.db, q, arg1, arg2)
+	err := n.stmtQueryRowScan(q, arg1, arg2)
 	return id, err
 }
 
@@ -84,7 +85,7 @@ func (n *Node) ContainerGet(name string) (ContainerArgs, error) {
 	q := "SELECT id, description, architecture, type, ephemeral, stateful, creation_date, last_use_date FROM containers WHERE name=?"
 	arg1 := []interface{}{name}
 	arg2 := []interface{}{&args.Id, &description, &args.Architecture, &args.Ctype, &ephemInt, &statefulInt, &args.CreationDate, &used}
-	err := dbQueryRowScan(n.db, q, arg1, arg2)
+	err := n.stmtQueryRowScan(q, arg1, arg2)
 	if err != nil {
 		return args, err
 	}
@@ -141,11 +142,6 @@ func (n *Node) ContainerCreate(args ContainerArgs) (int, error) {
 		return 0, DbErrAlreadyDefined
 	}
 
-	tx, err := begin(n.db)
-	if err != nil {
-		return 0, err
-	}
-
 	ephemInt := 0
 	if args.Ephemeral == true {
 		ephemInt = 1
@@ -159,42 +155,60 @@ func (n *Node) ContainerCreate(args ContainerArgs) (int, error) {
 	args.CreationDate = time.Now().UTC()
 	args.LastUsedDate = time.Unix(0, 0).UTC()
 
-	str := fmt.Sprintf("INSERT INTO containers (name, architecture, type, ephemeral, creation_date, last_use_date, stateful) VALUES (?, ?, ?, ?, ?, ?, ?)")
-	stmt, err := tx.Prepare(str)
-	if err != nil {
-		tx.Rollback()
-		return 0, err
-	}
-	defer stmt.Close()
-	result, err := stmt.Exec(args.Name, args.Architecture, args.Ctype, ephemInt, args.CreationDate.Unix(), args.LastUsedDate.Unix(), statefulInt)
-	if err != nil {
-		tx.Rollback()
-		return 0, err
-	}
+	// Parallel container creations may find the database locked, in which
+	// case the whole transaction gets retried.
+	var id int
+	err = n.Transaction(func(nodeTx *NodeTx) error {
+		tx := nodeTx.tx
+
+		// Snapshots reference the container they belong to
+		var parentID interface{}
+		var snapshotName interface{}
+		if args.Ctype == CTypeSnapshot {
+			fields := strings.SplitN(args.Name, shared.SnapshotDelimiter, 2)
+			var id int
+			err := tx.QueryRow("SELECT id FROM containers WHERE name=? AND type=?", fields[0], CTypeRegular).Scan(&id)
+			if err != nil {
+				return fmt.Errorf("Failed to find the container of snapshot %s: %v", args.Name, err)
+			}
+			parentID = id
+			if len(fields) == 2 {
+				snapshotName = fields[1]
+			}
+		}
 
-	id64, err := result.LastInsertId()
-	if err != nil {
-		tx.Rollback()
-		return 0, fmt.Errorf("Error inserting %s into database", args.Name)
-	}
-	// TODO: is this really int64? we should fix it everywhere if so
-	id := int(id64)
-	if err := ContainerConfigInsert(tx, id, args.Config); err != nil {
-		tx.Rollback()
-		return 0, err
-	}
+		str := "INSERT INTO containers (name, architecture, type, ephemeral, creation_date, last_use_date, stateful, parent_id, snapshot_name) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		stmt, err := tx.Prepare(str)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		result, err := stmt.Exec(args.Name, args.Architecture, args.Ctype, ephemInt, args.CreationDate.Unix(), args.LastUsedDate.Unix(), statefulInt, parentID, snapshotName)
+		if err != nil {
+			return err
+		}
 
-	if err := ContainerProfilesInsert(tx, id, args.Profiles); err != nil {
-		tx.Rollback()
-		return 0, err
-	}
+		id64, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("Error inserting %s into database", args.Name)
+		}
+		// TODO: is this really int64? we should fix it everywhere if so
+		id = int(id64)
+		if err := ContainerConfigInsert(tx, id, args.Config); err != nil {
+			return err
+		}
 
-	if err := DevicesAdd(tx, "container", int64(id), args.Devices); err != nil {
-		tx.Rollback()
+		if err := ContainerProfilesInsert(tx, id, args.Profiles); err != nil {
+			return err
+		}
+
+		return DevicesAdd(tx, "container", int64(id), args.Devices)
+	})
+	if err != nil {
 		return 0, err
 	}
 
-	return id, TxCommit(tx)
+	return id, nil
 }
 
 func ContainerConfigClear(tx *sql.Tx, id int) error {
@@ -243,7 +257,7 @@ func (n *Node) ContainerConfigGet(id int, key string) (string, error) {
 	value := ""
 	arg1 := []interface{}{id, key}
 	arg2 := []interface{}{&value}
-	err := dbQueryRowScan(n.db, q, arg1, arg2)
+	err := n.stmtQueryRowScan(q, arg1, arg2)
 	return value, err
 }
 
@@ -297,7 +311,7 @@ func (n *Node) ContainerProfiles(containerId int) ([]string, error) {
 	inargs := []interface{}{containerId}
 	outfmt := []interface{}{name}
 
-	results, err := queryScan(n.db, query, inargs, outfmt)
+	results, err := n.stmtQueryScan(query, inargs, outfmt)
 	if err != nil {
 		return nil, err
 	}
@@ -320,7 +334,7 @@ func (n *Node) ContainerConfig(containerId int) (map[string]string, error) {
 	outfmt := []interface{}{key, value}
 
 	// Results is already a slice here, not db Rows anymore.
-	results, err := queryScan(n.db, q, inargs, outfmt)
+	results, err := n.stmtQueryScan(q, inargs, outfmt)
 	if err != nil {
 		return nil, err //SmartError will wrap this and make "not found" errors pretty
 	}
@@ -338,7 +352,7 @@ func (n *Node) ContainerConfig(containerId int) (map[string]string, error) {
 }
 
 func (n *Node) ContainersList(cType ContainerType) ([]string, error) {
-	q := fmt.Sprintf("SELECT name FROM containers WHERE type=? ORDER BY name")
+	q := "SELECT name FROM containers WHERE type=? ORDER BY name"
 	inargs := []interface{}{cType}
 	var container string
 	outfmt := []interface{}{container}
@@ -368,7 +382,7 @@ func (n *Node) ContainerSetState(id int, state string) error {
 	}
 
 	// Clear any existing entry
-	str := fmt.Sprintf("DELETE FROM containers_config WHERE container_id = ? AND key = 'volatile.last_state.power'")
+	str := "DELETE FROM containers_config WHERE container_id = ? AND key = 'volatile.last_state.power'"
 	stmt, err := tx.Prepare(str)
 	if err != nil {
 		tx.Rollback()
@@ -382,7 +396,7 @@ func (n *Node) ContainerSetState(id int, state string) error {
 	}
 
 	// Insert the new one
-	str = fmt.Sprintf("INSERT INTO containers_config (container_id, key, value) VALUES (?, 'volatile.last_state.power', ?)")
+	str = "INSERT INTO containers_config (container_id, key, value) VALUES (?, 'volatile.last_state.power', ?)"
 	stmt, err = tx.Prepare(str)
 	if err != nil {
 		tx.Rollback()
@@ -404,7 +418,14 @@ func (n *Node) ContainerRename(oldName string, newName string) error {
 		return err
 	}
 
-	str := fmt.Sprintf("UPDATE containers SET name = ? WHERE name = ?")
+	// Renamed snapshots keep their container
+	var snapshotName interface{}
+	fields := strings.SplitN(newName, shared.SnapshotDelimiter, 2)
+	if len(fields) == 2 {
+		snapshotName = fields[1]
+	}
+
+	str := "UPDATE containers SET name = ?, snapshot_name = ? WHERE name = ?"
 	stmt, err := tx.Prepare(str)
 	if err != nil {
 		tx.Rollback()
@@ -415,10 +436,10 @@ func (n *Node) ContainerRename(oldName string, newName string) error {
 	logger.Debug(
 		"Calling SQL Query",
 		log.Ctx{
-			"query":   "UPDATE containers SET name = ? WHERE name = ?",
+			"query":   str,
 			"oldName": oldName,
 			"newName": newName})
-	if _, err := stmt.Exec(newName, oldName); err != nil {
+	if _, err := stmt.Exec(newName, snapshotName, oldName); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -427,7 +448,7 @@ func (n *Node) ContainerRename(oldName string, newName string) error {
 }
 
 func ContainerUpdate(tx *sql.Tx, id int, description string, architecture int, ephemeral bool) error {
-	str := fmt.Sprintf("UPDATE containers SET description=?, architecture=?, ephemeral=? WHERE id=?")
+	str := "UPDATE containers SET description=?, architecture=?, ephemeral=? WHERE id=?"
 	stmt, err := tx.Prepare(str)
 	if err != nil {
 		return err
@@ -452,13 +473,17 @@ func (n *Node) ContainerLastUsedUpdate(id int, date time.Time) error {
 	return err
 }
 
+// ContainerGetSnapshots returns the names of the snapshots of the container
+// with the given name, in the order they were taken.
 func (n *Node) ContainerGetSnapshots(name string) ([]string, error) {
 	result := []string{}
 
-	regexp := name + shared.SnapshotDelimiter
-	length := len(regexp)
-	q := "SELECT name FROM containers WHERE type=? AND SUBSTR(name,1,?)=?"
-	inargs := []interface{}{CTypeSnapshot, length, regexp}
+	q := `
+SELECT snapshots.name FROM containers AS snapshots
+  JOIN containers AS parents ON snapshots.parent_id = parents.id
+  WHERE parents.name=? AND parents.type=? AND snapshots.type=?
+  ORDER BY snapshots.id`
+	inargs := []interface{}{name, CTypeRegular, CTypeSnapshot}
 	outfmt := []interface{}{name}
 	dbResults, err := queryScan(n.db, q, inargs, outfmt)
 	if err != nil {
@@ -477,11 +502,14 @@ func (n *Node) ContainerGetSnapshots(name string) ([]string, error) {
  * To do that, we'll need to weed out based on # slashes in names
  */
 func (n *Node) ContainerNextSnapshot(name string) int {
-	base := name + shared.SnapshotDelimiter + "snap"
+	base := "snap"
 	length := len(base)
-	q := fmt.Sprintf("SELECT name FROM containers WHERE type=? AND SUBSTR(name,1,?)=?")
+	q := `
+SELECT snapshots.snapshot_name FROM containers AS snapshots
+  JOIN containers AS parents ON snapshots.parent_id = parents.id
+  WHERE parents.name=? AND parents.type=? AND snapshots.type=?`
 	var numstr string
-	inargs := []interface{}{CTypeSnapshot, length, base}
+	inargs := []interface{}{name, CTypeRegular, CTypeSnapshot}
 	outfmt := []interface{}{numstr}
 	results, err := queryScan(n.db, q, inargs, outfmt)
 	if err != nil {
@@ -491,7 +519,7 @@ func (n *Node) ContainerNextSnapshot(name string) int {
 
 	for _, r := range results {
 		numstr = r[0].(string)
-		if len(numstr) <= length {
+		if len(numstr) <= length || numstr[:length] != base {
 			continue
 		}
 		substr := numstr[length:]
@@ -3,10 +3,9 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"sync"
 	"time"
 
-	"github.com/mattn/go-sqlite3"
-
 	"github.com/lxc/lxd/lxd/db/node"
 	"github.com/lxc/lxd/lxd/db/query"
 	"github.com/lxc/lxd/shared/logger"
@@ -31,6 +30,8 @@ var (
 type Node struct {
 	db *sql.DB // Handle to the node-local SQLite database file.
 
+	stmts     map[string]*sql.Stmt // Prepared statements, by query.
+	stmtsLock sync.Mutex
 }
 
 // OpenNode creates a new Node object.
@@ -93,16 +94,29 @@ func (n *Node) DB() *sql.DB {
 // node-level database interactions invoked by the given function. If the
 // function returns no error, all database changes are committed to the
 // node-level database, otherwise they are rolled back.
+//
+// If the database is locked by another connection, the whole transaction is
+// retried, so the given function may be called more than once.
 func (n *Node) Transaction(f func(*NodeTx) error) error {
 	nodeTx := &NodeTx{}
-	return query.Transaction(n.db, func(tx *sql.Tx) error {
-		nodeTx.tx = tx
-		return f(nodeTx)
+	return query.Retry(func() error {
+		return query.Transaction(n.db, func(tx *sql.Tx) error {
+			nodeTx.tx = tx
+			return f(nodeTx)
+		})
 	})
 }
 
+// Ping checks that the database can be read, without retrying if it's
+// locked, so it can be used to detect a sick daemon.
+func (n *Node) Ping() error {
+	var count int
+	return n.db.QueryRow("SELECT COUNT(*) FROM schema").Scan(&count)
+}
+
 // Close the database facade.
 func (n *Node) Close() error {
+	n.stmtsClose()
 	return n.db.Close()
 }
 
@@ -123,16 +137,7 @@ func UpdateSchemasDotGo() error {
 }
 
 func IsDbLockedError(err error) bool {
-	if err == nil {
-		return false
-	}
-	if err == sqlite3.ErrLocked || err == sqlite3.ErrBusy {
-		return true
-	}
-	if err.Error() == "database is locked" {
-		return true
-	}
-	return false
+	return query.IsRetriableError(err)
 }
 
 func isNoMatchError(err error) bool {
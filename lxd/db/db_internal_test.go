@@ -456,6 +456,21 @@ func (s *dbTestSuite) Test_ContainerProfiles() {
 	}
 }
 
+func (s *dbTestSuite) Test_ProfileUpdate_rename() {
+	err := s.db.ProfileUpdate("theprofile", "newprofile")
+	s.Nil(err)
+
+	result, err := s.db.ContainerProfiles(1)
+	s.Nil(err)
+	s.Equal([]string{"newprofile"}, result)
+
+	err = s.db.ProfileUpdate("theprofile", "otherprofile")
+	s.Equal(NoSuchObjectError, err)
+
+	err = s.db.ProfileUpdate("newprofile", "default")
+	s.NotNil(err)
+}
+
 func (s *dbTestSuite) Test_dbDevices_profiles() {
 	var err error
 	var result types.Devices
@@ -491,3 +506,215 @@ func (s *dbTestSuite) Test_dbDevices_containers() {
 			fmt.Sprintf("Mismatching value for key %s: %s != %s", key, subresult[key], value))
 	}
 }
+
+func (s *dbTestSuite) Test_ProfilesDevices() {
+	devices, err := s.db.ProfilesDevices([]string{"default", "theprofile"})
+	s.Nil(err)
+
+	s.Equal(types.Device{"type": "nic", "devicekey": "devicevalue"}, devices["theprofile"]["devicename"])
+	s.Len(devices["default"], 0)
+}
+
+func (s *dbTestSuite) Test_ProfilesConfig() {
+	configs, err := s.db.ProfilesConfig([]string{"default", "theprofile"})
+	s.Nil(err)
+
+	s.Equal(map[string]map[string]string{
+		"default":    {},
+		"theprofile": {"thekey": "thevalue"},
+	}, configs)
+
+	_, err = s.db.ProfilesConfig([]string{"theprofile", "missing"})
+	s.Equal(NoSuchObjectError, err)
+}
+
+func (s *dbTestSuite) Test_Operations() {
+	now := time.Now().UTC()
+	op := OperationInfo{
+		Type: "container-create",
+		Operation: api.Operation{
+			ID:         "abcd",
+			Class:      "task",
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			StatusCode: api.Running,
+			Resources:  map[string][]string{"containers": {"c1"}},
+			Metadata:   map[string]interface{}{"progress": "10%"},
+		},
+	}
+
+	err := s.db.OperationSave(op)
+	s.Nil(err)
+
+	result, err := s.db.OperationGet("abcd")
+	s.Nil(err)
+	s.Equal("container-create", result.Type)
+	s.Equal(api.Running, result.StatusCode)
+	s.Equal("Running", result.Status)
+	s.Equal([]string{"c1"}, result.Resources["containers"])
+	s.Equal("10%", result.Metadata["progress"])
+
+	// Saving again replaces the existing entry
+	op.StatusCode = api.Failure
+	op.Err = "boom"
+	err = s.db.OperationSave(op)
+	s.Nil(err)
+
+	ops, err := s.db.Operations()
+	s.Nil(err)
+	s.Len(ops, 1)
+	s.Equal(api.Failure, ops[0].StatusCode)
+	s.Equal("boom", ops[0].Err)
+
+	err = s.db.OperationRemove("abcd")
+	s.Nil(err)
+
+	_, err = s.db.OperationGet("abcd")
+	s.Equal(NoSuchObjectError, err)
+}
+
+func (s *dbTestSuite) Test_CertificateRestrictions() {
+	cert := &CertInfo{
+		Fingerprint: "abcd",
+		Type:        1,
+		Name:        "monitoring",
+		Certificate: "PEM",
+		ReadOnly:    true,
+		Containers:  []string{"c2", "c1"},
+	}
+
+	err := s.db.CertSave(cert)
+	s.Nil(err)
+
+	result, err := s.db.CertificateGet("ab")
+	s.Nil(err)
+	s.Equal(true, result.ReadOnly)
+	s.Equal([]string{"c1", "c2"}, result.Containers)
+
+	err = s.db.CertUpdate("abcd", "monitoring", 1, false, []string{"c3"})
+	s.Nil(err)
+
+	certs, err := s.db.CertificatesGet()
+	s.Nil(err)
+	s.Len(certs, 1)
+	s.Equal(false, certs[0].ReadOnly)
+	s.Equal([]string{"c3"}, certs[0].Containers)
+
+	// The restrictions go away with the certificate
+	err = s.db.CertDelete("abcd")
+	s.Nil(err)
+
+	var count int
+	err = s.db.DB().QueryRow("SELECT count(*) FROM certificates_containers").Scan(&count)
+	s.Nil(err)
+	s.Equal(0, count)
+}
+
+func (s *dbTestSuite) Test_ClusterMembers() {
+	members, err := s.db.ClusterMembers()
+	s.Nil(err)
+	s.Len(members, 0)
+
+	now := time.Now().UTC()
+	err = s.db.ClusterMemberAdd(ClusterMemberInfo{Name: "rusp", Address: "10.0.0.2:8443", Certificate: "PEM2", JoinedAt: now})
+	s.Nil(err)
+
+	err = s.db.ClusterMemberAdd(ClusterMemberInfo{Name: "buzz", Address: "10.0.0.1:8443", Certificate: "PEM1", JoinedAt: now})
+	s.Nil(err)
+
+	// Names must be unique
+	err = s.db.ClusterMemberAdd(ClusterMemberInfo{Name: "buzz", Address: "10.0.0.3:8443", Certificate: "PEM3", JoinedAt: now})
+	s.NotNil(err)
+
+	members, err = s.db.ClusterMembers()
+	s.Nil(err)
+	s.Len(members, 2)
+	s.Equal("buzz", members[0].Name)
+	s.Equal("10.0.0.1:8443", members[0].Address)
+
+	member, err := s.db.ClusterMemberGet("rusp")
+	s.Nil(err)
+	s.Equal("PEM2", member.Certificate)
+	s.Equal(false, member.Evacuated)
+
+	err = s.db.ClusterMemberSetEvacuated("rusp", true)
+	s.Nil(err)
+
+	members, err = s.db.ClusterMembers()
+	s.Nil(err)
+	s.Equal(true, members[1].Evacuated)
+
+	err = s.db.ClusterMembersReplace([]ClusterMemberInfo{members[1]})
+	s.Nil(err)
+
+	_, err = s.db.ClusterMemberGet("buzz")
+	s.Equal(NoSuchObjectError, err)
+
+	err = s.db.ClusterMemberRemove("rusp")
+	s.Nil(err)
+
+	err = s.db.ClusterMemberRemove("rusp")
+	s.Equal(NoSuchObjectError, err)
+}
+
+func (s *dbTestSuite) Test_Ping() {
+	s.Nil(s.db.Ping())
+
+	s.db.Close()
+	s.NotNil(s.db.Ping())
+}
+
+func (s *dbTestSuite) Test_ContainerGetSnapshots() {
+	for _, args := range []ContainerArgs{
+		{Name: "c1", Ctype: CTypeRegular},
+		{Name: "c1/snap0", Ctype: CTypeSnapshot},
+		{Name: "c1/snap1", Ctype: CTypeSnapshot},
+		{Name: "c1-2", Ctype: CTypeRegular},
+		{Name: "c1-2/snap0", Ctype: CTypeSnapshot},
+	} {
+		_, err := s.db.ContainerCreate(args)
+		s.Nil(err)
+	}
+
+	snapshots, err := s.db.ContainerGetSnapshots("c1")
+	s.Nil(err)
+	s.Equal([]string{"c1/snap0", "c1/snap1"}, snapshots)
+
+	s.Equal(2, s.db.ContainerNextSnapshot("c1"))
+	s.Equal(0, s.db.ContainerNextSnapshot("c2"))
+
+	// Snapshots need their container
+	_, err = s.db.ContainerCreate(ContainerArgs{Name: "c2/snap0", Ctype: CTypeSnapshot})
+	s.NotNil(err)
+
+	// Renaming the container keeps its snapshots attached
+	s.Nil(s.db.ContainerRename("c1", "c3"))
+	snapshots, err = s.db.ContainerGetSnapshots("c3")
+	s.Nil(err)
+	s.Len(snapshots, 2)
+
+	// Snapshots are numbered by their own name
+	s.Nil(s.db.ContainerRename("c1/snap1", "c3/snap4"))
+	s.Equal(5, s.db.ContainerNextSnapshot("c3"))
+}
+
+// The statements of the container queries are prepared once and reused.
+func (s *dbTestSuite) Test_ContainerQueriesReuseStatements() {
+	for i := 0; i < 2; i++ {
+		id, err := s.db.ContainerId("thename")
+		s.Nil(err)
+		s.Equal(1, id)
+
+		config, err := s.db.ContainerConfig(id)
+		s.Nil(err)
+		s.Equal(map[string]string{"thekey": "thevalue"}, config)
+
+		_, err = s.db.ContainerId("notthere")
+		s.Equal(sql.ErrNoRows, err)
+	}
+
+	s.Len(s.db.stmts, 2)
+
+	s.db.stmtsClose()
+	s.Len(s.db.stmts, 0)
+}
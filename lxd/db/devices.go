@@ -6,6 +6,7 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 
+	"github.com/lxc/lxd/lxd/db/query"
 	"github.com/lxc/lxd/lxd/types"
 )
 
@@ -106,70 +107,102 @@ func DevicesAdd(tx *sql.Tx, w string, cID int64, devices types.Devices) error {
 	return nil
 }
 
-func dbDeviceConfig(db *sql.DB, id int, isprofile bool) (types.Device, error) {
-	var query string
-	var key, value string
-	newdev := types.Device{} // That's a map[string]string
-	inargs := []interface{}{id}
-	outfmt := []interface{}{key, value}
-
+// Devices returns the devices of the container or profile with the given
+// name, fetched along with their configuration in a single query.
+func (n *Node) Devices(qName string, isprofile bool) (types.Devices, error) {
+	var q string
 	if isprofile {
-		query = `SELECT key, value FROM profiles_devices_config WHERE profile_device_id=?`
+		q = `SELECT profiles.name, profiles_devices.name, profiles_devices.type,
+			COALESCE(profiles_devices_config.key, ''), COALESCE(profiles_devices_config.value, '')
+			FROM profiles_devices JOIN profiles
+			ON profiles_devices.profile_id = profiles.id
+			LEFT JOIN profiles_devices_config
+			ON profiles_devices_config.profile_device_id = profiles_devices.id
+			WHERE profiles.name=?`
 	} else {
-		query = `SELECT key, value FROM containers_devices_config WHERE container_device_id=?`
+		q = `SELECT containers.name, containers_devices.name, containers_devices.type,
+			COALESCE(containers_devices_config.key, ''), COALESCE(containers_devices_config.value, '')
+			FROM containers_devices JOIN containers
+			ON containers_devices.container_id = containers.id
+			LEFT JOIN containers_devices_config
+			ON containers_devices_config.container_device_id = containers_devices.id
+			WHERE containers.name=?`
 	}
 
-	results, err := queryScan(db, query, inargs, outfmt)
-
+	devices, err := devicesByOwner(stmtQueryer{node: n}, q, []interface{}{qName})
 	if err != nil {
-		return newdev, err
+		return nil, err
 	}
 
-	for _, r := range results {
-		key = r[0].(string)
-		value = r[1].(string)
-		newdev[key] = value
+	result, ok := devices[qName]
+	if !ok {
+		result = types.Devices{}
 	}
 
-	return newdev, nil
+	return result, nil
 }
 
-func (n *Node) Devices(qName string, isprofile bool) (types.Devices, error) {
-	var q string
-	if isprofile {
-		q = `SELECT profiles_devices.id, profiles_devices.name, profiles_devices.type
-			FROM profiles_devices JOIN profiles
-			ON profiles_devices.profile_id = profiles.id
-   		WHERE profiles.name=?`
-	} else {
-		q = `SELECT containers_devices.id, containers_devices.name, containers_devices.type
-			FROM containers_devices JOIN containers
-			ON containers_devices.container_id = containers.id
-			WHERE containers.name=?`
+// ProfilesDevices returns the devices of the profiles with the given names,
+// by profile name, fetched in a single query.
+func (n *Node) ProfilesDevices(names []string) (map[string]types.Devices, error) {
+	if len(names) == 0 {
+		return map[string]types.Devices{}, nil
+	}
+
+	q := fmt.Sprintf(`SELECT profiles.name, profiles_devices.name, profiles_devices.type,
+		COALESCE(profiles_devices_config.key, ''), COALESCE(profiles_devices_config.value, '')
+		FROM profiles_devices JOIN profiles
+		ON profiles_devices.profile_id = profiles.id
+		LEFT JOIN profiles_devices_config
+		ON profiles_devices_config.profile_device_id = profiles_devices.id
+		WHERE profiles.name IN %s`, query.Params(len(names)))
+	inargs := []interface{}{}
+	for _, name := range names {
+		inargs = append(inargs, name)
 	}
-	var id, dtype int
-	var name, stype string
-	inargs := []interface{}{qName}
-	outfmt := []interface{}{id, name, dtype}
-	results, err := queryScan(n.db, q, inargs, outfmt)
+
+	return devicesByOwner(n.db, q, inargs)
+}
+
+// Run the given query, yielding one row per device config key with the name
+// of the container or profile owning the device, the name and type of the
+// device and the key and value, and collect the devices by owner.
+func devicesByOwner(qi queryer, q string, inargs []interface{}) (map[string]types.Devices, error) {
+	var owner, name, key, value string
+	var dtype int
+	outfmt := []interface{}{owner, name, dtype, key, value}
+	results, err := queryScan(qi, q, inargs, outfmt)
 	if err != nil {
 		return nil, err
 	}
 
-	devices := types.Devices{}
+	devices := map[string]types.Devices{}
 	for _, r := range results {
-		id = r[0].(int)
+		owner = r[0].(string)
 		name = r[1].(string)
-		stype, err = dbDeviceTypeToString(r[2].(int))
-		if err != nil {
-			return nil, err
+		key = r[3].(string)
+		value = r[4].(string)
+
+		if devices[owner] == nil {
+			devices[owner] = types.Devices{}
 		}
-		newdev, err := dbDeviceConfig(n.db, id, isprofile)
-		if err != nil {
-			return nil, err
+
+		device, ok := devices[owner][name]
+		if !ok {
+			stype, err := dbDeviceTypeToString(r[2].(int))
+			if err != nil {
+				return nil, err
+			}
+
+			device = types.Device{"type": stype}
+			devices[owner][name] = device
+		}
+
+		// Devices without any config yield a single row with an
+		// empty key
+		if key != "" {
+			device[key] = value
 		}
-		newdev["type"] = stype
-		devices[name] = newdev
 	}
 
 	return devices, nil
@@ -7,6 +7,7 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 
+	"github.com/lxc/lxd/lxd/db/query"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/osarch"
 )
@@ -195,35 +196,35 @@ func (n *Node) ImageGet(fingerprint string, public bool, strictMatching bool) (i
 		&create, &expire, &used, &upload}
 
 	var inargs []interface{}
-	query := `
+	q := `
         SELECT
             id, fingerprint, filename, size, cached, public, auto_update, architecture,
             creation_date, expiry_date, last_use_date, upload_date
         FROM images`
 	if strictMatching {
 		inargs = []interface{}{fingerprint}
-		query += " WHERE fingerprint = ?"
+		q += " WHERE fingerprint = ?"
 	} else {
-		inargs = []interface{}{fingerprint + "%"}
-		query += " WHERE fingerprint LIKE ?"
+		inargs = []interface{}{query.LikePrefix(fingerprint)}
+		q += " WHERE fingerprint LIKE ? ESCAPE '\\'"
 	}
 
 	if public {
-		query += " AND public=1"
+		q += " AND public=1"
 	}
 
-	err = dbQueryRowScan(n.db, query, inargs, outfmt)
+	err = dbQueryRowScan(n.db, q, inargs, outfmt)
 	if err != nil {
 		return -1, nil, err // Likely: there are no rows for this fingerprint
 	}
 
 	// Validate we only have a single match
 	if !strictMatching {
-		query = "SELECT COUNT(id) FROM images WHERE fingerprint LIKE ?"
+		q = "SELECT COUNT(id) FROM images WHERE fingerprint LIKE ? ESCAPE '\\'"
 		count := 0
 		outfmt := []interface{}{&count}
 
-		err = dbQueryRowScan(n.db, query, inargs, outfmt)
+		err = dbQueryRowScan(n.db, q, inargs, outfmt)
 		if err != nil {
 			return -1, nil, err
 		}
@@ -258,7 +259,7 @@ func (n *Node) ImageGet(fingerprint string, public bool, strictMatching bool) (i
 	image.UploadedAt = *upload
 
 	// Get the properties
-	q := "SELECT key, value FROM images_properties where image_id=?"
+	q = "SELECT key, value FROM images_properties where image_id=?"
 	var key, value, name, desc string
 	inargs = []interface{}{id}
 	outfmt = []interface{}{key, value}
@@ -11,7 +11,7 @@ import (
 )
 
 func (n *Node) Networks() ([]string, error) {
-	q := fmt.Sprintf("SELECT name FROM networks")
+	q := "SELECT name FROM networks"
 	inargs := []interface{}{}
 	var name string
 	outfmt := []interface{}{name}
@@ -212,7 +212,7 @@ func NetworkUpdateDescription(tx *sql.Tx, id int64, description string) error {
 }
 
 func NetworkConfigAdd(tx *sql.Tx, id int64, config map[string]string) error {
-	str := fmt.Sprintf("INSERT INTO networks_config (network_id, key, value) VALUES(?, ?, ?)")
+	str := "INSERT INTO networks_config (network_id, key, value) VALUES(?, ?, ?)"
 	stmt, err := tx.Prepare(str)
 	defer stmt.Close()
 	if err != nil {
@@ -39,6 +39,15 @@ func EnsureSchema(db *sql.DB, dir string, hook schema.Hook) (int, error) {
 				return err
 			}
 
+			// The latest changes may still be in the write-ahead
+			// log only
+			if shared.PathExists(path + "-wal") {
+				err := shared.FileCopy(path+"-wal", path+".bak-wal")
+				if err != nil {
+					return err
+				}
+			}
+
 			backupDone = true
 		}
 		logger.Debugf("Updating DB schema from %d to %d", version, version+1)
@@ -46,6 +55,7 @@ func EnsureSchema(db *sql.DB, dir string, hook schema.Hook) (int, error) {
 		if hook != nil {
 			err := hook(version, tx)
 			if err != nil {
+				return err
 			}
 		}
 
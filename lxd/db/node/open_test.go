@@ -2,8 +2,10 @@ package node_test
 
 import (
 	"database/sql"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/lxc/lxd/lxd/db/node"
@@ -41,6 +43,34 @@ func TestEnsureSchema(t *testing.T) {
 	assert.False(t, hookHasRun) // Because we use a schema.Fresh()
 }
 
+// If the hook fails, the update is aborted, leaving the backup made before
+// applying it around.
+func TestEnsureSchema_HookError(t *testing.T) {
+	dir, cleanup := newDir(t)
+	defer cleanup()
+
+	db, err := node.Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = node.EnsureSchema(db, dir, nil)
+	require.NoError(t, err)
+
+	// Pretend that the last update wasn't applied yet.
+	_, err = db.Exec("DELETE FROM schema WHERE version=(SELECT MAX(version) FROM schema)")
+	require.NoError(t, err)
+
+	hook := func(int, *sql.Tx) error {
+		return fmt.Errorf("boom")
+	}
+	_, err = node.EnsureSchema(db, dir, hook)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+
+	_, err = os.Stat(filepath.Join(dir, "lxd.db.bak"))
+	assert.NoError(t, err)
+}
+
 // Create a new temporary directory, along with a function to clean it up.
 func newDir(t *testing.T) (string, func()) {
 	dir, err := ioutil.TempDir("", "lxd-db-node-test-")
@@ -12,8 +12,26 @@ CREATE TABLE certificates (
     type INTEGER NOT NULL,
     name VARCHAR(255) NOT NULL,
     certificate TEXT NOT NULL,
+    read_only INTEGER NOT NULL DEFAULT 0,
     UNIQUE (fingerprint)
 );
+CREATE TABLE certificates_containers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    certificate_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    FOREIGN KEY (certificate_id) REFERENCES certificates (id) ON DELETE CASCADE,
+    UNIQUE (certificate_id, name)
+);
+CREATE TABLE cluster_members (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    address VARCHAR(255) NOT NULL,
+    certificate TEXT NOT NULL,
+    joined_at DATETIME NOT NULL,
+    evacuated INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (name),
+    UNIQUE (address)
+);
 CREATE TABLE config (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     key VARCHAR(255) NOT NULL,
@@ -30,6 +48,8 @@ CREATE TABLE "containers" (
     stateful INTEGER NOT NULL DEFAULT 0,
     last_use_date DATETIME,
     description TEXT,
+    parent_id INTEGER REFERENCES containers (id) ON DELETE CASCADE,
+    snapshot_name TEXT,
     UNIQUE (name)
 );
 CREATE TABLE containers_config (
@@ -119,6 +139,19 @@ CREATE TABLE networks_config (
     UNIQUE (network_id, key),
     FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE
 );
+CREATE TABLE operations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    uuid TEXT NOT NULL,
+    type VARCHAR(255) NOT NULL DEFAULT '',
+    class VARCHAR(255) NOT NULL,
+    status_code INTEGER NOT NULL,
+    resources TEXT,
+    metadata TEXT,
+    err TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE (uuid)
+);
 CREATE TABLE patches (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     name VARCHAR(255) NOT NULL,
@@ -187,6 +220,12 @@ CREATE TABLE storage_volumes_config (
     UNIQUE (storage_volume_id, key),
     FOREIGN KEY (storage_volume_id) REFERENCES storage_volumes (id) ON DELETE CASCADE
 );
+CREATE INDEX containers_parent_id_idx ON containers (parent_id);
+CREATE INDEX containers_profiles_profile_id_idx ON containers_profiles (profile_id);
+CREATE INDEX images_properties_image_id_idx ON images_properties (image_id);
+CREATE INDEX images_source_image_id_idx ON images_source (image_id);
+CREATE INDEX storage_volumes_name_type_idx ON storage_volumes (name,
+    type);
 
-INSERT INTO schema (version, updated_at) VALUES (36, strftime("%s"))
+INSERT INTO schema (version, updated_at) VALUES (42, strftime("%s"))
 `
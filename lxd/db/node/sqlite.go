@@ -8,9 +8,12 @@ import (
 )
 
 func init() {
-	sql.Register("sqlite3_with_fk", &sqlite3.SQLiteDriver{ConnectHook: sqliteEnableForeignKeys})
+	sql.Register("sqlite3_with_fk", &sqlite3.SQLiteDriver{ConnectHook: sqliteSetupConnection})
 }
 
+// Maximum number of connections to the node-level database.
+const sqliteMaxConns = 8
+
 // Opens the node-level database with the correct parameters for LXD.
 func sqliteOpen(path string) (*sql.DB, error) {
 	timeout := 5 // TODO - make this command-line configurable?
@@ -20,10 +23,33 @@ func sqliteOpen(path string) (*sql.DB, error) {
 	openPath := fmt.Sprintf("%s?_busy_timeout=%d&_txlock=exclusive", path, timeout*1000)
 
 	// Open the database. If the file doesn't exist it is created.
-	return sql.Open("sqlite3_with_fk", openPath)
+	db, err := sql.Open("sqlite3_with_fk", openPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// With the write-ahead log the readers can use their own connections
+	// concurrently, but there's no point in opening many of them since the
+	// writers are serialized anyway. The connections are kept open for as
+	// long as the database is: they don't go stale like network ones, and
+	// re-opening them would run the setup again and drop their prepared
+	// statements.
+	db.SetMaxOpenConns(sqliteMaxConns)
+	db.SetMaxIdleConns(sqliteMaxConns)
+	db.SetConnMaxLifetime(0)
+
+	return db, nil
 }
 
-func sqliteEnableForeignKeys(conn *sqlite3.SQLiteConn) error {
+// Enables the foreign keys and the write-ahead log on each new connection. With
+// the write-ahead log, readers don't block writers and a writer doesn't block
+// readers, so concurrent API calls hit a locked database much less often.
+func sqliteSetupConnection(conn *sqlite3.SQLiteConn) error {
 	_, err := conn.Exec("PRAGMA foreign_keys=ON;", nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Exec("PRAGMA journal_mode=WAL;", nil)
 	return err
 }
@@ -84,9 +84,96 @@ var updates = map[int]schema.Update{
 	34: updateFromV33,
 	35: updateFromV34,
 	36: updateFromV35,
+	37: updateFromV36,
+	38: updateFromV37,
+	39: updateFromV38,
+	40: updateFromV39,
+	41: updateFromV40,
+	42: updateFromV41,
 }
 
 // Schema updates begin here
+func updateFromV41(tx *sql.Tx) error {
+	// Index the columns used to look rows up, which aren't already
+	// covered by a UNIQUE constraint.
+	stmts := `
+CREATE INDEX containers_parent_id_idx ON containers (parent_id);
+CREATE INDEX containers_profiles_profile_id_idx ON containers_profiles (profile_id);
+CREATE INDEX images_properties_image_id_idx ON images_properties (image_id);
+CREATE INDEX images_source_image_id_idx ON images_source (image_id);
+CREATE INDEX storage_volumes_name_type_idx ON storage_volumes (name, type);`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+func updateFromV40(tx *sql.Tx) error {
+	// Snapshots reference their container and have their own name,
+	// rather than being matched by the prefix of their full name.
+	stmts := `
+ALTER TABLE containers ADD COLUMN parent_id INTEGER REFERENCES containers (id) ON DELETE CASCADE;
+ALTER TABLE containers ADD COLUMN snapshot_name TEXT;
+UPDATE containers SET parent_id = (
+    SELECT parents.id FROM containers AS parents
+     WHERE parents.type = 0 AND parents.name = SUBSTR(containers.name, 1, INSTR(containers.name, '/') - 1)),
+  snapshot_name = SUBSTR(name, INSTR(name, '/') + 1)
+  WHERE type = 1;`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+func updateFromV39(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE cluster_members ADD COLUMN evacuated INTEGER NOT NULL DEFAULT 0;")
+	return err
+}
+
+func updateFromV38(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE cluster_members (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    address VARCHAR(255) NOT NULL,
+    certificate TEXT NOT NULL,
+    joined_at DATETIME NOT NULL,
+    UNIQUE (name),
+    UNIQUE (address)
+);`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV37(tx *sql.Tx) error {
+	stmts := `
+ALTER TABLE certificates ADD COLUMN read_only INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE certificates_containers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    certificate_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    FOREIGN KEY (certificate_id) REFERENCES certificates (id) ON DELETE CASCADE,
+    UNIQUE (certificate_id, name)
+);`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+func updateFromV36(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE operations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    uuid TEXT NOT NULL,
+    type VARCHAR(255) NOT NULL DEFAULT '',
+    class VARCHAR(255) NOT NULL,
+    status_code INTEGER NOT NULL,
+    resources TEXT,
+    metadata TEXT,
+    err TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE (uuid)
+);`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
 func updateFromV35(tx *sql.Tx) error {
 	stmts := `
 CREATE TABLE tmp (
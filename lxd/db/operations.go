@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// OperationInfo is a background operation as recorded in the database, so
+// that it outlives the daemon process which ran it.
+type OperationInfo struct {
+	// Type tells what the operation was doing, so that the right cleanup can
+	// be performed if it got interrupted.
+	Type string
+
+	api.Operation
+}
+
+// OperationSave records the given operation in the database, replacing any
+// previous entry with the same UUID.
+func (n *Node) OperationSave(op OperationInfo) error {
+	resources, err := json.Marshal(op.Resources)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := json.Marshal(op.Metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec(n.db, `
+INSERT OR REPLACE INTO operations (uuid, type, class, status_code, resources, metadata, err, created_at, updated_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		op.ID, op.Type, op.Class, op.StatusCode, string(resources), string(metadata), op.Err,
+		op.CreatedAt, op.UpdatedAt)
+
+	return err
+}
+
+// OperationGet returns the operation with the given UUID.
+func (n *Node) OperationGet(uuid string) (*OperationInfo, error) {
+	ops, err := n.operations("WHERE uuid=?", uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ops) == 0 {
+		return nil, NoSuchObjectError
+	}
+
+	return &ops[0], nil
+}
+
+// Operations returns all the operations recorded in the database.
+func (n *Node) Operations() ([]OperationInfo, error) {
+	return n.operations("")
+}
+
+// OperationRemove removes the operation with the given UUID from the database.
+func (n *Node) OperationRemove(uuid string) error {
+	_, err := exec(n.db, "DELETE FROM operations WHERE uuid=?", uuid)
+	return err
+}
+
+func (n *Node) operations(where string, args ...interface{}) ([]OperationInfo, error) {
+	q := `
+SELECT uuid, type, class, status_code, resources, metadata, err, created_at, updated_at
+    FROM operations ` + where + ` ORDER BY created_at`
+
+	rows, err := dbQuery(n.db, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ops := []OperationInfo{}
+	for rows.Next() {
+		op := OperationInfo{}
+		var resources, metadata sql.NullString
+		var createdAt, updatedAt time.Time
+
+		err := rows.Scan(&op.ID, &op.Type, &op.Class, &op.StatusCode, &resources, &metadata, &op.Err, &createdAt, &updatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if resources.Valid && resources.String != "" {
+			err = json.Unmarshal([]byte(resources.String), &op.Resources)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if metadata.Valid && metadata.String != "" {
+			err = json.Unmarshal([]byte(metadata.String), &op.Metadata)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		op.Status = op.StatusCode.String()
+		op.CreatedAt = createdAt
+		op.UpdatedAt = updatedAt
+		ops = append(ops, op)
+	}
+
+	return ops, rows.Err()
+}
@@ -1,14 +1,10 @@
 package db
 
-import (
-	"fmt"
-)
-
 func (n *Node) Patches() ([]string, error) {
 	inargs := []interface{}{}
 	outfmt := []interface{}{""}
 
-	query := fmt.Sprintf("SELECT name FROM patches")
+	query := "SELECT name FROM patches"
 	result, err := queryScan(n.db, query, inargs, outfmt)
 	if err != nil {
 		return []string{}, err
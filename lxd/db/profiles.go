@@ -6,13 +6,14 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 
+	"github.com/lxc/lxd/lxd/db/query"
 	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared/api"
 )
 
 // Profiles returns a string list of profiles.
 func (n *Node) Profiles() ([]string, error) {
-	q := fmt.Sprintf("SELECT name FROM profiles")
+	q := "SELECT name FROM profiles"
 	inargs := []interface{}{}
 	var name string
 	outfmt := []interface{}{name}
@@ -161,6 +162,57 @@ func (n *Node) ProfileConfig(name string) (map[string]string, error) {
 	return config, nil
 }
 
+// ProfilesConfig returns the configuration of the profiles with the given
+// names, by profile name, fetched in a single query. If any of the profiles
+// doesn't exist, NoSuchObjectError is returned.
+func (n *Node) ProfilesConfig(names []string) (map[string]map[string]string, error) {
+	configs := map[string]map[string]string{}
+	if len(names) == 0 {
+		return configs, nil
+	}
+
+	q := fmt.Sprintf(`
+        SELECT
+            profiles.name, COALESCE(profiles_config.key, ''), COALESCE(profiles_config.value, '')
+        FROM profiles
+        LEFT JOIN profiles_config ON profiles_config.profile_id=profiles.id
+		WHERE profiles.name IN %s`, query.Params(len(names)))
+	inargs := []interface{}{}
+	for _, name := range names {
+		inargs = append(inargs, name)
+	}
+	var name, key, value string
+	outfmt := []interface{}{name, key, value}
+	results, err := queryScan(n.db, q, inargs, outfmt)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get profiles: %v", err)
+	}
+
+	for _, r := range results {
+		name = r[0].(string)
+		key = r[1].(string)
+		value = r[2].(string)
+
+		if configs[name] == nil {
+			configs[name] = map[string]string{}
+		}
+
+		// Profiles without any config yield a single row with an
+		// empty key
+		if key != "" {
+			configs[name][key] = value
+		}
+	}
+
+	for _, name := range names {
+		if configs[name] == nil {
+			return nil, NoSuchObjectError
+		}
+	}
+
+	return configs, nil
+}
+
 func (n *Node) ProfileDelete(name string) error {
 	id, _, err := n.ProfileGet(name)
 	if err != nil {
@@ -181,12 +233,25 @@ func (n *Node) ProfileUpdate(name string, newName string) error {
 		return err
 	}
 
-	_, err = tx.Exec("UPDATE profiles SET name=? WHERE name=?", newName, name)
+	// Containers reference profiles by ID so renaming the profile entry is
+	// all that's needed to keep them attached to it.
+	result, err := tx.Exec("UPDATE profiles SET name=? WHERE name=?", newName, name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	count, err := result.RowsAffected()
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	if count != 1 {
+		tx.Rollback()
+		return NoSuchObjectError
+	}
+
 	err = TxCommit(tx)
 
 	return err
@@ -219,7 +284,7 @@ func ProfileConfigClear(tx *sql.Tx, id int64) error {
 }
 
 func ProfileConfigAdd(tx *sql.Tx, id int64, config map[string]string) error {
-	str := fmt.Sprintf("INSERT INTO profiles_config (profile_id, key, value) VALUES(?, ?, ?)")
+	str := "INSERT INTO profiles_config (profile_id, key, value) VALUES(?, ?, ?)"
 	stmt, err := tx.Prepare(str)
 	defer stmt.Close()
 	if err != nil {
@@ -7,13 +7,22 @@ import (
 	"strings"
 )
 
-// Return a parameters expression with the given number of '?'
-// placeholders. E.g. exprParams(2) -> "(?, ?)". Useful for
-// IN expressions.
-func exprParams(n int) string {
+// Params returns a parameters expression with the given number of '?'
+// placeholders. E.g. Params(2) -> "(?, ?)". Useful for IN expressions.
+func Params(n int) string {
 	tokens := make([]string, n)
 	for i := 0; i < n; i++ {
 		tokens[i] = "?"
 	}
 	return fmt.Sprintf("(%s)", strings.Join(tokens, ", "))
 }
+
+// LikePrefix returns a pattern matching the values starting with the given
+// prefix, to be used with "LIKE ? ESCAPE '\'". The wildcards in the prefix
+// are escaped, so they get matched literally.
+func LikePrefix(prefix string) string {
+	prefix = strings.Replace(prefix, `\`, `\\`, -1)
+	prefix = strings.Replace(prefix, "%", `\%`, -1)
+	prefix = strings.Replace(prefix, "_", `\_`, -1)
+	return prefix + "%"
+}
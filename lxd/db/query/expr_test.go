@@ -0,0 +1,42 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLikePrefix(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("CREATE TABLE test (name TEXT)")
+	require.NoError(t, err)
+	for _, name := range []string{"abc", "a_c", "a%c", `a\c`} {
+		_, err := db.Exec("INSERT INTO test (name) VALUES (?)", name)
+		require.NoError(t, err)
+	}
+
+	cases := map[string][]string{
+		"a":   {"a%c", `a\c`, "a_c", "abc"},
+		"ab":  {"abc"},
+		"a_":  {"a_c"},
+		"a%":  {"a%c"},
+		`a\`:  {`a\c`},
+		"%":   {},
+		"abc": {"abc"},
+	}
+
+	for prefix, expected := range cases {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+
+		names, err := query.SelectStrings(tx, `SELECT name FROM test WHERE name LIKE ? ESCAPE '\' ORDER BY name`, query.LikePrefix(prefix))
+		require.NoError(t, err)
+		assert.Equal(t, expected, names, prefix)
+
+		require.NoError(t, tx.Rollback())
+	}
+}
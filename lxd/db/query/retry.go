@@ -0,0 +1,56 @@
+package query
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Number of attempts made by Retry, and base delay between them.
+var (
+	retryAttempts = 20
+	retryDelay    = 50 * time.Millisecond
+)
+
+// Retry executes the given function, running it again a few times, after a
+// short random delay, as long as it fails because the database is locked by
+// another connection. It returns the error of the last attempt.
+//
+// The function is expected to run a whole transaction, since a transaction
+// which hit a locked database can't be carried on.
+func Retry(f func() error) error {
+	var err error
+	for i := 0; i < retryAttempts; i++ {
+		err = f()
+		if !IsRetriableError(err) {
+			return err
+		}
+
+		time.Sleep(retryDelay + time.Duration(rand.Int63n(int64(retryDelay))))
+	}
+
+	return err
+}
+
+// IsRetriableError returns true if the given error is caused by the database
+// being busy or locked, in which case it's worth trying again.
+func IsRetriableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	sqliteErr, ok := err.(sqlite3.Error)
+	if ok {
+		return sqliteErr.Code == sqlite3.ErrLocked || sqliteErr.Code == sqlite3.ErrBusy
+	}
+
+	if err == sqlite3.ErrLocked || err == sqlite3.ErrBusy {
+		return true
+	}
+
+	// The error might have been wrapped, e.g. by Transaction
+	message := err.Error()
+	return strings.Contains(message, "database is locked") || strings.Contains(message, "database table is locked")
+}
@@ -0,0 +1,53 @@
+package query_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+// The function is run again as long as the database is locked.
+func TestRetry_Locked(t *testing.T) {
+	attempts := 0
+	err := query.Retry(func() error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("failed to begin transaction: database is locked")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+// Other errors are returned right away.
+func TestRetry_OtherError(t *testing.T) {
+	attempts := 0
+	err := query.Retry(func() error {
+		attempts++
+		return fmt.Errorf("boom")
+	})
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestIsRetriableError(t *testing.T) {
+	cases := []struct {
+		err       error
+		retriable bool
+	}{
+		{nil, false},
+		{fmt.Errorf("boom"), false},
+		{sqlite3.Error{Code: sqlite3.ErrBusy}, true},
+		{sqlite3.Error{Code: sqlite3.ErrLocked}, true},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint}, false},
+		{fmt.Errorf("database is locked"), true},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.retriable, query.IsRetriableError(c.err), "%v", c.err)
+	}
+}
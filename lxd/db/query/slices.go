@@ -5,8 +5,9 @@ import (
 )
 
 // SelectStrings executes a statement which must yield rows with a single string
-// column. It returns the list of column values.
-func SelectStrings(tx *sql.Tx, query string) ([]string, error) {
+// column, with the given arguments for its parameters. It returns the list of
+// column values.
+func SelectStrings(tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
 	values := []string{}
 	scan := func(rows *sql.Rows) error {
 		var value string
@@ -18,7 +19,7 @@ func SelectStrings(tx *sql.Tx, query string) ([]string, error) {
 		return nil
 	}
 
-	err := scanSingleColumn(tx, query, "TEXT", scan)
+	err := scanSingleColumn(tx, query, args, "TEXT", scan)
 	if err != nil {
 		return nil, err
 	}
@@ -27,8 +28,9 @@ func SelectStrings(tx *sql.Tx, query string) ([]string, error) {
 }
 
 // SelectIntegers executes a statement which must yield rows with a single integer
-// column. It returns the list of column values.
-func SelectIntegers(tx *sql.Tx, query string) ([]int, error) {
+// column, with the given arguments for its parameters. It returns the list of
+// column values.
+func SelectIntegers(tx *sql.Tx, query string, args ...interface{}) ([]int, error) {
 	values := []int{}
 	scan := func(rows *sql.Rows) error {
 		var value int
@@ -40,7 +42,7 @@ func SelectIntegers(tx *sql.Tx, query string) ([]int, error) {
 		return nil
 	}
 
-	err := scanSingleColumn(tx, query, "INTEGER", scan)
+	err := scanSingleColumn(tx, query, args, "INTEGER", scan)
 	if err != nil {
 		return nil, err
 	}
@@ -51,8 +53,8 @@ func SelectIntegers(tx *sql.Tx, query string) ([]int, error) {
 // Execute the given query and ensure that it yields rows with a single column
 // of the given database type. For every row yielded, execute the given
 // scanner.
-func scanSingleColumn(tx *sql.Tx, query string, typeName string, scan scanFunc) error {
-	rows, err := tx.Query(query)
+func scanSingleColumn(tx *sql.Tx, query string, args []interface{}, typeName string, scan scanFunc) error {
+	rows, err := tx.Query(query, args...)
 	if err != nil {
 		return err
 	}
@@ -38,10 +38,12 @@ SELECT version FROM schema ORDER BY version
 }
 
 // Return a list of SQL statements that can be used to create all tables in the
-// database.
+// database, followed by their explicitly created indexes.
 func selectTablesSQL(tx *sql.Tx) ([]string, error) {
 	statement := `
-SELECT sql FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name != 'schema' ORDER BY name
+SELECT sql FROM sqlite_master
+  WHERE type IN ('table', 'index') AND sql IS NOT NULL AND name NOT LIKE 'sqlite_%' AND name != 'schema'
+  ORDER BY type = 'index', name
 `
 	return query.SelectStrings(tx, statement)
 }
@@ -275,6 +275,30 @@ func TestSchemaDump(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// The indexes are dumped too, after the tables.
+func TestSchemaDump_Indexes(t *testing.T) {
+	schema, db := newSchemaAndDB(t)
+	schema.Add(updateCreateTable)
+	schema.Add(updateCreateIndex)
+	_, err := schema.Ensure(db)
+	assert.NoError(t, err)
+
+	dump, err := schema.Dump(db)
+	assert.NoError(t, err)
+
+	_, db = newSchemaAndDB(t)
+	schema.Fresh(dump)
+	_, err = schema.Ensure(db)
+	assert.NoError(t, err)
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+
+	indexes, err := query.SelectStrings(tx, "SELECT name FROM sqlite_master WHERE type = 'index'")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test_id_idx"}, indexes)
+}
+
 // If not all updates are applied, Dump() returns an error.
 func TestSchemaDump_MissingUpdatees(t *testing.T) {
 	schema, db := newSchemaAndDB(t)
@@ -370,6 +394,12 @@ func updateAddColumn(tx *sql.Tx) error {
 	return err
 }
 
+// An update that indexes the test table.
+func updateCreateIndex(tx *sql.Tx) error {
+	_, err := tx.Exec("CREATE INDEX test_id_idx ON test (id)")
+	return err
+}
+
 // An update that unconditionally fails with an error.
 func updateBoom(tx *sql.Tx) error {
 	return fmt.Errorf("boom")
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// Return the prepared statement for the given query, preparing it the first
+// time it's needed.
+//
+// This is meant for the fixed queries run on the hot paths, like loading a
+// container for each API request, not for the ones built on the fly, since
+// the statements are kept until the database is closed.
+func (n *Node) stmt(q string) (*sql.Stmt, error) {
+	n.stmtsLock.Lock()
+	defer n.stmtsLock.Unlock()
+
+	stmt, ok := n.stmts[q]
+	if ok {
+		return stmt, nil
+	}
+
+	stmt, err := n.db.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.stmts == nil {
+		n.stmts = map[string]*sql.Stmt{}
+	}
+	n.stmts[q] = stmt
+
+	return stmt, nil
+}
+
+// Close all the prepared statements.
+func (n *Node) stmtsClose() {
+	n.stmtsLock.Lock()
+	defer n.stmtsLock.Unlock()
+
+	for _, stmt := range n.stmts {
+		stmt.Close()
+	}
+
+	n.stmts = nil
+}
+
+// Like dbQueryRowScan, but using a prepared statement.
+func (n *Node) stmtQueryRowScan(q string, args []interface{}, outargs []interface{}) error {
+	return query.Retry(func() error {
+		stmt, err := n.stmt(q)
+		if err != nil {
+			return err
+		}
+
+		return stmt.QueryRow(args...).Scan(outargs...)
+	})
+}
+
+// Like queryScan, but using a prepared statement.
+func (n *Node) stmtQueryScan(q string, inargs []interface{}, outfmt []interface{}) ([][]interface{}, error) {
+	return queryScan(stmtQueryer{node: n}, q, inargs, outfmt)
+}
+
+// Implements the queryer interface, by running the queries through prepared
+// statements.
+type stmtQueryer struct {
+	node *Node
+}
+
+func (s stmtQueryer) Query(q string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := s.node.stmt(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.Query(args...)
+}
@@ -497,42 +497,6 @@ func deviceNetlinkListener() (chan []string, chan []string, chan usbDevice, erro
 	return chCPU, chNetwork, chUSB, nil
 }
 
-func parseCpuset(cpu string) ([]int, error) {
-	cpus := []int{}
-	chunks := strings.Split(cpu, ",")
-	for _, chunk := range chunks {
-		if strings.Contains(chunk, "-") {
-			// Range
-			fields := strings.SplitN(chunk, "-", 2)
-			if len(fields) != 2 {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
-			}
-
-			low, err := strconv.Atoi(fields[0])
-			if err != nil {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
-			}
-
-			high, err := strconv.Atoi(fields[1])
-			if err != nil {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
-			}
-
-			for i := low; i <= high; i++ {
-				cpus = append(cpus, i)
-			}
-		} else {
-			// Simple entry
-			nr, err := strconv.Atoi(chunk)
-			if err != nil {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
-			}
-			cpus = append(cpus, nr)
-		}
-	}
-	return cpus, nil
-}
-
 func deviceTaskBalance(s *state.State) {
 	min := func(x, y int) int {
 		if x < y {
@@ -557,7 +521,7 @@ func deviceTaskBalance(s *state.State) {
 		}
 	}
 
-	effectiveCpusInt, err := parseCpuset(effectiveCpus)
+	effectiveCpusInt, err := shared.ParseCpuset(effectiveCpus)
 	if err != nil {
 		logger.Errorf("Error parsing effective CPU set")
 		return
@@ -574,7 +538,7 @@ func deviceTaskBalance(s *state.State) {
 		// File might exist even though there are no isolated cpus.
 		isolatedCpus := strings.TrimSpace(string(buf))
 		if isolatedCpus != "" {
-			isolatedCpusInt, err = parseCpuset(isolatedCpus)
+			isolatedCpusInt, err = shared.ParseCpuset(isolatedCpus)
 			if err != nil {
 				logger.Errorf("Error parsing isolated CPU set: %s", string(isolatedCpus))
 				return
@@ -597,7 +561,7 @@ func deviceTaskBalance(s *state.State) {
 	if err != nil && shared.PathExists("/sys/fs/cgroup/cpuset/lxc") {
 		logger.Warn("Error setting lxd's cpuset.cpus", log.Ctx{"err": err})
 	}
-	cpus, err := parseCpuset(effectiveCpus)
+	cpus, err := shared.ParseCpuset(effectiveCpus)
 	if err != nil {
 		logger.Error("Error parsing host's cpu set", log.Ctx{"cpuset": effectiveCpus, "err": err})
 		return
@@ -611,35 +575,59 @@ func deviceTaskBalance(s *state.State) {
 	}
 	fixedContainers := map[int][]container{}
 	balancedContainers := map[container]int{}
+	allowedCpus := map[container][]int{}
 	for _, name := range containers {
 		c, err := containerLoadByName(s, name)
 		if err != nil {
 			continue
 		}
 
+		if !c.IsRunning() {
+			continue
+		}
+
+		// Restrict the container to the CPUs of its NUMA nodes
 		conf := c.ExpandedConfig()
+		allowedCpus[c] = cpus
+		if conf["limits.cpu.nodes"] != "" {
+			nodeCpus, err := deviceNumaNodeCPUs(conf["limits.cpu.nodes"])
+			if err != nil {
+				logger.Error("balance: Invalid NUMA nodes", log.Ctx{"name": c.Name(), "err": err, "value": conf["limits.cpu.nodes"]})
+				continue
+			}
+
+			allowedCpus[c] = []int{}
+			for _, nr := range nodeCpus {
+				if shared.IntInSlice(nr, cpus) {
+					allowedCpus[c] = append(allowedCpus[c], nr)
+				}
+			}
+		}
+
 		cpulimit, ok := conf["limits.cpu"]
 		if !ok || cpulimit == "" {
-			cpulimit = effectiveCpus
-		}
+			cpulimitSlice := []string{}
+			for _, nr := range allowedCpus[c] {
+				cpulimitSlice = append(cpulimitSlice, fmt.Sprintf("%d", nr))
+			}
 
-		if !c.IsRunning() {
-			continue
+			cpulimit = strings.Join(cpulimitSlice, ",")
 		}
 
 		count, err := strconv.Atoi(cpulimit)
 		if err == nil {
 			// Load-balance
-			count = min(count, len(cpus))
+			count = min(count, len(allowedCpus[c]))
 			balancedContainers[c] = count
 		} else {
 			// Pinned
-			containerCpus, err := parseCpuset(cpulimit)
+			containerCpus, err := shared.ParseCpuset(cpulimit)
 			if err != nil {
-				return
+				logger.Error("balance: Invalid CPU set", log.Ctx{"name": c.Name(), "err": err, "value": cpulimit})
+				continue
 			}
 			for _, nr := range containerCpus {
-				if !shared.IntInSlice(nr, cpus) {
+				if !shared.IntInSlice(nr, allowedCpus[c]) {
 					continue
 				}
 
@@ -696,6 +684,10 @@ func deviceTaskBalance(s *state.State) {
 			if count == 0 {
 				break
 			}
+
+			if !shared.IntInSlice(cpu.id, allowedCpus[ctn]) {
+				continue
+			}
 			count -= 1
 
 			id := cpu.strId
@@ -724,6 +716,42 @@ func deviceTaskBalance(s *state.State) {
 	}
 }
 
+// deviceNumaNodeCPUs returns the CPUs belonging to the given set of NUMA
+// nodes (e.g. "0,2-3").
+func deviceNumaNodeCPUs(nodes string) ([]int, error) {
+	nodeIDs, err := shared.ParseCpuset(nodes)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid NUMA node set: %s", nodes)
+	}
+
+	cpus := []int{}
+	for _, node := range nodeIDs {
+		buf, err := ioutil.ReadFile(fmt.Sprintf("/sys/devices/system/node/node%d/cpulist", node))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("NUMA node %d doesn't exist", node)
+			}
+
+			return nil, err
+		}
+
+		// Memory-only nodes don't have any CPU
+		cpulist := strings.TrimSpace(string(buf))
+		if cpulist == "" {
+			continue
+		}
+
+		nodeCpus, err := shared.ParseCpuset(cpulist)
+		if err != nil {
+			return nil, err
+		}
+
+		cpus = append(cpus, nodeCpus...)
+	}
+
+	return cpus, nil
+}
+
 func deviceNetworkPriority(s *state.State, netif string) {
 	// Don't bother running when CGroup support isn't there
 	if !s.OS.CGroupNetPrioController {
@@ -776,7 +804,7 @@ func deviceUSBEvent(s *state.State, usb usbDevice) {
 		c, ok := containerIf.(*containerLXC)
 		if !ok {
 			logger.Errorf("got device event on non-LXC container?")
-			return
+			continue
 		}
 
 		if !c.IsRunning() {
@@ -794,17 +822,19 @@ func deviceUSBEvent(s *state.State, usb usbDevice) {
 				continue
 			}
 
+			// A failure for one container shouldn't prevent the
+			// device from reaching the others.
 			if usb.action == "add" {
 				err := c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, usb.major, usb.minor, usb.path)
 				if err != nil {
 					logger.Error("failed to create usb device", log.Ctx{"err": err, "usb": usb, "container": c.Name()})
-					return
+					continue
 				}
 			} else if usb.action == "remove" {
 				err := c.removeUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, usb.major, usb.minor, usb.path)
 				if err != nil {
 					logger.Error("failed to remove usb device", log.Ctx{"err": err, "usb": usb, "container": c.Name()})
-					return
+					continue
 				}
 			} else {
 				logger.Error("unknown action for usb device", log.Ctx{"usb": usb})
@@ -961,7 +991,20 @@ func deviceRemoveInterface(nic string) error {
 	return err
 }
 
-func deviceMountDisk(srcPath string, dstPath string, readonly bool, recursive bool) error {
+// deviceDiskPropagation maps the valid values of the disk "propagation"
+// property to their mount flags
+var deviceDiskPropagation = map[string]int{
+	"private":     syscall.MS_PRIVATE,
+	"shared":      syscall.MS_SHARED,
+	"slave":       syscall.MS_SLAVE,
+	"unbindable":  syscall.MS_UNBINDABLE,
+	"rprivate":    syscall.MS_PRIVATE | syscall.MS_REC,
+	"rshared":     syscall.MS_SHARED | syscall.MS_REC,
+	"rslave":      syscall.MS_SLAVE | syscall.MS_REC,
+	"runbindable": syscall.MS_UNBINDABLE | syscall.MS_REC,
+}
+
+func deviceMountDisk(srcPath string, dstPath string, readonly bool, recursive bool, propagation string) error {
 	var err error
 
 	// Prepare the mount flags
@@ -998,8 +1041,17 @@ func deviceMountDisk(srcPath string, dstPath string, readonly bool, recursive bo
 	}
 
 	flags = syscall.MS_REC | syscall.MS_SLAVE
+	if propagation != "" {
+		propagationFlags, ok := deviceDiskPropagation[propagation]
+		if !ok {
+			return fmt.Errorf("Invalid propagation mode \"%s\"", propagation)
+		}
+
+		flags = propagationFlags
+	}
+
 	if err = syscall.Mount("", dstPath, "", uintptr(flags), ""); err != nil {
-		return fmt.Errorf("unable to make mount %s private: %s", dstPath, err)
+		return fmt.Errorf("unable to set the propagation of mount %s: %s", dstPath, err)
 	}
 
 	return nil
@@ -1254,7 +1306,7 @@ func deviceParseDiskLimit(readSpeed string, writeSpeed string) (int64, int64, in
 		bps := int64(0)
 		iops := int64(0)
 
-		if readSpeed == "" {
+		if value == "" {
 			return bps, iops, nil
 		}
 
@@ -43,6 +43,15 @@ type Config struct {
 	//
 	// It can be updated after the endpoints are up using UpdateNetworkAddress().
 	NetworkAddress string
+
+	// HTTP server exposing the metrics, if any.
+	MetricsServer *http.Server
+
+	// MetricsAddress sets the address for the metrics endpoint. If not
+	// set, the metrics endpoint won't be started.
+	//
+	// It can be updated after the endpoints are up using MetricsUpdateAddress().
+	MetricsAddress string
 }
 
 // Up brings up all applicable LXD endpoints and starts accepting HTTP
@@ -83,6 +92,13 @@ type Config struct {
 //
 // The network endpoint socket will use TLS encryption, using the certificate
 // keypair and CA passed via config.Cert.
+//
+// metrics endpoint (TCP socket with TLS)
+// --------------------------------------
+//
+// If a metrics address was set via config.MetricsAddress, create a network
+// socket bound to it, serving config.MetricsServer. It uses the same TLS
+// keypair and CA as the network endpoint.
 func Up(config *Config) (*Endpoints, error) {
 	if config.Dir == "" {
 		return nil, fmt.Errorf("no directory configured")
@@ -134,6 +150,7 @@ func (e *Endpoints) up(config *Config) error {
 		devlxd:  config.DevLxdServer,
 		local:   config.RestServer,
 		network: config.RestServer,
+		metrics: config.MetricsServer,
 	}
 	e.cert = config.Cert
 
@@ -170,6 +187,11 @@ func (e *Endpoints) up(config *Config) error {
 		e.listeners[network] = networkCreateListener(config.NetworkAddress, e.cert)
 	}
 
+	if config.MetricsAddress != "" && config.MetricsServer != nil {
+		// Errors here are not fatal and are just logged.
+		e.listeners[metrics] = networkCreateListener(config.MetricsAddress, e.cert)
+	}
+
 	logger.Infof("Starting /dev/lxd handler:")
 	e.serveHTTP(devlxd)
 
@@ -177,6 +199,9 @@ func (e *Endpoints) up(config *Config) error {
 	e.serveHTTP(local)
 	e.serveHTTP(network)
 
+	logger.Infof("Metrics server:")
+	e.serveHTTP(metrics)
+
 	return nil
 }
 
@@ -185,8 +210,14 @@ func (e *Endpoints) Down() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	logger.Infof("Stopping metrics handler:")
+	err := e.closeListener(metrics)
+	if err != nil {
+		return err
+	}
+
 	logger.Infof("Stopping REST API handler:")
-	err := e.closeListener(network)
+	err = e.closeListener(network)
 	if err != nil {
 		return err
 	}
@@ -276,6 +307,7 @@ const (
 	local kind = iota
 	devlxd
 	network
+	metrics
 )
 
 // Human-readable descriptions of the various kinds of endpoints.
@@ -283,4 +315,5 @@ var descriptions = map[kind]string{
 	local:   "Unix socket",
 	devlxd:  "devlxd socket",
 	network: "TCP socket",
+	metrics: "metrics TCP socket",
 }
@@ -4,6 +4,9 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"os/user"
+	"strconv"
+	"syscall"
 	"testing"
 
 	"github.com/lxc/lxd/shared"
@@ -60,6 +63,24 @@ func TestEndpoints_LocalSocketBasedActivation(t *testing.T) {
 	assert.Equal(t, true, shared.PathExists(path))
 }
 
+// The unix socket is only accessible by the process user and the given group.
+func TestEndpoints_LocalUnixSocketGroup(t *testing.T) {
+	endpoints, config, cleanup := newEndpoints(t)
+	defer cleanup()
+
+	group, err := user.LookupGroupId(strconv.Itoa(os.Getgid()))
+	require.NoError(t, err)
+
+	config.LocalUnixSocketGroup = group.Name
+	require.NoError(t, endpoints.Up(config))
+
+	info, err := os.Stat(endpoints.LocalSocketPath())
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+	assert.Equal(t, uint32(os.Getgid()), info.Sys().(*syscall.Stat_t).Gid)
+}
+
 // If a custom group for the unix socket is specified, but no such one exists,
 // an error is returned.
 func TestEndpoints_LocalUnknownUnixGroup(t *testing.T) {
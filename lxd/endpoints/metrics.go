@@ -0,0 +1,71 @@
+package endpoints
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// MetricsAddress returns the network addresss of the metrics endpoint, or an
+// empty string if there's no metrics endpoint.
+func (e *Endpoints) MetricsAddress() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	listener := e.listeners[metrics]
+	if listener == nil {
+		return ""
+	}
+	return listener.Addr().String()
+}
+
+// MetricsUpdateAddress updates the address for the metrics endpoint, shutting
+// it down and restarting it.
+func (e *Endpoints) MetricsUpdateAddress(address string) error {
+	if address != "" {
+		address = util.CanonicalNetworkAddress(address)
+	}
+
+	if address == e.MetricsAddress() {
+		return nil
+	}
+
+	logger.Infof("Update metrics address")
+
+	// First try to see if we can listen to this new port at all, so we
+	// don't close the old one (if any) in case of errors.
+	var listener net.Listener
+	if address != "" {
+		var err error
+		for i := 0; i < 10; i++ { // Ten retries over a second seems reasonable.
+			listener, err = net.Listen("tcp", address)
+			if err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot listen on metrics socket: %v", err)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.closeListener(metrics)
+
+	if address != "" {
+		if e.servers[metrics] == nil {
+			listener.Close()
+			return fmt.Errorf("no metrics server configured")
+		}
+
+		e.listeners[metrics] = networkTLSListener(listener, e.cert)
+		e.serveHTTP(metrics)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package endpoints_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// If a metrics address is set, a metrics TCP socket gets created, using the
+// same certificate as the network endpoint.
+func TestEndpoints_MetricsCreateTCPSocket(t *testing.T) {
+	endpoints, config, cleanup := newEndpoints(t)
+	defer cleanup()
+
+	config.MetricsServer = newServer()
+	config.MetricsAddress = "127.0.0.1:0"
+	require.NoError(t, endpoints.Up(config))
+
+	assert.NoError(t, httpGetOverTLSSocket(endpoints.MetricsAddress(), config.Cert))
+	assert.Equal(t, "", endpoints.NetworkAddress())
+}
+
+// When the metrics address is updated, any previous metrics socket gets
+// closed.
+func TestEndpoints_MetricsUpdateAddress(t *testing.T) {
+	endpoints, config, cleanup := newEndpoints(t)
+	defer cleanup()
+
+	config.MetricsServer = newServer()
+	require.NoError(t, endpoints.Up(config))
+	assert.Equal(t, "", endpoints.MetricsAddress())
+
+	require.NoError(t, endpoints.MetricsUpdateAddress("127.0.0.1:0"))
+	assert.NoError(t, httpGetOverTLSSocket(endpoints.MetricsAddress(), config.Cert))
+
+	require.NoError(t, endpoints.MetricsUpdateAddress(""))
+	assert.Equal(t, "", endpoints.MetricsAddress())
+}
@@ -83,16 +83,19 @@ func (e *Endpoints) NetworkUpdateAddress(address string) error {
 // endpoint.
 //
 // If the network endpoint is active, in-flight requests will continue using
-// the old certificate, and only new requests will use the new one.
+// the old certificate, and only new requests will use the new one. The same
+// goes for the metrics endpoint.
 func (e *Endpoints) NetworkUpdateCert(cert *shared.CertInfo) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.cert = cert
-	listener, ok := e.listeners[network]
-	if !ok {
-		return
+	for _, kind := range []kind{network, metrics} {
+		listener, ok := e.listeners[kind]
+		if !ok || listener == nil {
+			continue
+		}
+		listener.(*networkListener).Config(cert)
 	}
-	listener.(*networkListener).Config(cert)
 }
 
 // Create a new net.Listener bound to the tcp socket of the network endpoint.
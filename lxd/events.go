@@ -11,11 +11,17 @@ import (
 	"github.com/gorilla/websocket"
 	log "github.com/lxc/lxd/shared/log15"
 	"github.com/pborman/uuid"
+	"gopkg.in/macaroon-bakery.v2/httpbakery"
 
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
 )
 
+// The types of events which can be subscribed to
+var eventTypes = []string{"logging", "operation", "lifecycle"}
+
 type eventsHandler struct {
 }
 
@@ -49,6 +55,7 @@ var eventListeners map[string]*eventListener = make(map[string]*eventListener)
 type eventListener struct {
 	connection   *websocket.Conn
 	messageTypes []string
+	container    string
 	active       chan bool
 	id           string
 	lock         sync.Mutex
@@ -70,19 +77,21 @@ func (r *eventsServe) String() string {
 func eventsSocket(r *http.Request, w http.ResponseWriter) error {
 	typeStr := r.FormValue("type")
 	if typeStr == "" {
-		typeStr = "logging,operation"
+		typeStr = strings.Join(eventTypes, ",")
 	}
 
 	c, err := shared.WebsocketUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return err
 	}
+	shared.WebsocketKeepAlive(c, true)
 
 	listener := eventListener{
 		active:       make(chan bool, 1),
 		connection:   c,
 		id:           uuid.NewRandom().String(),
 		messageTypes: strings.Split(typeStr, ","),
+		container:    r.FormValue("container"),
 	}
 
 	eventsLock.Lock()
@@ -91,12 +100,23 @@ func eventsSocket(r *http.Request, w http.ResponseWriter) error {
 
 	logger.Debugf("New event listener: %s", listener.id)
 
+	go eventListenerWatch(&listener)
+
 	<-listener.active
 
 	return nil
 }
 
 func eventsGet(d *Daemon, r *http.Request) Response {
+	typeStr := r.FormValue("type")
+	if typeStr != "" {
+		for _, entry := range strings.Split(typeStr, ",") {
+			if !shared.StringInSlice(entry, eventTypes) {
+				return BadRequest(fmt.Errorf("Unknown event type: %s", entry))
+			}
+		}
+	}
+
 	return &eventsServe{r}
 }
 
@@ -120,6 +140,10 @@ func eventSend(eventType string, eventMessage interface{}) error {
 			continue
 		}
 
+		if listener.container != "" && !eventMatchesContainer(eventType, eventMessage, listener.container) {
+			continue
+		}
+
 		go func(listener *eventListener, body []byte) {
 			// Check that the listener still exists
 			if listener == nil {
@@ -135,18 +159,9 @@ func eventSend(eventType string, eventMessage interface{}) error {
 				return
 			}
 
-			err = listener.connection.WriteMessage(websocket.TextMessage, body)
+			err := listener.connection.WriteMessage(websocket.TextMessage, body)
 			if err != nil {
-				// Remove the listener from the list
-				eventsLock.Lock()
-				delete(eventListeners, listener.id)
-				eventsLock.Unlock()
-
-				// Disconnect the listener
-				listener.connection.Close()
-				listener.active <- false
-				listener.done = true
-				logger.Debugf("Disconnected event listener: %s", listener.id)
+				eventListenerDisconnect(listener)
 			}
 		}(listener, body)
 	}
@@ -154,3 +169,133 @@ func eventSend(eventType string, eventMessage interface{}) error {
 
 	return nil
 }
+
+// Read from the connection of the listener until it fails, so that the pongs
+// of the client get processed and the listener gets disconnected as soon as
+// the client goes away, rather than at the next event.
+func eventListenerWatch(listener *eventListener) {
+	for {
+		_, _, err := listener.connection.NextReader()
+		if err != nil {
+			break
+		}
+	}
+
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+
+	if listener.done {
+		return
+	}
+
+	eventListenerDisconnect(listener)
+}
+
+// Remove the listener from the list and disconnect it. The lock of the listener
+// must be held.
+func eventListenerDisconnect(listener *eventListener) {
+	// Remove the listener from the list
+	eventsLock.Lock()
+	delete(eventListeners, listener.id)
+	eventsLock.Unlock()
+
+	// Disconnect the listener
+	listener.connection.Close()
+	listener.active <- false
+	listener.done = true
+	logger.Debugf("Disconnected event listener: %s", listener.id)
+}
+
+// eventSendLifecycle notifies the listeners that an action (like
+// "container-started") was performed on the object at the source URL, on
+// behalf of the requestor (nil for actions LXD initiated itself).
+func eventSendLifecycle(action string, source string, context map[string]interface{}, requestor *api.EventLifecycleRequestor) error {
+	return eventSend("lifecycle", api.EventLifecycle{
+		Action:    action,
+		Source:    source,
+		Context:   context,
+		Requestor: requestor,
+	})
+}
+
+// eventRequestor identifies the client behind the request, for inclusion in
+// lifecycle events.
+func eventRequestor(r *http.Request) *api.EventLifecycleRequestor {
+	// Requests forwarded by other cluster members come with their
+	// original requestor (see clusterForwardedRequest)
+	if r.Header.Get(clusterForwardedAddressHeader) != "" {
+		return &api.EventLifecycleRequestor{
+			Address:  r.Header.Get(clusterForwardedAddressHeader),
+			Protocol: r.Header.Get(clusterForwardedProtocolHeader),
+			Username: r.Header.Get(clusterForwardedUsernameHeader),
+		}
+	}
+
+	requestor := &api.EventLifecycleRequestor{Address: r.RemoteAddr}
+
+	if r.RemoteAddr == "@" {
+		requestor.Protocol = "unix"
+	} else if r.Header.Get(httpbakery.BakeryProtocolHeader) != "" {
+		requestor.Protocol = "macaroon"
+	} else if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		requestor.Protocol = "tls"
+		requestor.Username = shared.CertFingerprint(r.TLS.PeerCertificates[0])
+	}
+
+	return requestor
+}
+
+// eventContainerSource returns the API URL of the container or snapshot, for
+// use as the source of lifecycle events.
+func eventContainerSource(name string) string {
+	if shared.IsSnapshot(name) {
+		fields := strings.SplitN(name, shared.SnapshotDelimiter, 2)
+		return fmt.Sprintf("/%s/containers/%s/snapshots/%s", version.APIVersion, fields[0], fields[1])
+	}
+
+	return fmt.Sprintf("/%s/containers/%s", version.APIVersion, name)
+}
+
+// eventMatchesContainer returns whether the event relates to the given
+// container (or one of its snapshots).
+func eventMatchesContainer(eventType string, eventMessage interface{}, name string) bool {
+	source := eventContainerSource(name)
+	matchURL := func(url string) bool {
+		return url == source || strings.HasPrefix(url, source+"/")
+	}
+
+	switch eventType {
+	case "lifecycle":
+		event, ok := eventMessage.(api.EventLifecycle)
+		return ok && matchURL(event.Source)
+	case "operation":
+		op, ok := eventMessage.(*api.Operation)
+		if !ok {
+			return false
+		}
+
+		for _, url := range op.Resources["containers"] {
+			if matchURL(url) {
+				return true
+			}
+		}
+	case "logging":
+		event, ok := eventMessage.(shared.Jmap)
+		if !ok {
+			return false
+		}
+
+		ctx, ok := event["context"].(map[string]string)
+		if !ok {
+			return false
+		}
+
+		for _, key := range []string{"container", "name"} {
+			if ctx[key] == name || strings.HasPrefix(ctx[key], name+shared.SnapshotDelimiter) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestEventMatchesContainer(t *testing.T) {
+	cases := []struct {
+		eventType string
+		message   interface{}
+		match     bool
+	}{
+		{"lifecycle", api.EventLifecycle{Source: "/1.0/containers/c1"}, true},
+		{"lifecycle", api.EventLifecycle{Source: "/1.0/containers/c1/snapshots/snap0"}, true},
+		{"lifecycle", api.EventLifecycle{Source: "/1.0/containers/c10"}, false},
+		{"operation", &api.Operation{Resources: map[string][]string{"containers": {"/1.0/containers/c2", "/1.0/containers/c1"}}}, true},
+		{"operation", &api.Operation{Resources: map[string][]string{"images": {"/1.0/images/c1"}}}, false},
+		{"logging", shared.Jmap{"context": map[string]string{"container": "c1"}}, true},
+		{"logging", shared.Jmap{"context": map[string]string{"name": "c1/snap0"}}, true},
+		{"logging", shared.Jmap{"context": map[string]string{"name": "c10"}}, false},
+		{"logging", shared.Jmap{"message": "no context"}, false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.match, eventMatchesContainer(c.eventType, c.message, "c1"), "%s %v", c.eventType, c.message)
+	}
+}
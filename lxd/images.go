@@ -30,6 +30,8 @@ import (
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/cancel"
+	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/logging"
 	"github.com/lxc/lxd/shared/osarch"
@@ -87,7 +89,7 @@ func detectCompression(fname string) ([]string, string, error) {
 
 }
 
-func unpack(file string, path string, sType storageType, runningInUserns bool) error {
+func unpack(file string, path string, sType storageType, runningInUserns bool, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	extractArgs, extension, err := detectCompression(file)
 	if err != nil {
 		return err
@@ -95,6 +97,7 @@ func unpack(file string, path string, sType storageType, runningInUserns bool) e
 
 	command := ""
 	args := []string{}
+	var reader io.Reader
 	if strings.HasPrefix(extension, ".tar") {
 		command = "tar"
 		if runningInUserns {
@@ -106,7 +109,29 @@ func unpack(file string, path string, sType storageType, runningInUserns bool) e
 		}
 		args = append(args, "-C", path, "--numeric-owner")
 		args = append(args, extractArgs...)
-		args = append(args, file)
+
+		if tracker != nil {
+			// Feed the tarball through stdin so we can track its progress
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			fi, err := f.Stat()
+			if err != nil {
+				return err
+			}
+
+			tracker.Length = fi.Size()
+			reader = &ioprogress.ProgressReader{
+				ReadCloser: f,
+				Tracker:    tracker,
+			}
+			args = append(args, "-")
+		} else {
+			args = append(args, file)
+		}
 	} else if strings.HasPrefix(extension, ".squashfs") {
 		command = "unsquashfs"
 		args = append(args, "-f", "-d", path, "-n")
@@ -124,7 +149,22 @@ func unpack(file string, path string, sType storageType, runningInUserns bool) e
 		return fmt.Errorf("Unsupported image format: %s", extension)
 	}
 
-	output, err := shared.RunCommand(command, args...)
+	// Run the extraction so that it gets killed if the operation is
+	// cancelled
+	var buf bytes.Buffer
+	cmd := exec.Command(command, args...)
+	cmd.Stdin = reader
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+
+	err = cmd.Start()
+	if err == nil {
+		done := cancel.CancelableCommand(canceler, cmd)
+		err = cmd.Wait()
+		done()
+	}
+
+	output := buf.String()
 	if err != nil {
 		// Check if we ran out of space
 		fs := syscall.Statfs_t{}
@@ -156,20 +196,27 @@ func unpack(file string, path string, sType storageType, runningInUserns bool) e
 	return nil
 }
 
-func unpackImage(imagefname string, destpath string, sType storageType, runningInUserns bool) error {
-	err := unpack(imagefname, destpath, sType, runningInUserns)
+func unpackImage(imagefname string, destpath string, sType storageType, runningInUserns bool, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
+	// For split images, only track the (much larger) rootfs tarball
+	split := shared.PathExists(imagefname + ".rootfs")
+	metaTracker := tracker
+	if split {
+		metaTracker = nil
+	}
+
+	err := unpack(imagefname, destpath, sType, runningInUserns, metaTracker, canceler)
 	if err != nil {
 		return err
 	}
 
 	rootfsPath := fmt.Sprintf("%s/rootfs", destpath)
-	if shared.PathExists(imagefname + ".rootfs") {
+	if split {
 		err = os.MkdirAll(rootfsPath, 0755)
 		if err != nil {
 			return fmt.Errorf("Error creating rootfs directory")
 		}
 
-		err = unpack(imagefname+".rootfs", rootfsPath, sType, runningInUserns)
+		err = unpack(imagefname+".rootfs", rootfsPath, sType, runningInUserns, tracker, canceler)
 		if err != nil {
 			return err
 		}
@@ -633,7 +680,7 @@ func getImgPostInfo(d *Daemon, r *http.Request, builddir string, post *os.File)
 // the image. No entry in the images database will be created. This implies that
 // imageCreateinPool() should only be called when an image already exists in the
 // database and hence has already a storage volume in at least one storage pool.
-func imageCreateInPool(d *Daemon, info *api.Image, storagePool string) error {
+func imageCreateInPool(d *Daemon, info *api.Image, storagePool string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	if storagePool == "" {
 		return fmt.Errorf("No storage pool specified.")
 	}
@@ -646,7 +693,7 @@ func imageCreateInPool(d *Daemon, info *api.Image, storagePool string) error {
 
 	// Create the storage volume for the image on the requested storage
 	// pool.
-	err = s.ImageCreate(info.Fingerprint)
+	err = s.ImageCreate(info.Fingerprint, tracker, canceler)
 	if err != nil {
 		return err
 	}
@@ -705,6 +752,8 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 		return InternalError(fmt.Errorf("Invalid images JSON"))
 	}
 
+	requestor := eventRequestor(r)
+
 	// Begin background operation
 	run := func(op *operation) error {
 		var err error
@@ -757,6 +806,8 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 		metadata["fingerprint"] = info.Fingerprint
 		metadata["size"] = strconv.FormatInt(info.Size, 10)
 		op.UpdateMetadata(metadata)
+
+		eventSendLifecycle("image-created", fmt.Sprintf("/%s/images/%s", version.APIVersion, info.Fingerprint), nil, requestor)
 		return nil
 	}
 
@@ -1203,8 +1254,15 @@ func imageDelete(d *Daemon, r *http.Request) Response {
 		return d.db.ImageDelete(imgID)
 	}
 
+	requestor := eventRequestor(r)
 	rmimg := func(op *operation) error {
-		return deleteFromAllPools()
+		err := deleteFromAllPools()
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("image-deleted", fmt.Sprintf("/%s/images/%s", version.APIVersion, fingerprint), nil, requestor)
+		return nil
 	}
 
 	resources := map[string][]string{}
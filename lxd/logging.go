@@ -3,6 +3,8 @@ package main
 import (
 	"io/ioutil"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/lxc/lxd/lxd/db"
@@ -10,11 +12,105 @@ import (
 	"github.com/lxc/lxd/lxd/task"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/logging"
 	"golang.org/x/net/context"
 
 	log "github.com/lxc/lxd/shared/log15"
 )
 
+// The supported values of core.log_targets
+var loggingTargets = []string{"stderr", "file", "syslog", "journald"}
+
+// The logging settings from the command line, used when the server config
+// doesn't set any.
+var loggingDefaults struct {
+	handler log.Handler // Handler set up from the command line arguments
+	custom  log.Handler // Additional handler of the sub-command, if any
+	logfile string
+	syslog  string
+}
+
+// Handler set up from the core.log_* keys of the server config, if any
+var loggingLock sync.Mutex
+var loggingHandler *logging.Handler
+
+// loggingSetup sets the level, the targets (comma separated) and the format
+// of the daemon log, falling back to the command line settings for the empty
+// ones. If all of them are empty, the logging set up from the command line is
+// restored.
+func loggingSetup(level string, targets string, format string) error {
+	// Nothing to do if the logger wasn't set up by setupSubCommand
+	root, ok := logger.Log.(log.Logger)
+	if !ok || loggingDefaults.handler == nil {
+		return nil
+	}
+
+	var handler *logging.Handler
+	if level != "" || targets != "" || format != "" {
+		options := logging.Options{
+			Level:   level,
+			Format:  format,
+			Logfile: loggingDefaults.logfile,
+			Tag:     "lxd",
+		}
+
+		if options.Level == "" && debug {
+			options.Level = "debug"
+		}
+
+		if options.Logfile == "" {
+			options.Logfile = shared.LogPath("lxd.log")
+		}
+
+		for _, target := range strings.Split(targets, ",") {
+			target = strings.TrimSpace(target)
+			if target != "" {
+				options.Targets = append(options.Targets, target)
+			}
+		}
+
+		if len(options.Targets) == 0 {
+			if loggingDefaults.logfile != "" {
+				options.Targets = append(options.Targets, "file")
+			}
+
+			if loggingDefaults.syslog != "" {
+				options.Targets = append(options.Targets, "syslog")
+			}
+
+			if len(options.Targets) == 0 {
+				options.Targets = append(options.Targets, "stderr")
+			}
+		}
+
+		var err error
+		handler, err = logging.NewHandler(options)
+		if err != nil {
+			return err
+		}
+	}
+
+	loggingLock.Lock()
+	defer loggingLock.Unlock()
+
+	switch {
+	case handler == nil:
+		root.SetHandler(loggingDefaults.handler)
+	case loggingDefaults.custom != nil:
+		root.SetHandler(log.MultiHandler(handler, loggingDefaults.custom))
+	default:
+		root.SetHandler(handler)
+	}
+
+	oldHandler := loggingHandler
+	loggingHandler = handler
+	if oldHandler != nil {
+		return oldHandler.Close()
+	}
+
+	return nil
+}
+
 // This task function expires logs when executed. It's started by the Daemon
 // and will run once every 24h.
 func expireLogsTask(state *state.State) (task.Func, task.Schedule) {
@@ -79,6 +175,12 @@ func expireLogs(ctx context.Context, state *state.State) error {
 		default:
 		}
 
+		// Only the containers have directories, leave the daemon
+		// logs (like the audit log) alone
+		if !entry.IsDir() {
+			continue
+		}
+
 		// Check if the container still exists
 		if shared.StringInSlice(entry.Name(), containers) {
 			// Remove any log file which wasn't modified in the past 48 hours
@@ -51,8 +51,8 @@ Commands:
         Perform a clean shutdown of LXD and all running containers
     waitready [--timeout=15]
         Wait until LXD is ready to handle requests
-    import <container name> [--force]
-        Import a pre-existing container from storage
+    import [<container name>] [--force]
+        Import a pre-existing container from storage (all unknown containers if no name is given)
 
 
 Common options:
@@ -83,7 +83,8 @@ Daemon debug options:
 
 Init options:
     --auto
-        Automatic (non-interactive) mode
+        Automatic (non-interactive) mode, also connects the default
+        profile to the managed bridge, setting up lxdbr0 if there is none
     --preseed
         Pre-seed mode, expects YAML config from stdin
 
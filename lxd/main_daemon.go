@@ -5,11 +5,15 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	dbg "github.com/lxc/lxd/lxd/debug"
 	"github.com/lxc/lxd/lxd/sys"
 	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
 )
 
 func cmdDaemon(args *Args) error {
@@ -26,7 +30,7 @@ func cmdDaemon(args *Args) error {
 	)
 	defer stop()
 	if err != nil {
-		fmt.Printf("%v\n", err)
+		logger.Error("Failed to start debug activities", log.Ctx{"err": err})
 		return nil
 	}
 
@@ -54,19 +58,25 @@ func cmdDaemon(args *Args) error {
 	signal.Notify(ch, syscall.SIGTERM)
 
 	s := d.State()
+	shutdownContainers := true
 	select {
 	case sig := <-ch:
-
 		if sig == syscall.SIGPWR {
 			logger.Infof("Received '%s signal', shutting down containers.", sig)
-			containersShutdown(s)
-			networkShutdown(s)
 		} else {
 			logger.Infof("Received '%s signal', exiting.", sig)
+			shutdownContainers = false
 		}
 
 	case <-d.shutdownChan:
 		logger.Infof("Asked to shutdown by API, shutting down containers.")
+	}
+
+	// Refuse new changes and let the operations in flight complete
+	atomic.StoreInt32(&d.shuttingDown, 1)
+	operationsShutdown(30 * time.Second)
+
+	if shutdownContainers {
 		containersShutdown(s)
 		networkShutdown(s)
 	}
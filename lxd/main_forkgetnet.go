@@ -3,9 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net"
-	"strconv"
 	"strings"
 
 	"github.com/lxc/lxd/shared/api"
@@ -19,41 +17,7 @@ func cmdForkGetNet(args *Args) error {
 		return err
 	}
 
-	stats := map[string][]int64{}
-
-	content, err := ioutil.ReadFile("/proc/net/dev")
-	if err == nil {
-		for _, line := range strings.Split(string(content), "\n") {
-			fields := strings.Fields(line)
-
-			if len(fields) != 17 {
-				continue
-			}
-
-			rxBytes, err := strconv.ParseInt(fields[1], 10, 64)
-			if err != nil {
-				continue
-			}
-
-			rxPackets, err := strconv.ParseInt(fields[2], 10, 64)
-			if err != nil {
-				continue
-			}
-
-			txBytes, err := strconv.ParseInt(fields[9], 10, 64)
-			if err != nil {
-				continue
-			}
-
-			txPackets, err := strconv.ParseInt(fields[10], 10, 64)
-			if err != nil {
-				continue
-			}
-
-			intName := strings.TrimSuffix(fields[0], ":")
-			stats[intName] = []int64{rxBytes, rxPackets, txBytes, txPackets}
-		}
-	}
+	stats := networkGetDevStats()
 
 	for _, netIf := range interfaces {
 		netState := "down"
@@ -97,28 +61,11 @@ func cmdForkGetNet(args *Args) error {
 					family = "inet6"
 				}
 
-				scope := "global"
-				if strings.HasPrefix(fields[0], "127") {
-					scope = "local"
-				}
-
-				if fields[0] == "::1" {
-					scope = "local"
-				}
-
-				if strings.HasPrefix(fields[0], "169.254") {
-					scope = "link"
-				}
-
-				if strings.HasPrefix(fields[0], "fe80:") {
-					scope = "link"
-				}
-
 				address := api.ContainerStateNetworkAddress{}
 				address.Family = family
 				address.Address = fields[0]
 				address.Netmask = fields[1]
-				address.Scope = scope
+				address.Scope = networkGetAddressScope(fields[0])
 
 				network.Addresses = append(network.Addresses, address)
 			}
@@ -7,24 +7,58 @@ import (
 )
 
 func cmdImport(args *Args) error {
-	if len(args.Params) < 1 {
-		return fmt.Errorf("please specify a container to import")
+	c, err := lxd.ConnectLXDUnix("", nil)
+	if err != nil {
+		return err
 	}
-	name := args.Params[0]
-	req := map[string]interface{}{
-		"name":  name,
-		"force": args.Force,
+
+	names := args.Params
+	if len(names) < 1 {
+		// No container specified, recover all the containers
+		// found on disk that LXD doesn't know about.
+		names, err = importScanContainers(c)
+		if err != nil {
+			return err
+		}
+
+		if len(names) == 0 {
+			return fmt.Errorf("no containers to import found on disk")
+		}
 	}
 
-	c, err := lxd.ConnectLXDUnix("", nil)
+	for _, name := range names {
+		req := map[string]interface{}{
+			"name":  name,
+			"force": args.Force,
+		}
+
+		_, _, err = c.RawQuery("POST", "/internal/containers", req, "")
+		if err != nil {
+			return fmt.Errorf("failed to import container \"%s\": %s", name, err)
+		}
+
+		if len(args.Params) < 1 {
+			fmt.Printf("Imported container \"%s\"\n", name)
+		}
+	}
+
+	return nil
+}
+
+// importScanContainers returns the names of all the containers that have a
+// backup.yaml file on one of the storage pools but no database entry, as found
+// by the daemon, which mounts their volumes to look for it.
+func importScanContainers(c lxd.ContainerServer) ([]string, error) {
+	resp, _, err := c.RawQuery("GET", "/internal/containers", nil, "")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, _, err = c.RawQuery("POST", "/internal/containers", req, "")
+	names := []string{}
+	err = resp.MetadataAsStruct(&names)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return names, nil
 }
@@ -125,9 +125,96 @@ func (cmd *CmdInit) fillDataAuto(data *cmdInitData, client lxd.ContainerServer,
 			return err
 		}
 	}
+
+	// Give the default profile a bridged eth0 on a new lxdbr0 bridge, unless
+	// some networking is already set up.
+	bridge, err := cmd.autoBridge(client, data)
+	if err != nil {
+		return err
+	}
+
+	if bridge != nil {
+		return cmd.fillDataWithBridge(data, bridge)
+	}
+
+	// Otherwise connect it to the existing managed bridge, if any.
+	existingBridge, err := cmd.existingBridge(client, data)
+	if err != nil {
+		return err
+	}
+
+	if existingBridge != "" {
+		return cmd.fillDataWithNic(data, existingBridge)
+	}
+
 	return nil
 }
 
+// Return the parameters of the bridge to create in --auto mode, or nil if
+// the server already has a managed network or the default profile already
+// has an eth0 device.
+func (cmd *CmdInit) autoBridge(client lxd.ContainerServer, data *cmdInitData) (*cmdInitBridgeParams, error) {
+	if len(data.Profiles) == 0 {
+		return nil, nil
+	}
+
+	_, ok := data.Profiles[0].Devices["eth0"]
+	if ok {
+		return nil, nil
+	}
+
+	networks, err := client.GetNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, network := range networks {
+		if network.Managed {
+			return nil, nil
+		}
+	}
+
+	// Don't clash with an existing host interface
+	if shared.PathExists("/sys/class/net/lxdbr0") {
+		return nil, nil
+	}
+
+	return &cmdInitBridgeParams{
+		Name:    "lxdbr0",
+		IPv4:    "auto",
+		IPv4Nat: true,
+		IPv6:    "auto",
+		IPv6Nat: true,
+	}, nil
+}
+
+// Return the name of the managed bridge the default profile should get its
+// eth0 device from, or an empty string if the default profile already has an
+// eth0 device or the server has no managed bridge.
+func (cmd *CmdInit) existingBridge(client lxd.ContainerServer, data *cmdInitData) (string, error) {
+	if len(data.Profiles) == 0 {
+		return "", nil
+	}
+
+	_, ok := data.Profiles[0].Devices["eth0"]
+	if ok {
+		return "", nil
+	}
+
+	networks, err := client.GetNetworks()
+	if err != nil {
+		return "", err
+	}
+
+	for _, network := range networks {
+		if network.Managed && network.Type == "bridge" {
+			return network.Name, nil
+		}
+	}
+
+	return "", nil
+}
+
 // Fill the given configuration data with parameters collected with
 // interactive questions.
 func (cmd *CmdInit) fillDataInteractive(data *cmdInitData, client lxd.ContainerServer, backendsAvailable []string, existingPools []string) error {
@@ -164,6 +251,22 @@ func (cmd *CmdInit) fillDataInteractive(data *cmdInitData, client lxd.ContainerS
 		return err
 	}
 
+	// Without a new bridge, offer to connect the default profile to the
+	// existing managed one, if any.
+	if bridge == nil {
+		existingBridge, err := cmd.existingBridge(client, data)
+		if err != nil {
+			return err
+		}
+
+		if existingBridge != "" && cmd.askExistingBridge(existingBridge) {
+			err = cmd.fillDataWithNic(data, existingBridge)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -174,6 +277,72 @@ func (cmd *CmdInit) fillDataPreseed(data *cmdInitData, client lxd.ContainerServe
 		return fmt.Errorf("Invalid preseed YAML content")
 	}
 
+	return cmd.fillDataPreseedDefaultProfile(data, client)
+}
+
+// Give the current default profile a root disk on the first preseeded storage
+// pool and a bridged eth0 on the first preseeded bridge, if it doesn't have
+// such devices yet. Nothing is changed if the preseed configures the default
+// profile itself.
+func (cmd *CmdInit) fillDataPreseedDefaultProfile(data *cmdInitData, client lxd.ContainerServer) error {
+	for _, profile := range data.Profiles {
+		if profile.Name == "default" {
+			return nil
+		}
+	}
+
+	if len(data.Pools) == 0 && len(data.Networks) == 0 {
+		return nil
+	}
+
+	// Without a default profile, there's nothing to fill
+	currentProfile, _, err := client.GetProfile("default")
+	if err != nil {
+		if err.Error() == "not found" {
+			return nil
+		}
+
+		return err
+	}
+
+	defaultProfile := api.ProfilesPost{Name: "default", ProfilePut: currentProfile.Writable()}
+	if defaultProfile.Devices == nil {
+		defaultProfile.Devices = map[string]map[string]string{}
+	}
+
+	changed := false
+	if len(data.Pools) > 0 {
+		rootDiskDeviceKey, _, _ := containerGetRootDiskDevice(defaultProfile.Devices)
+		if rootDiskDeviceKey == "" && defaultProfile.Devices["root"] == nil {
+			defaultProfile.Devices["root"] = map[string]string{
+				"type": "disk",
+				"path": "/",
+				"pool": data.Pools[0].Name,
+			}
+			changed = true
+		}
+	}
+
+	for _, network := range data.Networks {
+		if network.Type != "" && network.Type != "bridge" {
+			continue
+		}
+
+		if defaultProfile.Devices["eth0"] == nil {
+			defaultProfile.Devices["eth0"] = map[string]string{
+				"type":    "nic",
+				"nictype": "bridged",
+				"parent":  network.Name,
+			}
+			changed = true
+		}
+		break
+	}
+
+	if changed {
+		data.Profiles = append(data.Profiles, defaultProfile)
+	}
+
 	return nil
 }
 
@@ -346,12 +515,16 @@ func (cmd *CmdInit) fillDataWithBridge(data *cmdInitData, bridge *cmdInitBridgeP
 	network.Config = bridgeConfig
 	data.Networks = []api.NetworksPost{network}
 
+	return cmd.fillDataWithNic(data, bridge.Name)
+}
+
+// Attach the given bridge as eth0 device of the default profile, if such
+// device doesn't exists yet.
+func (cmd *CmdInit) fillDataWithNic(data *cmdInitData, bridge string) error {
 	if len(data.Profiles) == 0 {
 		return fmt.Errorf("error: profile 'default' profile not found")
 	}
 
-	// Attach the bridge as eth0 device of the default profile, if such
-	// device doesn't exists yet.
 	defaultProfile := data.Profiles[0]
 	err := cmd.profileDeviceAlreadyExists(&defaultProfile, "eth0")
 	if err != nil {
@@ -360,11 +533,10 @@ func (cmd *CmdInit) fillDataWithBridge(data *cmdInitData, bridge *cmdInitBridgeP
 	defaultProfile.Devices["eth0"] = map[string]string{
 		"type":    "nic",
 		"nictype": "bridged",
-		"parent":  bridge.Name,
+		"parent":  bridge,
 	}
 
 	return nil
-
 }
 
 // Apply the configuration specified in the given init data.
@@ -931,6 +1103,11 @@ func (cmd *CmdInit) askBridge(client lxd.ContainerServer) *cmdInitBridgeParams {
 	return bridge
 }
 
+// Ask if the user wants the default profile to use the given existing bridge.
+func (cmd *CmdInit) askExistingBridge(bridge string) bool {
+	return cmd.Context.AskBool(fmt.Sprintf("Would you like the default profile to use the existing \"%s\" bridge (yes/no) [default=yes]? ", bridge), "yes")
+}
+
 // Defines the schema for all possible configuration knobs supported by the
 // lxd init command, either directly fed via --preseed or populated by
 // the auto/interactive modes.
@@ -133,6 +133,25 @@ func (suite *cmdInitTestSuite) TestCmdInit_AutoHTTPSAddressAndTrustPassword() {
 	suite.Req.Nil(util.PasswordCheck(secret, "sekret"))
 }
 
+// In --auto mode no bridge is created if the default profile already has an
+// eth0 device.
+func (suite *cmdInitTestSuite) TestCmdInit_AutoBridgeExistingNic() {
+	profile, _, err := suite.client.GetProfile("default")
+	suite.Req.Nil(err)
+	profileData := profile.Writable()
+	profileData.Devices["eth0"] = map[string]string{"type": "nic", "nictype": "p2p"}
+	err = suite.client.UpdateProfile("default", profileData, "")
+	suite.Req.Nil(err)
+
+	data := &cmdInitData{}
+	cmd := suite.command
+	cmd.fillDataWithCurrentDefaultProfile(data, suite.client)
+
+	bridge, err := cmd.autoBridge(suite.client, data)
+	suite.Req.Nil(err)
+	suite.Req.Nil(bridge)
+}
+
 // The images auto-update interval can be interactively set by simply accepting
 // the answer "yes" to the relevant question.
 func (suite *cmdInitTestSuite) TestCmdInit_ImagesAutoUpdateAnswerYes() {
@@ -383,6 +402,53 @@ func (suite *cmdInitTestSuite) TestCmdInit_NetworkPreseed() {
 	suite.Req.Equal("none", network.Config["ipv6.address"])
 }
 
+// Without a new bridge, the default profile can interactively be connected to
+// an existing managed one.
+func (suite *cmdInitTestSuite) TestCmdInit_NetworkInteractiveExistingBridge() {
+	post := api.NetworksPost{
+		Name: "egg",
+	}
+	post.Config = map[string]string{
+		"ipv4.address": "none",
+		"ipv6.address": "none",
+	}
+	err := suite.client.CreateNetwork(post)
+	suite.Req.Nil(err)
+
+	answers := &cmdInitAnswers{
+		ExistingBridge:     true,
+		WantExistingBridge: true,
+	}
+	answers.Render(suite.streams)
+
+	suite.Req.Nil(suite.command.Run())
+
+	profile, _, err := suite.client.GetProfile("default")
+	suite.Req.Nil(err)
+	suite.Req.Equal("bridged", profile.Devices["eth0"]["nictype"])
+	suite.Req.Equal("egg", profile.Devices["eth0"]["parent"])
+}
+
+// A preseeded bridge gets attached to the default profile, if the preseed
+// doesn't configure it.
+func (suite *cmdInitTestSuite) TestCmdInit_NetworkPreseedDefaultProfile() {
+	suite.args.Preseed = true
+	suite.streams.InputAppend(`networks:
+- name: bar
+  type: bridge
+  config:
+    ipv4.address: none
+    ipv6.address: none
+`)
+
+	suite.Req.Nil(suite.command.Run())
+
+	profile, _, err := suite.client.GetProfile("default")
+	suite.Req.Nil(err)
+	suite.Req.Equal("bridged", profile.Devices["eth0"]["nictype"])
+	suite.Req.Equal("bar", profile.Devices["eth0"]["parent"])
+}
+
 // Update a network via preseed.
 func (suite *cmdInitTestSuite) TestCmdInit_NetworkPreseedUpdate() {
 	post := api.NetworksPost{
@@ -640,6 +706,8 @@ type cmdInitAnswers struct {
 	BridgeName               string
 	BridgeIPv4               string
 	BridgeIPv6               string
+	ExistingBridge           bool
+	WantExistingBridge       bool
 }
 
 // Render the input text the user would type for the desired answers, populating
@@ -657,6 +725,8 @@ func (answers *cmdInitAnswers) Render(streams *cmd.MemoryStreams) {
 		streams.InputAppendLine(answers.BridgeName)
 		streams.InputAppendLine(answers.BridgeIPv4)
 		streams.InputAppendLine(answers.BridgeIPv6)
+	} else if answers.ExistingBridge {
+		streams.InputAppendBoolAnswer(answers.WantExistingBridge)
 	}
 }
 
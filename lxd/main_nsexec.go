@@ -38,7 +38,9 @@ package main
 #include <libgen.h>
 #include <ifaddrs.h>
 #include <dirent.h>
+#include <ftw.h>
 #include <grp.h>
+#include <sys/xattr.h>
 
 // This expects:
 //  ./lxd forkputfile /source/path <pid> /target/path
@@ -180,7 +182,136 @@ void attach_userns(int pid) {
 	}
 }
 
-int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is_put, char *type, uid_t uid, gid_t gid, mode_t mode, uid_t defaultUid, gid_t defaultGid, mode_t defaultMode, bool append) {
+// The type of a file, as reported by the files API
+const char *file_type(mode_t mode) {
+	switch (mode & S_IFMT) {
+	case S_IFREG:
+		return "file";
+	case S_IFDIR:
+		return "directory";
+	case S_IFLNK:
+		return "symlink";
+	case S_IFBLK:
+		return "block";
+	case S_IFCHR:
+		return "char";
+	case S_IFIFO:
+		return "fifo";
+	case S_IFSOCK:
+		return "socket";
+	}
+
+	return "unknown";
+}
+
+void print_hex(const char *buf, size_t len) {
+	size_t i;
+
+	for (i = 0; i < len; i++)
+		fprintf(stderr, "%02x", (unsigned char)buf[i]);
+}
+
+// Decode the hex string in place, returning the length of the result
+ssize_t hex_decode(char *hex) {
+	size_t i, len = strlen(hex) / 2;
+	unsigned int byte;
+
+	for (i = 0; i < len; i++) {
+		if (sscanf(hex + 2 * i, "%2x", &byte) != 1)
+			return -1;
+
+		hex[i] = byte;
+	}
+
+	return len;
+}
+
+// Report the extended attributes (including the POSIX ACLs) of the file,
+// hex encoded. Those we can't read are skipped.
+void print_xattrs(int fd) {
+	ssize_t list_len, value_len;
+	char *list, *name, *value;
+
+	list_len = flistxattr(fd, NULL, 0);
+	if (list_len <= 0)
+		return;
+
+	list = malloc(list_len);
+	if (!list)
+		return;
+
+	list_len = flistxattr(fd, list, list_len);
+	for (name = list; list_len > 0 && name < list + list_len; name += strlen(name) + 1) {
+		value_len = fgetxattr(fd, name, NULL, 0);
+		if (value_len < 0)
+			continue;
+
+		value = malloc(value_len + 1);
+		if (!value)
+			continue;
+
+		value_len = fgetxattr(fd, name, value, value_len);
+		if (value_len >= 0) {
+			fprintf(stderr, "xattr: ");
+			print_hex(name, strlen(name));
+			fprintf(stderr, " ");
+			print_hex(value, value_len);
+			fprintf(stderr, "\n");
+		}
+
+		free(value);
+	}
+
+	free(list);
+}
+
+// Set the extended attributes listed in the file, one per line as a hex
+// encoded name and value separated by a space, on the file descriptor or,
+// without one, on the path (not following symlinks).
+int apply_xattrs(FILE *xattrs, int fd, char *path) {
+	char *line = NULL, *value;
+	size_t line_size = 0;
+	ssize_t len, value_len;
+	int ret = 0;
+
+	while ((len = getline(&line, &line_size, xattrs)) > 0) {
+		if (line[len - 1] == '\n')
+			line[len - 1] = 0;
+
+		value = strchr(line, ' ');
+		if (!value) {
+			errno = EINVAL;
+			error("error: bad xattr");
+			ret = -1;
+			break;
+		}
+		*value++ = 0;
+
+		len = hex_decode(line);
+		value_len = hex_decode(value);
+		if (len < 0 || value_len < 0) {
+			errno = EINVAL;
+			error("error: bad xattr");
+			ret = -1;
+			break;
+		}
+		line[len] = 0;
+
+		if (fd >= 0)
+			ret = fsetxattr(fd, line, value, value_len, 0);
+		else
+			ret = lsetxattr(path, line, value, value_len, 0);
+		if (ret < 0) {
+			error("error: setxattr");
+			break;
+		}
+	}
+
+	free(line);
+	return ret;
+}
+
+int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is_put, char *type, uid_t uid, gid_t gid, mode_t mode, uid_t defaultUid, gid_t defaultGid, mode_t defaultMode, bool append, char *xattrs_path) {
 	int host_fd = -1, container_fd = -1;
 	int ret = -1;
 	int container_open_flags;
@@ -188,6 +319,7 @@ int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is
 	int exists = 1;
 	bool is_dir_manip = type != NULL && !strcmp(type, "directory");
 	bool is_symlink_manip = type != NULL && !strcmp(type, "symlink");
+	FILE *xattrs = NULL;
 
 	if (!is_dir_manip && !is_symlink_manip) {
 		host_fd = open(host, O_RDWR);
@@ -197,6 +329,15 @@ int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is
 		}
 	}
 
+	// The extended attributes to set come from a file on the host
+	if (is_put && xattrs_path && *xattrs_path) {
+		xattrs = fopen(xattrs_path, "r");
+		if (!xattrs) {
+			error("error: open xattrs");
+			goto close_host;
+		}
+	}
+
 	if (pid > 0) {
 		attach_userns(pid);
 
@@ -217,6 +358,8 @@ int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is
 	}
 
 	if (is_put && is_dir_manip) {
+		bool set_mode = mode != -1;
+
 		if (mode == -1) {
 			mode = defaultMode;
 		}
@@ -239,6 +382,17 @@ int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is
 			return -1;
 		}
 
+		// mkdir ignores the setgid bit and changing the ownership
+		// clears the setuid and setgid ones
+		if (set_mode && chmod(container, mode) < 0) {
+			error("error: chmod");
+			return -1;
+		}
+
+		// Set after the ownership, which drops file capabilities
+		if (xattrs && apply_xattrs(xattrs, -1, container) < 0)
+			return -1;
+
 		return 0;
 	}
 
@@ -265,12 +419,43 @@ int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is
 			return -1;
 		}
 
+		if (xattrs && apply_xattrs(xattrs, -1, container) < 0)
+			return -1;
+
 		return 0;
 	}
 
+	// Report the target of symlinks, which get followed
+	if (!is_put && lstat(container, &st) == 0 && S_ISLNK(st.st_mode)) {
+		char target[PATH_MAX + 1];
+		ssize_t target_len, i;
+
+		target_len = readlink(container, target, PATH_MAX);
+		if (target_len >= 0) {
+			fprintf(stderr, "target: ");
+			for (i = 0; i < target_len; i++) {
+				if (target[i] == '\n')
+					putc(0, stderr);
+				else
+					putc(target[i], stderr);
+			}
+			fprintf(stderr, "\n");
+		}
+	}
+
 	if (stat(container, &st) < 0)
 		exists = 0;
 
+	// Only report the details of special files, without reading them
+	if (!is_put && exists && !S_ISDIR(st.st_mode) && !S_ISREG(st.st_mode)) {
+		fprintf(stderr, "uid: %ld\n", (long)st.st_uid);
+		fprintf(stderr, "gid: %ld\n", (long)st.st_gid);
+		fprintf(stderr, "mode: %ld\n", (unsigned long)st.st_mode & 07777);
+		fprintf(stderr, "type: %s\n", file_type(st.st_mode));
+		ret = 0;
+		goto close_host;
+	}
+
 	container_open_flags = O_RDWR;
 	if (is_put)
 		container_open_flags |= O_CREAT;
@@ -310,15 +495,22 @@ int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is
 			goto close_container;
 		}
 
+		if (fchown(container_fd, uid, gid) < 0) {
+			error("error: chown");
+			goto close_container;
+		}
+
+		// Set after the ownership, which clears the setuid and setgid
+		// bits
 		if (mode != -1 && fchmod(container_fd, mode) < 0) {
 			error("error: chmod");
 			goto close_container;
 		}
 
-		if (fchown(container_fd, uid, gid) < 0) {
-			error("error: chown");
+		// Set after the ownership, which drops file capabilities
+		if (xattrs && apply_xattrs(xattrs, container_fd, NULL) < 0)
 			goto close_container;
-		}
+
 		ret = 0;
 	} else {
 
@@ -329,7 +521,8 @@ int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is
 
 		fprintf(stderr, "uid: %ld\n", (long)st.st_uid);
 		fprintf(stderr, "gid: %ld\n", (long)st.st_gid);
-		fprintf(stderr, "mode: %ld\n", (unsigned long)st.st_mode & (S_IRWXU | S_IRWXG | S_IRWXO));
+		fprintf(stderr, "mode: %ld\n", (unsigned long)st.st_mode & 07777);
+		print_xattrs(container_fd);
 		if (S_ISDIR(st.st_mode)) {
 			DIR *fdir;
 			struct dirent *de;
@@ -344,10 +537,39 @@ int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is
 
 			while((de = readdir(fdir))) {
 				int len, i;
+				struct stat est;
 
 				if (!strcmp(de->d_name, ".") || !strcmp(de->d_name, ".."))
 					continue;
 
+				// Details of the entry, for the line which follows
+				if (fstatat(dirfd(fdir), de->d_name, &est, AT_SYMLINK_NOFOLLOW) == 0) {
+					fprintf(stderr, "entry_stat: %s %lld %ld %ld %lu %lld\n",
+						file_type(est.st_mode),
+						(long long)est.st_size,
+						(long)est.st_uid,
+						(long)est.st_gid,
+						(unsigned long)est.st_mode & 07777,
+						(long long)est.st_mtime);
+
+					if (S_ISLNK(est.st_mode)) {
+						char target[PATH_MAX + 1];
+						ssize_t target_len;
+
+						target_len = readlinkat(dirfd(fdir), de->d_name, target, PATH_MAX);
+						if (target_len >= 0) {
+							fprintf(stderr, "entry_target: ");
+							for (i = 0; i < target_len; i++) {
+								if (target[i] == '\n')
+									putc(0, stderr);
+								else
+									putc(target[i], stderr);
+							}
+							fprintf(stderr, "\n");
+						}
+					}
+				}
+
 				fprintf(stderr, "entry: ");
 
 				// swap \n to \0 since we split this output by line
@@ -374,6 +596,8 @@ close_container:
 	close(container_fd);
 close_host:
 	close(host_fd);
+	if (xattrs)
+		fclose(xattrs);
 	return ret;
 }
 
@@ -532,6 +756,7 @@ void forkdofile(char *buf, char *cur, bool is_put, ssize_t size) {
 	char *command = cur, *rootfs = NULL, *source = NULL, *target = NULL, *writeMode = NULL, *type = NULL;
 	pid_t pid;
 	bool append = false;
+	char *xattrs = NULL;
 
 	ADVANCE_ARG_REQUIRED();
 	rootfs = cur;
@@ -571,9 +796,12 @@ void forkdofile(char *buf, char *cur, bool is_put, ssize_t size) {
 		if (strcmp(cur, "append") == 0) {
 			append = true;
 		}
+
+		ADVANCE_ARG_REQUIRED();
+		xattrs = cur;
 	}
 
-	_exit(manip_file_in_ns(rootfs, pid, source, target, is_put, type, uid, gid, mode, defaultUid, defaultGid, defaultMode, append));
+	_exit(manip_file_in_ns(rootfs, pid, source, target, is_put, type, uid, gid, mode, defaultUid, defaultGid, defaultMode, append, xattrs));
 }
 
 void forkcheckfile(char *buf, char *cur, bool is_put, ssize_t size) {
@@ -616,10 +844,31 @@ void forkcheckfile(char *buf, char *cur, bool is_put, ssize_t size) {
 	_exit(0);
 }
 
+dev_t remove_dev;
+
+int check_remove_entry(const char *path, const struct stat *sb, int typeflag, struct FTW *ftwbuf) {
+	if (sb->st_dev != remove_dev) {
+		fprintf(stderr, "Refusing to remove %s: it's a mount point\n", path);
+		return -1;
+	}
+
+	return 0;
+}
+
+int remove_entry(const char *path, const struct stat *sb, int typeflag, struct FTW *ftwbuf) {
+	if (remove(path) < 0) {
+		fprintf(stderr, "Failed to remove %s: %s\n", path, strerror(errno));
+		return -1;
+	}
+
+	return 0;
+}
+
 void forkremovefile(char *buf, char *cur, bool is_put, ssize_t size) {
 	char *command = cur, *rootfs = NULL, *path = NULL;
 	pid_t pid;
 	struct stat sb;
+	bool recursive = false;
 
 	ADVANCE_ARG_REQUIRED();
 	rootfs = cur;
@@ -630,6 +879,11 @@ void forkremovefile(char *buf, char *cur, bool is_put, ssize_t size) {
 	ADVANCE_ARG_REQUIRED();
 	path = cur;
 
+	ADVANCE_ARG_REQUIRED();
+	if (strcmp(cur, "recursive") == 0) {
+		recursive = true;
+	}
+
 	if (pid > 0) {
 		attach_userns(pid);
 
@@ -649,12 +903,24 @@ void forkremovefile(char *buf, char *cur, bool is_put, ssize_t size) {
 		}
 	}
 
-	if (stat(path, &sb) < 0) {
+	if (lstat(path, &sb) < 0) {
 		error("error: stat");
 		_exit(1);
 	}
 
-	if ((sb.st_mode & S_IFMT) == S_IFDIR) {
+	if ((sb.st_mode & S_IFMT) == S_IFDIR && recursive) {
+		// Refuse to remove anything if the directory contains mount
+		// points, then remove its content first, without following
+		// symlinks or crossing mount points
+		remove_dev = sb.st_dev;
+		if (nftw(path, check_remove_entry, 64, FTW_PHYS) != 0) {
+			_exit(1);
+		}
+
+		if (nftw(path, remove_entry, 64, FTW_DEPTH | FTW_PHYS | FTW_MOUNT) != 0) {
+			_exit(1);
+		}
+	} else if ((sb.st_mode & S_IFMT) == S_IFDIR) {
 		if (rmdir(path) < 0) {
 			fprintf(stderr, "Failed to remove %s: %s\n", path, strerror(errno));
 			_exit(1);
@@ -92,12 +92,22 @@ func setupSubCommand(context *cmd.Context, args *Args, handler log.Handler) erro
 		syslog = "lxd"
 	}
 
-	var err error
-	logger.Log, err = logging.GetLogger(syslog, args.Logfile, args.Verbose, args.Debug, handler)
+	defaultHandler, err := logging.GetHandler(syslog, args.Logfile, args.Verbose, args.Debug, handler)
 	if err != nil {
 		context.Output("%v\n", err)
 		return err
 	}
 
+	root := log.New()
+	root.SetHandler(defaultHandler)
+	logger.Log = root
+
+	// Remember the command line settings, the server config may override
+	// them later on
+	loggingDefaults.handler = defaultHandler
+	loggingDefaults.custom = handler
+	loggingDefaults.logfile = args.Logfile
+	loggingDefaults.syslog = syslog
+
 	return nil
 }
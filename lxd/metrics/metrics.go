@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of a metric.
+type Type string
+
+// The supported metric types.
+const (
+	Counter Type = "counter"
+	Gauge   Type = "gauge"
+)
+
+// Labels identify a sample among the ones of the same metric.
+type Labels map[string]string
+
+// Set is a set of metrics, rendered in the OpenMetrics text format.
+type Set struct {
+	metrics []*metric
+	index   map[string]*metric
+}
+
+type metric struct {
+	name    string
+	kind    Type
+	help    string
+	samples []sample
+}
+
+type sample struct {
+	labels Labels
+	value  float64
+}
+
+// NewSet returns an empty set of metrics.
+func NewSet() *Set {
+	return &Set{index: map[string]*metric{}}
+}
+
+// Add adds a sample of the metric with the given name, type and help text.
+// The samples of the same metric are rendered together, in the order they were
+// added. The names of counters must not include the "_total" suffix, it gets
+// added when rendering them.
+func (s *Set) Add(name string, kind Type, help string, value float64, labels Labels) {
+	m, ok := s.index[name]
+	if !ok {
+		m = &metric{name: name, kind: kind, help: help}
+		s.index[name] = m
+		s.metrics = append(s.metrics, m)
+	}
+
+	m.samples = append(m.samples, sample{labels: labels, value: value})
+}
+
+// String renders the metrics in the OpenMetrics text format.
+func (s *Set) String() string {
+	buffer := &bytes.Buffer{}
+
+	for _, m := range s.metrics {
+		fmt.Fprintf(buffer, "# HELP %s %s\n", m.name, escape(m.help, false))
+		fmt.Fprintf(buffer, "# TYPE %s %s\n", m.name, m.kind)
+
+		name := m.name
+		if m.kind == Counter {
+			name += "_total"
+		}
+
+		for _, sample := range m.samples {
+			buffer.WriteString(name)
+			buffer.WriteString(renderLabels(sample.labels))
+			buffer.WriteString(" ")
+			buffer.WriteString(strconv.FormatFloat(sample.value, 'g', -1, 64))
+			buffer.WriteString("\n")
+		}
+	}
+
+	buffer.WriteString("# EOF\n")
+
+	return buffer.String()
+}
+
+// Render the given labels sorted by name, or an empty string if there's none.
+func renderLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := []string{}
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := []string{}
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escape(labels[name], true)))
+	}
+
+	return fmt.Sprintf("{%s}", strings.Join(pairs, ","))
+}
+
+// Escape the backslashes and line feeds, as well as the double quotes of label
+// values.
+func escape(value string, quotes bool) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, "\n", `\n`, -1)
+	if quotes {
+		value = strings.Replace(value, `"`, `\"`, -1)
+	}
+
+	return value
+}
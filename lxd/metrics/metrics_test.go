@@ -0,0 +1,34 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet_String(t *testing.T) {
+	set := metrics.NewSet()
+	set.Add("lxd_container_processes", metrics.Gauge, "Number of processes", 12, metrics.Labels{"name": "c1"})
+	set.Add("lxd_api_requests", metrics.Counter, "Number of API requests", 3, metrics.Labels{"method": "GET", "code": "200"})
+	set.Add("lxd_container_processes", metrics.Gauge, "Number of processes", 1.5, metrics.Labels{"name": "c\"2\"\n"})
+	set.Add("lxd_goroutines", metrics.Gauge, "Number of goroutines\n", 42, nil)
+
+	expected := `# HELP lxd_container_processes Number of processes
+# TYPE lxd_container_processes gauge
+lxd_container_processes{name="c1"} 12
+lxd_container_processes{name="c\"2\"\n"} 1.5
+# HELP lxd_api_requests Number of API requests
+# TYPE lxd_api_requests counter
+lxd_api_requests_total{code="200",method="GET"} 3
+# HELP lxd_goroutines Number of goroutines\n
+# TYPE lxd_goroutines gauge
+lxd_goroutines 42
+# EOF
+`
+	assert.Equal(t, expected, set.String())
+}
+
+func TestSet_StringEmpty(t *testing.T) {
+	assert.Equal(t, "# EOF\n", metrics.NewSet().String())
+}
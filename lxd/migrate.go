@@ -103,6 +103,8 @@ func (c *migrationFields) disconnect() {
 		c.controlConn.WriteMessage(websocket.CloseMessage, closeMsg)
 		c.controlConn = nil /* don't close twice */
 	}
+	fsConn := c.fsConn
+	criuConn := c.criuConn
 	c.controlLock.Unlock()
 
 	/* Below we just Close(), which doesn't actually write to the
@@ -113,12 +115,35 @@ func (c *migrationFields) disconnect() {
 	 * connection, since we report the error over the control channel
 	 * anyway.
 	 */
-	if c.fsConn != nil {
-		c.fsConn.Close()
+	if fsConn != nil {
+		fsConn.Close()
 	}
 
-	if c.criuConn != nil {
-		c.criuConn.Close()
+	if criuConn != nil {
+		criuConn.Close()
+	}
+}
+
+// setConn sets one of the websockets, under the lock so that it can be
+// aborted from another goroutine.
+func (c *migrationFields) setConn(conn **websocket.Conn, value *websocket.Conn) {
+	c.controlLock.Lock()
+	*conn = value
+	c.controlLock.Unlock()
+}
+
+// abort force closes all the websockets without going through the close
+// handshake, making any pending or future read or write on them fail. This
+// is used to stop ongoing transfers from another goroutine.
+func (c *migrationFields) abort() {
+	c.controlLock.Lock()
+	conns := []*websocket.Conn{c.controlConn, c.fsConn, c.criuConn}
+	c.controlLock.Unlock()
+
+	for _, conn := range conns {
+		if conn != nil {
+			conn.Close()
+		}
 	}
 }
 
@@ -231,6 +256,8 @@ func (s *migrationSourceWs) Connect(op *operation, r *http.Request, w http.Respo
 	if err != nil {
 		return err
 	}
+	// The control connection is only read at some steps of the migration
+	shared.WebsocketKeepAlive(c, false)
 
 	*conn = c
 
@@ -241,7 +268,9 @@ func (s *migrationSourceWs) Connect(op *operation, r *http.Request, w http.Respo
 	return nil
 }
 
-func (s *migrationSourceWs) ConnectTarget(target api.ContainerPostTarget) error {
+// ConnectTarget connects the source to the migration websockets of the target
+// (push mode), going through the given HTTP proxy function if needed.
+func (s *migrationSourceWs) ConnectTarget(target api.ContainerPostTarget, proxy func(*http.Request) (*url.URL, error)) error {
 	var err error
 	var cert *x509.Certificate
 
@@ -265,6 +294,7 @@ func (s *migrationSourceWs) ConnectTarget(target api.ContainerPostTarget) error
 	dialer := websocket.Dialer{
 		TLSClientConfig: config,
 		NetDial:         shared.RFC3493Dialer,
+		Proxy:           proxy,
 	}
 
 	for name, secret := range target.Websockets {
@@ -290,6 +320,7 @@ func (s *migrationSourceWs) ConnectTarget(target api.ContainerPostTarget) error
 		if err != nil {
 			return err
 		}
+		shared.WebsocketKeepAlive(wsConn, false)
 
 		*conn = wsConn
 	}
@@ -904,6 +935,10 @@ type migrationSink struct {
 	dialer       websocket.Dialer
 	allConnected chan bool
 	push         bool
+
+	// Closed when the migration operation gets cancelled
+	cancelled  chan bool
+	cancelOnce sync.Once
 }
 
 type MigrationSinkArgs struct {
@@ -918,11 +953,12 @@ type MigrationSinkArgs struct {
 
 func NewMigrationSink(args *MigrationSinkArgs) (*migrationSink, error) {
 	sink := migrationSink{
-		src:    migrationFields{container: args.Container, containerOnly: args.ContainerOnly},
-		dest:   migrationFields{containerOnly: args.ContainerOnly},
-		url:    args.Url,
-		dialer: args.Dialer,
-		push:   args.Push,
+		src:       migrationFields{container: args.Container, containerOnly: args.ContainerOnly},
+		dest:      migrationFields{containerOnly: args.ContainerOnly},
+		url:       args.Url,
+		dialer:    args.Dialer,
+		push:      args.Push,
+		cancelled: make(chan bool),
 	}
 
 	if sink.push {
@@ -984,6 +1020,7 @@ func (c *migrationSink) connectWithSecret(secret string) (*websocket.Conn, error
 	if err != nil {
 		return nil, err
 	}
+	shared.WebsocketKeepAlive(conn, false)
 
 	return conn, err
 }
@@ -1026,21 +1063,45 @@ func (s *migrationSink) Connect(op *operation, r *http.Request, w http.ResponseW
 	if err != nil {
 		return err
 	}
+	// The control connection is only read at some steps of the migration
+	shared.WebsocketKeepAlive(c, false)
 
+	s.dest.controlLock.Lock()
 	*conn = c
+	connected := s.dest.controlConn != nil && (!s.dest.live || s.dest.criuConn != nil) && s.dest.fsConn != nil
+	s.dest.controlLock.Unlock()
 
-	if s.dest.controlConn != nil && (!s.dest.live || s.dest.criuConn != nil) && s.dest.fsConn != nil {
+	if connected {
 		s.allConnected <- true
 	}
 
 	return nil
 }
 
+// Cancel aborts the migration. Closing the websockets stops the rsync and
+// CRIU transfers, which in turn makes Do() fail and the caller clean up the
+// partially received container.
+func (c *migrationSink) Cancel(op *operation) error {
+	c.cancelOnce.Do(func() { close(c.cancelled) })
+
+	if c.push {
+		c.dest.abort()
+	} else {
+		c.src.abort()
+	}
+
+	return nil
+}
+
 func (c *migrationSink) Do(migrateOp *operation) error {
 	var err error
 
 	if c.push {
-		<-c.allConnected
+		select {
+		case <-c.allConnected:
+		case <-c.cancelled:
+			return fmt.Errorf("Migration cancelled")
+		}
 	}
 
 	disconnector := c.src.disconnect
@@ -1051,27 +1112,38 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 	if c.push {
 		defer disconnector()
 	} else {
-		c.src.controlConn, err = c.connectWithSecret(c.src.controlSecret)
+		var conn *websocket.Conn
+		conn, err = c.connectWithSecret(c.src.controlSecret)
 		if err != nil {
 			return err
 		}
+		c.src.setConn(&c.src.controlConn, conn)
 		defer c.src.disconnect()
 
-		c.src.fsConn, err = c.connectWithSecret(c.src.fsSecret)
+		conn, err = c.connectWithSecret(c.src.fsSecret)
 		if err != nil {
 			c.src.sendControl(err)
 			return err
 		}
+		c.src.setConn(&c.src.fsConn, conn)
 
 		if c.src.live {
-			c.src.criuConn, err = c.connectWithSecret(c.src.criuSecret)
+			conn, err = c.connectWithSecret(c.src.criuSecret)
 			if err != nil {
 				c.src.sendControl(err)
 				return err
 			}
+			c.src.setConn(&c.src.criuConn, conn)
 		}
 	}
 
+	// Catch a cancellation which happened while connecting
+	select {
+	case <-c.cancelled:
+		return fmt.Errorf("Migration cancelled")
+	default:
+	}
+
 	receiver := c.src.recv
 	if c.push {
 		receiver = c.dest.recv
@@ -1136,7 +1208,9 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		return err
 	}
 
-	restore := make(chan error)
+	// Buffered so that the transfers can always finish, even once we're
+	// no longer listening for their result.
+	restore := make(chan error, 1)
 	go func(c *migrationSink) {
 		imagesDir := ""
 		srcIdmap := new(idmap.IdmapSet)
@@ -1157,7 +1231,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		 * container to start checkpointing, so the total transfer time
 		 * will be minimized even if we're dumb here.
 		 */
-		fsTransfer := make(chan error)
+		fsTransfer := make(chan error, 1)
 		go func() {
 			snapshots := []*Snapshot{}
 
@@ -1234,7 +1308,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 				for !sync.GetFinalPreDump() {
 					logger.Debugf("About to receive rsync")
 					// Transfer a CRIU pre-dump
-					err = RsyncRecv(shared.AddSlash(imagesDir), criuConn, nil)
+					err = RsyncRecv(shared.AddSlash(imagesDir), criuConn, nil, migrateOp.canceler)
 					if err != nil {
 						restore <- err
 						return
@@ -1265,7 +1339,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 			}
 
 			// Final CRIU dump
-			err = RsyncRecv(shared.AddSlash(imagesDir), criuConn, nil)
+			err = RsyncRecv(shared.AddSlash(imagesDir), criuConn, nil, migrateOp.canceler)
 			if err != nil {
 				restore <- err
 				return
@@ -1318,10 +1392,22 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		case msg, ok := <-source:
 			if !ok {
 				disconnector()
+
+				// Wait for the transfers to stop so the caller
+				// can safely clean up after them.
+				<-restore
+
+				select {
+				case <-c.cancelled:
+					return fmt.Errorf("Migration cancelled")
+				default:
+				}
+
 				return fmt.Errorf("Got error reading source")
 			}
 			if !*msg.Success {
 				disconnector()
+				<-restore
 				return fmt.Errorf(*msg.Message)
 			} else {
 				// The source can only tell us it failed (e.g. if
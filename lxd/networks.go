@@ -390,6 +390,198 @@ func doNetworkUpdate(d *Daemon, name string, oldConfig map[string]string, req ap
 
 var networkCmd = Command{name: "networks/{name}", get: networkGet, delete: networkDelete, post: networkPost, put: networkPut, patch: networkPatch}
 
+func networkStateGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	netIf, err := net.InterfaceByName(name)
+	if err != nil {
+		return NotFound
+	}
+
+	netState := "down"
+	netType := "unknown"
+
+	if netIf.Flags&net.FlagBroadcast > 0 {
+		netType = "broadcast"
+	}
+
+	if netIf.Flags&net.FlagPointToPoint > 0 {
+		netType = "point-to-point"
+	}
+
+	if netIf.Flags&net.FlagLoopback > 0 {
+		netType = "loopback"
+	}
+
+	if netIf.Flags&net.FlagUp > 0 {
+		netState = "up"
+	}
+
+	network := api.NetworkState{
+		Addresses: []api.NetworkStateAddress{},
+		Counters:  api.NetworkStateCounters{},
+		Hwaddr:    netIf.HardwareAddr.String(),
+		Mtu:       netIf.MTU,
+		State:     netState,
+		Type:      netType,
+	}
+
+	addrs, err := netIf.Addrs()
+	if err == nil {
+		for _, addr := range addrs {
+			fields := strings.SplitN(addr.String(), "/", 2)
+			if len(fields) != 2 {
+				continue
+			}
+
+			family := "inet"
+			if strings.Contains(fields[0], ":") {
+				family = "inet6"
+			}
+
+			address := api.NetworkStateAddress{}
+			address.Family = family
+			address.Address = fields[0]
+			address.Netmask = fields[1]
+			address.Scope = networkGetAddressScope(fields[0])
+
+			network.Addresses = append(network.Addresses, address)
+		}
+	}
+
+	counters, ok := networkGetDevStats()[netIf.Name]
+	if ok {
+		network.Counters.BytesReceived = counters[0]
+		network.Counters.PacketsReceived = counters[1]
+		network.Counters.BytesSent = counters[2]
+		network.Counters.PacketsSent = counters[3]
+	}
+
+	return SyncResponse(true, network)
+}
+
+var networkStateCmd = Command{name: "networks/{name}/state", get: networkStateGet}
+
+func networkLeasesGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Try to get the network
+	n, err := doNetworkGet(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate that we do have leases for it
+	if !n.Managed || n.Type != "bridge" {
+		return BadRequest(fmt.Errorf("Leases can only be retrieved for managed bridges"))
+	}
+
+	leases := []api.NetworkLease{}
+	containers := map[string]string{}
+
+	// Get all the static allocations and the MAC addresses in use
+	cts, err := d.db.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, ct := range cts {
+		c, err := containerLoadByName(d.State(), ct)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		for k, dev := range c.ExpandedDevices() {
+			if dev["type"] != "nic" || dev["nictype"] != "bridged" || dev["parent"] != name {
+				continue
+			}
+
+			// Fill in the hwaddr from volatile
+			hwaddr := dev["hwaddr"]
+			if hwaddr == "" {
+				hwaddr = c.LocalConfig()[fmt.Sprintf("volatile.%s.hwaddr", k)]
+			}
+
+			if hwaddr != "" {
+				containers[strings.ToLower(hwaddr)] = ct
+			}
+
+			for _, key := range []string{"ipv4.address", "ipv6.address"} {
+				if dev[key] == "" {
+					continue
+				}
+
+				leases = append(leases, api.NetworkLease{
+					Hostname:  ct,
+					Hwaddr:    hwaddr,
+					Address:   dev[key],
+					Type:      "static",
+					Container: ct,
+				})
+			}
+		}
+	}
+
+	// Get the dynamic leases
+	leaseFile := shared.VarPath("networks", name, "dnsmasq.leases")
+	if !shared.PathExists(leaseFile) {
+		return SyncResponse(true, leases)
+	}
+
+	content, err := ioutil.ReadFile(leaseFile)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, lease := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(lease)
+		if len(fields) < 5 {
+			continue
+		}
+
+		// IPv6 leases carry the IAID and DUID rather than the MAC,
+		// the latter being found at the end of the DUID.
+		macSlice := networkGetMacSlice(fields[1])
+		if strings.Contains(fields[2], ":") {
+			macSlice = networkGetMacSlice(fields[4])
+			if len(macSlice) > 6 {
+				macSlice = macSlice[len(macSlice)-6:]
+			}
+		}
+		hwaddr := strings.Join(macSlice, ":")
+
+		// Skip the static allocations we already know about
+		known := false
+		for _, entry := range leases {
+			if entry.Type == "static" && entry.Address == fields[2] {
+				known = true
+				break
+			}
+		}
+
+		if known {
+			continue
+		}
+
+		hostname := fields[3]
+		if hostname == "*" {
+			hostname = ""
+		}
+
+		leases = append(leases, api.NetworkLease{
+			Hostname:  hostname,
+			Hwaddr:    hwaddr,
+			Address:   fields[2],
+			Type:      "dynamic",
+			Container: containers[hwaddr],
+		})
+	}
+
+	return SyncResponse(true, leases)
+}
+
+var networkLeasesCmd = Command{name: "networks/{name}/leases", get: networkLeasesGet}
+
 // The network structs and functions
 func networkLoadByName(s *state.State, name string) (*network, error) {
 	id, dbInfo, err := s.DB.NetworkGet(name)
@@ -514,6 +706,14 @@ func (n *network) Delete() error {
 		return err
 	}
 
+	// Cleanup the dnsmasq leases, hosts and config files
+	if shared.PathExists(shared.VarPath("networks", n.name)) {
+		err = os.RemoveAll(shared.VarPath("networks", n.name))
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -58,6 +58,34 @@ func networkAttachInterface(netName string, devName string) error {
 	return nil
 }
 
+func networkSetInterfaceVlan(netName string, devName string, vlan string) error {
+	if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/bridge", netName)) {
+		content, err := ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/bridge/vlan_filtering", netName))
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(string(content)) != "1" {
+			return fmt.Errorf("VLAN filtering isn't enabled on bridge \"%s\"", netName)
+		}
+
+		// Replace the default VLAN with the requested one
+		shared.RunCommand("bridge", "vlan", "del", "dev", devName, "vid", "1")
+
+		_, err = shared.RunCommand("bridge", "vlan", "add", "dev", devName, "vid", vlan, "pvid", "untagged")
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err := shared.RunCommand("ovs-vsctl", "set", "port", devName, fmt.Sprintf("tag=%s", vlan))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func networkDetachInterface(netName string, devName string) error {
 	if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/bridge", netName)) {
 		_, err := shared.RunCommand("ip", "link", "set", "dev", devName, "nomaster")
@@ -103,7 +131,7 @@ func networkIsInUse(c container, name string) bool {
 			continue
 		}
 
-		if !shared.StringInSlice(d["nictype"], []string{"bridged", "macvlan", "physical", "sriov"}) {
+		if !shared.StringInSlice(d["nictype"], []string{"bridged", "ipvlan", "macvlan", "physical", "routed", "sriov"}) {
 			continue
 		}
 
@@ -111,6 +139,11 @@ func networkIsInUse(c container, name string) bool {
 			continue
 		}
 
+		// Bridged and SR-IOV VLANs are handled by the parent itself
+		if shared.StringInSlice(d["nictype"], []string{"bridged", "sriov"}) && d["parent"] == name {
+			return true
+		}
+
 		if networkGetHostDevice(d["parent"], d["vlan"]) == name {
 			return true
 		}
@@ -119,6 +152,34 @@ func networkIsInUse(c container, name string) bool {
 	return false
 }
 
+// The addresses the host side of routed nics uses as the container's gateway
+const networkRoutedGatewayV4 = "169.254.0.1"
+const networkRoutedGatewayV6 = "fe80::1"
+
+// networkRoutedAddresses splits a comma separated list of addresses and
+// turns them into single host subnets as used by routed and ipvlan nics.
+func networkRoutedAddresses(value string, family string) []string {
+	addresses := []string{}
+	for _, addr := range strings.Split(value, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+
+		if !strings.Contains(addr, "/") {
+			if family == "ipv6" {
+				addr = addr + "/128"
+			} else {
+				addr = addr + "/32"
+			}
+		}
+
+		addresses = append(addresses, addr)
+	}
+
+	return addresses
+}
+
 func networkGetHostDevice(parent string, vlan string) string {
 	// If no VLAN, just use the raw device
 	if vlan == "" {
@@ -992,3 +1053,61 @@ func networkClearLease(s *state.State, network string, hwaddr string) error {
 
 	return nil
 }
+
+// networkGetDevStats returns the received bytes, received packets, sent bytes
+// and sent packets of every interface listed in /proc/net/dev.
+func networkGetDevStats() map[string][]int64 {
+	stats := map[string][]int64{}
+
+	content, err := ioutil.ReadFile("/proc/net/dev")
+	if err != nil {
+		return stats
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) != 17 {
+			continue
+		}
+
+		rxBytes, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		rxPackets, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		txBytes, err := strconv.ParseInt(fields[9], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		txPackets, err := strconv.ParseInt(fields[10], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		intName := strings.TrimSuffix(fields[0], ":")
+		stats[intName] = []int64{rxBytes, rxPackets, txBytes, txPackets}
+	}
+
+	return stats
+}
+
+// networkGetAddressScope returns the scope ("global", "local" or "link") of
+// the provided IP address.
+func networkGetAddressScope(address string) string {
+	if strings.HasPrefix(address, "127") || address == "::1" {
+		return "local"
+	}
+
+	if strings.HasPrefix(address, "169.254") || strings.HasPrefix(address, "fe80:") {
+		return "link"
+	}
+
+	return "global"
+}
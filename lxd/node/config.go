@@ -79,13 +79,25 @@ var ConfigSchema = config.Schema{
 	// FIXME: Legacy node-level config values. Will be migrated to
 	//        cluster-config, but we need them here just to avoid
 	//        spurious errors in the logs
+	"core.audit_log":                 {},
+	"core.hooks_path":                {},
 	"core.https_allowed_headers":     {},
 	"core.https_allowed_methods":     {},
 	"core.https_allowed_origin":      {},
 	"core.https_allowed_credentials": {},
+	"core.lxcfs":                     {},
+	"core.log_format":                {},
+	"core.log_level":                 {},
+	"core.log_targets":               {},
+	"core.max_operations":            {},
+	"core.max_operations_per_client": {},
+	"core.metrics_address":           {},
 	"core.proxy_http":                {},
 	"core.proxy_https":               {},
 	"core.proxy_ignore_hosts":        {},
+	"core.privileged_containers":     {},
+	"core.rate_limit":                {},
+	"core.rate_limit_per_client":     {},
 	"core.trust_password":            {},
 	"images.auto_update_cached":      {},
 	"images.auto_update_interval":    {},
@@ -11,17 +11,37 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pborman/uuid"
 
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/cancel"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
 )
 
 var operationsLock sync.Mutex
+
+// Operation slots reserved by the requests being handled, by client (see
+// apiLimitsCheck)
+var operationsReserved = map[string]int{}
+
 var operations map[string]*operation = make(map[string]*operation)
 
+// Database used to record the running operations, so that those interrupted
+// by a daemon restart can be reported and cleaned up
+var operationsDB *db.Node
+
+// Operation types needing cleanup when interrupted. For container creation,
+// the container being created is the first one in the "containers" resources
+// (copies also list their source there).
+const (
+	operationTypeContainerCreate = "container-create"
+)
+
 type operationClass int
 
 const (
@@ -32,9 +52,9 @@ const (
 
 func (t operationClass) String() string {
 	return map[operationClass]string{
-		operationClassTask:      "task",
-		operationClassWebsocket: "websocket",
-		operationClassToken:     "token",
+		operationClassTask:      api.OperationClassTask,
+		operationClassWebsocket: api.OperationClassWebsocket,
+		operationClassToken:     api.OperationClassToken,
 	}[t]
 }
 
@@ -51,6 +71,13 @@ type operation struct {
 	readonly  bool
 	canceler  *cancel.Canceler
 
+	// What the operation is doing, recorded in the database (see operationsRecover)
+	opType string
+
+	// Client which created the operation, for the concurrency limits (see
+	// apiLimitsCheck)
+	requestor string
+
 	// Those functions are called at various points in the operation lifecycle
 	onRun     func(*operation) error
 	onCancel  func(*operation) error
@@ -64,11 +91,14 @@ type operation struct {
 }
 
 func (op *operation) done() {
+	// Checked and set under the lock, as a cancel may race with the end
+	// of the run and chanDone must only be closed once
+	op.lock.Lock()
 	if op.readonly {
+		op.lock.Unlock()
 		return
 	}
 
-	op.lock.Lock()
 	op.readonly = true
 	op.onRun = nil
 	op.onCancel = nil
@@ -76,6 +106,14 @@ func (op *operation) done() {
 	close(op.chanDone)
 	op.lock.Unlock()
 
+	// The operation wasn't interrupted, no need to remember it
+	if operationsDB != nil && op.class != operationClassToken {
+		err := operationsDB.OperationRemove(op.id)
+		if err != nil {
+			logger.Warnf("Failed to remove operation %s from the database: %s", op.id, err)
+		}
+	}
+
 	time.AfterFunc(time.Second*5, func() {
 		operationsLock.Lock()
 		_, ok := operations[op.id]
@@ -110,6 +148,7 @@ func (op *operation) Run() (chan error, error) {
 
 	op.lock.Lock()
 	op.status = api.Running
+	op.record()
 
 	if op.onRun != nil {
 		go func(op *operation, chanRun chan error) {
@@ -198,7 +237,9 @@ func (op *operation) Cancel() (chan error, error) {
 	_, md, _ := op.Render()
 	eventSend("operation", md)
 
-	if op.canceler != nil {
+	// Operations with their own cancel handler may have nothing to
+	// interrupt at this time
+	if op.canceler != nil && (op.onCancel == nil || op.canceler.Cancelable()) {
 		err := op.canceler.Cancel()
 		if err != nil {
 			return nil, err
@@ -253,6 +294,37 @@ func (op *operation) Connect(r *http.Request, w http.ResponseWriter) (chan error
 	return chanConnect, nil
 }
 
+// record saves the operation in the database. Token operations are short
+// lived and only carry secrets, so aren't recorded, and neither is the
+// metadata of websocket operations for the same reason.
+func (op *operation) record() {
+	if operationsDB == nil || op.class == operationClassToken {
+		return
+	}
+
+	info := db.OperationInfo{
+		Type: op.opType,
+		Operation: api.Operation{
+			ID:         op.id,
+			Class:      op.class.String(),
+			CreatedAt:  op.createdAt,
+			UpdatedAt:  op.updatedAt,
+			StatusCode: op.status,
+			Resources:  op.resources,
+			Err:        op.err,
+		},
+	}
+
+	if op.class != operationClassWebsocket {
+		info.Metadata = op.metadata
+	}
+
+	err := operationsDB.OperationSave(info)
+	if err != nil {
+		logger.Warnf("Failed to record operation %s in the database: %s", op.id, err)
+	}
+}
+
 func (op *operation) mayCancel() bool {
 	if op.class == operationClassToken {
 		return true
@@ -313,9 +385,11 @@ func (op *operation) WaitFinal(timeout int) (bool, error) {
 	// Wait until timeout
 	if timeout > 0 {
 		timer := time.NewTimer(time.Duration(timeout) * time.Second)
+		defer timer.Stop()
+
 		select {
 		case <-op.chanDone:
-			return false, nil
+			return true, nil
 
 		case <-timer.C:
 			return false, nil
@@ -435,16 +509,32 @@ func operationGet(id string) (*operation, error) {
 	return op, nil
 }
 
+// operationRender renders the operation for a client with the given
+// certificate restrictions, if any. Those clients don't get the secrets found
+// in the metadata of websocket and token operations.
+func operationRender(op *operation, restrictions *db.CertInfo) (*api.Operation, error) {
+	_, body, err := op.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	if restrictions != nil && (op.class == operationClassWebsocket || op.class == operationClassToken) {
+		body.Metadata = nil
+	}
+
+	return body, nil
+}
+
 // API functions
 func operationAPIGet(d *Daemon, r *http.Request) Response {
 	id := mux.Vars(r)["id"]
 
 	op, err := operationGet(id)
 	if err != nil {
-		return NotFound
+		return operationRecordedResponse(d, id)
 	}
 
-	_, body, err := op.Render()
+	body, err := operationRender(op, certificateRestrictions(d, r))
 	if err != nil {
 		return SmartError(err)
 	}
@@ -457,7 +547,12 @@ func operationAPIDelete(d *Daemon, r *http.Request) Response {
 
 	op, err := operationGet(id)
 	if err != nil {
-		return NotFound
+		_, err := d.db.OperationGet(id)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		return BadRequest(fmt.Errorf("Only running operations can be cancelled"))
 	}
 
 	_, err = op.Cancel()
@@ -474,14 +569,23 @@ func operationsAPIGet(d *Daemon, r *http.Request) Response {
 	var md shared.Jmap
 
 	recursion := util.IsRecursionRequest(r)
+	restrictions := certificateRestrictions(d, r)
 
 	md = shared.Jmap{}
 
+	// Copy the operations so they can be rendered without holding the lock
 	operationsLock.Lock()
-	ops := operations
+	ops := make([]*operation, 0, len(operations))
+	for _, v := range operations {
+		ops = append(ops, v)
+	}
 	operationsLock.Unlock()
 
 	for _, v := range ops {
+		if !certificateAllowsResources(restrictions, v.resources) {
+			continue
+		}
+
 		status := strings.ToLower(v.status.String())
 		_, ok := md[status]
 		if !ok {
@@ -497,7 +601,7 @@ func operationsAPIGet(d *Daemon, r *http.Request) Response {
 			continue
 		}
 
-		_, body, err := v.Render()
+		body, err := operationRender(v, restrictions)
 		if err != nil {
 			continue
 		}
@@ -505,21 +609,165 @@ func operationsAPIGet(d *Daemon, r *http.Request) Response {
 		md[status] = append(md[status].([]*api.Operation), body)
 	}
 
+	// Add the operations interrupted by a daemon restart
+	recorded, err := d.db.Operations()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, v := range recorded {
+		if !v.StatusCode.IsFinal() || !certificateAllowsResources(restrictions, v.Resources) {
+			continue
+		}
+
+		status := strings.ToLower(v.Status)
+		_, ok := md[status]
+		if !ok {
+			if recursion {
+				md[status] = make([]*api.Operation, 0)
+			} else {
+				md[status] = make([]string, 0)
+			}
+		}
+
+		if !recursion {
+			md[status] = append(md[status].([]string), fmt.Sprintf("/%s/operations/%s", version.APIVersion, v.ID))
+			continue
+		}
+
+		op := v.Operation
+		md[status] = append(md[status].([]*api.Operation), &op)
+	}
+
 	return SyncResponse(true, md)
 }
 
 var operationsCmd = Command{name: "operations", get: operationsAPIGet}
 
+// operationRecordedResponse renders an operation which was interrupted by a
+// daemon restart, as recorded in the database.
+func operationRecordedResponse(d *Daemon, id string) Response {
+	info, err := d.db.OperationGet(id)
+	if err != nil || !info.StatusCode.IsFinal() {
+		return NotFound
+	}
+
+	return SyncResponse(true, info.Operation)
+}
+
+// operationsShutdown waits for the running operations to complete, for at
+// most the given time, and then cancels the ones still running. Tokens are
+// cancelled right away. Operations which can't be cancelled are left to
+// operationsRecover on next start.
+func operationsShutdown(timeout time.Duration) {
+	operationsLock.Lock()
+	running := []*operation{}
+	for _, op := range operations {
+		if op.status == api.Running {
+			running = append(running, op)
+		}
+	}
+	operationsLock.Unlock()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	expired := false
+	for _, op := range running {
+		if op.class != operationClassToken && !expired {
+			select {
+			case <-op.chanDone:
+				continue
+			case <-deadline.C:
+				expired = true
+			}
+		}
+
+		if op.status.IsFinal() || !op.mayCancel() {
+			continue
+		}
+
+		logger.Info("Cancelling operation on shutdown", log.Ctx{"id": op.id, "class": op.class.String()})
+		chanCancel, err := op.Cancel()
+		if err != nil {
+			continue
+		}
+
+		select {
+		case <-chanCancel:
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// operationsRecover marks the operations which were running when the daemon
+// last stopped as failed, and cleans up after them. Operations already
+// recovered by a previous start are forgotten.
+func operationsRecover(s *state.State) error {
+	recorded, err := s.DB.Operations()
+	if err != nil {
+		return err
+	}
+
+	for _, info := range recorded {
+		if info.StatusCode.IsFinal() {
+			err := s.DB.OperationRemove(info.ID)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		logger.Warn("Operation interrupted by daemon restart", log.Ctx{"id": info.ID, "type": info.Type})
+
+		switch info.Type {
+		case operationTypeContainerCreate:
+			// Remove the partially created container
+			names := info.Resources["containers"]
+			if len(names) == 0 {
+				break
+			}
+
+			c, err := containerLoadByName(s, names[0])
+			if err != nil {
+				break
+			}
+
+			err = c.Delete()
+			if err != nil {
+				logger.Error("Failed to remove partially created container", log.Ctx{"container": names[0], "err": err})
+			}
+		}
+
+		info.StatusCode = api.Failure
+		info.Err = "Interrupted by daemon restart"
+		info.UpdatedAt = time.Now()
+
+		err := s.DB.OperationSave(info)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func operationAPIWaitGet(d *Daemon, r *http.Request) Response {
 	timeout, err := shared.AtoiEmptyDefault(r.FormValue("timeout"), -1)
 	if err != nil {
-		return InternalError(err)
+		return BadRequest(err)
+	}
+
+	if timeout < -1 {
+		return BadRequest(fmt.Errorf("Invalid timeout: %d", timeout))
 	}
 
 	id := mux.Vars(r)["id"]
 	op, err := operationGet(id)
 	if err != nil {
-		return NotFound
+		// Operations left over from a previous daemon are always done
+		return operationRecordedResponse(d, id)
 	}
 
 	_, err = op.WaitFinal(timeout)
@@ -527,7 +775,7 @@ func operationAPIWaitGet(d *Daemon, r *http.Request) Response {
 		return InternalError(err)
 	}
 
-	_, body, err := op.Render()
+	body, err := operationRender(op, certificateRestrictions(d, r))
 	if err != nil {
 		return SmartError(err)
 	}
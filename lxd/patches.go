@@ -99,6 +99,9 @@ func patchesApplyAll(d *Daemon) error {
 		if err != nil {
 			return err
 		}
+
+		// The patches may change the records of any container
+		containerCache.Clear()
 	}
 
 	return nil
@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/gorilla/mux"
 	_ "github.com/mattn/go-sqlite3"
 
+	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -89,6 +92,11 @@ func profilesPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	err = containerCheckPrivileged(d, r, req.Config, []string{})
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	// Update DB entry
 	_, err = d.db.ProfileCreate(req.Name, req.Description, req.Config, req.Devices)
 	if err != nil {
@@ -176,6 +184,11 @@ func profilePut(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	err = containerCheckPrivileged(d, r, req.Config, []string{})
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	return doProfileUpdate(d, name, id, profile, req)
 }
 
@@ -242,6 +255,11 @@ func profilePatch(d *Daemon, r *http.Request) Response {
 		}
 	}
 
+	err = containerCheckPrivileged(d, r, req.Config, []string{})
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	return doProfileUpdate(d, name, id, profile, req)
 }
 
@@ -259,6 +277,10 @@ func profilePost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("No name provided"))
 	}
 
+	if name == "default" {
+		return BadRequest(fmt.Errorf("The default profile can't be renamed"))
+	}
+
 	// Check that the name isn't already in use
 	id, _, _ := d.db.ProfileGet(req.Name)
 	if id > 0 {
@@ -278,6 +300,8 @@ func profilePost(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	containerCache.Clear()
+
 	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/profiles/%s", version.APIVersion, req.Name))
 }
 
@@ -300,7 +324,109 @@ func profileDelete(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	containerCache.Clear()
+
 	return EmptySyncResponse
 }
 
 var profileCmd = Command{name: "profiles/{name}", get: profileGet, put: profilePut, delete: profileDelete, post: profilePost, patch: profilePatch}
+
+// doProfileUsage figures out which parts of the profile are in effect for the
+// given container, that is, not overridden by a profile applied after it or by
+// the container's own config and devices.
+func doProfileUsage(s *state.State, profile *api.Profile, args db.ContainerArgs) (*api.ProfileUsage, error) {
+	profilesConfig := []map[string]string{}
+	profilesDevices := []types.Devices{}
+
+	// Collect what's applied on top of the profile
+	found := false
+	for _, name := range args.Profiles {
+		if !found {
+			found = name == profile.Name
+			continue
+		}
+
+		profileConfig, err := s.DB.ProfileConfig(name)
+		if err != nil {
+			return nil, err
+		}
+		profilesConfig = append(profilesConfig, profileConfig)
+
+		profileDevices, err := s.DB.Devices(name, true)
+		if err != nil {
+			return nil, err
+		}
+		profilesDevices = append(profilesDevices, profileDevices)
+	}
+
+	config := containerExpandConfig(profilesConfig, args.Config)
+	devices := containerExpandDevices(profilesDevices, args.Devices)
+
+	usage := api.ProfileUsage{
+		Container:         args.Name,
+		Config:            map[string]string{},
+		Devices:           map[string]map[string]string{},
+		OverriddenConfig:  []string{},
+		OverriddenDevices: []string{},
+	}
+
+	for k, v := range profile.Config {
+		_, ok := config[k]
+		if ok {
+			usage.OverriddenConfig = append(usage.OverriddenConfig, k)
+			continue
+		}
+
+		usage.Config[k] = v
+	}
+
+	for k, v := range profile.Devices {
+		_, ok := devices[k]
+		if ok {
+			usage.OverriddenDevices = append(usage.OverriddenDevices, k)
+			continue
+		}
+
+		usage.Devices[k] = v
+	}
+
+	sort.Strings(usage.OverriddenConfig)
+	sort.Strings(usage.OverriddenDevices)
+
+	return &usage, nil
+}
+
+// /1.0/profiles/{name}/usage
+// Show what the profile contributes to each of the containers using it
+func profileUsageGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	_, profile, err := d.db.ProfileGet(name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	cts, err := d.db.ProfileContainersGet(name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	result := []api.ProfileUsage{}
+	for _, ct := range cts {
+		args, err := d.db.ContainerGet(ct)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		usage, err := doProfileUsage(d.State(), profile, args)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		result = append(result, *usage)
+	}
+
+	return SyncResponse(true, result)
+}
+
+var profileUsageCmd = Command{name: "profiles/{name}/usage", get: profileUsageGet}
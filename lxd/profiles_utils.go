@@ -5,6 +5,7 @@ import (
 	"reflect"
 
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared/api"
 )
 
@@ -22,6 +23,12 @@ func doProfileUpdate(d *Daemon, name string, id int64, profile *api.Profile, req
 
 	containers := getContainersWithProfile(d.State(), name)
 
+	// Make sure the change can be applied to all the containers using the profile
+	err = doProfileUpdateCheck(d, name, containers, req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	// Check if the root device is supposed to be changed or removed.
 	oldProfileRootDiskDeviceKey, oldProfileRootDiskDevice, _ := containerGetRootDiskDevice(profile.Devices)
 	_, newProfileRootDiskDevice, _ := containerGetRootDiskDevice(req.Devices)
@@ -106,6 +113,8 @@ func doProfileUpdate(d *Daemon, name string, id int64, profile *api.Profile, req
 		return SmartError(err)
 	}
 
+	containerCache.Clear()
+
 	// Update all the containers using the profile. Must be done after db.TxCommit due to DB lock.
 	failures := map[string]error{}
 	for _, c := range containers {
@@ -131,3 +140,49 @@ func doProfileUpdate(d *Daemon, name string, id int64, profile *api.Profile, req
 
 	return EmptySyncResponse
 }
+
+// doProfileUpdateCheck validates the expanded config and devices of the
+// containers using the profile as they'd be after the update, so that the
+// change is rejected rather than saved and then failing to apply.
+func doProfileUpdateCheck(d *Daemon, name string, containers []container, req api.ProfilePut) error {
+	for _, c := range containers {
+		profileConfigs := []map[string]string{}
+		profileDevices := []types.Devices{}
+
+		for _, p := range c.Profiles() {
+			if p == name {
+				profileConfigs = append(profileConfigs, req.Config)
+				profileDevices = append(profileDevices, req.Devices)
+				continue
+			}
+
+			pConfig, err := d.db.ProfileConfig(p)
+			if err != nil {
+				return err
+			}
+
+			pDevices, err := d.db.Devices(p, true)
+			if err != nil {
+				return err
+			}
+
+			profileConfigs = append(profileConfigs, pConfig)
+			profileDevices = append(profileDevices, pDevices)
+		}
+
+		config := containerExpandConfig(profileConfigs, c.LocalConfig())
+		devices := containerExpandDevices(profileDevices, c.LocalDevices())
+
+		err := containerValidConfig(d.os, config, false, true)
+		if err != nil {
+			return fmt.Errorf("Invalid config for container \"%s\": %s", c.Name(), err)
+		}
+
+		err = containerValidDevices(d.db, devices, false, true)
+		if err != nil {
+			return fmt.Errorf("Invalid devices for container \"%s\": %s", c.Name(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter limits the rate of events, separately for each key, using a token
+// bucket per key which holds up to one second worth of events.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Buckets unused for that long are forgotten, once there are more than
+// maxBuckets of them.
+const (
+	maxBuckets = 1024
+	bucketTTL  = time.Minute
+)
+
+// New returns a new Limiter.
+func New() *Limiter {
+	return &Limiter{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow returns whether one more event for the given key is allowed, given a
+// rate of events per second. A rate of zero or less means no limit.
+func (l *Limiter) Allow(key string, rate int64) bool {
+	if rate <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		l.prune(now)
+		b = &bucket{tokens: float64(rate), last: now}
+		l.buckets[key] = b
+	}
+
+	// Refill the bucket for the time elapsed since the last event
+	b.tokens += now.Sub(b.last).Seconds() * float64(rate)
+	if b.tokens > float64(rate) {
+		b.tokens = float64(rate)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// Forget the buckets which haven't been used for a while, if there are too
+// many of them.
+func (l *Limiter) prune(now time.Time) {
+	if len(l.buckets) < maxBuckets {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > bucketTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// SetClock replaces the function returning the current time.
+func (l *Limiter) SetClock(now func() time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.now = now
+}
+
+// Buckets returns the number of buckets the limiter keeps.
+func (l *Limiter) Buckets() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
@@ -0,0 +1,60 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+// Up to a second worth of events is allowed at once, then events are allowed
+// again as time passes.
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Now()
+	limiter := ratelimit.New()
+	limiter.SetClock(func() time.Time { return now })
+
+	for i := 0; i < 5; i++ {
+		assert.True(t, limiter.Allow("foo", 5))
+	}
+	assert.False(t, limiter.Allow("foo", 5))
+
+	// Other keys have their own bucket
+	assert.True(t, limiter.Allow("bar", 5))
+
+	now = now.Add(200 * time.Millisecond)
+	assert.True(t, limiter.Allow("foo", 5))
+	assert.False(t, limiter.Allow("foo", 5))
+
+	// The bucket never holds more than a second worth of events
+	now = now.Add(time.Hour)
+	for i := 0; i < 5; i++ {
+		assert.True(t, limiter.Allow("foo", 5))
+	}
+	assert.False(t, limiter.Allow("foo", 5))
+}
+
+func TestLimiter_AllowUnlimited(t *testing.T) {
+	limiter := ratelimit.New()
+	for i := 0; i < 100; i++ {
+		assert.True(t, limiter.Allow("foo", 0))
+	}
+}
+
+// Unused buckets get forgotten once there are many of them.
+func TestLimiter_Prune(t *testing.T) {
+	now := time.Now()
+	limiter := ratelimit.New()
+	limiter.SetClock(func() time.Time { return now })
+
+	for i := 0; i < 1024; i++ {
+		limiter.Allow(fmt.Sprintf("client%d", i), 1)
+	}
+	assert.Equal(t, 1024, limiter.Buckets())
+
+	now = now.Add(2 * time.Minute)
+	limiter.Allow("new", 1)
+	assert.Equal(t, 1, limiter.Buckets())
+}
@@ -311,6 +311,27 @@ func PreconditionFailed(err error) Response {
 	return &errorResponse{http.StatusPreconditionFailed, err.Error()}
 }
 
+func Unavailable(err error) Response {
+	return &errorResponse{http.StatusServiceUnavailable, err.Error()}
+}
+
+// responseStatusCode returns the HTTP status code the given response gets
+// rendered with, when successful.
+func responseStatusCode(resp Response) int {
+	switch resp := resp.(type) {
+	case *errorResponse:
+		return resp.code
+	case *operationResponse:
+		return http.StatusAccepted
+	}
+
+	return http.StatusOK
+}
+
+func TooManyRequests(err error) Response {
+	return &errorResponse{http.StatusTooManyRequests, err.Error()}
+}
+
 /*
  * SmartError returns the right error message based on err.
  */
@@ -0,0 +1,41 @@
+package main
+
+// revertSteps records the steps of a multi-step operation, such as creating a
+// container, along with how to undo each of them, so that a failure at any
+// point leaves nothing behind. The usual pattern is:
+//
+//	revert := revertSteps{}
+//	defer revert.Fail()
+//
+//	... do a step, then revert.Add(func() { ... undo it ... })
+//
+//	revert.Success()
+//	return nil
+type revertSteps struct {
+	steps []func()
+	done  bool
+}
+
+// Add records how to undo the step which was just done.
+func (r *revertSteps) Add(undo func()) {
+	r.steps = append(r.steps, undo)
+}
+
+// Success marks the whole operation as successful, so that Fail doesn't undo
+// anything anymore.
+func (r *revertSteps) Success() {
+	r.done = true
+}
+
+// Fail undoes the recorded steps, in reverse order, unless Success was called.
+func (r *revertSteps) Fail() {
+	if r.done {
+		return
+	}
+
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		r.steps[i]()
+	}
+
+	r.done = true
+}
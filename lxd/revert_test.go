@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The steps are undone in reverse order, only once.
+func TestRevertSteps_Fail(t *testing.T) {
+	undone := []int{}
+
+	revert := revertSteps{}
+	revert.Add(func() { undone = append(undone, 1) })
+	revert.Add(func() { undone = append(undone, 2) })
+	revert.Fail()
+	revert.Fail()
+
+	assert.Equal(t, []int{2, 1}, undone)
+}
+
+// Nothing is undone after a success.
+func TestRevertSteps_Success(t *testing.T) {
+	undone := false
+
+	revert := revertSteps{}
+	revert.Add(func() { undone = true })
+	revert.Success()
+	revert.Fail()
+
+	assert.False(t, undone)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/pborman/uuid"
 
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/cancel"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -176,7 +177,7 @@ func RsyncSend(name string, path string, conn *websocket.Conn, readWrapper func(
 // RsyncRecv sets up the receiving half of the websocket to rsync (the other
 // half set up by RsyncSend), putting the contents in the directory specified
 // by path.
-func RsyncRecv(path string, conn *websocket.Conn, writeWrapper func(io.WriteCloser) io.WriteCloser) error {
+func RsyncRecv(path string, conn *websocket.Conn, writeWrapper func(io.WriteCloser) io.WriteCloser, canceler *cancel.Canceler) error {
 	cmd := exec.Command("rsync",
 		"--server",
 		"-vlogDtpre.iLsfx",
@@ -206,6 +207,10 @@ func RsyncRecv(path string, conn *websocket.Conn, writeWrapper func(io.WriteClos
 		return err
 	}
 
+	// Kill rsync if the migration gets cancelled
+	done := cancel.CancelableCommand(canceler, cmd)
+	defer done()
+
 	writePipe := io.WriteCloser(stdin)
 	if writeWrapper != nil {
 		writePipe = writeWrapper(stdin)
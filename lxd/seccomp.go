@@ -131,6 +131,9 @@ func getSeccompProfileContent(c container) (string, error) {
 
 	blacklist := config["security.syscalls.blacklist"]
 	if blacklist != "" {
+		// The compat policy is architecture specific, so go back to
+		// the generic section for the user's own entries.
+		policy += "[all]\n"
 		policy += blacklist
 	}
 
@@ -150,7 +153,7 @@ func SeccompCreateProfile(c container) error {
 
 	profile, err := getSeccompProfileContent(c)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	if err := os.MkdirAll(seccompPath, 0700); err != nil {
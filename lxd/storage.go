@@ -15,6 +15,7 @@ import (
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/cancel"
 	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
@@ -162,8 +163,9 @@ type storage interface {
 	// ContainerCreate creates an empty container (no rootfs/metadata.yaml)
 	ContainerCreate(container container) error
 
-	// ContainerCreateFromImage creates a container from a image.
-	ContainerCreateFromImage(c container, fingerprint string) error
+	// ContainerCreateFromImage creates a container from a image, reporting
+	// the unpacking progress to the optional tracker.
+	ContainerCreateFromImage(c container, fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error
 	ContainerCanRestore(target container, source container) error
 	ContainerDelete(c container) error
 	ContainerCopy(target container, source container, containerOnly bool) error
@@ -185,7 +187,7 @@ type storage interface {
 	ContainerSnapshotCreateEmpty(c container) error
 
 	// Functions dealing with image storage volumes.
-	ImageCreate(fingerprint string) error
+	ImageCreate(fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error
 	ImageDelete(fingerprint string) error
 	ImageMount(fingerprint string) (bool, error)
 	ImageUmount(fingerprint string) (bool, error)
@@ -225,6 +227,12 @@ type storage interface {
 		srcIdmap *idmap.IdmapSet,
 		op *operation,
 		containerOnly bool) error
+
+	// Functions dealing with optimized container exports, which carry
+	// the native send streams of the container and its snapshots in
+	// the given directory.
+	ContainerExportOptimized(c container, snapshots []container, dir string) error
+	ContainerImportOptimized(c container, snapshots []container, dir string) error
 }
 
 func storageCoreInit(driver string) (storage, error) {
@@ -765,6 +773,29 @@ func progressWrapperRender(op *operation, key string, description string, progre
 	}
 }
 
+// StorageProgressTracker reports the progress (in percent) of an operation
+// whose total size is known upfront, like unpacking an image.
+func StorageProgressTracker(op *operation, key string, description string) *ioprogress.ProgressTracker {
+	if op == nil {
+		return nil
+	}
+
+	return &ioprogress.ProgressTracker{
+		Handler: func(percent int64, speed int64) {
+			meta := op.metadata
+			if meta == nil {
+				meta = make(map[string]interface{})
+			}
+
+			progress := fmt.Sprintf("%s: %d%% (%s/s)", description, percent, shared.GetByteSizeString(speed, 2))
+			if meta[key] != progress {
+				meta[key] = progress
+				op.UpdateMetadata(meta)
+			}
+		},
+	}
+}
+
 // StorageProgressReader reports the read progress.
 func StorageProgressReader(op *operation, key string, description string) func(io.ReadCloser) io.ReadCloser {
 	return func(reader io.ReadCloser) io.ReadCloser {
@@ -858,6 +889,44 @@ func SetupStorageDriver(s *state.State, forceCheck bool) error {
 	return nil
 }
 
+// storagePoolsShutdown unmounts all storage pools and releases the loop
+// devices backing loop-file based pools. It is meant to be called on daemon
+// shutdown when no containers are running anymore. The pools will be set up
+// again by SetupStorageDriver() on the next start.
+func storagePoolsShutdown(s *state.State) {
+	pools, err := s.DB.StoragePools()
+	if err != nil {
+		if err != db.NoSuchObjectError {
+			logger.Warnf("Failed to retrieve existing storage pools: %s.", err)
+		}
+		return
+	}
+
+	for _, poolName := range pools {
+		pool, err := storagePoolInit(s, poolName)
+		if err != nil {
+			logger.Warnf("Failed to initialize storage pool \"%s\": %s.", poolName, err)
+			continue
+		}
+
+		_, err = pool.StoragePoolUmount()
+		if err != nil {
+			logger.Warnf("Failed to unmount storage pool \"%s\": %s.", poolName, err)
+			continue
+		}
+
+		switch pool := pool.(type) {
+		case *storageZfs:
+			err = pool.zfsPoolExportLoop()
+		case *storageLvm:
+			err = pool.lvmPoolReleaseLoop()
+		}
+		if err != nil {
+			logger.Warnf("Failed to release loop device of storage pool \"%s\": %s.", poolName, err)
+		}
+	}
+}
+
 func storagePoolDriversCacheUpdate(dbNode *db.Node) {
 	// Get a list of all storage drivers currently in use
 	// on this LXD instance. Only do this when we do not already have done
@@ -0,0 +1,255 @@
+// +build linux
+// +build cgo
+
+package quota
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/lxc/lxd/shared"
+)
+
+/*
+#define _GNU_SOURCE
+#include <errno.h>
+#include <fcntl.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+#include <unistd.h>
+#include <linux/fs.h>
+#include <sys/ioctl.h>
+#include <sys/quota.h>
+#include <sys/types.h>
+
+#ifndef FS_XFLAG_PROJINHERIT
+struct fsxattr {
+	__u32 fsx_xflags;
+	__u32 fsx_extsize;
+	__u32 fsx_nextents;
+	__u32 fsx_projid;
+	unsigned char fsx_pad[12];
+};
+#define FS_XFLAG_PROJINHERIT 0x00000200
+#endif
+
+#ifndef FS_IOC_FSGETXATTR
+#define FS_IOC_FSGETXATTR _IOR('X', 31, struct fsxattr)
+#endif
+
+#ifndef FS_IOC_FSSETXATTR
+#define FS_IOC_FSSETXATTR _IOW('X', 32, struct fsxattr)
+#endif
+
+#ifndef PRJQUOTA
+#define PRJQUOTA 2
+#endif
+
+int quota_supported(char *dev_path) {
+	struct dqinfo info;
+
+	return quotactl(QCMD(Q_GETINFO, PRJQUOTA), dev_path, 0, (caddr_t)&info);
+}
+
+int64_t quota_get_usage(char *dev_path, uint32_t id) {
+	struct dqblk quota;
+
+	if (quotactl(QCMD(Q_GETQUOTA, PRJQUOTA), dev_path, id, (caddr_t)&quota) < 0)
+		return -1;
+
+	return quota.dqb_curspace;
+}
+
+int quota_set(char *dev_path, uint32_t id, uint64_t hard_bytes) {
+	struct dqblk quota;
+
+	memset(&quota, 0, sizeof(quota));
+	quota.dqb_bhardlimit = hard_bytes / QIF_DQBLKSIZE;
+	quota.dqb_valid = QIF_BLIMITS;
+
+	return quotactl(QCMD(Q_SETQUOTA, PRJQUOTA), dev_path, id, (caddr_t)&quota);
+}
+
+int quota_set_path(char *path, uint32_t id) {
+	struct fsxattr attr;
+	int fd, ret;
+
+	fd = open(path, O_RDONLY | O_CLOEXEC | O_NOFOLLOW);
+	if (fd < 0)
+		return -1;
+
+	ret = ioctl(fd, FS_IOC_FSGETXATTR, &attr);
+	if (ret < 0) {
+		close(fd);
+		return -1;
+	}
+
+	attr.fsx_xflags |= FS_XFLAG_PROJINHERIT;
+	attr.fsx_projid = id;
+
+	ret = ioctl(fd, FS_IOC_FSSETXATTR, &attr);
+	close(fd);
+
+	return ret;
+}
+
+int64_t quota_get_path(char *path) {
+	struct fsxattr attr;
+	int fd, ret;
+
+	fd = open(path, O_RDONLY | O_CLOEXEC | O_NOFOLLOW);
+	if (fd < 0)
+		return -1;
+
+	ret = ioctl(fd, FS_IOC_FSGETXATTR, &attr);
+	close(fd);
+	if (ret < 0)
+		return -1;
+
+	return attr.fsx_projid;
+}
+*/
+import "C"
+
+// devForPath returns the path of the block device backing the filesystem the
+// given path lives on.
+func devForPath(path string) (string, error) {
+	var stat syscall.Stat_t
+	err := syscall.Lstat(path, &stat)
+	if err != nil {
+		return "", err
+	}
+
+	devMajor := ((stat.Dev >> 8) & 0xfff) | ((stat.Dev >> 32) & 0xfffff000)
+	devMinor := (stat.Dev & 0xff) | ((stat.Dev >> 12) & 0xffffff00)
+	devID := fmt.Sprintf("%d:%d", devMajor, devMinor)
+
+	mountinfo, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer mountinfo.Close()
+
+	scanner := bufio.NewScanner(mountinfo)
+	for scanner.Scan() {
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) < 5 {
+			continue
+		}
+
+		if tokens[2] != devID {
+			continue
+		}
+
+		// The mount source is the second to last field
+		source := tokens[len(tokens)-2]
+		if shared.PathExists(source) {
+			return source, nil
+		}
+	}
+
+	return "", fmt.Errorf("Couldn't find backing device for \"%s\"", path)
+}
+
+// Supported checks if the given path supports project quotas
+func Supported(path string) (bool, error) {
+	devPath, err := devForPath(path)
+	if err != nil {
+		return false, err
+	}
+
+	cDevPath := C.CString(devPath)
+	defer C.free(unsafe.Pointer(cDevPath))
+
+	return C.quota_supported(cDevPath) == 0, nil
+}
+
+// GetProject returns the project quota ID for the given path
+func GetProject(path string) (uint32, error) {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	id := C.quota_get_path(cPath)
+	if id < 0 {
+		return 0, fmt.Errorf("Failed to get project from \"%s\"", path)
+	}
+
+	return uint32(id), nil
+}
+
+// SetProject recursively sets the project quota ID (and project inherit flag)
+// on the provided path
+func SetProject(path string, id uint32) error {
+	return filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Only regular files and directories can carry a project ID
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		cPath := C.CString(filePath)
+		defer C.free(unsafe.Pointer(cPath))
+
+		if C.quota_set_path(cPath, C.uint32_t(id)) != 0 {
+			return fmt.Errorf("Failed to set project ID \"%d\" on \"%s\"", id, filePath)
+		}
+
+		return nil
+	})
+}
+
+// DeleteProject unsets the project ID from the path and clears any quota
+// configured for it
+func DeleteProject(path string, id uint32) error {
+	err := SetProject(path, 0)
+	if err != nil {
+		return err
+	}
+
+	return SetProjectQuota(path, id, 0)
+}
+
+// GetProjectUsage returns the current consumption of the project
+func GetProjectUsage(path string, id uint32) (int64, error) {
+	devPath, err := devForPath(path)
+	if err != nil {
+		return -1, err
+	}
+
+	cDevPath := C.CString(devPath)
+	defer C.free(unsafe.Pointer(cDevPath))
+
+	size := C.quota_get_usage(cDevPath, C.uint32_t(id))
+	if size < 0 {
+		return -1, fmt.Errorf("Failed to get project consumption for id \"%d\" on \"%s\"", id, devPath)
+	}
+
+	return int64(size), nil
+}
+
+// SetProjectQuota sets the hard limit (in bytes) of the project, 0 meaning
+// no limit
+func SetProjectQuota(path string, id uint32, bytes int64) error {
+	devPath, err := devForPath(path)
+	if err != nil {
+		return err
+	}
+
+	cDevPath := C.CString(devPath)
+	defer C.free(unsafe.Pointer(cDevPath))
+
+	if C.quota_set(cDevPath, C.uint32_t(id), C.uint64_t(bytes)) != 0 {
+		return fmt.Errorf("Failed to set project quota for id \"%d\" on \"%s\"", id, devPath)
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -19,7 +20,9 @@ import (
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/cancel"
 	"github.com/lxc/lxd/shared/idmap"
+	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -744,7 +747,7 @@ func (s *storageBtrfs) ContainerCreate(container container) error {
 }
 
 // And this function is why I started hating on btrfs...
-func (s *storageBtrfs) ContainerCreateFromImage(container container, fingerprint string) error {
+func (s *storageBtrfs) ContainerCreateFromImage(container container, fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	logger.Debugf("Creating BTRFS storage volume for container \"%s\" on storage pool \"%s\".", s.volume.Name, s.pool.Name)
 
 	source := s.pool.Config["source"]
@@ -787,7 +790,7 @@ func (s *storageBtrfs) ContainerCreateFromImage(container container, fingerprint
 
 		var imgerr error
 		if !shared.PathExists(imageMntPoint) || !isBtrfsSubVolume(imageMntPoint) {
-			imgerr = s.ImageCreate(fingerprint)
+			imgerr = s.ImageCreate(fingerprint, tracker, canceler)
 		}
 
 		lxdStorageMapLock.Lock()
@@ -1381,7 +1384,7 @@ func (s *storageBtrfs) ContainerSnapshotCreateEmpty(snapshotContainer container)
 	return nil
 }
 
-func (s *storageBtrfs) ImageCreate(fingerprint string) error {
+func (s *storageBtrfs) ImageCreate(fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	logger.Debugf("Creating BTRFS storage volume for image \"%s\" on storage pool \"%s\".", fingerprint, s.pool.Name)
 
 	// Create the subvolume.
@@ -1433,7 +1436,7 @@ func (s *storageBtrfs) ImageCreate(fingerprint string) error {
 
 	// Unpack the image in imageMntPoint.
 	imagePath := shared.VarPath("images", fingerprint)
-	err = unpackImage(imagePath, tmpImageSubvolumeName, storageTypeBtrfs, s.s.OS.RunningInUserNS)
+	err = unpackImage(imagePath, tmpImageSubvolumeName, storageTypeBtrfs, s.s.OS.RunningInUserNS, tracker, canceler)
 	if err != nil {
 		return err
 	}
@@ -2231,6 +2234,140 @@ func (s *storageBtrfs) btrfsLookupFsUUID(fs string) (string, error) {
 	return outputString, nil
 }
 
+func (s *storageBtrfs) ContainerExportOptimized(c container, snapshots []container, dir string) error {
+	err := os.MkdirAll(filepath.Join(dir, "snapshots"), 0700)
+	if err != nil {
+		return err
+	}
+
+	// Send the snapshots from the oldest to the newest, each one relative
+	// to the previous one, so that they keep sharing their extents
+	prev := ""
+	for _, snap := range snapshots {
+		_, snapName, _ := containerGetParentAndSnapshotName(snap.Name())
+		snapMntPoint := getSnapshotMountPoint(s.pool.Name, snap.Name())
+		err := btrfsSendToFile(snapMntPoint, prev, filepath.Join(dir, "snapshots", fmt.Sprintf("%s.bin", snapName)))
+		if err != nil {
+			return err
+		}
+
+		prev = snapMntPoint
+	}
+
+	// Then the container itself, which can only be sent from a read-only
+	// snapshot
+	tmpContainerMntPoint, err := ioutil.TempDir(getContainerMountPoint(s.pool.Name, ""), c.Name())
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpContainerMntPoint)
+
+	err = os.Chmod(tmpContainerMntPoint, 0700)
+	if err != nil {
+		return err
+	}
+
+	exportSnapshot := fmt.Sprintf("%s/.export", tmpContainerMntPoint)
+	err = s.btrfsPoolVolumesSnapshot(getContainerMountPoint(s.pool.Name, c.Name()), exportSnapshot, true)
+	if err != nil {
+		return err
+	}
+	defer btrfsSubVolumesDelete(exportSnapshot)
+
+	return btrfsSendToFile(exportSnapshot, prev, filepath.Join(dir, "container.bin"))
+}
+
+func (s *storageBtrfs) ContainerImportOptimized(c container, snapshots []container, dir string) error {
+	// Receive the stream next to the target, then replace the pre-created
+	// subvolume with a snapshot of what was received
+	btrfsRecv := func(file string, receivedName string, targetPath string, readonly bool) error {
+		tmpMntPoint, err := ioutil.TempDir(filepath.Dir(targetPath), c.Name())
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpMntPoint)
+
+		err = os.Chmod(tmpMntPoint, 0700)
+		if err != nil {
+			return err
+		}
+
+		err = btrfsSubVolumesDelete(targetPath)
+		if err != nil {
+			return err
+		}
+
+		err = btrfsReceiveFromFile(file, tmpMntPoint)
+		if err != nil {
+			return err
+		}
+
+		receivedSnapshot := fmt.Sprintf("%s/%s", tmpMntPoint, receivedName)
+		err = s.btrfsPoolVolumesSnapshot(receivedSnapshot, targetPath, readonly)
+		if err != nil {
+			return err
+		}
+
+		return btrfsSubVolumesDelete(receivedSnapshot)
+	}
+
+	for _, snap := range snapshots {
+		_, snapName, _ := containerGetParentAndSnapshotName(snap.Name())
+		err := btrfsRecv(filepath.Join(dir, "snapshots", fmt.Sprintf("%s.bin", snapName)), snapName, getSnapshotMountPoint(s.pool.Name, snap.Name()), true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return btrfsRecv(filepath.Join(dir, "container.bin"), ".export", getContainerMountPoint(s.pool.Name, c.Name()), false)
+}
+
+// btrfsSendToFile writes the send stream of the given read-only subvolume to
+// a file, relative to the parent subvolume if one is given.
+func btrfsSendToFile(btrfsPath string, btrfsParent string, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	args := []string{"send"}
+	if btrfsParent != "" {
+		args = append(args, "-p", btrfsParent)
+	}
+	args = append(args, btrfsPath)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("btrfs", args...)
+	cmd.Stdout = f
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		logger.Errorf("Problem with btrfs send: %s.", stderr.String())
+		return fmt.Errorf("Failed to send BTRFS subvolume: %s", stderr.String())
+	}
+
+	return f.Close()
+}
+
+// btrfsReceiveFromFile receives the send stream in the given file into the
+// directory.
+func btrfsReceiveFromFile(file string, btrfsPath string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	output, err := shared.RunCommandWithStdin(f, "btrfs", "receive", "-e", btrfsPath)
+	if err != nil {
+		logger.Errorf("Problem with btrfs receive: %s.", output)
+		return fmt.Errorf("Failed to receive BTRFS subvolume: %s", output)
+	}
+
+	return nil
+}
+
 func (s *storageBtrfs) StorageEntitySetQuota(volumeType int, size int64, data interface{}) error {
 	logger.Debugf(`Setting BTRFS quota for "%s"`, s.volume.Name)
 
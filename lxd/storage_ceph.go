@@ -9,6 +9,8 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/cancel"
+	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -944,7 +946,7 @@ func (s *storageCeph) ContainerCreate(container container) error {
 	return nil
 }
 
-func (s *storageCeph) ContainerCreateFromImage(container container, fingerprint string) error {
+func (s *storageCeph) ContainerCreateFromImage(container container, fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	logger.Debugf(`Creating RBD storage volume for container "%s" on `+
 		`storage pool "%s"`, s.volume.Name, s.pool.Name)
 
@@ -987,7 +989,7 @@ func (s *storageCeph) ContainerCreateFromImage(container container, fingerprint
 		}
 
 		if !ok {
-			imgerr = s.ImageCreate(fingerprint)
+			imgerr = s.ImageCreate(fingerprint, tracker, canceler)
 		}
 
 		lxdStorageMapLock.Lock()
@@ -2232,7 +2234,7 @@ func (s *storageCeph) ContainerSnapshotCreateEmpty(c container) error {
 	return nil
 }
 
-func (s *storageCeph) ImageCreate(fingerprint string) error {
+func (s *storageCeph) ImageCreate(fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	logger.Debugf(`Creating RBD storage volume for image "%s" on storage `+
 		`pool "%s"`, fingerprint, s.pool.Name)
 
@@ -2368,7 +2370,7 @@ func (s *storageCeph) ImageCreate(fingerprint string) error {
 
 		// rsync contents into image
 		imagePath := shared.VarPath("images", fingerprint)
-		err = unpackImage(imagePath, imageMntPoint, storageTypeCeph, s.s.OS.RunningInUserNS)
+		err = unpackImage(imagePath, imageMntPoint, storageTypeCeph, s.s.OS.RunningInUserNS, tracker, canceler)
 		if err != nil {
 			logger.Errorf(`Failed to unpack image for RBD storage `+
 				`volume for image "%s" on storage pool "%s": %s`,
@@ -2692,6 +2694,14 @@ func (s *storageCeph) ImageUmount(fingerprint string) (bool, error) {
 	return true, nil
 }
 
+func (s *storageCeph) ContainerExportOptimized(c container, snapshots []container, dir string) error {
+	return fmt.Errorf("Optimized exports aren't supported by the ceph storage driver")
+}
+
+func (s *storageCeph) ContainerImportOptimized(c container, snapshots []container, dir string) error {
+	return fmt.Errorf("Optimized exports aren't supported by the ceph storage driver")
+}
+
 func (s *storageCeph) StorageEntitySetQuota(volumeType int, size int64, data interface{}) error {
 	logger.Debugf(`Setting RBD quota for "%s"`, s.volume.Name)
 
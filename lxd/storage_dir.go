@@ -9,9 +9,12 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"github.com/lxc/lxd/lxd/storage/quota"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/cancel"
 	"github.com/lxc/lxd/shared/idmap"
+	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -480,7 +483,7 @@ func (s *storageDir) ContainerCreate(container container) error {
 	return nil
 }
 
-func (s *storageDir) ContainerCreateFromImage(container container, imageFingerprint string) error {
+func (s *storageDir) ContainerCreateFromImage(container container, imageFingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	logger.Debugf("Creating DIR storage volume for container \"%s\" on storage pool \"%s\".", s.volume.Name, s.pool.Name)
 
 	_, err := s.StoragePoolMount()
@@ -509,7 +512,7 @@ func (s *storageDir) ContainerCreateFromImage(container container, imageFingerpr
 	}()
 
 	imagePath := shared.VarPath("images", imageFingerprint)
-	err = unpackImage(imagePath, containerMntPoint, storageTypeDir, s.s.OS.RunningInUserNS)
+	err = unpackImage(imagePath, containerMntPoint, storageTypeDir, s.s.OS.RunningInUserNS, tracker, canceler)
 	if err != nil {
 		return err
 	}
@@ -554,7 +557,16 @@ func (s *storageDir) ContainerDelete(container container) error {
 	containerName := container.Name()
 	containerMntPoint := getContainerMountPoint(s.pool.Name, containerName)
 	if shared.PathExists(containerMntPoint) {
-		err := os.RemoveAll(containerMntPoint)
+		// Release the project quota so that the ID can be reused.
+		projectID, err := quota.GetProject(containerMntPoint)
+		if err == nil && projectID != 0 {
+			err := quota.SetProjectQuota(containerMntPoint, projectID, 0)
+			if err != nil {
+				logger.Warnf("Failed to clear quota for container \"%s\": %s", containerName, err)
+			}
+		}
+
+		err = os.RemoveAll(containerMntPoint)
 		if err != nil {
 			// RemovaAll fails on very long paths, so attempt an rm -Rf
 			output, err := shared.RunCommand("rm", "-Rf", containerMntPoint)
@@ -605,7 +617,7 @@ func (s *storageDir) copyContainer(target container, source container) error {
 	}
 
 	bwlimit := s.pool.Config["rsync.bwlimit"]
-	output, err := rsyncLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
+	output, err := storageLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
 	if err != nil {
 		return fmt.Errorf("failed to rsync container: %s: %s", string(output), err)
 	}
@@ -639,7 +651,7 @@ func (s *storageDir) copySnapshot(target container, source container) error {
 	}
 
 	bwlimit := s.pool.Config["rsync.bwlimit"]
-	output, err := rsyncLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
+	output, err := storageLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
 	if err != nil {
 		return fmt.Errorf("failed to rsync container: %s: %s", string(output), err)
 	}
@@ -788,7 +800,7 @@ func (s *storageDir) ContainerRestore(container container, sourceContainer conta
 
 	// Restore using rsync
 	bwlimit := s.pool.Config["rsync.bwlimit"]
-	output, err := rsyncLocalCopy(sourcePath, targetPath, bwlimit)
+	output, err := storageLocalCopy(sourcePath, targetPath, bwlimit)
 	if err != nil {
 		return fmt.Errorf("failed to rsync container: %s: %s", string(output), err)
 	}
@@ -803,7 +815,19 @@ func (s *storageDir) ContainerRestore(container container, sourceContainer conta
 }
 
 func (s *storageDir) ContainerGetUsage(container container) (int64, error) {
-	return -1, fmt.Errorf("the directory container backend doesn't support quotas")
+	containerMntPoint := getContainerMountPoint(s.pool.Name, container.Name())
+
+	ok, err := quota.Supported(containerMntPoint)
+	if err != nil || !ok {
+		return -1, fmt.Errorf("the directory container backend doesn't support quotas")
+	}
+
+	projectID, err := s.quotaProjectID(container.Name(), storagePoolVolumeTypeContainer)
+	if err != nil {
+		return -1, err
+	}
+
+	return quota.GetProjectUsage(containerMntPoint, projectID)
 }
 
 func (s *storageDir) ContainerSnapshotCreate(snapshotContainer container, sourceContainer container) error {
@@ -823,7 +847,7 @@ func (s *storageDir) ContainerSnapshotCreate(snapshotContainer container, source
 	}
 
 	rsync := func(snapshotContainer container, oldPath string, newPath string, bwlimit string) error {
-		output, err := rsyncLocalCopy(oldPath, newPath, bwlimit)
+		output, err := storageLocalCopy(oldPath, newPath, bwlimit)
 		if err != nil {
 			s.ContainerDelete(snapshotContainer)
 			return fmt.Errorf("failed to rsync: %s: %s", string(output), err)
@@ -1009,7 +1033,7 @@ func (s *storageDir) ContainerSnapshotStop(container container) (bool, error) {
 	return true, nil
 }
 
-func (s *storageDir) ImageCreate(fingerprint string) error {
+func (s *storageDir) ImageCreate(fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	return nil
 }
 
@@ -1046,8 +1070,72 @@ func (s *storageDir) MigrationSink(live bool, container container, snapshots []*
 	return rsyncMigrationSink(live, container, snapshots, conn, srcIdmap, op, containerOnly)
 }
 
+// quotaProjectID returns the project quota ID used for the given volume. It is
+// derived from the volume's database ID, offset to stay clear of project IDs
+// commonly used by administrators.
+func (s *storageDir) quotaProjectID(volumeName string, volumeType int) (uint32, error) {
+	volumeID, err := s.s.DB.StoragePoolVolumeGetTypeID(volumeName, volumeType, s.poolID)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(volumeID + 10000), nil
+}
+
+func (s *storageDir) ContainerExportOptimized(c container, snapshots []container, dir string) error {
+	return fmt.Errorf("Optimized exports aren't supported by the dir storage driver")
+}
+
+func (s *storageDir) ContainerImportOptimized(c container, snapshots []container, dir string) error {
+	return fmt.Errorf("Optimized exports aren't supported by the dir storage driver")
+}
+
 func (s *storageDir) StorageEntitySetQuota(volumeType int, size int64, data interface{}) error {
-	return fmt.Errorf("the directory container backend doesn't support quotas")
+	logger.Debugf(`Setting DIR quota for "%s"`, s.volume.Name)
+
+	if volumeType != storagePoolVolumeTypeContainer {
+		return fmt.Errorf("the directory backend only supports quotas on containers")
+	}
+
+	c := data.(container)
+	containerMntPoint := getContainerMountPoint(s.pool.Name, c.Name())
+
+	ok, err := quota.Supported(containerMntPoint)
+	if err != nil || !ok {
+		// Removing a quota which can't be set isn't an error
+		if size == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("the directory container backend doesn't support quotas")
+	}
+
+	projectID, err := s.quotaProjectID(c.Name(), volumeType)
+	if err != nil {
+		return err
+	}
+
+	// Tag the whole container tree with the project, new files will
+	// inherit it from their parent directory.
+	currentID, err := quota.GetProject(containerMntPoint)
+	if err != nil {
+		return err
+	}
+
+	if currentID != projectID {
+		err = quota.SetProject(containerMntPoint, projectID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = quota.SetProjectQuota(containerMntPoint, projectID, size)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf(`Set DIR quota for "%s"`, s.volume.Name)
+	return nil
 }
 
 func (s *storageDir) StoragePoolResources() (*api.ResourcesStoragePool, error) {
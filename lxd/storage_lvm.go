@@ -11,7 +11,9 @@ import (
 
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/cancel"
 	"github.com/lxc/lxd/shared/idmap"
+	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -456,6 +458,38 @@ func (s *storageLvm) StoragePoolUmount() (bool, error) {
 	return true, nil
 }
 
+// lvmPoolReleaseLoop deactivates the volume group of loop-file backed LVM
+// storage pools and lets the kernel detach the loop device backing it.
+// StoragePoolMount() will set it up again.
+func (s *storageLvm) lvmPoolReleaseLoop() error {
+	source := s.pool.Config["source"]
+	if !filepath.IsAbs(source) || shared.IsBlockdevPath(source) {
+		return nil
+	}
+
+	poolName := s.getOnDiskPoolName()
+	msg, err := shared.TryRunCommand("vgchange", "-an", poolName)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate LVM volume group \"%s\": %s", poolName, msg)
+	}
+
+	loopF, err := prepareLoopDev(source, 0)
+	if err != nil {
+		return err
+	}
+	defer loopF.Close()
+
+	// The loop device will go away once the last reference to it is
+	// closed.
+	err = setAutoclearOnLoopDev(int(loopF.Fd()))
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Released loop device of LVM storage pool \"%s\".", s.pool.Name)
+	return nil
+}
+
 func (s *storageLvm) StoragePoolVolumeCreate() error {
 	logger.Infof("Creating LVM storage volume \"%s\" on storage pool \"%s\".", s.volume.Name, s.pool.Name)
 	tryUndo := true
@@ -928,7 +962,7 @@ func (s *storageLvm) ContainerCreate(container container) error {
 	return nil
 }
 
-func (s *storageLvm) ContainerCreateFromImage(container container, fingerprint string) error {
+func (s *storageLvm) ContainerCreateFromImage(container container, fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	logger.Debugf("Creating LVM storage volume for container \"%s\" on storage pool \"%s\".", s.volume.Name, s.pool.Name)
 
 	tryUndo := true
@@ -938,9 +972,9 @@ func (s *storageLvm) ContainerCreateFromImage(container container, fingerprint s
 
 	var err error
 	if s.useThinpool {
-		err = s.containerCreateFromImageThinLv(container, fingerprint)
+		err = s.containerCreateFromImageThinLv(container, fingerprint, tracker, canceler)
 	} else {
-		err = s.containerCreateFromImageLv(container, fingerprint)
+		err = s.containerCreateFromImageLv(container, fingerprint, tracker, canceler)
 	}
 	if err != nil {
 		logger.Errorf(`Failed to create LVM storage volume for `+
@@ -1399,7 +1433,25 @@ func (s *storageLvm) ContainerRestore(target container, source container) error
 }
 
 func (s *storageLvm) ContainerGetUsage(container container) (int64, error) {
-	return -1, fmt.Errorf("the LVM container backend doesn't support quotas")
+	// Thin logical volumes know how much of the thin pool they consume.
+	if s.useThinpool {
+		containerLvmName := containerNameToLVName(container.Name())
+		containerLvmPath := getLvmDevPath(s.getOnDiskPoolName(), storagePoolVolumeAPIEndpointContainers, containerLvmName)
+		return lvmGetLVUsage(containerLvmPath)
+	}
+
+	// Otherwise fallback to the usage of the mounted filesystem.
+	containerMntPoint := getContainerMountPoint(s.pool.Name, container.Name())
+	if !shared.IsMountPoint(containerMntPoint) {
+		return -1, fmt.Errorf("the LVM container backend can only report usage of mounted containers")
+	}
+
+	res, err := storageResource(containerMntPoint)
+	if err != nil {
+		return -1, err
+	}
+
+	return int64(res.Space.Used), nil
 }
 
 func (s *storageLvm) ContainerSnapshotCreate(snapshotContainer container, sourceContainer container) error {
@@ -1564,7 +1616,7 @@ func (s *storageLvm) ContainerSnapshotCreateEmpty(snapshotContainer container) e
 	return nil
 }
 
-func (s *storageLvm) ImageCreate(fingerprint string) error {
+func (s *storageLvm) ImageCreate(fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	logger.Debugf("Creating LVM storage volume for image \"%s\" on storage pool \"%s\".", fingerprint, s.pool.Name)
 
 	tryUndo := true
@@ -1627,7 +1679,7 @@ func (s *storageLvm) ImageCreate(fingerprint string) error {
 		}
 
 		imagePath := shared.VarPath("images", fingerprint)
-		err = unpackImage(imagePath, imageMntPoint, storageTypeLvm, s.s.OS.RunningInUserNS)
+		err = unpackImage(imagePath, imageMntPoint, storageTypeLvm, s.s.OS.RunningInUserNS, tracker, canceler)
 		if err != nil {
 			return err
 		}
@@ -1740,6 +1792,14 @@ func (s *storageLvm) MigrationSink(live bool, container container, snapshots []*
 	return rsyncMigrationSink(live, container, snapshots, conn, srcIdmap, op, containerOnly)
 }
 
+func (s *storageLvm) ContainerExportOptimized(c container, snapshots []container, dir string) error {
+	return fmt.Errorf("Optimized exports aren't supported by the lvm storage driver")
+}
+
+func (s *storageLvm) ContainerImportOptimized(c container, snapshots []container, dir string) error {
+	return fmt.Errorf("Optimized exports aren't supported by the lvm storage driver")
+}
+
 func (s *storageLvm) StorageEntitySetQuota(volumeType int, size int64, data interface{}) error {
 	logger.Debugf(`Setting LVM quota for "%s"`, s.volume.Name)
 
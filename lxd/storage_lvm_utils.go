@@ -10,6 +10,8 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/cancel"
+	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
 )
@@ -435,7 +437,7 @@ func (s *storageLvm) copyContainer(target container, source container) error {
 	return nil
 }
 
-func (s *storageLvm) containerCreateFromImageLv(c container, fp string) error {
+func (s *storageLvm) containerCreateFromImageLv(c container, fp string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	containerName := c.Name()
 
 	err := s.ContainerCreate(c)
@@ -461,7 +463,7 @@ func (s *storageLvm) containerCreateFromImageLv(c container, fp string) error {
 
 	imagePath := shared.VarPath("images", fp)
 	containerMntPoint := getContainerMountPoint(s.pool.Name, containerName)
-	err = unpackImage(imagePath, containerMntPoint, storageTypeLvm, s.s.OS.RunningInUserNS)
+	err = unpackImage(imagePath, containerMntPoint, storageTypeLvm, s.s.OS.RunningInUserNS, tracker, canceler)
 	if err != nil {
 		logger.Errorf(`Failed to unpack image "%s" into non-thinpool `+
 			`LVM storage volume "%s" for container "%s" on `+
@@ -478,7 +480,7 @@ func (s *storageLvm) containerCreateFromImageLv(c container, fp string) error {
 	return nil
 }
 
-func (s *storageLvm) containerCreateFromImageThinLv(c container, fp string) error {
+func (s *storageLvm) containerCreateFromImageThinLv(c container, fp string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	poolName := s.getOnDiskPoolName()
 	// Check if the image already exists.
 	imageLvmDevPath := getLvmDevPath(poolName, storagePoolVolumeAPIEndpointImages, fp)
@@ -512,7 +514,7 @@ func (s *storageLvm) containerCreateFromImageThinLv(c container, fp string) erro
 		}
 
 		if !ok {
-			imgerr = s.ImageCreate(fp)
+			imgerr = s.ImageCreate(fp, tracker, canceler)
 		}
 
 		lxdStorageMapLock.Lock()
@@ -655,6 +657,32 @@ func lvmGetLVSize(lvPath string) (string, error) {
 	return detectedSize, nil
 }
 
+// lvmGetLVUsage returns the number of bytes allocated in the thin pool by the
+// given thin logical volume.
+func lvmGetLVUsage(lvPath string) (int64, error) {
+	msg, err := shared.TryRunCommand("lvs", "--noheadings", "-o", "lv_size,data_percent", "--nosuffix", "--units", "b", lvPath)
+	if err != nil {
+		return -1, fmt.Errorf("failed to retrieve usage of logical volume: %s: %s", string(msg), err)
+	}
+
+	fields := strings.Fields(msg)
+	if len(fields) != 2 {
+		return -1, fmt.Errorf("unexpected output from lvs: %s", msg)
+	}
+
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return -1, err
+	}
+
+	percent, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return -1, err
+	}
+
+	return int64(float64(size) * percent / 100), nil
+}
+
 func storageLVMThinpoolExists(vgName string, poolName string) (bool, error) {
 	output, err := shared.RunCommand("vgs", "--noheadings", "-o", "lv_attr", fmt.Sprintf("%s/%s", vgName, poolName))
 	if err != nil {
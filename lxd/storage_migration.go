@@ -8,6 +8,7 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/cancel"
 	"github.com/lxc/lxd/shared/idmap"
 )
 
@@ -149,6 +150,12 @@ func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, c
 		return fmt.Errorf("the container's root device is missing the pool property")
 	}
 
+	// Let rsync get killed if the migration is cancelled
+	var canceler *cancel.Canceler
+	if op != nil {
+		canceler = op.canceler
+	}
+
 	isDirBackend := container.Storage().GetStorageType() == storageTypeDir
 	if isDirBackend {
 		if !containerOnly {
@@ -173,7 +180,7 @@ func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, c
 				}
 
 				wrapper := StorageProgressWriter(op, "fs_progress", s.Name())
-				if err := RsyncRecv(shared.AddSlash(s.Path()), conn, wrapper); err != nil {
+				if err := RsyncRecv(shared.AddSlash(s.Path()), conn, wrapper, canceler); err != nil {
 					return err
 				}
 
@@ -185,7 +192,7 @@ func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, c
 		}
 
 		wrapper := StorageProgressWriter(op, "fs_progress", container.Name())
-		err = RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper)
+		err = RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper, canceler)
 		if err != nil {
 			return err
 		}
@@ -207,7 +214,7 @@ func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, c
 				}
 
 				wrapper := StorageProgressWriter(op, "fs_progress", snap.GetName())
-				err := RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper)
+				err := RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper, canceler)
 				if err != nil {
 					return err
 				}
@@ -225,7 +232,7 @@ func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, c
 		}
 
 		wrapper := StorageProgressWriter(op, "fs_progress", container.Name())
-		err = RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper)
+		err = RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper, canceler)
 		if err != nil {
 			return err
 		}
@@ -234,7 +241,7 @@ func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, c
 	if live {
 		/* now receive the final sync */
 		wrapper := StorageProgressWriter(op, "fs_progress", container.Name())
-		err := RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper)
+		err := RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper, canceler)
 		if err != nil {
 			return err
 		}
@@ -6,7 +6,9 @@ import (
 	"github.com/gorilla/websocket"
 
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/cancel"
 	"github.com/lxc/lxd/shared/idmap"
+	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -117,7 +119,7 @@ func (s *storageMock) ContainerCreate(container container) error {
 }
 
 func (s *storageMock) ContainerCreateFromImage(
-	container container, imageFingerprint string) error {
+	container container, imageFingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 
 	return nil
 }
@@ -188,7 +190,7 @@ func (s *storageMock) ContainerSnapshotCreateEmpty(snapshotContainer container)
 	return nil
 }
 
-func (s *storageMock) ImageCreate(fingerprint string) error {
+func (s *storageMock) ImageCreate(fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	return nil
 }
 
@@ -219,6 +221,14 @@ func (s *storageMock) MigrationSink(live bool, container container, snapshots []
 	return nil
 }
 
+func (s *storageMock) ContainerExportOptimized(c container, snapshots []container, dir string) error {
+	return nil
+}
+
+func (s *storageMock) ContainerImportOptimized(c container, snapshots []container, dir string) error {
+	return nil
+}
+
 func (s *storageMock) StorageEntitySetQuota(volumeType int, size int64, data interface{}) error {
 	return nil
 }
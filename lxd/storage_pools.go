@@ -81,6 +81,12 @@ func storagePoolsPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("No driver provided"))
 	}
 
+	// Check that the storage pool does not already exist.
+	_, err = d.db.StoragePoolGetID(req.Name)
+	if err == nil {
+		return Conflict
+	}
+
 	err = storagePoolCreateInternal(d.State(), req.Name, req.Description, req.Driver, req.Config)
 	if err != nil {
 		return InternalError(err)
@@ -157,9 +163,9 @@ func storagePoolPut(d *Daemon, r *http.Request) Response {
 func storagePoolPatch(d *Daemon, r *http.Request) Response {
 	poolName := mux.Vars(r)["name"]
 
-	// Get the existing network
+	// Get the existing storage pool.
 	_, dbInfo, err := d.db.StoragePoolGet(poolName)
-	if dbInfo != nil {
+	if err != nil {
 		return SmartError(err)
 	}
 
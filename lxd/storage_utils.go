@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
@@ -364,3 +365,37 @@ func storageResource(path string) (*api.ResourcesStoragePool, error) {
 
 	return &res, nil
 }
+
+// storageLocalCopy copies a directory, sharing the data blocks with the source
+// through reflinks when the backing filesystem supports them (btrfs and XFS)
+// and falling back to a regular rsync copy otherwise. As cp never deletes
+// anything, reflinks are only used when the destination is empty, rsync
+// taking care of removing the stale files from an existing one.
+func storageLocalCopy(source string, dest string, bwlimit string) (string, error) {
+	empty := true
+	if shared.PathExists(dest) {
+		var err error
+		empty, err = shared.PathIsEmpty(dest)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	fsType, err := util.FilesystemDetect(source)
+	if err == nil && empty && shared.StringInSlice(fsType, []string{"btrfs", "xfs"}) {
+		err := os.MkdirAll(dest, 0755)
+		if err != nil {
+			return "", err
+		}
+
+		output, err := shared.RunCommand("cp", "-a", "--reflink=always", fmt.Sprintf("%s.", shared.AddSlash(source)), dest)
+		if err == nil {
+			return output, nil
+		}
+
+		// Whatever got copied will be fixed up by rsync.
+		logger.Debugf("Reflink copy of \"%s\" failed, falling back to rsync: %s", source, output)
+	}
+
+	return rsyncLocalCopy(source, dest, bwlimit)
+}
@@ -16,7 +16,9 @@ import (
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/cancel"
 	"github.com/lxc/lxd/shared/idmap"
+	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
 
 	"github.com/pborman/uuid"
@@ -366,6 +368,28 @@ func (s *storageZfs) StoragePoolUmount() (bool, error) {
 	return true, nil
 }
 
+// zfsPoolExportLoop exports loop-file backed zpools so that the loop device
+// backing them gets released. StoragePoolCheck() will import them again.
+func (s *storageZfs) zfsPoolExportLoop() error {
+	source := s.pool.Config["source"]
+	if !filepath.IsAbs(source) || shared.IsBlockdevPath(source) {
+		return nil
+	}
+
+	poolName := strings.Split(s.getOnDiskPoolName(), "/")[0]
+	if !zfsFilesystemEntityExists(poolName, "") {
+		return nil
+	}
+
+	msg, err := shared.RunCommand("zpool", "export", poolName)
+	if err != nil {
+		return fmt.Errorf("failed to export ZFS storage pool \"%s\": %s", poolName, msg)
+	}
+
+	logger.Debugf("Exported ZFS storage pool \"%s\".", poolName)
+	return nil
+}
+
 func (s *storageZfs) StoragePoolVolumeCreate() error {
 	logger.Infof("Creating ZFS storage volume \"%s\" on storage pool \"%s\".", s.volume.Name, s.pool.Name)
 
@@ -814,7 +838,7 @@ func (s *storageZfs) ContainerCreate(container container) error {
 	return nil
 }
 
-func (s *storageZfs) ContainerCreateFromImage(container container, fingerprint string) error {
+func (s *storageZfs) ContainerCreateFromImage(container container, fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	logger.Debugf("Creating ZFS storage volume for container \"%s\" on storage pool \"%s\".", s.volume.Name, s.pool.Name)
 
 	containerPath := container.Path()
@@ -838,7 +862,7 @@ func (s *storageZfs) ContainerCreateFromImage(container container, fingerprint s
 
 		var imgerr error
 		if !zfsFilesystemEntityExists(poolName, fsImage) {
-			imgerr = s.ImageCreate(fingerprint)
+			imgerr = s.ImageCreate(fingerprint, tracker, canceler)
 		}
 
 		lxdStorageMapLock.Lock()
@@ -1812,7 +1836,7 @@ func (s *storageZfs) ContainerSnapshotCreateEmpty(snapshotContainer container) e
 // - mark new zfs volume images/<fingerprint> readonly
 // - remove mountpoint property from zfs volume images/<fingerprint>
 // - create read-write snapshot from zfs volume images/<fingerprint>
-func (s *storageZfs) ImageCreate(fingerprint string) error {
+func (s *storageZfs) ImageCreate(fingerprint string, tracker *ioprogress.ProgressTracker, canceler *cancel.Canceler) error {
 	logger.Debugf("Creating ZFS storage volume for image \"%s\" on storage pool \"%s\".", fingerprint, s.pool.Name)
 
 	poolName := s.getOnDiskPoolName()
@@ -1907,7 +1931,7 @@ func (s *storageZfs) ImageCreate(fingerprint string) error {
 	}
 
 	// Unpack the image into the temporary mountpoint.
-	err = unpackImage(imagePath, tmpImageDir, storageTypeZfs, s.s.OS.RunningInUserNS)
+	err = unpackImage(imagePath, tmpImageDir, storageTypeZfs, s.s.OS.RunningInUserNS, tracker, canceler)
 	if err != nil {
 		return err
 	}
@@ -2333,6 +2357,102 @@ func (s *storageZfs) MigrationSink(live bool, container container, snapshots []*
 	return nil
 }
 
+func (s *storageZfs) ContainerExportOptimized(c container, snapshots []container, dir string) error {
+	poolName := s.getOnDiskPoolName()
+	zfsName := fmt.Sprintf("containers/%s", c.Name())
+
+	err := os.MkdirAll(filepath.Join(dir, "snapshots"), 0700)
+	if err != nil {
+		return err
+	}
+
+	// Send the snapshots from the oldest to the newest, each one relative
+	// to the previous one, so that they keep sharing their blocks
+	prev := ""
+	for _, snap := range snapshots {
+		_, snapName, _ := containerGetParentAndSnapshotName(snap.Name())
+		zfsSnapName := fmt.Sprintf("snapshot-%s", snapName)
+		err := zfsSendToFile(poolName, zfsName, zfsSnapName, prev, filepath.Join(dir, "snapshots", fmt.Sprintf("%s.bin", snapName)))
+		if err != nil {
+			return err
+		}
+
+		prev = zfsSnapName
+	}
+
+	// Then the container itself, through a temporary snapshot
+	exportSnapName := fmt.Sprintf("export-%s", uuid.NewRandom().String())
+	err = zfsPoolVolumeSnapshotCreate(poolName, zfsName, exportSnapName)
+	if err != nil {
+		return err
+	}
+	defer zfsPoolVolumeSnapshotDestroy(poolName, zfsName, exportSnapName)
+
+	return zfsSendToFile(poolName, zfsName, exportSnapName, prev, filepath.Join(dir, "container.bin"))
+}
+
+func (s *storageZfs) ContainerImportOptimized(c container, snapshots []container, dir string) error {
+	poolName := s.getOnDiskPoolName()
+	zfsName := fmt.Sprintf("containers/%s", c.Name())
+
+	// zfs receive needs the (empty) filesystem to be unmounted
+	containerMntPoint := getContainerMountPoint(s.pool.Name, c.Name())
+	if shared.IsMountPoint(containerMntPoint) {
+		err := zfsUmount(poolName, zfsName, containerMntPoint)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(snapshots) > 0 {
+		snapshotMntPointSymlinkTarget := shared.VarPath("storage-pools", s.pool.Name, "snapshots", c.Name())
+		snapshotMntPointSymlink := shared.VarPath("snapshots", c.Name())
+		if !shared.PathExists(snapshotMntPointSymlink) {
+			err := os.Symlink(snapshotMntPointSymlinkTarget, snapshotMntPointSymlink)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, snap := range snapshots {
+		_, snapName, _ := containerGetParentAndSnapshotName(snap.Name())
+		err := zfsReceiveFromFile(poolName, fmt.Sprintf("%s@snapshot-%s", zfsName, snapName), filepath.Join(dir, "snapshots", fmt.Sprintf("%s.bin", snapName)))
+		if err != nil {
+			return err
+		}
+
+		snapshotMntPoint := getSnapshotMountPoint(s.pool.Name, snap.Name())
+		if !shared.PathExists(snapshotMntPoint) {
+			err := os.MkdirAll(snapshotMntPoint, 0700)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := zfsReceiveFromFile(poolName, zfsName, filepath.Join(dir, "container.bin"))
+	if err != nil {
+		return err
+	}
+
+	// Drop the temporary snapshot the container got sent from
+	zfsSnapshots, err := zfsPoolListSnapshots(poolName, zfsName)
+	if err != nil {
+		return err
+	}
+
+	for _, snap := range zfsSnapshots {
+		if strings.HasPrefix(snap, "export-") {
+			zfsPoolVolumeSnapshotDestroy(poolName, zfsName, snap)
+		}
+	}
+
+	// zfs receive may or may not have mounted it already
+	zfsMount(poolName, zfsName)
+	return nil
+}
+
 func (s *storageZfs) StorageEntitySetQuota(volumeType int, size int64, data interface{}) error {
 	logger.Debugf(`Setting ZFS quota for "%s"`, s.volume.Name)
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -331,6 +332,52 @@ func zfsPoolVolumeSnapshotCreate(pool string, path string, name string) error {
 	return nil
 }
 
+// zfsSendToFile writes the send stream of the given snapshot to a file,
+// relative to the parent snapshot if one is given.
+func zfsSendToFile(pool string, path string, name string, parent string, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	args := []string{"send"}
+	if parent != "" {
+		args = append(args, "-i", fmt.Sprintf("%s/%s@%s", pool, path, parent))
+	}
+	args = append(args, fmt.Sprintf("%s/%s@%s", pool, path, name))
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("zfs", args...)
+	cmd.Stdout = f
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		logger.Errorf("zfs send failed: %s.", stderr.String())
+		return fmt.Errorf("Failed to send ZFS snapshot: %s", stderr.String())
+	}
+
+	return f.Close()
+}
+
+// zfsReceiveFromFile receives the send stream in the given file into the
+// filesystem or snapshot, overwriting it.
+func zfsReceiveFromFile(pool string, path string, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	output, err := shared.RunCommandWithStdin(f, "zfs", "receive", "-F", "-u", fmt.Sprintf("%s/%s", pool, path))
+	if err != nil {
+		logger.Errorf("zfs receive failed: %s.", output)
+		return fmt.Errorf("Failed to receive ZFS snapshot: %s", output)
+	}
+
+	return nil
+}
+
 func zfsPoolVolumeSnapshotDestroy(pool, path string, name string) error {
 	output, err := shared.RunCommand(
 		"zfs",
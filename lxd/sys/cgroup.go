@@ -18,6 +18,7 @@ func (s *OS) initCGroup() {
 		&s.CGroupMemoryController,
 		&s.CGroupNetPrioController,
 		&s.CGroupPidsController,
+		&s.CGroupHugetlbController,
 		&s.CGroupSwapAccounting,
 	}
 	for i, flag := range flags {
@@ -48,5 +49,6 @@ var cGroups = []struct {
 	{"memory", cGroupMissing("memory controller", "memory limits will be ignored")},
 	{"net_prio", cGroupMissing("network class controller", "network limits will be ignored")},
 	{"pids", cGroupMissing("pids controller", "process limits will be ignored")},
+	{"hugetlb", cGroupMissing("hugetlb controller", "hugepage limits will be ignored")},
 	{"memory/memory.memsw.limit_in_bytes", cGroupDisabled("memory swap accounting", "swap limits will be ignored")},
 }
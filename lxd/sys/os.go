@@ -38,6 +38,7 @@ type OS struct {
 	CGroupMemoryController  bool
 	CGroupNetPrioController bool
 	CGroupPidsController    bool
+	CGroupHugetlbController bool
 	CGroupSwapAccounting    bool
 
 	MockMode bool // If true some APIs will be mocked (for testing)
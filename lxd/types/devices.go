@@ -100,7 +100,12 @@ func (old Devices) Update(newlist Devices) (map[string]Device, map[string]Device
 			continue
 		}
 
-		updateDiff = deviceEqualsDiffKeys(oldDevice, newDevice)
+		// Record the changed keys of every updated device
+		for _, k := range deviceEqualsDiffKeys(oldDevice, newDevice) {
+			if !shared.StringInSlice(k, updateDiff) {
+				updateDiff = append(updateDiff, k)
+			}
+		}
 
 		for _, k := range []string{"limits.max", "limits.read", "limits.write", "limits.egress", "limits.ingress", "ipv4.address", "ipv6.address"} {
 			delete(oldDevice, k)
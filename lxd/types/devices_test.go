@@ -20,3 +20,38 @@ func TestSortableDevices(t *testing.T) {
 		t.Error("devices sorted incorrectly")
 	}
 }
+
+func TestDevicesUpdateDiff(t *testing.T) {
+	old := Devices{
+		"eth0": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr0"},
+		"eth1": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr0"},
+	}
+
+	new := Devices{
+		"eth0": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr0", "limits.ingress": "10Mbit"},
+		"eth1": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr0", "ipv4.address": "10.0.0.2"},
+	}
+
+	rmlist, addlist, updatelist, updateDiff := old.Update(new)
+	if len(rmlist) != 0 || len(addlist) != 0 {
+		t.Error("devices shouldn't have been removed or added")
+	}
+
+	if len(updatelist) != 2 {
+		t.Errorf("expected 2 updated devices, got %d", len(updatelist))
+	}
+
+	for _, key := range []string{"limits.ingress", "ipv4.address"} {
+		found := false
+		for _, k := range updateDiff {
+			if k == key {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			t.Errorf("%s missing from the update diff", key)
+		}
+	}
+}
@@ -1,13 +1,33 @@
 package util
 
 import (
-	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
+	"io"
 
 	"golang.org/x/crypto/scrypt"
 )
 
+// PasswordHash returns the salted scrypt hash of the given password, hex
+// encoded, in the form expected by PasswordCheck.
+func PasswordHash(password string) (string, error) {
+	salt := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := scrypt.Key([]byte(password), salt, 1<<14, 8, 1, 64)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(append(salt, hash...)), nil
+}
+
+// PasswordCheck validates the provided password against the encoded secret
 func PasswordCheck(secret, password string) error {
 	// No password set
 	if secret == "" {
@@ -20,13 +40,17 @@ func PasswordCheck(secret, password string) error {
 		return err
 	}
 
+	if len(buff) <= 32 {
+		return fmt.Errorf("Invalid password hash")
+	}
+
 	salt := buff[0:32]
 	hash, err := scrypt.Key([]byte(password), salt, 1<<14, 8, 1, 64)
 	if err != nil {
 		return err
 	}
 
-	if !bytes.Equal(hash, buff[32:]) {
+	if subtle.ConstantTimeCompare(hash, buff[32:]) != 1 {
 		return fmt.Errorf("Bad password provided")
 	}
 
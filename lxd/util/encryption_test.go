@@ -0,0 +1,18 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordCheck(t *testing.T) {
+	secret, err := util.PasswordHash("foo")
+	assert.NoError(t, err)
+
+	assert.NoError(t, util.PasswordCheck(secret, "foo"))
+	assert.Error(t, util.PasswordCheck(secret, "bar"))
+	assert.Error(t, util.PasswordCheck("", "foo"))
+	assert.Error(t, util.PasswordCheck("abcd", "foo"))
+}
@@ -2,6 +2,8 @@ package util
 
 import (
 	"fmt"
+	"io/ioutil"
+	"strings"
 
 	"github.com/lxc/lxd/shared"
 )
@@ -16,3 +18,28 @@ func LoadModule(module string) error {
 	_, err := shared.RunCommand("modprobe", module)
 	return err
 }
+
+// HasFilesystem checks whether the running kernel supports the given
+// filesystem type, loading its module if needed.
+func HasFilesystem(filesystem string) bool {
+	// Attempt to load the module, it may not be built-in
+	LoadModule(filesystem)
+
+	content, err := ioutil.ReadFile("/proc/filesystems")
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[len(fields)-1] == filesystem {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"time"
+)
+
+// Cluster represents the clustering status of a LXD server
+//
+// API extension: clustering
+type Cluster struct {
+	ServerName string `json:"server_name" yaml:"server_name"`
+	Enabled    bool   `json:"enabled" yaml:"enabled"`
+}
+
+// ClusterPut represents the fields required to bootstrap a new cluster or to
+// join an existing one
+//
+// API extension: clustering
+type ClusterPut struct {
+	Cluster `yaml:",inline"`
+
+	// Only set when joining an existing cluster
+	ClusterAddress     string `json:"cluster_address" yaml:"cluster_address"`
+	ClusterCertificate string `json:"cluster_certificate" yaml:"cluster_certificate"`
+	ClusterToken       string `json:"cluster_token" yaml:"cluster_token"`
+}
+
+// ClusterMembersPost represents the fields required to create a join token
+// for a new cluster member
+//
+// API extension: clustering
+type ClusterMembersPost struct {
+	ServerName string `json:"server_name" yaml:"server_name"`
+}
+
+// ClusterMember represents a member of a LXD cluster
+//
+// API extension: clustering
+type ClusterMember struct {
+	ServerName string    `json:"server_name" yaml:"server_name"`
+	URL        string    `json:"url" yaml:"url"`
+	Status     string    `json:"status" yaml:"status"`
+	Message    string    `json:"message" yaml:"message"`
+	JoinedAt   time.Time `json:"joined_at" yaml:"joined_at"`
+
+	// API extension: cluster_evacuation
+	Evacuated bool `json:"evacuated" yaml:"evacuated"`
+}
+
+// ClusterMemberStatePost represents the fields required to evacuate a
+// cluster member or to restore it
+//
+// API extension: cluster_evacuation
+type ClusterMemberStatePost struct {
+	Action string `json:"action" yaml:"action"`
+	Mode   string `json:"mode" yaml:"mode"`
+}
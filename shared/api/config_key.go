@@ -0,0 +1,11 @@
+package api
+
+// ConfigKey represents a well-known container configuration key
+// API extension: config_keys
+type ConfigKey struct {
+	Name        string `json:"name" yaml:"name"`
+	Type        string `json:"type" yaml:"type"`
+	Default     string `json:"default" yaml:"default"`
+	LiveUpdate  bool   `json:"live_update" yaml:"live_update"`
+	Description string `json:"description" yaml:"description"`
+}
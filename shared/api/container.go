@@ -30,6 +30,9 @@ type ContainerPost struct {
 
 	// API extension: container_push_target
 	Target *ContainerPostTarget `json:"target" yaml:"target"`
+
+	// API extension: container_pool_move
+	Pool string `json:"pool,omitempty" yaml:"pool,omitempty"`
 }
 
 // ContainerPostTarget represents the migration target host and operation
@@ -70,6 +73,9 @@ type Container struct {
 
 	// API extension: container_last_used_at
 	LastUsedAt time.Time `json:"last_used_at" yaml:"last_used_at"`
+
+	// API extension: clustering
+	Location string `json:"location" yaml:"location"`
 }
 
 // Writable converts a full Container struct into a ContainerPut struct (filters read-only fields)
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"time"
+)
+
+// ContainerFileEntry represents an entry of a directory of a LXD container
+//
+// API extension: file_list_details
+type ContainerFileEntry struct {
+	Name         string    `json:"name" yaml:"name"`
+	Type         string    `json:"type" yaml:"type"`
+	Size         int64     `json:"size" yaml:"size"`
+	UID          int64     `json:"uid" yaml:"uid"`
+	GID          int64     `json:"gid" yaml:"gid"`
+	Mode         int       `json:"mode" yaml:"mode"`
+	ModifiedDate time.Time `json:"modified_at" yaml:"modified_at"`
+
+	// API extension: file_tarball
+	Target string `json:"target,omitempty" yaml:"target,omitempty"`
+}
@@ -40,6 +40,9 @@ type ContainerStateMemory struct {
 	UsagePeak     int64 `json:"usage_peak" yaml:"usage_peak"`
 	SwapUsage     int64 `json:"swap_usage" yaml:"swap_usage"`
 	SwapUsagePeak int64 `json:"swap_usage_peak" yaml:"swap_usage_peak"`
+
+	// API extension: container_oom_events
+	OOMKills int64 `json:"oom_kills" yaml:"oom_kills"`
 }
 
 // ContainerStateNetwork represents the network information section of a LXD container's state
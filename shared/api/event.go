@@ -0,0 +1,24 @@
+package api
+
+// EventLifecycle represents the metadata of a "lifecycle" event, sent when
+// an action is performed on an object
+//
+// API extension: event_lifecycle
+type EventLifecycle struct {
+	Action  string                 `json:"action" yaml:"action"`
+	Source  string                 `json:"source" yaml:"source"`
+	Context map[string]interface{} `json:"context,omitempty" yaml:"context,omitempty"`
+
+	// API extension: event_lifecycle_requestor
+	Requestor *EventLifecycleRequestor `json:"requestor,omitempty" yaml:"requestor,omitempty"`
+}
+
+// EventLifecycleRequestor represents the client which caused a lifecycle
+// event, unset for actions initiated by LXD or the container itself
+//
+// API extension: event_lifecycle_requestor
+type EventLifecycleRequestor struct {
+	Protocol string `json:"protocol" yaml:"protocol"`
+	Username string `json:"username" yaml:"username"`
+	Address  string `json:"address" yaml:"address"`
+}
@@ -44,3 +44,46 @@ type Network struct {
 func (network *Network) Writable() NetworkPut {
 	return network.NetworkPut
 }
+
+// NetworkState represents the network state
+//
+// API extension: network_state
+type NetworkState struct {
+	Addresses []NetworkStateAddress `json:"addresses" yaml:"addresses"`
+	Counters  NetworkStateCounters  `json:"counters" yaml:"counters"`
+	Hwaddr    string                `json:"hwaddr" yaml:"hwaddr"`
+	Mtu       int                   `json:"mtu" yaml:"mtu"`
+	State     string                `json:"state" yaml:"state"`
+	Type      string                `json:"type" yaml:"type"`
+}
+
+// NetworkStateAddress represents a network address
+//
+// API extension: network_state
+type NetworkStateAddress struct {
+	Family  string `json:"family" yaml:"family"`
+	Address string `json:"address" yaml:"address"`
+	Netmask string `json:"netmask" yaml:"netmask"`
+	Scope   string `json:"scope" yaml:"scope"`
+}
+
+// NetworkStateCounters represents packet counters
+//
+// API extension: network_state
+type NetworkStateCounters struct {
+	BytesReceived   int64 `json:"bytes_received" yaml:"bytes_received"`
+	BytesSent       int64 `json:"bytes_sent" yaml:"bytes_sent"`
+	PacketsReceived int64 `json:"packets_received" yaml:"packets_received"`
+	PacketsSent     int64 `json:"packets_sent" yaml:"packets_sent"`
+}
+
+// NetworkLease represents a DHCP lease
+//
+// API extension: network_leases
+type NetworkLease struct {
+	Hostname  string `json:"hostname" yaml:"hostname"`
+	Hwaddr    string `json:"hwaddr" yaml:"hwaddr"`
+	Address   string `json:"address" yaml:"address"`
+	Type      string `json:"type" yaml:"type"`
+	Container string `json:"container" yaml:"container"`
+}
@@ -4,6 +4,20 @@ import (
 	"time"
 )
 
+// LXD operation classes
+const (
+	// OperationClassTask is a background task, only carrying a status
+	OperationClassTask = "task"
+
+	// OperationClassWebsocket is a task requiring the client to connect to
+	// websockets, whose secrets are in the operation's metadata
+	OperationClassWebsocket = "websocket"
+
+	// OperationClassToken is a temporary credential, whose secret is in the
+	// operation's metadata
+	OperationClassToken = "token"
+)
+
 // Operation represents a LXD background operation
 type Operation struct {
 	ID         string                 `json:"id" yaml:"id"`
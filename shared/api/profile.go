@@ -33,3 +33,18 @@ type Profile struct {
 func (profile *Profile) Writable() ProfilePut {
 	return profile.ProfilePut
 }
+
+// ProfileUsage represents what a LXD profile contributes to a container using it
+// API extension: profile_usage
+type ProfileUsage struct {
+	Container string `json:"container" yaml:"container"`
+
+	// Config keys and devices from the profile which are in effect
+	Config  map[string]string            `json:"config" yaml:"config"`
+	Devices map[string]map[string]string `json:"devices" yaml:"devices"`
+
+	// Config keys and devices from the profile which are overridden by a
+	// later profile or by the container itself
+	OverriddenConfig  []string `json:"overridden_config" yaml:"overridden_config"`
+	OverriddenDevices []string `json:"overridden_devices" yaml:"overridden_devices"`
+}
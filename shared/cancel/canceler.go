@@ -3,25 +3,36 @@ package cancel
 import (
 	"fmt"
 	"net/http"
+	"os/exec"
+	"sync"
 )
 
 // A struct to track canceleation
 type Canceler struct {
+	lock        sync.Mutex
 	reqChCancel map[*http.Request]chan struct{}
+	cmds        map[*exec.Cmd]struct{}
 }
 
 func NewCanceler() *Canceler {
 	c := Canceler{}
 	c.reqChCancel = make(map[*http.Request]chan struct{})
+	c.cmds = make(map[*exec.Cmd]struct{})
 	return &c
 }
 
 func (c *Canceler) Cancelable() bool {
-	return len(c.reqChCancel) > 0
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.reqChCancel) > 0 || len(c.cmds) > 0
 }
 
 func (c *Canceler) Cancel() error {
-	if !c.Cancelable() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.reqChCancel) == 0 && len(c.cmds) == 0 {
 		return fmt.Errorf("This operation cannot be canceled at this time")
 	}
 
@@ -29,6 +40,12 @@ func (c *Canceler) Cancel() error {
 		close(ch)
 		delete(c.reqChCancel, req)
 	}
+
+	for cmd := range c.cmds {
+		cmd.Process.Kill()
+		delete(c.cmds, cmd)
+	}
+
 	return nil
 }
 
@@ -36,17 +53,39 @@ func CancelableDownload(c *Canceler, client *http.Client, req *http.Request) (*h
 	chDone := make(chan bool)
 	chCancel := make(chan struct{})
 	if c != nil {
+		c.lock.Lock()
 		c.reqChCancel[req] = chCancel
+		c.lock.Unlock()
 	}
 	req.Cancel = chCancel
 
 	go func() {
 		<-chDone
 		if c != nil {
+			c.lock.Lock()
 			delete(c.reqChCancel, req)
+			c.lock.Unlock()
 		}
 	}()
 
 	resp, err := client.Do(req)
 	return resp, chDone, err
 }
+
+// CancelableCommand makes a started command get killed when the operation is
+// cancelled. The returned function must be called once the command is done.
+func CancelableCommand(c *Canceler, cmd *exec.Cmd) func() {
+	if c == nil {
+		return func() {}
+	}
+
+	c.lock.Lock()
+	c.cmds[cmd] = struct{}{}
+	c.lock.Unlock()
+
+	return func() {
+		c.lock.Lock()
+		delete(c.cmds, cmd)
+		c.lock.Unlock()
+	}
+}
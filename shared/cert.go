@@ -6,6 +6,7 @@
 package shared
 
 import (
+	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -101,6 +102,23 @@ func (c *CertInfo) PublicKey() []byte {
 	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: data})
 }
 
+// PrivateKey is a convenience to encode the underlying private key to ASCII.
+func (c *CertInfo) PrivateKey() []byte {
+	switch key := c.KeyPair().PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		data := x509.MarshalPKCS1PrivateKey(key)
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: data})
+	case *ecdsa.PrivateKey:
+		data, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: data})
+	}
+
+	return nil
+}
+
 // CertKind defines the kind of certificate to generate from scratch in
 // KeyPairAndCA when it's not there.
 //
@@ -77,3 +77,22 @@ func TestGenerateMemCert(t *testing.T) {
 		t.Errorf("GenerateMemCert returned a cert with Type %q not \"RSA PRIVATE KEY\"", block.Type)
 	}
 }
+
+// The private key of a key pair can be encoded back to PEM.
+func TestCertInfo_PrivateKey(t *testing.T) {
+	cert := shared.TestingKeyPair()
+
+	block, _ := pem.Decode(cert.PrivateKey())
+	if block == nil {
+		t.Fatal("failed to decode private key")
+	}
+
+	if block.Type != "RSA PRIVATE KEY" {
+		t.Errorf("unexpected PEM block type: %s", block.Type)
+	}
+
+	_, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Errorf("failed to parse private key: %v", err)
+	}
+}
@@ -2,6 +2,9 @@ package shared
 
 import (
 	"fmt"
+	"math"
+	"net/url"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -83,121 +86,522 @@ func IsOneOf(value string, valid []string) error {
 	return nil
 }
 
-func IsAny(value string) error {
+// IsSize validates a size in bytes (e.g. 512MB or 2GiB).
+func IsSize(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := ParseByteSizeString(value)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
-// KnownContainerConfigKeys maps all fully defined, well-known config keys
-// to an appropriate checker function, which validates whether or not a
-// given value is syntactically legal.
-var KnownContainerConfigKeys = map[string]func(value string) error{
-	"boot.autostart":             IsBool,
-	"boot.autostart.delay":       IsInt64,
-	"boot.autostart.priority":    IsInt64,
-	"boot.stop.priority":         IsInt64,
-	"boot.host_shutdown_timeout": IsInt64,
-
-	"limits.cpu": IsAny,
-	"limits.cpu.allowance": func(value string) error {
-		if value == "" {
-			return nil
-		}
+// isKernelLimit validates a resource limit, either a single value or a
+// "soft:hard" pair, each being a number or "unlimited".
+func isKernelLimit(value string) error {
+	if value == "" {
+		return nil
+	}
 
-		if strings.HasSuffix(value, "%") {
-			// Percentage based allocation
-			_, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
-			if err != nil {
-				return err
-			}
+	fields := strings.Split(value, ":")
+	if len(fields) > 2 {
+		return fmt.Errorf("Invalid limit: %s", value)
+	}
 
-			return nil
+	limits := []uint64{}
+	for _, field := range fields {
+		if field == "unlimited" {
+			limits = append(limits, math.MaxUint64)
+			continue
 		}
 
-		// Time based allocation
-		fields := strings.SplitN(value, "/", 2)
-		if len(fields) != 2 {
-			return fmt.Errorf("Invalid allowance: %s", value)
+		limit, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid limit: %s", value)
 		}
 
-		_, err := strconv.Atoi(strings.TrimSuffix(fields[0], "ms"))
-		if err != nil {
-			return err
+		limits = append(limits, limit)
+	}
+
+	if len(limits) == 2 && limits[0] > limits[1] {
+		return fmt.Errorf("Invalid limit: %s (soft limit is above the hard limit)", value)
+	}
+
+	return nil
+}
+
+func IsAny(value string) error {
+	return nil
+}
+
+// isCPULimit validates limits.cpu, either a number of CPUs to load-balance
+// across or a set of CPUs to pin to.
+func isCPULimit(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	// A number of CPUs to load-balance across
+	count, err := strconv.Atoi(value)
+	if err == nil {
+		if count < 1 {
+			return fmt.Errorf("Invalid number of CPUs: %s", value)
 		}
 
-		_, err = strconv.Atoi(strings.TrimSuffix(fields[1], "ms"))
+		return nil
+	}
+
+	// A set of CPUs to pin to
+	_, err = ParseCpuset(value)
+	return err
+}
+
+// isCPUAllowance validates limits.cpu.allowance, either a percentage or a
+// quota/period pair of durations.
+func isCPUAllowance(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if strings.HasSuffix(value, "%") {
+		// Percentage based allocation
+		percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
 		if err != nil {
 			return err
 		}
 
+		if percent < 1 {
+			return fmt.Errorf("Invalid allowance: %s (must be at least 1%%)", value)
+		}
+
 		return nil
-	},
-	"limits.cpu.priority": IsPriority,
+	}
 
-	"limits.disk.priority": IsPriority,
+	// Time based allocation
+	fields := strings.SplitN(value, "/", 2)
+	if len(fields) != 2 {
+		return fmt.Errorf("Invalid allowance: %s", value)
+	}
 
-	"limits.memory": func(value string) error {
-		if value == "" {
-			return nil
-		}
+	quota, err := strconv.Atoi(strings.TrimSuffix(fields[0], "ms"))
+	if err != nil {
+		return err
+	}
 
-		if strings.HasSuffix(value, "%") {
-			_, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
-			if err != nil {
-				return err
-			}
+	period, err := strconv.Atoi(strings.TrimSuffix(fields[1], "ms"))
+	if err != nil {
+		return err
+	}
 
-			return nil
-		}
+	// The kernel requires a quota of at least 1ms and a period
+	// between 1ms and 1s
+	if quota < 1 {
+		return fmt.Errorf("Invalid allowance: %s (quota must be at least 1ms)", value)
+	}
+
+	if period < 1 || period > 1000 {
+		return fmt.Errorf("Invalid allowance: %s (period must be between 1ms and 1000ms)", value)
+	}
 
-		_, err := ParseByteSizeString(value)
+	return nil
+}
+
+func isCPUNodes(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := ParseCpuset(value)
+	return err
+}
+
+// isMemoryLimit validates limits.memory, either a percentage of the host's
+// memory or a size in bytes.
+func isMemoryLimit(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
 		if err != nil {
 			return err
 		}
 
+		if percent < 1 || percent > 100 {
+			return fmt.Errorf("Invalid memory limit: %s (must be between 1%% and 100%%)", value)
+		}
+
+		return nil
+	}
+
+	_, err := ParseByteSizeString(value)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// isHookPath validates a hooks.* key, the absolute path of a host command or
+// the URL of an HTTP callback.
+func isHookPath(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("Invalid hook: %s (bad URL)", value)
+		}
+
 		return nil
+	}
+
+	if !filepath.IsAbs(value) {
+		return fmt.Errorf("Invalid hook: %s (must be an absolute path or an HTTP URL)", value)
+	}
+
+	return nil
+}
+
+func isMemoryEnforce(value string) error {
+	return IsOneOf(value, []string{"soft", "hard"})
+}
+
+// ContainerConfigKey describes a well-known container config key.
+type ContainerConfigKey struct {
+	Type        string
+	Default     string
+	LiveUpdate  bool
+	Description string
+
+	// Validator checks whether or not a given value is syntactically legal
+	Validator func(value string) error
+}
+
+// KnownContainerConfigKeys maps all fully defined, well-known config keys
+// to their description, including an appropriate checker function which
+// validates whether or not a given value is syntactically legal.
+var KnownContainerConfigKeys = map[string]ContainerConfigKey{
+	"boot.autostart": {
+		Type:        "boolean",
+		Description: "Always start the container when LXD starts (if not set, restore last state)",
+		Validator:   IsBool,
 	},
-	"limits.memory.enforce": func(value string) error {
-		return IsOneOf(value, []string{"soft", "hard"})
+	"boot.autostart.delay": {
+		Type:        "integer",
+		Default:     "0",
+		Description: "Number of seconds to wait after the container started before starting the next one",
+		Validator:   IsInt64,
+	},
+	"boot.autostart.priority": {
+		Type:        "integer",
+		Default:     "0",
+		Description: "What order to start the containers in (starting with highest)",
+		Validator:   IsInt64,
+	},
+	"boot.stop.priority": {
+		Type:        "integer",
+		Default:     "0",
+		Description: "What order to shutdown the containers (starting with highest)",
+		Validator:   IsInt64,
+	},
+	"boot.host_shutdown_timeout": {
+		Type:        "integer",
+		Default:     "30",
+		LiveUpdate:  true,
+		Description: "Seconds to wait for container to shutdown before it is force stopped",
+		Validator:   IsInt64,
 	},
-	"limits.memory.swap":          IsBool,
-	"limits.memory.swap.priority": IsPriority,
-
-	"limits.network.priority": IsPriority,
 
-	"limits.processes": IsInt64,
+	"hooks.pre-start": {
+		Type:        "string",
+		LiveUpdate:  true,
+		Description: "Host command or HTTP callback to run before the container starts, failing it aborts the start",
+		Validator:   isHookPath,
+	},
+	"hooks.post-start": {
+		Type:        "string",
+		LiveUpdate:  true,
+		Description: "Host command or HTTP callback to run once the container has started",
+		Validator:   isHookPath,
+	},
+	"hooks.post-stop": {
+		Type:        "string",
+		LiveUpdate:  true,
+		Description: "Host command or HTTP callback to run once the container has stopped",
+		Validator:   isHookPath,
+	},
 
-	"linux.kernel_modules": IsAny,
+	"limits.cpu": {
+		Type:        "string",
+		LiveUpdate:  true,
+		Description: "Number or range of CPUs to expose to the container",
+		Validator:   isCPULimit,
+	},
+	"limits.cpu.allowance": {
+		Type:        "string",
+		Default:     "100%",
+		LiveUpdate:  true,
+		Description: "How much of the CPU can be used. Can be a percentage (e.g. 50%) for a soft limit or hard a chunk of time (25ms/100ms)",
+		Validator:   isCPUAllowance,
+	},
+	"limits.cpu.nodes": {
+		Type:        "string",
+		LiveUpdate:  true,
+		Description: "NUMA nodes (e.g. `0` or `0,2-3`) the container's CPUs and memory are restricted to",
+		Validator:   isCPUNodes,
+	},
+	"limits.cpu.priority": {
+		Type:        "integer",
+		Default:     "10",
+		LiveUpdate:  true,
+		Description: "CPU scheduling priority compared to other containers sharing the same CPUs (overcommit) (integer between 0 and 10)",
+		Validator:   IsPriority,
+	},
+	"limits.disk.priority": {
+		Type:        "integer",
+		Default:     "5",
+		LiveUpdate:  true,
+		Description: "When under load, how much priority to give to the container's I/O requests (integer between 0 and 10)",
+		Validator:   IsPriority,
+	},
+	"limits.memory": {
+		Type:        "string",
+		LiveUpdate:  true,
+		Description: "Percentage of the host's memory or fixed value in bytes (supports kB, MB, GB, TB, PB and EB suffixes as well as their KiB, MiB, ... equivalents)",
+		Validator:   isMemoryLimit,
+	},
+	"limits.memory.enforce": {
+		Type:        "string",
+		Default:     "hard",
+		LiveUpdate:  true,
+		Description: "If hard, container can't exceed its memory limit. If soft, the container can exceed its memory limit when extra host memory is available.",
+		Validator:   isMemoryEnforce,
+	},
+	"limits.memory.swap": {
+		Type:        "boolean",
+		Default:     "true",
+		LiveUpdate:  true,
+		Description: "Whether to allow some of the container's memory to be swapped out to disk",
+		Validator:   IsBool,
+	},
+	"limits.memory.swap.priority": {
+		Type:        "integer",
+		Default:     "10",
+		LiveUpdate:  true,
+		Description: "The higher this is set, the least likely the container is to be swapped to disk (integer between 0 and 10)",
+		Validator:   IsPriority,
+	},
+	"limits.network.priority": {
+		Type:        "integer",
+		Default:     "0",
+		LiveUpdate:  true,
+		Description: "When under load, how much priority to give to the container's network requests (integer between 0 and 10)",
+		Validator:   IsPriority,
+	},
+	"limits.processes": {
+		Type:        "integer",
+		LiveUpdate:  true,
+		Description: "Maximum number of processes that can run in the container",
+		Validator:   IsInt64,
+	},
+	"limits.hugepages.64KB": {
+		Type:        "string",
+		LiveUpdate:  true,
+		Description: "Maximum amount of memory (in bytes, supports the usual suffixes) that can be allocated through 64KB hugepages",
+		Validator:   IsSize,
+	},
+	"limits.hugepages.1MB": {
+		Type:        "string",
+		LiveUpdate:  true,
+		Description: "Maximum amount of memory (in bytes, supports the usual suffixes) that can be allocated through 1MB hugepages",
+		Validator:   IsSize,
+	},
+	"limits.hugepages.2MB": {
+		Type:        "string",
+		LiveUpdate:  true,
+		Description: "Maximum amount of memory (in bytes, supports the usual suffixes) that can be allocated through 2MB hugepages",
+		Validator:   IsSize,
+	},
+	"limits.hugepages.1GB": {
+		Type:        "string",
+		LiveUpdate:  true,
+		Description: "Maximum amount of memory (in bytes, supports the usual suffixes) that can be allocated through 1GB hugepages",
+		Validator:   IsSize,
+	},
 
-	"migration.incremental.memory":            IsBool,
-	"migration.incremental.memory.iterations": IsUint32,
-	"migration.incremental.memory.goal":       IsUint32,
+	"linux.kernel_modules": {
+		Type:        "string",
+		LiveUpdate:  true,
+		Description: "Comma separated list of kernel modules to load before starting the container",
+		Validator:   IsAny,
+	},
 
-	"security.nesting":    IsBool,
-	"security.privileged": IsBool,
-	"security.devlxd":     IsBool,
+	"nvidia.runtime": {
+		Type:        "boolean",
+		Default:     "false",
+		Description: "Pass the host NVIDIA and CUDA runtime libraries into the container",
+		Validator:   IsBool,
+	},
+	"nvidia.driver.capabilities": {
+		Type:        "string",
+		Default:     "compute,utility",
+		Description: "What driver capabilities the container needs (sets libnvidia-container NVIDIA_DRIVER_CAPABILITIES)",
+		Validator:   IsAny,
+	},
 
-	"security.idmap.base":     IsUint32,
-	"security.idmap.isolated": IsBool,
-	"security.idmap.size":     IsUint32,
+	"migration.incremental.memory": {
+		Type:        "boolean",
+		Default:     "false",
+		LiveUpdate:  true,
+		Description: "Incremental memory transfer of the container's memory to reduce downtime.",
+		Validator:   IsBool,
+	},
+	"migration.incremental.memory.iterations": {
+		Type:        "integer",
+		Default:     "10",
+		LiveUpdate:  true,
+		Description: "Maximum number of transfer operations to go through before stopping the container.",
+		Validator:   IsUint32,
+	},
+	"migration.incremental.memory.goal": {
+		Type:        "integer",
+		Default:     "70",
+		LiveUpdate:  true,
+		Description: "Percentage of memory to have in sync before stopping the container.",
+		Validator:   IsUint32,
+	},
 
-	"security.syscalls.blacklist_default": IsBool,
-	"security.syscalls.blacklist_compat":  IsBool,
-	"security.syscalls.blacklist":         IsAny,
-	"security.syscalls.whitelist":         IsAny,
+	"security.nesting": {
+		Type:        "boolean",
+		Default:     "false",
+		LiveUpdate:  true,
+		Description: "Support running lxd (nested) inside the container",
+		Validator:   IsBool,
+	},
+	"security.privileged": {
+		Type:        "boolean",
+		Default:     "false",
+		Description: "Runs the container in privileged mode",
+		Validator:   IsBool,
+	},
+	"security.devlxd": {
+		Type:        "boolean",
+		Default:     "true",
+		LiveUpdate:  true,
+		Description: "Controls the presence of /dev/lxd in the container",
+		Validator:   IsBool,
+	},
+	"security.apparmor": {
+		Type:        "boolean",
+		Default:     "true",
+		Description: "Confine the container with its own generated AppArmor profile (raw.apparmor is appended to it). If false, the container runs unconfined",
+		Validator:   IsBool,
+	},
+	"security.idmap.base": {
+		Type:        "integer",
+		Description: "The base host ID to use for the allocation (overrides auto-detection)",
+		Validator:   IsUint32,
+	},
+	"security.idmap.isolated": {
+		Type:        "boolean",
+		Default:     "false",
+		Description: "Use an idmap for this container that is unique among containers with isolated set.",
+		Validator:   IsBool,
+	},
+	"security.idmap.size": {
+		Type:        "integer",
+		Description: "The size of the idmap to use",
+		Validator:   IsUint32,
+	},
+	"security.syscalls.blacklist_default": {
+		Type:        "boolean",
+		Default:     "true",
+		Description: "Enables the default syscall blacklist",
+		Validator:   IsBool,
+	},
+	"security.syscalls.blacklist_compat": {
+		Type:        "boolean",
+		Default:     "false",
+		Description: "On x86_64 this enables blocking of compat_* syscalls, it is a no-op on other arches",
+		Validator:   IsBool,
+	},
+	"security.syscalls.blacklist": {
+		Type:        "string",
+		Description: "A '\\n' separated list of syscalls to blacklist",
+		Validator:   IsAny,
+	},
+	"security.syscalls.whitelist": {
+		Type:        "string",
+		Description: "A '\\n' separated list of syscalls to whitelist (mutually exclusive with security.syscalls.blacklist*)",
+		Validator:   IsAny,
+	},
 
 	// Caller is responsible for full validation of any raw.* value
-	"raw.apparmor": IsAny,
-	"raw.lxc":      IsAny,
-	"raw.seccomp":  IsAny,
-	"raw.idmap":    IsAny,
+	"raw.apparmor": {
+		Type:        "blob",
+		LiveUpdate:  true,
+		Description: "Apparmor profile entries to be appended to the generated profile",
+		Validator:   IsAny,
+	},
+	"raw.lxc": {
+		Type:        "blob",
+		Description: "Raw LXC configuration to be appended to the generated one",
+		Validator:   IsAny,
+	},
+	"raw.seccomp": {
+		Type:        "blob",
+		Description: "Raw Seccomp configuration",
+		Validator:   IsAny,
+	},
+	"raw.idmap": {
+		Type:        "blob",
+		Description: "Raw idmap configuration (e.g. \"both 1000 1000\")",
+		Validator:   IsAny,
+	},
 
-	"volatile.apply_template":   IsAny,
-	"volatile.base_image":       IsAny,
-	"volatile.last_state.idmap": IsAny,
-	"volatile.last_state.power": IsAny,
-	"volatile.idmap.next":       IsAny,
-	"volatile.idmap.base":       IsAny,
-	"volatile.apply_quota":      IsAny,
+	"volatile.apply_template": {
+		Type:        "string",
+		Description: "The name of a template hook which should be triggered upon next startup",
+		Validator:   IsAny,
+	},
+	"volatile.base_image": {
+		Type:        "string",
+		Description: "The hash of the image the container was created from, if any.",
+		Validator:   IsAny,
+	},
+	"volatile.last_state.idmap": {
+		Type:        "string",
+		Description: "Serialized container uid/gid map",
+		Validator:   IsAny,
+	},
+	"volatile.last_state.power": {
+		Type:        "string",
+		Description: "Container state as of last host shutdown",
+		Validator:   IsAny,
+	},
+	"volatile.idmap.next": {
+		Type:        "string",
+		Description: "The idmap to use next time the container starts",
+		Validator:   IsAny,
+	},
+	"volatile.idmap.base": {
+		Type:        "integer",
+		Description: "The first id in the container's primary idmap range",
+		Validator:   IsAny,
+	},
+	"volatile.apply_quota": {
+		Type:        "string",
+		Description: "Disk quota to be applied on next container start",
+		Validator:   IsAny,
+	},
 }
 
 // ConfigKeyChecker returns a function that will check whether or not
@@ -207,8 +611,8 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 // be done by the caller.  User defined keys are always considered to
 // be valid, e.g. user.* and environment.* keys.
 func ConfigKeyChecker(key string) (func(value string) error, error) {
-	if f, ok := KnownContainerConfigKeys[key]; ok {
-		return f, nil
+	if k, ok := KnownContainerConfigKeys[key]; ok {
+		return k.Validator, nil
 	}
 
 	if strings.HasPrefix(key, "volatile.") {
@@ -226,6 +630,12 @@ func ConfigKeyChecker(key string) (func(value string) error, error) {
 	}
 
 	if strings.HasPrefix(key, "environment.") {
+		// The variable name must be usable as NAME=VALUE
+		name := strings.TrimPrefix(key, "environment.")
+		if name == "" || strings.ContainsAny(name, "= \t\n\x00") {
+			return nil, fmt.Errorf("Invalid environment variable name: %s", name)
+		}
+
 		return IsAny, nil
 	}
 
@@ -239,8 +649,26 @@ func ConfigKeyChecker(key string) (func(value string) error, error) {
 
 	if strings.HasPrefix(key, "limits.kernel.") &&
 		(len(key) > len("limits.kernel.")) {
+		return isKernelLimit, nil
+	}
+
+	if strings.HasPrefix(key, "linux.sysctl.") &&
+		(len(key) > len("linux.sysctl.")) {
 		return IsAny, nil
 	}
 
 	return nil, fmt.Errorf("Unknown configuration key: %s", key)
 }
+
+// ConfigKeyLiveUpdate returns whether or not a change to the given config
+// key can be applied to a running container. Keys which aren't fully
+// defined, e.g. user.* or limits.kernel.* keys, only take effect on the
+// next container start.
+func ConfigKeyLiveUpdate(key string) bool {
+	k, ok := KnownContainerConfigKeys[key]
+	if !ok {
+		return false
+	}
+
+	return k.LiveUpdate
+}
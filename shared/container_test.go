@@ -0,0 +1,40 @@
+package shared
+
+import (
+	"testing"
+)
+
+func TestKnownContainerConfigKeys(t *testing.T) {
+	for name, key := range KnownContainerConfigKeys {
+		if key.Type == "" {
+			t.Errorf("Key %s has no type", name)
+		}
+
+		if key.Description == "" {
+			t.Errorf("Key %s has no description", name)
+		}
+
+		if key.Validator == nil {
+			t.Errorf("Key %s has no validator", name)
+			continue
+		}
+
+		if key.Validator(key.Default) != nil {
+			t.Errorf("Key %s rejects its own default value %q", name, key.Default)
+		}
+	}
+}
+
+func TestConfigKeyLiveUpdate(t *testing.T) {
+	if !ConfigKeyLiveUpdate("limits.memory") {
+		t.Error("limits.memory should be live updatable")
+	}
+
+	if ConfigKeyLiveUpdate("security.privileged") {
+		t.Error("security.privileged shouldn't be live updatable")
+	}
+
+	if ConfigKeyLiveUpdate("user.foo") {
+		t.Error("user.foo shouldn't be live updatable")
+	}
+}
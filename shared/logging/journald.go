@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Path of the socket of the systemd journal, for its native protocol.
+var journaldSocket = "/run/systemd/journal/socket"
+
+// Syslog priorities of the log levels, as expected by the journal.
+var journaldPriorities = map[log.Lvl]int{
+	log.LvlCrit:  2,
+	log.LvlError: 3,
+	log.LvlWarn:  4,
+	log.LvlInfo:  6,
+	log.LvlDebug: 7,
+}
+
+// JournaldHandler is a log handler sending the messages to the systemd
+// journal, with their context as journal fields.
+type JournaldHandler struct {
+	conn *net.UnixConn
+	tag  string
+}
+
+// NewJournaldHandler connects to the systemd journal, tagging the messages
+// with the given identifier.
+func NewJournaldHandler(tag string) (*JournaldHandler, error) {
+	addr := &net.UnixAddr{Name: journaldSocket, Net: "unixgram"}
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JournaldHandler{conn: conn, tag: tag}, nil
+}
+
+// Log sends the given record to the journal.
+func (h *JournaldHandler) Log(r *log.Record) error {
+	buffer := &bytes.Buffer{}
+
+	journaldField(buffer, "MESSAGE", r.Msg)
+	journaldField(buffer, "PRIORITY", fmt.Sprintf("%d", journaldPriorities[r.Lvl]))
+	if h.tag != "" {
+		journaldField(buffer, "SYSLOG_IDENTIFIER", h.tag)
+	}
+
+	for i := 0; i+1 < len(r.Ctx); i += 2 {
+		key, ok := r.Ctx[i].(string)
+		if !ok {
+			continue
+		}
+
+		name := journaldFieldName(key)
+		if name == "" {
+			continue
+		}
+
+		journaldField(buffer, name, fmt.Sprintf("%+v", formatShared(r.Ctx[i+1])))
+	}
+
+	_, err := h.conn.Write(buffer.Bytes())
+	return err
+}
+
+// Close closes the connection to the journal.
+func (h *JournaldHandler) Close() error {
+	return h.conn.Close()
+}
+
+// Append a field to the given journal entry, using the binary form for values
+// spanning several lines.
+func journaldField(buffer *bytes.Buffer, name string, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buffer, "%s=%s\n", name, value)
+		return
+	}
+
+	buffer.WriteString(name)
+	buffer.WriteByte('\n')
+	binary.Write(buffer, binary.LittleEndian, uint64(len(value)))
+	buffer.WriteString(value)
+	buffer.WriteByte('\n')
+}
+
+// Turn a context key into a valid journal field name: upper case letters,
+// digits and underscores, not starting with an underscore. Returns an empty
+// string if there's nothing left.
+func journaldFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+
+		return '_'
+	}, key)
+
+	return strings.TrimLeft(name, "_0123456789")
+}
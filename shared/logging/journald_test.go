@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+func TestJournaldHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-journald-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	defer func(path string) { journaldSocket = path }(journaldSocket)
+	journaldSocket = socket
+
+	handler, err := NewJournaldHandler("lxd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+
+	logger := log.New()
+	logger.SetHandler(handler)
+	logger.Warn("Container started", log.Ctx{"container": "c1", "request-id": "foo\nbar"})
+
+	buffer := make([]byte, 1024)
+	n, err := listener.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := string(buffer[:n])
+
+	for _, field := range []string{"MESSAGE=Container started\n", "PRIORITY=4\n", "SYSLOG_IDENTIFIER=lxd\n", "CONTAINER=c1\n"} {
+		if !strings.Contains(entry, field) {
+			t.Errorf("Missing %q in %q", field, entry)
+		}
+	}
+
+	// Values spanning several lines use the binary form
+	if !strings.Contains(entry, "REQUEST_ID\n\x07\x00\x00\x00\x00\x00\x00\x00foo\nbar\n") {
+		t.Errorf("Missing request ID in %q", entry)
+	}
+}
+
+func TestJournaldFieldName(t *testing.T) {
+	cases := map[string]string{
+		"err":        "ERR",
+		"request-id": "REQUEST_ID",
+		"_private":   "PRIVATE",
+		"1st":        "ST",
+		"!!":         "",
+	}
+
+	for key, name := range cases {
+		if journaldFieldName(key) != name {
+			t.Errorf("Expected %q for %q, got %q", name, key, journaldFieldName(key))
+		}
+	}
+}
@@ -2,6 +2,7 @@ package logging
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
@@ -14,8 +15,19 @@ import (
 
 // GetLogger returns a logger suitable for using as logger.Log.
 func GetLogger(syslog string, logfile string, verbose bool, debug bool, customHandler log.Handler) (logger.Logger, error) {
+	handler, err := GetHandler(syslog, logfile, verbose, debug, customHandler)
+	if err != nil {
+		return nil, err
+	}
+
 	Log := log.New()
+	Log.SetHandler(handler)
+
+	return Log, nil
+}
 
+// GetHandler returns the handler used by the logger returned by GetLogger.
+func GetHandler(syslog string, logfile string, verbose bool, debug bool, customHandler log.Handler) (log.Handler, error) {
 	var handlers []log.Handler
 	var syshandler log.Handler
 
@@ -77,9 +89,115 @@ func GetLogger(syslog string, logfile string, verbose bool, debug bool, customHa
 		handlers = append(handlers, customHandler)
 	}
 
-	Log.SetHandler(log.MultiHandler(handlers...))
+	return log.MultiHandler(handlers...), nil
+}
+
+// Options holds the settings of the handler returned by NewHandler.
+type Options struct {
+	// Lowest level of the messages to log: "debug", "info" (the
+	// default), "warn" or "error".
+	Level string
 
-	return Log, nil
+	// Where to log the messages: any of "stderr", "file", "syslog" and
+	// "journald".
+	Targets []string
+
+	// Format of the messages: "logfmt" (the default) or "json".
+	Format string
+
+	// Path of the log file, for the "file" target.
+	Logfile string
+
+	// Identifier of the program logging, for the "syslog" and "journald"
+	// targets.
+	Tag string
+}
+
+// Handler is a log handler sending messages to several targets, which can be
+// closed once it's not used anymore.
+type Handler struct {
+	log.Handler
+	closers []io.Closer
+}
+
+// Close closes the files and connections used by the handler.
+func (h *Handler) Close() error {
+	var firstErr error
+	for _, closer := range h.closers {
+		err := closer.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// NewHandler returns a handler sending the messages of at least the given
+// level to all the given targets, in the given format.
+func NewHandler(options Options) (*Handler, error) {
+	level := log.LvlInfo
+	if options.Level != "" {
+		var err error
+		level, err = log.LvlFromString(options.Level)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var format log.Format
+	switch options.Format {
+	case "", "logfmt":
+		format = LogfmtFormat()
+	case "json":
+		format = log.JsonFormat()
+	default:
+		return nil, fmt.Errorf("Unknown log format: %s", options.Format)
+	}
+
+	result := &Handler{}
+	handlers := []log.Handler{}
+	for _, target := range options.Targets {
+		var handler log.Handler
+		var closer io.Closer
+		var err error
+
+		switch target {
+		case "stderr":
+			handler = log.StreamHandler(os.Stderr, format)
+		case "file":
+			var file *os.File
+			file, err = os.OpenFile(options.Logfile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+			if err == nil {
+				handler = log.StreamHandler(file, format)
+				closer = file
+			}
+		case "syslog":
+			handler, closer, err = newSyslogHandler(options.Tag, format)
+		case "journald":
+			var journald *JournaldHandler
+			journald, err = NewJournaldHandler(options.Tag)
+			if err == nil {
+				handler = journald
+				closer = journald
+			}
+		default:
+			err = fmt.Errorf("Unknown log target: %s", target)
+		}
+		if err != nil {
+			result.Close()
+			return nil, err
+		}
+
+		handlers = append(handlers, handler)
+		if closer != nil {
+			result.closers = append(result.closers, closer)
+		}
+	}
+
+	result.Handler = log.LvlFilterHandler(level, log.MultiHandler(handlers...))
+
+	return result, nil
 }
 
 // SetLogger installs the given logger as global logger. It returns a function
@@ -3,6 +3,10 @@
 package logging
 
 import (
+	"io"
+	"log/syslog"
+	"strings"
+
 	log "github.com/lxc/lxd/shared/log15"
 )
 
@@ -22,3 +26,31 @@ func getSystemHandler(syslog string, debug bool, format log.Format) log.Handler
 
 	return nil
 }
+
+// newSyslogHandler returns a handler writing messages to syslog, along with
+// the connection to close once done.
+func newSyslogHandler(tag string, format log.Format) (log.Handler, io.Closer, error) {
+	writer, err := syslog.New(syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	handler := log.FuncHandler(func(r *log.Record) error {
+		message := strings.TrimSpace(string(format.Format(r)))
+
+		switch r.Lvl {
+		case log.LvlCrit:
+			return writer.Crit(message)
+		case log.LvlError:
+			return writer.Err(message)
+		case log.LvlWarn:
+			return writer.Warning(message)
+		case log.LvlInfo:
+			return writer.Info(message)
+		default:
+			return writer.Debug(message)
+		}
+	})
+
+	return handler, writer, nil
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Only the messages of at least the given level get written, in the given
+// format.
+func TestNewHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-logging-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logfile := filepath.Join(dir, "lxd.log")
+	handler, err := NewHandler(Options{Level: "warn", Targets: []string{"file"}, Format: "json", Logfile: logfile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := log.New()
+	logger.SetHandler(handler)
+	logger.Info("Not logged")
+	logger.Warn("Logged", log.Ctx{"container": "c1"})
+
+	err = handler.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(logfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected one message, got %q", lines)
+	}
+
+	if !strings.Contains(lines[0], `"msg":"Logged"`) || !strings.Contains(lines[0], `"container":"c1"`) {
+		t.Errorf("Unexpected message: %s", lines[0])
+	}
+}
+
+func TestNewHandler_Invalid(t *testing.T) {
+	_, err := NewHandler(Options{Level: "loud"})
+	if err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+
+	_, err = NewHandler(Options{Format: "xml"})
+	if err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+
+	_, err = NewHandler(Options{Targets: []string{"printer"}})
+	if err == nil {
+		t.Error("Expected an error for an unknown target")
+	}
+}
@@ -3,6 +3,9 @@
 package logging
 
 import (
+	"fmt"
+	"io"
+
 	log "github.com/lxc/lxd/shared/log15"
 )
 
@@ -10,3 +13,8 @@ import (
 func getSystemHandler(syslog string, debug bool, format log.Format) log.Handler {
 	return nil
 }
+
+// newSyslogHandler on Windows fails, there's no syslog.
+func newSyslogHandler(tag string, format log.Format) (log.Handler, io.Closer, error) {
+	return nil, nil, fmt.Errorf("Syslog isn't supported on Windows")
+}
@@ -425,6 +425,52 @@ var WebsocketUpgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
+// Interval between the pings sent by WebsocketKeepAlive
+const websocketPingInterval = 30 * time.Second
+
+// Time after which a peer which doesn't answer pings or doesn't read what's
+// written to it is considered gone
+const websocketPeerTimeout = 2 * time.Minute
+
+// WebsocketKeepAlive sends pings on the given websocket at regular intervals
+// until it gets closed, so that half-open connections get detected. The
+// connection gets closed when the pings can't be written anymore.
+//
+// If readDeadline is true, the connection also gets a read deadline, pushed
+// back at each pong of the peer, so that reads fail if it never answers or
+// stops answering. Since pongs are only processed while reading, this must only
+// be used for connections which are read from continuously.
+func WebsocketKeepAlive(conn *websocket.Conn, readDeadline bool) {
+	if readDeadline {
+		conn.SetReadDeadline(time.Now().Add(websocketPeerTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(websocketPeerTimeout))
+		})
+	}
+
+	go func() {
+		ticker := time.NewTicker(websocketPingInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			deadline := time.Now().Add(websocketPeerTimeout)
+			err := conn.WriteControl(websocket.PingMessage, nil, deadline)
+			if err != nil {
+				logger.Debugf("Failed to ping websocket peer %s: %s", conn.RemoteAddr(), err)
+				conn.Close()
+				return
+			}
+		}
+	}()
+}
+
+// IsWebsocketTimeout returns whether the given error, returned when reading
+// from a websocket, means that the peer stopped answering pings.
+func IsWebsocketTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
 // AllocatePort asks the kernel for a free open port that is ready to use
 func AllocatePort() (int, error) {
 	addr, err := net.ResolveTCPAddr("tcp", "localhost:0")
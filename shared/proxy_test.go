@@ -0,0 +1,41 @@
+package shared
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProxyFromConfig(t *testing.T) {
+	proxy := ProxyFromConfig("https://proxy:3128", "http://proxy:8080", "example.com,.internal")
+
+	cases := map[string]string{
+		"https://images.linuxcontainers.org": "https://proxy:3128",
+		"http://images.linuxcontainers.org":  "http://proxy:8080",
+		"https://example.com:8443":           "",
+		"https://foo.example.com":            "",
+		"https://lxd.internal":               "",
+		"https://localhost:8443":             "",
+		"https://127.0.0.1:8443":             "",
+	}
+
+	for target, expected := range cases {
+		req, err := http.NewRequest("GET", target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		url, err := proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := ""
+		if url != nil {
+			result = url.String()
+		}
+
+		if result != expected {
+			t.Errorf("Proxy for %s is %q, expected %q", target, result, expected)
+		}
+	}
+}
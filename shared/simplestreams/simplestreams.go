@@ -453,38 +453,67 @@ func (s *SimpleStreams) applyAliases(images []api.Image) ([]api.Image, map[strin
 		return &api.ImageAlias{Name: name}
 	}
 
-	architectureName, _ := osarch.ArchitectureGetLocal()
-
+	// Build new lists of aliases from the provided ones
 	newImages := []api.Image{}
+	sources := [][]api.ImageAlias{}
 	for _, image := range images {
-		if image.Aliases != nil {
-			// Build a new list of aliases from the provided ones
-			aliases := image.Aliases
-			image.Aliases = nil
-
-			for _, entry := range aliases {
-				// Short
-				if image.Architecture == architectureName {
-					alias := addAlias(fmt.Sprintf("%s", entry.Name), image.Fingerprint)
-					if alias != nil {
-						image.Aliases = append(image.Aliases, *alias)
-					}
-				}
+		sources = append(sources, image.Aliases)
+		image.Aliases = nil
+		newImages = append(newImages, image)
+	}
 
-				// Medium
-				alias := addAlias(fmt.Sprintf("%s/%s", entry.Name, image.Properties["architecture"]), image.Fingerprint)
+	// Short, pointing to the image of the local architecture, or else of
+	// the first other architecture it can run
+	for _, architecture := range localArchitectures() {
+		for i, image := range newImages {
+			if image.Architecture != architecture {
+				continue
+			}
+
+			for _, entry := range sources[i] {
+				alias := addAlias(entry.Name, image.Fingerprint)
 				if alias != nil {
-					image.Aliases = append(image.Aliases, *alias)
+					newImages[i].Aliases = append(newImages[i].Aliases, *alias)
 				}
 			}
 		}
+	}
 
-		newImages = append(newImages, image)
+	// Medium
+	for i, image := range newImages {
+		for _, entry := range sources[i] {
+			alias := addAlias(fmt.Sprintf("%s/%s", entry.Name, image.Properties["architecture"]), image.Fingerprint)
+			if alias != nil {
+				newImages[i].Aliases = append(newImages[i].Aliases, *alias)
+			}
+		}
 	}
 
 	return newImages, aliases, nil
 }
 
+// Return the names of the architectures the local machine can run, starting
+// with its own.
+func localArchitectures() []string {
+	architectureName, _ := osarch.ArchitectureGetLocal()
+	architectures := []string{architectureName}
+
+	architecture, err := osarch.ArchitectureId(architectureName)
+	if err != nil {
+		return architectures
+	}
+
+	personalities, _ := osarch.ArchitecturePersonalities(architecture)
+	for _, personality := range personalities {
+		name, err := osarch.ArchitectureName(personality)
+		if err == nil {
+			architectures = append(architectures, name)
+		}
+	}
+
+	return architectures
+}
+
 func (s *SimpleStreams) getImages() ([]api.Image, map[string]*api.ImageAliasesEntry, error) {
 	if s.cachedImages != nil && s.cachedAliases != nil {
 		return s.cachedImages, s.cachedAliases, nil
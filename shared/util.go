@@ -225,6 +225,51 @@ func ParseLXDFileHeaders(headers http.Header) (uid int64, gid int64, mode int, t
 	return uid, gid, mode, type_, write
 }
 
+// IsFileXattrAllowed tells whether the extended attribute with the given name
+// may be set through the files API: user attributes, file capabilities and
+// POSIX ACLs.
+func IsFileXattrAllowed(name string) bool {
+	return strings.HasPrefix(name, "user.") || name == "security.capability" || strings.HasPrefix(name, "system.posix_acl_")
+}
+
+// LXDFileXattrsHeader encodes the extended attributes of a file for the
+// X-LXD-xattrs header, as a JSON object of their base64 encoded values.
+func LXDFileXattrsHeader(xattrs map[string]string) (string, error) {
+	values := map[string][]byte{}
+	for name, value := range xattrs {
+		values[name] = []byte(value)
+	}
+
+	buf, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+// ParseLXDFileXattrsHeader decodes the X-LXD-xattrs header, returning nil if
+// it's not set.
+func ParseLXDFileXattrsHeader(headers http.Header) (map[string]string, error) {
+	header := headers.Get("X-LXD-xattrs")
+	if header == "" {
+		return nil, nil
+	}
+
+	values := map[string][]byte{}
+	err := json.Unmarshal([]byte(header), &values)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid X-LXD-xattrs header: %s", err)
+	}
+
+	xattrs := map[string]string{}
+	for name, value := range values {
+		xattrs[name] = string(value)
+	}
+
+	return xattrs, nil
+}
+
 func ReadToJSON(r io.Reader, req interface{}) error {
 	buf, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -681,8 +726,47 @@ func ParseMetadata(metadata interface{}) (map[string]interface{}, error) {
 	return newMetadata, nil
 }
 
-// Parse a size string in bytes (e.g. 200kB or 5GB) into the number of bytes it
-// represents. Supports suffixes up to EB. "" == 0.
+// ParseCpuset parses a cpuset string (e.g. "1,3,5-7") into the list of
+// CPU ids it contains.
+func ParseCpuset(cpu string) ([]int, error) {
+	cpus := []int{}
+	chunks := strings.Split(cpu, ",")
+	for _, chunk := range chunks {
+		chunk = strings.TrimSpace(chunk)
+		if strings.Contains(chunk, "-") {
+			// Range
+			fields := strings.SplitN(chunk, "-", 2)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
+			}
+
+			low, err := strconv.Atoi(fields[0])
+			if err != nil || low < 0 {
+				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
+			}
+
+			high, err := strconv.Atoi(fields[1])
+			if err != nil || high < low {
+				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
+			}
+
+			for i := low; i <= high; i++ {
+				cpus = append(cpus, i)
+			}
+		} else {
+			// Simple entry
+			nr, err := strconv.Atoi(chunk)
+			if err != nil || nr < 0 {
+				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
+			}
+			cpus = append(cpus, nr)
+		}
+	}
+	return cpus, nil
+}
+
+// Parse a size string in bytes (e.g. 200kB, 5GB or 2GiB) into the number of
+// bytes it represents. Supports suffixes up to EB (or EiB). "" == 0.
 func ParseByteSizeString(input string) (int64, error) {
 	suffixLen := 2
 
@@ -696,6 +780,9 @@ func ParseByteSizeString(input string) (int64, error) {
 	} else if (len(input) >= 2) && (input[len(input)-1] == 'B') && unicode.IsNumber(rune(input[len(input)-2])) {
 		// "B" suffix --> bytes.
 		suffixLen = 1
+	} else if (len(input) >= 4) && strings.HasSuffix(input, "iB") {
+		// IEC suffix (KiB, MiB, ...).
+		suffixLen = 3
 	} else if strings.HasSuffix(input, " bytes") {
 		// Backward compatible behaviour in case we talk to a LXD that
 		// still uses GetByteSizeString() that returns "n bytes".
@@ -719,24 +806,24 @@ func ParseByteSizeString(input string) (int64, error) {
 	}
 
 	// The value is already in bytes.
-	if suffixLen != 2 {
+	if suffixLen != 2 && suffixLen != 3 {
 		return valueInt, nil
 	}
 
 	// Figure out the multiplicator
 	multiplicator := int64(0)
 	switch suffix {
-	case "kB":
+	case "kB", "KiB":
 		multiplicator = 1024
-	case "MB":
+	case "MB", "MiB":
 		multiplicator = 1024 * 1024
-	case "GB":
+	case "GB", "GiB":
 		multiplicator = 1024 * 1024 * 1024
-	case "TB":
+	case "TB", "TiB":
 		multiplicator = 1024 * 1024 * 1024 * 1024
-	case "PB":
+	case "PB", "PiB":
 		multiplicator = 1024 * 1024 * 1024 * 1024 * 1024
-	case "EB":
+	case "EB", "EiB":
 		multiplicator = 1024 * 1024 * 1024 * 1024 * 1024 * 1024
 	default:
 		return -1, fmt.Errorf("Unsupported suffix: %s", suffix)
@@ -843,6 +930,24 @@ func RunCommand(name string, arg ...string) (string, error) {
 	return string(output), nil
 }
 
+// RunCommandWithStdin is like RunCommand but feeds the given reader to the
+// command's standard input.
+func RunCommandWithStdin(stdin io.Reader, name string, arg ...string) (string, error) {
+	cmd := exec.Command(name, arg...)
+	cmd.Stdin = stdin
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		err := RunError{
+			msg: fmt.Sprintf("Failed to run: %s %s: %s", name, strings.Join(arg, " "), strings.TrimSpace(string(output))),
+			Err: err,
+		}
+		return string(output), err
+	}
+
+	return string(output), nil
+}
+
 func TryRunCommand(name string, arg ...string) (string, error) {
 	var err error
 	var output string
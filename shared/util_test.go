@@ -105,3 +105,70 @@ func TestReaderToChannel(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCpuset(t *testing.T) {
+	cpus, err := ParseCpuset("1,3,5-7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{1, 3, 5, 6, 7}
+	if fmt.Sprintf("%v", cpus) != fmt.Sprintf("%v", expected) {
+		t.Errorf("%v != %v", cpus, expected)
+	}
+
+	for _, invalid := range []string{"", "a", "3-1", "-1", "1-", "1,,2"} {
+		_, err := ParseCpuset(invalid)
+		if err == nil {
+			t.Errorf("Expected an error for \"%s\"", invalid)
+		}
+	}
+}
+
+func TestParseByteSizeString(t *testing.T) {
+	valid := map[string]int64{
+		"":        0,
+		"512":     512,
+		"512B":    512,
+		"2kB":     2048,
+		"512MB":   512 * 1024 * 1024,
+		"2GiB":    2 * 1024 * 1024 * 1024,
+		"1KiB":    1024,
+		"1 bytes": 1,
+	}
+
+	for input, expected := range valid {
+		value, err := ParseByteSizeString(input)
+		if err != nil {
+			t.Errorf("Unexpected error for \"%s\": %s", input, err)
+			continue
+		}
+
+		if value != expected {
+			t.Errorf("\"%s\": %d != %d", input, value, expected)
+		}
+	}
+
+	for _, input := range []string{"GB", "2XB", "2XiB", "-1MB", "iB"} {
+		_, err := ParseByteSizeString(input)
+		if err == nil {
+			t.Errorf("Expected an error for \"%s\"", input)
+		}
+	}
+}
+
+func TestRunCommandWithStdin(t *testing.T) {
+	output, err := RunCommandWithStdin(bytes.NewBufferString("hello"), "cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output != "hello" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	_, err = RunCommandWithStdin(nil, "false")
+	if err == nil {
+		t.Error("Expected an error from a failing command")
+	}
+}
@@ -86,4 +86,56 @@ var APIExtensions = []string{
 	"infiniband",
 	"maas_network",
 	"devlxd_events",
+	"storage_dir_quota",
+	"container_pool_move",
+	"container_export",
+	"network_static_address",
+	"network_state",
+	"network_leases",
+	"network_vlan_bridged",
+	"container_nic_routed",
+	"container_nic_ipvlan",
+	"network_vlan_sriov",
+	"nvidia_runtime",
+	"container_disk_propagation",
+	"container_disk_shift",
+	"container_cpu_nodes",
+	"container_hugepages",
+	"container_sysctl",
+	"security_apparmor",
+	"privileged_containers_policy",
+	"config_keys",
+	"container_cloud_init_seed",
+	"profile_usage",
+	"container_hooks",
+	"lxcfs_toggle",
+	"image_unpack_progress",
+	"migration_cancel",
+	"operation_recovery",
+	"event_lifecycle",
+	"event_container_filter",
+	"event_lifecycle_requestor",
+	"container_oom_events",
+	"certificate_pem",
+	"certificate_restrictions",
+	"audit_log",
+	"clustering",
+	"cluster_evacuation",
+	"cluster_image_sync",
+	"metrics",
+	"health_checks",
+	"api_limits",
+	"logging_config",
+	"exec_control_noninteractive",
+	"container_exec_user_group_cwd",
+	"container_exec_timeout",
+	"container_exec_detachable",
+	"container_exec_event",
+	"container_exec_shell",
+	"file_delete_recursive",
+	"file_list_details",
+	"file_tarball",
+	"file_tarball_push",
+	"file_types",
+	"file_xattrs",
 }
@@ -164,6 +164,9 @@ run_test test_snapshots "container snapshots"
 run_test test_snap_restore "snapshot restores"
 run_test test_config_profiles "profiles and configuration"
 run_test test_config_edit "container configuration edit"
+run_test test_config_keys "container configuration keys"
+run_test test_config_cloud_init "container cloud-init seeding"
+run_test test_config_hooks "container lifecycle hooks"
 run_test test_config_edit_container_snapshot_pool_config "container and snapshot volume configuration edit"
 run_test test_container_metadata "manage container metadata and templates"
 run_test test_server_config "server configuration"
@@ -184,12 +187,19 @@ run_test test_init_interactive "lxd init interactive"
 run_test test_init_preseed "lxd init preseed"
 run_test test_storage_profiles "storage profiles"
 run_test test_container_import "container import"
+run_test test_container_export "container export"
 run_test test_storage_volume_attach "attaching storage volumes"
 run_test test_storage_driver_ceph "ceph storage driver"
 run_test test_resources "resources"
 run_test test_kernel_limits "kernel limits"
 run_test test_macaroon_auth "macaroon authentication"
 run_test test_console "console"
+run_test test_operations "operations"
+run_test test_events "events"
+run_test test_clustering "clustering"
+run_test test_metrics "metrics"
+run_test test_health "health checks"
+run_test test_limits "API limits"
 
 # shellcheck disable=SC2034
 TEST_RESULT=success
@@ -23,6 +23,28 @@ test_container_import() {
     lxc start ctImport
     lxc delete --force ctImport
 
+    # Recover all containers missing from the database at once
+    lxc init testimage ctImport
+    lxc init testimage ctImport2
+    lxc start ctImport ctImport2
+    pid=$(lxc info ctImport | grep ^Pid | awk '{print $2}')
+    pid2=$(lxc info ctImport2 | grep ^Pid | awk '{print $2}')
+    kill -9 "${pid}" "${pid2}"
+    sqlite3 "${LXD_DIR}/lxd.db" "PRAGMA foreign_keys=ON; DELETE FROM containers WHERE name='ctImport'"
+    sqlite3 "${LXD_DIR}/lxd.db" "PRAGMA foreign_keys=ON; DELETE FROM containers WHERE name='ctImport2'"
+    lxd import
+    lxc info ctImport
+    lxc info ctImport2
+    ! lxd import || false
+    lxc delete --force ctImport ctImport2
+
+    # Including stopped ones, whose volume may not be mounted
+    lxc init testimage ctImport
+    sqlite3 "${LXD_DIR}/lxd.db" "PRAGMA foreign_keys=ON; DELETE FROM containers WHERE name='ctImport'"
+    lxd import
+    lxc info ctImport
+    lxc delete ctImport
+
     lxc init testimage ctImport
     lxc snapshot ctImport
     lxc start ctImport
@@ -178,3 +200,76 @@ test_container_import() {
   LXD_DIR=${LXD_DIR}
   kill_lxd "${LXD_IMPORT_DIR}"
 }
+
+test_container_export() {
+  ensure_import_testimage
+  lxd_backend=$(storage_backend "$LXD_DIR")
+
+  import_export() {
+    op=$(curl -s --unix-socket "${LXD_DIR}/unix.socket" -X POST -H "Content-Type: application/octet-stream" --data-binary "@${1}" lxd/1.0/containers | jq -r .operation)
+    [ "$(curl -s --unix-socket "${LXD_DIR}/unix.socket" "lxd${op}/wait" | jq -r .metadata.status)" = "Success" ]
+  }
+
+  echo foo > "${TEST_DIR}/export-file"
+  lxc init testimage c1
+  lxc file push "${TEST_DIR}/export-file" c1/root/foo
+  lxc snapshot c1
+  lxc file push "${TEST_DIR}/export-file" c1/root/bar
+
+  # Running containers can't be exported
+  lxc start c1
+  [ "$(curl -s --unix-socket "${LXD_DIR}/unix.socket" lxd/1.0/containers/c1/export | jq -r .error_code)" = "400" ]
+  lxc stop c1 --force
+
+  curl -s --unix-socket "${LXD_DIR}/unix.socket" -o "${TEST_DIR}/c1.tar" lxd/1.0/containers/c1/export
+  tar -tf "${TEST_DIR}/c1.tar" | grep -q "^container/rootfs/root/bar$"
+  tar -tf "${TEST_DIR}/c1.tar" | grep -q "^snapshots/snap0/rootfs/root/foo$"
+
+  # The container can't be imported while it exists
+  ! import_export "${TEST_DIR}/c1.tar" || false
+  lxc delete c1
+  import_export "${TEST_DIR}/c1.tar"
+  lxc info c1 | grep snap0
+  lxc start c1
+  [ "$(lxc exec c1 -- cat /root/bar)" = "foo" ]
+  lxc stop c1 --force
+
+  if [ "$lxd_backend" = "zfs" ] || [ "$lxd_backend" = "btrfs" ]; then
+    curl -s --unix-socket "${LXD_DIR}/unix.socket" -o "${TEST_DIR}/c1.tar" "lxd/1.0/containers/c1/export?optimized=true"
+    tar -tf "${TEST_DIR}/c1.tar" | grep -q "^container.bin$"
+    tar -tf "${TEST_DIR}/c1.tar" | grep -q "^snapshots/snap0.bin$"
+
+    lxc delete c1
+    import_export "${TEST_DIR}/c1.tar"
+    lxc info c1 | grep snap0
+    lxc start c1
+    [ "$(lxc exec c1 -- cat /root/bar)" = "foo" ]
+    lxc stop c1 --force
+    lxc restore c1 snap0
+    lxc start c1
+    [ "$(lxc exec c1 -- cat /root/foo)" = "foo" ]
+    ! lxc exec c1 -- test -e /root/bar || false
+  else
+    [ "$(curl -s --unix-socket "${LXD_DIR}/unix.socket" "lxd/1.0/containers/c1/export?optimized=true" | jq -r .error_code)" = "400" ]
+  fi
+
+  lxc delete --force c1
+
+  # Exports are checked like creation requests before being unpacked
+  import_error() {
+    mkdir -p "${TEST_DIR}/bad-export"
+    tar -xOf "${TEST_DIR}/c1.tar" index.yaml | sed "${1}" > "${TEST_DIR}/bad-export/index.yaml"
+    tar -C "${TEST_DIR}/bad-export" -cf "${TEST_DIR}/bad-export.tar" index.yaml
+    curl -s --unix-socket "${LXD_DIR}/unix.socket" -X POST -H "Content-Type: application/octet-stream" --data-binary "@${TEST_DIR}/bad-export.tar" lxd/1.0/containers | jq -r .error_code
+    rm -rf "${TEST_DIR}/bad-export" "${TEST_DIR}/bad-export.tar"
+  }
+
+  [ "$(import_error 's|name: c1/snap0|name: c1/../../snap0|')" = "400" ]
+  [ "$(import_error 's|^  name: c1$|  name: c1/foo|')" = "400" ]
+  lxc config set core.privileged_containers deny
+  [ "$(import_error 's|^  config:$|  config:\n    security.privileged: "true"|')" = "400" ]
+  lxc config unset core.privileged_containers
+  ! lxc info c1 || false
+
+  rm -f "${TEST_DIR}/c1.tar" "${TEST_DIR}/export-file"
+}
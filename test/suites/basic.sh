@@ -13,6 +13,10 @@ test_basic_usage() {
 
   # Test an alias with slashes
   lxc image show "${sum}"
+
+  # wildcards in partial fingerprints are matched literally
+  [ "$(curl -s --unix-socket "${LXD_DIR}/unix.socket" "lxd/1.0/images/%25" | jq -r .error_code)" = "404" ]
+  [ "$(curl -s --unix-socket "${LXD_DIR}/unix.socket" "lxd/1.0/images/_" | jq -r .error_code)" = "404" ]
   lxc image alias create a/b/ "${sum}"
   lxc image alias delete a/b/
 
@@ -45,6 +49,15 @@ test_basic_usage() {
   my_curl -f -X GET "https://${LXD_ADDR}/1.0"
   my_curl -f -X GET "https://${LXD_ADDR}/1.0/containers"
 
+  # GET /1.0 has all the information needed for feature detection
+  my_curl -f -X GET "https://${LXD_ADDR}/1.0" > "${TEST_DIR}/server.json"
+  [ "$(jq -r .metadata.auth "${TEST_DIR}/server.json")" = "trusted" ]
+  [ "$(jq -r .metadata.api_version "${TEST_DIR}/server.json")" = "1.0" ]
+  jq -r ".metadata.api_extensions[]" "${TEST_DIR}/server.json" | grep -q "^storage$"
+  [ "$(jq -r .metadata.environment.server_pid "${TEST_DIR}/server.json")" = "$(cat "${LXD_DIR}/lxd.pid")" ]
+  [ "$(jq -r .metadata.environment.certificate_fingerprint "${TEST_DIR}/server.json")" != "" ]
+  rm "${TEST_DIR}/server.json"
+
   # Re-import the image
   mv "${LXD_DIR}/${sum}.tar.xz" "${LXD_DIR}/testimage.tar.xz"
   lxc image import "${LXD_DIR}/testimage.tar.xz" --alias testimage user.foo=bar
@@ -318,10 +331,37 @@ test_basic_usage() {
   lxc exec --env BEST_BAND=meshuggah foo env | grep meshuggah
   lxc exec foo ip link show | grep eth0
 
+  # check that we can set the user, group and directory of the command
+  lxc exec --cwd /tmp foo pwd | grep /tmp
+  [ "$(lxc exec --user 1000 --group 1000 foo -- id -u)" = "1000" ]
+  [ "$(lxc exec --user 1000 --group 1000 foo -- id -g)" = "1000" ]
+  lxc exec foo -- sh -c 'echo "test:x:1000:1001::/tmp:/bin/sh" >> /etc/passwd'
+  [ "$(lxc exec --user 1000 foo -- id -g)" = "1001" ]
+  [ "$(lxc exec --user 1000 --group 0 foo -- id -g)" = "0" ]
+
+  # check the default environment of the commands
+  op=$(my_curl -X POST "https://${LXD_ADDR}/1.0/containers/foo/exec" -d '{"command": ["env"], "environment": {}, "wait-for-websocket": false, "interactive": false, "record-output": true}' | jq -r .operation)
+  output=$(my_curl "https://${LXD_ADDR}${op}/wait" | jq -r '.metadata.metadata.output["1"]')
+  my_curl "https://${LXD_ADDR}${output}" | grep -q "^HOME=/root$"
+  my_curl "https://${LXD_ADDR}${output}" | grep -q "^USER=root$"
+
+  # check that we can start the login shell of the user
+  echo env | lxc exec --shell foo | grep -q "^LOGNAME=root$"
+  ! lxc exec --shell foo -- true || false
+
+  # check the default size of the terminal when the client has none
+  [ "$(lxc exec -t foo -- stty size | tr -d '\r')" = "24 80" ]
+
   # check that we can get the return code for a non- wait-for-websocket exec
   op=$(my_curl -X POST "https://${LXD_ADDR}/1.0/containers/foo/exec" -d '{"command": ["sleep", "1"], "environment": {}, "wait-for-websocket": false, "interactive": false}' | jq -r .operation)
   [ "$(my_curl "https://${LXD_ADDR}${op}/wait" | jq -r .metadata.metadata.return)" != "null" ]
 
+  # check that the output of a non- wait-for-websocket exec can be recorded
+  op=$(my_curl -X POST "https://${LXD_ADDR}/1.0/containers/foo/exec" -d '{"command": ["sh", "-c", "echo out; echo err >&2"], "environment": {}, "wait-for-websocket": false, "interactive": false, "record-output": true}' | jq -r .operation)
+  metadata=$(my_curl "https://${LXD_ADDR}${op}/wait" | jq -r .metadata.metadata)
+  [ "$(my_curl "https://${LXD_ADDR}$(echo "${metadata}" | jq -r '.output["1"]')")" = "out" ]
+  [ "$(my_curl "https://${LXD_ADDR}$(echo "${metadata}" | jq -r '.output["2"]')")" = "err" ]
+
   # test file transfer
   echo abc > "${LXD_DIR}/in"
 
@@ -388,6 +428,13 @@ test_basic_usage() {
     fi
     lxc delete lxd-apparmor-test
     [ ! -f "${LXD_DIR}/security/apparmor/profiles/lxd-lxd-apparmor-test" ]
+
+    # check that no profile is generated when apparmor is turned off
+    lxc launch testimage lxd-apparmor-test -c security.apparmor=false
+    [ ! -f "${LXD_DIR}/security/apparmor/profiles/lxd-lxd-apparmor-test" ]
+    pid=$(lxc info lxd-apparmor-test | grep ^Pid | awk '{print $2}')
+    grep -q "^unconfined" "/proc/${pid}/attr/current"
+    lxc delete lxd-apparmor-test --force
   else
     echo "==> SKIP: apparmor tests (missing kernel support)"
   fi
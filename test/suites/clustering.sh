@@ -0,0 +1,137 @@
+test_clustering() {
+  # shellcheck disable=2039
+  local LXD_ONE_DIR LXD_TWO_DIR addr_one cert_one op token
+
+  LXD_ONE_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  chmod +x "${LXD_ONE_DIR}"
+  spawn_lxd "${LXD_ONE_DIR}" true
+  addr_one=$(cat "${LXD_ONE_DIR}/lxd.addr")
+  cert_one=$(jq -Rs . < "${LXD_ONE_DIR}/server.crt")
+
+  # The members need their own server certificate
+  LXD_TWO_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  chmod +x "${LXD_TWO_DIR}"
+  spawn_lxd "${LXD_TWO_DIR}" true
+  shutdown_lxd "${LXD_TWO_DIR}"
+  rm "${LXD_TWO_DIR}/server.crt" "${LXD_TWO_DIR}/server.key"
+  respawn_lxd "${LXD_TWO_DIR}"
+
+  # Bootstrap the cluster
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" lxd/1.0/cluster | jq -r .metadata.enabled)" = "false" ]
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X PUT -d '{"enabled": true}' lxd/1.0/cluster | jq -r .error_code)" = "400" ]
+  curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X PUT -d '{"server_name": "node1", "enabled": true}' lxd/1.0/cluster
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" lxd/1.0/cluster | jq -r .metadata.server_name)" = "node1" ]
+
+  # Join tokens are issued for a given name
+  op=$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X POST -d '{"server_name": "node2"}' lxd/1.0/cluster/members | jq -r .operation)
+  token=$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" "lxd${op}" | jq -r .metadata.metadata.secret)
+  [ "$(curl --unix-socket "${LXD_TWO_DIR}/unix.socket" -X PUT -d "{\"server_name\": \"node3\", \"enabled\": true, \"cluster_address\": \"${addr_one}\", \"cluster_certificate\": ${cert_one}, \"cluster_token\": \"${token}\"}" lxd/1.0/cluster | jq -r .type)" = "error" ]
+  [ "$(curl --unix-socket "${LXD_TWO_DIR}/unix.socket" lxd/1.0/cluster | jq -r .metadata.enabled)" = "false" ]
+
+  # New members need the storage pools of the cluster
+  op=$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X POST -d '{"server_name": "node2"}' lxd/1.0/cluster/members | jq -r .operation)
+  token=$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" "lxd${op}" | jq -r .metadata.metadata.secret)
+  [ "$(curl --unix-socket "${LXD_TWO_DIR}/unix.socket" -X PUT -d "{\"server_name\": \"node2\", \"enabled\": true, \"cluster_address\": \"${addr_one}\", \"cluster_certificate\": ${cert_one}, \"cluster_token\": \"${token}\"}" lxd/1.0/cluster | jq -r .error_code)" = "400" ]
+  LXD_DIR=${LXD_TWO_DIR} lxc storage create "lxdtest-$(basename "${LXD_ONE_DIR}")" dir
+
+  # Profiles get merged when joining
+  LXD_DIR=${LXD_ONE_DIR} lxc profile create p1
+  LXD_DIR=${LXD_ONE_DIR} lxc profile set p1 user.foo one
+  LXD_DIR=${LXD_TWO_DIR} lxc profile create p1
+  LXD_DIR=${LXD_TWO_DIR} lxc profile create p2
+
+  op=$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X POST -d '{"server_name": "node2"}' lxd/1.0/cluster/members | jq -r .operation)
+  token=$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" "lxd${op}" | jq -r .metadata.metadata.secret)
+  curl --unix-socket "${LXD_TWO_DIR}/unix.socket" -X PUT -d "{\"server_name\": \"node2\", \"enabled\": true, \"cluster_address\": \"${addr_one}\", \"cluster_certificate\": ${cert_one}, \"cluster_token\": \"${token}\"}" lxd/1.0/cluster
+  [ "$(curl --unix-socket "${LXD_TWO_DIR}/unix.socket" lxd/1.0/cluster | jq -r .metadata.server_name)" = "node2" ]
+
+  # Both members know about each other
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" lxd/1.0/cluster/members | jq -r '.metadata | length')" = "2" ]
+  [ "$(curl --unix-socket "${LXD_TWO_DIR}/unix.socket" lxd/1.0/cluster/members/node1 | jq -r .metadata.status)" = "Online" ]
+
+  # Profiles are shared
+  [ "$(LXD_DIR=${LXD_TWO_DIR} lxc profile get p1 user.foo)" = "one" ]
+  LXD_DIR=${LXD_ONE_DIR} lxc profile show p2
+  LXD_DIR=${LXD_TWO_DIR} lxc profile set p2 user.foo two
+  [ "$(LXD_DIR=${LXD_ONE_DIR} lxc profile get p2 user.foo)" = "two" ]
+  LXD_DIR=${LXD_ONE_DIR} lxc profile delete p1
+  LXD_DIR=${LXD_ONE_DIR} lxc profile delete p2
+  ! LXD_DIR=${LXD_TWO_DIR} lxc profile show p1 || false
+  ! LXD_DIR=${LXD_TWO_DIR} lxc profile show p2 || false
+
+  (
+    set -e
+    # shellcheck disable=SC2030
+    LXD_DIR=${LXD_ONE_DIR}
+    ensure_import_testimage
+  )
+
+  # Images get fetched from the other members when needed
+  (
+    set -e
+    # shellcheck disable=SC2030
+    LXD_DIR=${LXD_TWO_DIR}
+    [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" lxd/1.0/images | jq -r '.metadata | length')" = "0" ]
+    lxc init testimage c2
+    [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" lxd/1.0/images | jq -r '.metadata | length')" = "1" ]
+  )
+
+  (
+    set -e
+    # shellcheck disable=SC2030
+    LXD_DIR=${LXD_ONE_DIR}
+
+    # The containers of the other members are listed and forwarded to
+    lxc list | grep -q c2
+    [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" "lxd/1.0/containers?recursion=1" | jq -r '.metadata[] | select(.name == "c2") | .location')" = "node2" ]
+    [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" lxd/1.0/containers/c2 | jq -r .metadata.location)" = "node2" ]
+    lxc config set c2 user.foo bar
+
+    # Container names are unique across the cluster
+    ! lxc init testimage c2 || false
+  )
+
+  [ "$(LXD_DIR=${LXD_TWO_DIR} lxc config get c2 user.foo)" = "bar" ]
+
+  # Members with containers can't be removed
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X DELETE lxd/1.0/cluster/members/node2 | jq -r .error_code)" = "400" ]
+
+  # Containers can be created on a given member
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X POST -d '{"name": "c3", "source": {"type": "image", "alias": "testimage"}}' "lxd/1.0/containers?target=node3" | jq -r .error_code)" = "400" ]
+  op=$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X POST -d '{"name": "c3", "source": {"type": "image", "alias": "testimage"}}' "lxd/1.0/containers?target=node2" | jq -r .operation)
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" "lxd${op}/wait" | jq -r .metadata.status)" = "Success" ]
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" lxd/1.0/containers/c3 | jq -r .metadata.location)" = "node2" ]
+
+  # Copies are made on the member of their source
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X POST -d '{"name": "c3-copy", "source": {"type": "copy", "source": "c3"}}' "lxd/1.0/containers?target=node1" | jq -r .error_code)" = "400" ]
+  op=$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X POST -d '{"name": "c3-copy", "source": {"type": "copy", "source": "c3"}}' lxd/1.0/containers | jq -r .operation)
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" "lxd${op}/wait" | jq -r .metadata.status)" = "Success" ]
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" lxd/1.0/containers/c3-copy | jq -r .metadata.location)" = "node2" ]
+  LXD_DIR=${LXD_ONE_DIR} lxc delete c3-copy
+
+  # Evacuating a member moves its containers away
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X POST -d '{"action": "evacuate", "mode": "foo"}' lxd/1.0/cluster/members/node2/state | jq -r .error_code)" = "400" ]
+  op=$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X POST -d '{"action": "evacuate"}' lxd/1.0/cluster/members/node2/state | jq -r .operation)
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" "lxd${op}/wait" | jq -r .metadata.status)" = "Success" ]
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" lxd/1.0/cluster/members/node2 | jq -r .metadata.evacuated)" = "true" ]
+  [ "$(curl --unix-socket "${LXD_TWO_DIR}/unix.socket" lxd/1.0/containers/c2 | jq -r .metadata.location)" = "node1" ]
+  [ "$(curl --unix-socket "${LXD_TWO_DIR}/unix.socket" lxd/1.0/containers/c3 | jq -r .metadata.location)" = "node1" ]
+  [ "$(LXD_DIR=${LXD_ONE_DIR} lxc config get c2 user.foo)" = "bar" ]
+
+  # Evacuated members don't get new containers until restored
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X POST -d '{"name": "c4", "source": {"type": "image", "alias": "testimage"}}' "lxd/1.0/containers?target=node2" | jq -r .error_code)" = "400" ]
+  curl --unix-socket "${LXD_TWO_DIR}/unix.socket" -X POST -d '{"action": "restore"}' lxd/1.0/cluster/members/node2/state
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" lxd/1.0/cluster/members/node2 | jq -r .metadata.evacuated)" = "false" ]
+
+  LXD_DIR=${LXD_TWO_DIR} lxc delete c2 c3
+
+  curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X DELETE lxd/1.0/cluster/members/node2
+  [ "$(curl --unix-socket "${LXD_TWO_DIR}/unix.socket" lxd/1.0/cluster | jq -r .metadata.enabled)" = "false" ]
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" lxd/1.0/cluster/members | jq -r '.metadata | length')" = "1" ]
+
+  curl --unix-socket "${LXD_ONE_DIR}/unix.socket" -X PUT -d '{"enabled": false}' lxd/1.0/cluster
+  [ "$(curl --unix-socket "${LXD_ONE_DIR}/unix.socket" lxd/1.0/cluster | jq -r .metadata.enabled)" = "false" ]
+
+  kill_lxd "${LXD_ONE_DIR}"
+  kill_lxd "${LXD_TWO_DIR}"
+}
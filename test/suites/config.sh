@@ -149,16 +149,80 @@ test_config_profiles() {
 
   mkdir -p "${TEST_DIR}/mnt1"
   lxc config device add foo mnt1 disk source="${TEST_DIR}/mnt1" path=/mnt1 readonly=true
+  ! lxc config device set foo mnt1 limits.read bogus || false
+  lxc config device set foo mnt1 limits.write 10MB
+  lxc config device unset foo mnt1 limits.write
+  ! lxc config device set foo mnt1 propagation bogus || false
+  lxc config device set foo mnt1 propagation rslave
+  lxc config device unset foo mnt1 propagation
+  touch "${TEST_DIR}/mnt1-file"
+  ! lxc config device add foo mntfile disk source="${TEST_DIR}/mnt1-file" path=/mntfile shift=true || false
+  rm "${TEST_DIR}/mnt1-file"
+  ! lxc config device add foo usb0 usb vendorid=xyz || false
+  ! lxc config device add foo eth9 nic nictype=p2p hwaddr=bogus || false
+  ! lxc config set foo limits.cpu 3-1 || false
+  ! lxc config set foo limits.cpu 0 || false
+  ! lxc config set foo limits.cpu.allowance 0% || false
+  ! lxc config set foo limits.cpu.allowance 10ms/2000ms || false
+  lxc config set foo limits.cpu.allowance 25ms/100ms
+  lxc config unset foo limits.cpu.allowance
+  ! lxc config set foo limits.memory 0% || false
+  lxc config set foo limits.memory 1GiB
+  lxc config unset foo limits.memory
+  ! lxc config set foo limits.cpu.nodes 1024 || false
+  ! lxc config set foo limits.hugepages.2MB bogus || false
+  lxc config set foo limits.hugepages.2MB 64MB
+  lxc config unset foo limits.hugepages.2MB
+  ! lxc config set foo limits.kernel.nofile 2000:1000 || false
+  ! lxc config set foo limits.kernel.nofile bogus || false
+  lxc config set foo limits.kernel.nofile 1000:unlimited
+  lxc config unset foo limits.kernel.nofile
+  ! lxc config set foo linux.sysctl.vm.swappiness 10 || false
+  lxc config set foo linux.sysctl.net.ipv4.ip_forward 1
+  lxc config unset foo linux.sysctl.net.ipv4.ip_forward
+  ! lxc config set foo environment.A=B value || false
+  lxc config set foo environment.LXD_TEST_ENV value
+  ! lxc config set foo raw.idmap "both 1000" || false
+  if [ -d /sys/devices/system/node/node0 ]; then
+    lxc config set foo limits.cpu.nodes 0
+    lxc config unset foo limits.cpu.nodes
+  fi
+  ! lxc config device add foo eth9 nic nictype=p2p mtu=bogus || false
+  lxc config device add foo usb0 usb vendorid=1d6b productid=0002
+  lxc config device remove foo usb0
+  ! lxc config device add foo routed0 nic nictype=routed || false
+  lxc config device add foo routed0 nic nictype=routed ipv4.address=192.0.2.10
+  lxc config device remove foo routed0
   lxc profile create onenic
   lxc profile device add onenic eth0 nic nictype=p2p
   lxc profile assign foo onenic
   lxc profile create unconfined
   lxc profile set unconfined raw.lxc "lxc.aa_profile=unconfined"
   lxc profile assign foo onenic,unconfined
+
+  # test profile usage
+  lxc profile set onenic user.usage one
+  lxc profile set unconfined user.usage two
+  lxc query /1.0/profiles/onenic/usage | jq -r '.[0].overridden_config[0]' | grep -q "^user.usage$"
+  lxc query /1.0/profiles/onenic/usage | jq -r '.[0].devices.eth0.nictype' | grep -q "^p2p$"
+  lxc query /1.0/profiles/unconfined/usage | jq -r '.[0].config["user.usage"]' | grep -q "^two$"
+  lxc profile unset onenic user.usage
+  lxc profile unset unconfined user.usage
+
+  # profile changes which can't apply to a container are rejected
+  lxc config set foo security.syscalls.whitelist "reboot"
+  ! lxc profile set onenic security.syscalls.blacklist "reboot" || false
+  ! lxc profile get onenic security.syscalls.blacklist | grep -q reboot || false
+  lxc config unset foo security.syscalls.whitelist
+
   # test profile rename
   lxc profile create foo
   lxc profile rename foo bar
   lxc profile list | grep -qv foo  # the old name is gone
+  lxc profile create baz
+  ! lxc profile rename bar baz || false
+  ! lxc profile rename default newdefault || false
+  lxc profile delete baz
   lxc profile delete bar
 
   lxc config device list foo | grep mnt1
@@ -171,6 +235,8 @@ test_config_profiles() {
   # test live-adding a nic
   lxc start foo
   lxc exec foo -- cat /proc/self/mountinfo | grep -q "/mnt1.*ro,"
+  lxc exec foo -- cat /proc/1/environ | tr '\0' '\n' | grep -q "^LXD_TEST_ENV=value$"
+  lxc exec foo -- env | grep -q "^LXD_TEST_ENV=value$"
   ! lxc config show foo | grep -q "raw.lxc" || false
   lxc config show foo --expanded | grep -q "raw.lxc"
   ! lxc config show foo | grep -v "volatile.eth0" | grep -q "eth0" || false
@@ -181,6 +247,12 @@ test_config_profiles() {
   lxc config device list foo | grep eth2
   lxc config device remove foo eth2
 
+  # test live-adding a nic with network limits
+  lxc config device add foo eth3 nic nictype=p2p name=eth11 host_name="lxdlim$$" limits.ingress=10Mbit
+  tc qdisc show dev "lxdlim$$" | grep -q htb
+  ! lxc config device set foo eth3 limits.egress bogus || false
+  lxc config device remove foo eth3
+
   # test live-adding a disk
   mkdir "${TEST_DIR}/mnt2"
   touch "${TEST_DIR}/mnt2/hosts"
@@ -247,9 +319,81 @@ test_config_edit() {
     lxc init testimage foo -s "lxdtest-$(basename "${LXD_DIR}")"
     lxc config show foo | sed 's/^description:.*/description: bar/' | lxc config edit foo
     lxc config show foo | grep -q 'description: bar'
+
+    # volatile keys are managed by LXD
+    ! lxc config set foo volatile.base_image abc || false
+    ! lxc init testimage bar -s "lxdtest-$(basename "${LXD_DIR}")" -c volatile.base_image=abc || false
+    lxc config unset foo volatile.base_image
     lxc delete foo
 }
 
+test_config_cloud_init() {
+    ensure_import_testimage
+
+    lxc init testimage c1 -s "lxdtest-$(basename "${LXD_DIR}")" -c user.user-data="#cloud-config"
+    lxc init testimage c2 -s "lxdtest-$(basename "${LXD_DIR}")" -c user.user-data="#cloud-config"
+    echo "" > "${TEST_DIR}/cloud.cfg"
+    lxc file push -p "${TEST_DIR}/cloud.cfg" c1/etc/cloud/cloud.cfg
+    rm "${TEST_DIR}/cloud.cfg"
+
+    # the seed is only written for images with cloud-init
+    lxc start c1
+    lxc start c2
+    lxc exec c1 -- grep -q "#cloud-config" /var/lib/cloud/seed/nocloud-net/user-data
+    lxc exec c1 -- grep -q "instance-id: c1" /var/lib/cloud/seed/nocloud-net/meta-data
+    ! lxc exec c2 -- test -e /var/lib/cloud/seed/nocloud-net/user-data || false
+
+    lxc delete c1 c2 --force
+}
+
+test_config_hooks() {
+    ensure_import_testimage
+
+    mkdir "${TEST_DIR}/hooks"
+    cat > "${TEST_DIR}/hooks/hook.sh" << EOF
+#!/bin/sh
+echo "\${LXD_HOOK} \${LXD_CONTAINER_NAME}" >> "${TEST_DIR}/hook.log"
+EOF
+    printf '#!/bin/sh\nexit 1\n' > "${TEST_DIR}/hooks/false.sh"
+    chmod +x "${TEST_DIR}/hooks/hook.sh" "${TEST_DIR}/hooks/false.sh"
+
+    lxc init testimage c1 -s "lxdtest-$(basename "${LXD_DIR}")"
+    ! lxc config set c1 hooks.pre-start hook.sh || false
+    lxc config set c1 hooks.pre-start "${TEST_DIR}/hooks/hook.sh"
+    lxc config set c1 hooks.post-stop "${TEST_DIR}/hooks/hook.sh"
+
+    # hooks only run from core.hooks_path
+    ! lxc start c1 || false
+    ! lxc config set core.hooks_path hooks || false
+    lxc config set core.hooks_path "${TEST_DIR}/hooks"
+
+    lxc start c1
+    grep -q "^pre-start c1$" "${TEST_DIR}/hook.log"
+    lxc stop c1 --force
+    sleep 1
+    grep -q "^post-stop c1$" "${TEST_DIR}/hook.log"
+
+    # a failing pre-start hook prevents the start
+    lxc config set c1 hooks.pre-start "${TEST_DIR}/hooks/false.sh"
+    ! lxc start c1 || false
+    lxc config set c1 hooks.pre-start /bin/true
+    ! lxc start c1 || false
+
+    # so does a failing HTTP callback
+    ! lxc config set c1 hooks.pre-start ftp://127.0.0.1/ || false
+    lxc config set c1 hooks.pre-start http://127.0.0.1:1/
+    ! lxc start c1 || false
+
+    lxc delete c1
+    lxc config unset core.hooks_path
+    rm -r "${TEST_DIR}/hooks" "${TEST_DIR}/hook.log"
+}
+
+test_config_keys() {
+    lxc query /1.0/config-keys | jq -r '.[] | select(.name == "limits.memory") | .live_update' | grep -q true
+    lxc query /1.0/config-keys | jq -r '.[] | select(.name == "security.privileged") | .live_update' | grep -q false
+}
+
 test_config_edit_container_snapshot_pool_config() {
     # shellcheck disable=2034,2039,2155
     local storage_pool="lxdtest-$(basename "${LXD_DIR}")"
@@ -0,0 +1,36 @@
+test_events() {
+  ensure_import_testimage
+
+  # unknown event types are rejected
+  [ "$(my_curl "https://${LXD_ADDR}/1.0/events?type=foo" | jq -r .error_code)" = "400" ]
+
+  lxc monitor --type=lifecycle > "${TEST_DIR}/events.log" &
+  monitor_pid=$!
+  sleep 1
+
+  lxc init testimage events
+  lxc start events
+  lxc restart events --force
+  lxc snapshot events snap0
+  lxc move events/snap0 events/snap1
+  lxc delete events/snap1
+  lxc exec events -- true
+  lxc stop events --force
+  lxc config set events user.foo bar
+  lxc move events events2
+  lxc delete events2
+  sleep 1
+
+  kill -9 "${monitor_pid}" || true
+
+  for action in container-created container-started container-restarted container-snapshot-created container-snapshot-renamed container-snapshot-deleted container-exec container-stopped container-updated container-renamed container-deleted; do
+    grep -q "action: ${action}$" "${TEST_DIR}/events.log"
+  done
+  grep -q "source: /1.0/containers/events/snapshots/snap0$" "${TEST_DIR}/events.log"
+  grep -q "source: /1.0/containers/events2$" "${TEST_DIR}/events.log"
+
+  # the requestor of the actions is recorded
+  grep -q "protocol: unix$" "${TEST_DIR}/events.log"
+
+  rm "${TEST_DIR}/events.log"
+}
@@ -110,6 +110,67 @@ test_filemanip() {
   lxc file push -p "${TEST_DIR}"/source/foo filemanip/A/B/C/D/
   [ "$(lxc exec filemanip cat /A/B/C/D/foo)" = "foo" ]
 
+  # directory listings can have the details of the entries
+  entry=$(my_curl "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/A/B/C/D&recursion=1" | jq -c '.metadata[0]')
+  [ "$(echo "${entry}" | jq -r .name)" = "foo" ]
+  [ "$(echo "${entry}" | jq -r .type)" = "file" ]
+  [ "$(echo "${entry}" | jq -r .size)" = "4" ]
+
+  # directories can be pulled as tarballs
+  lxc exec filemanip -- ln -s foo /A/B/C/D/bar
+  my_curl "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/A&recursive=1" > "${TEST_DIR}/A.tar"
+  tar -tvf "${TEST_DIR}/A.tar" | grep -q "./B/C/D/foo$"
+  tar -tvf "${TEST_DIR}/A.tar" | grep -q "./B/C/D/bar -> foo$"
+
+  # the target of symlinks is reported
+  my_curl -D - -o /dev/null "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/A/B/C/D/bar" | grep -qi "^X-LXD-target: foo"
+  [ "$(lxc file pull filemanip/A/B/C/D/bar -)" = "foo" ]
+
+  # and pushed back as tarballs
+  my_curl -X POST -H "X-LXD-type: tarball" --data-binary "@${TEST_DIR}/A.tar" "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/A2"
+  [ "$(lxc exec filemanip cat /A2/B/C/D/foo)" = "foo" ]
+  [ "$(lxc exec filemanip readlink /A2/B/C/D/bar)" = "foo" ]
+  rm "${TEST_DIR}/A.tar"
+
+  # with their setuid, setgid and sticky bits
+  mkdir -p "${TEST_DIR}/special/sticky"
+  touch "${TEST_DIR}/special/suid"
+  chmod 4755 "${TEST_DIR}/special/suid"
+  chmod 1777 "${TEST_DIR}/special/sticky"
+  tar -C "${TEST_DIR}/special" -cf "${TEST_DIR}/special.tar" .
+  my_curl -X POST -H "X-LXD-type: tarball" --data-binary "@${TEST_DIR}/special.tar" "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/special"
+  [ "$(lxc exec filemanip -- stat -c %a /special/suid)" = "4755" ]
+  [ "$(lxc exec filemanip -- stat -c %a /special/sticky)" = "1777" ]
+  my_curl -D - -o /dev/null "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/special/suid" | grep -qi "^X-LXD-mode: 4755"
+  [ "$(my_curl "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/special&recursion=1" | jq -r '.metadata[] | select(.name == "sticky") | .mode')" = "1023" ]
+  lxc file delete -r filemanip/special
+  rm -r "${TEST_DIR}/special" "${TEST_DIR}/special.tar"
+
+  # non-empty directories are only deleted recursively
+  ! lxc file delete filemanip/A || false
+  lxc file delete -r filemanip/A
+  ! lxc exec filemanip -- test -e /A || false
+
+  # ownership is the one seen in the container, even when it's stopped
+  lxc stop filemanip --force
+  lxc file push --uid=0 --gid=0 "${TEST_DIR}"/filemanip filemanip/root/owned
+  [ "$(lxc file pull filemanip/root/owned -)" = "test" ]
+  ! lxc file push --uid=4294967295 "${TEST_DIR}"/filemanip filemanip/root/unmapped || false
+  lxc start filemanip
+  [ "$(lxc exec filemanip -- stat -c "%u:%g" /root/owned)" = "0:0" ]
+  ! lxc file push --uid=4294967295 "${TEST_DIR}"/filemanip filemanip/root/unmapped || false
+
+  # extended attributes and ACLs are kept on request
+  if command -v setfacl >/dev/null 2>&1 && setfacl -m u:1000:r "${TEST_DIR}"/filemanip; then
+    lxc file push --xattrs "${TEST_DIR}"/filemanip filemanip/root/acl
+    lxc file pull --xattrs filemanip/root/acl "${TEST_DIR}"/acl
+    getfacl -n "${TEST_DIR}"/acl | grep -q "^user:1000:r--"
+    rm "${TEST_DIR}"/acl
+  fi
+
+  # only user attributes, file capabilities and ACLs can be set
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" -X POST -H 'X-LXD-xattrs: {"trusted.foo": "YmFy"}' --data-binary test "lxd/1.0/containers/filemanip/files?path=/root/trusted" | jq -r .error_code)" = "400" ]
+
   lxc delete filemanip -f
 
   if [ "$(storage_backend "$LXD_DIR")" != "lvm" ]; then
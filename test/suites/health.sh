@@ -0,0 +1,24 @@
+test_health() {
+  # the daemon is ready and healthy
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" "lxd/internal/ready?timeout=1" | jq -r .status_code)" = "200" ]
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" "lxd/internal/ready?timeout=-2" | jq -r .error_code)" = "400" ]
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" lxd/internal/healthz | jq -r .metadata.database)" = "ok" ]
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" lxd/internal/healthz | jq -r .metadata.storage)" = "ok" ]
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" lxd/internal/healthz | jq -r .metadata.lxc)" = "ok" ]
+
+  # untrusted clients only get the status code
+  [ "$(curl -k -s "https://${LXD_ADDR}/internal/healthz" | jq -r .status_code)" = "200" ]
+  [ "$(curl -k -s "https://${LXD_ADDR}/internal/healthz" | jq -r .metadata.database)" = "null" ]
+  [ "$(curl -k -s "https://${LXD_ADDR}/internal/ready" | jq -r .error_code)" = "403" ]
+
+  # a missing storage pool makes the daemon unhealthy
+  lxc storage create lxdtest-health dir
+  mv "${LXD_DIR}/storage-pools/lxdtest-health" "${LXD_DIR}/storage-pools/lxdtest-health.bak"
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" lxd/internal/healthz | jq -r .error_code)" = "503" ]
+  curl --unix-socket "${LXD_DIR}/unix.socket" lxd/internal/healthz | jq -r .error | grep -q lxdtest-health
+  [ "$(curl -k -s "https://${LXD_ADDR}/internal/healthz" | jq -r .error)" = "LXD is unhealthy" ]
+
+  mv "${LXD_DIR}/storage-pools/lxdtest-health.bak" "${LXD_DIR}/storage-pools/lxdtest-health"
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" lxd/internal/healthz | jq -r .status_code)" = "200" ]
+  lxc storage delete lxdtest-health
+}
@@ -0,0 +1,31 @@
+test_limits() {
+  ensure_import_testimage
+
+  ! lxc config set core.rate_limit -1 || false
+  ! lxc config set core.max_operations foo || false
+
+  # requests over the rate limit get a 429 error
+  lxc config set core.rate_limit_per_client 5
+  codes=""
+  for _ in $(seq 20); do
+    codes="${codes} $(my_curl "https://${LXD_ADDR}/1.0" | jq -r .error_code)"
+  done
+  echo "${codes}" | grep -q 429
+  sleep 1
+  lxc config unset core.rate_limit_per_client
+
+  # so do the requests over the limit of running operations
+  lxc launch testimage c1
+  lxc config set core.max_operations_per_client 1
+  my_curl -X POST -d '{"command": ["sleep", "10"], "wait-for-websocket": false, "interactive": false}' "https://${LXD_ADDR}/1.0/containers/c1/exec" | jq -r .operation
+  [ "$(my_curl -X POST -d '{"command": ["true"], "wait-for-websocket": false, "interactive": false}' "https://${LXD_ADDR}/1.0/containers/c1/exec" | jq -r .error_code)" = "429" ]
+
+  # the operations can still be followed
+  [ "$(my_curl "https://${LXD_ADDR}/1.0/operations" | jq -r .error_code)" = "0" ]
+
+  # other clients have their own limit
+  lxc exec c1 true
+
+  lxc config unset core.max_operations_per_client
+  lxc delete --force c1
+}
@@ -0,0 +1,41 @@
+test_metrics() {
+  ensure_import_testimage
+
+  lxc launch testimage c1
+
+  # the metrics are served in the OpenMetrics text format
+  curl --unix-socket "${LXD_DIR}/unix.socket" lxd/1.0/metrics | grep -q '^lxd_container_processes{name="c1"}'
+  curl --unix-socket "${LXD_DIR}/unix.socket" lxd/1.0/metrics | grep -q '^lxd_containers{status="Running"} 1$'
+  curl --unix-socket "${LXD_DIR}/unix.socket" lxd/1.0/metrics | grep -q '^lxd_api_requests_total{code="200",method="GET"}'
+  curl --unix-socket "${LXD_DIR}/unix.socket" lxd/1.0/metrics | tail -n1 | grep -q '^# EOF$'
+
+  # metrics certificates only give access to the metrics endpoint
+  gen_cert metrics
+  fingerprint=$(openssl x509 -in "${LXD_CONF}/metrics.crt" -noout -fingerprint -sha256 | cut -d= -f2 | tr -d : | tr "[:upper:]" "[:lower:]")
+  jq -n --arg cert "$(cat "${LXD_CONF}/metrics.crt")" '{"type": "metrics", "name": "metrics", "certificate": $cert}' | \
+    curl --unix-socket "${LXD_DIR}/unix.socket" -X POST -d @- lxd/1.0/certificates
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" "lxd/1.0/certificates/${fingerprint}" | jq -r .metadata.type)" = "metrics" ]
+
+  metrics_curl() {
+    curl -k -s --cert "${LXD_CONF}/metrics.crt" --key "${LXD_CONF}/metrics.key" "$@"
+  }
+
+  metrics_addr="127.0.0.1:$(local_tcp_port)"
+  lxc config set core.metrics_address "${metrics_addr}"
+  metrics_curl "https://${metrics_addr}/1.0/metrics" | grep -q '^lxd_container_processes{name="c1"}'
+  [ "$(metrics_curl "https://${metrics_addr}/1.0/containers" | jq -r .error_code)" = "404" ]
+  [ "$(metrics_curl "https://${LXD_ADDR}/1.0/containers" | jq -r .error_code)" = "403" ]
+  [ "$(metrics_curl "https://${LXD_ADDR}/1.0/metrics" | jq -r .error_code)" = "403" ]
+
+  # trusted clients can use the metrics endpoint too
+  my_curl "https://${metrics_addr}/1.0/metrics" | grep -q '^lxd_goroutines '
+
+  # untrusted ones can't
+  [ "$(curl -k -s "https://${metrics_addr}/1.0/metrics" | jq -r .error_code)" = "403" ]
+
+  lxc config unset core.metrics_address
+  ! metrics_curl "https://${metrics_addr}/1.0/metrics" || false
+
+  curl --unix-socket "${LXD_DIR}/unix.socket" -X DELETE "lxd/1.0/certificates/${fingerprint}"
+  lxc delete --force c1
+}
@@ -31,13 +31,17 @@ test_network() {
 
   # Configured bridge with static assignment
   lxc network create lxdt$$ dns.domain=test dns.mode=managed
+  lxc network info lxdt$$ | grep -q "inet6.*global"
+  lxc query /1.0/networks/lxdt$$/state | jq -r .state | grep -q up
   lxc network attach lxdt$$ nettest eth0
+  ! lxc config device set nettest eth0 vlan 5000 || false
   v4_addr="$(lxc network get lxdt$$ ipv4.address | cut -d/ -f1)0"
   v6_addr="$(lxc network get lxdt$$ ipv4.address | cut -d/ -f1)00"
   lxc config device set nettest eth0 ipv4.address "${v4_addr}"
   lxc config device set nettest eth0 ipv6.address "${v6_addr}"
   grep -q "${v4_addr}.*nettest" "${LXD_DIR}/networks/lxdt$$/dnsmasq.hosts/nettest"
   grep -q "${v6_addr}.*nettest" "${LXD_DIR}/networks/lxdt$$/dnsmasq.hosts/nettest"
+  lxc network list-leases lxdt$$ | grep -q "${v4_addr}.*STATIC.*nettest"
   lxc start nettest
 
   SUCCESS=0
@@ -51,4 +55,5 @@ test_network() {
 
   lxc delete nettest -f
   lxc network delete lxdt$$
+  [ ! -d "${LXD_DIR}/networks/lxdt$$" ]
 }
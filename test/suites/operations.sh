@@ -0,0 +1,44 @@
+test_operations() {
+  ensure_import_testimage
+
+  # image secrets are token operations, which may always be cancelled
+  fingerprint=$(lxc image info testimage | grep "^Fingerprint" | cut -d' ' -f2)
+  op=$(my_curl -X POST "https://${LXD_ADDR}/1.0/images/${fingerprint}/secret" -d '{}' | jq -r .operation)
+  uuid=$(basename "${op}")
+
+  # the operation shows up in the listings
+  my_curl "https://${LXD_ADDR}/1.0/operations" | jq -r .metadata.running[] | grep -q "${op}"
+  my_curl "https://${LXD_ADDR}/1.0/operations?recursion=1" | jq -r .metadata.running[].id | grep -q "${uuid}"
+  lxc operation list | grep -q "${uuid}"
+
+  # and can be fetched by its UUID
+  [ "$(my_curl "https://${LXD_ADDR}${op}" | jq -r .metadata.may_cancel)" = "true" ]
+  lxc operation show "${uuid}" | grep -q "class: token"
+  my_curl "https://${LXD_ADDR}${op}" | jq -r .metadata.metadata.secret | grep -q -v null
+
+  # waiting on it times out as it's still running
+  [ "$(my_curl "https://${LXD_ADDR}${op}/wait?timeout=1" | jq -r .metadata.status)" = "Running" ]
+  [ "$(my_curl "https://${LXD_ADDR}${op}/wait?timeout=foo" | jq -r .error_code)" = "400" ]
+  [ "$(my_curl "https://${LXD_ADDR}${op}/wait?timeout=-2" | jq -r .error_code)" = "400" ]
+
+  # cancelling it removes it from the running operations
+  lxc operation delete "${uuid}"
+  ! my_curl "https://${LXD_ADDR}/1.0/operations" | jq -r ".metadata.running // [] | .[]" | grep -q "${op}" || false
+
+  # unknown operations are reported as such
+  [ "$(my_curl "https://${LXD_ADDR}/1.0/operations/foo" | jq -r .error_code)" = "404" ]
+  ! lxc operation delete foo || false
+
+  # operations interrupted by a restart are reported as failed and cleaned up
+  lxc init testimage interrupted
+  my_curl -X POST "https://${LXD_ADDR}/1.0/images/${fingerprint}/secret" -d '{}'
+  shutdown_lxd "${LXD_DIR}"
+  [ ! -e "${LXD_DIR}/unix.socket" ]
+  sqlite3 "${LXD_DIR}/lxd.db" "INSERT INTO operations (uuid, type, class, status_code, resources, metadata, err, created_at, updated_at) VALUES ('interrupted-op', 'container-create', 'task', 103, '{\"containers\":[\"interrupted\"]}', '{}', '', datetime('now'), datetime('now'))"
+  respawn_lxd "${LXD_DIR}"
+  [ "$(my_curl "https://${LXD_ADDR}/1.0/operations/interrupted-op" | jq -r .metadata.status)" = "Failure" ]
+  [ "$(my_curl "https://${LXD_ADDR}/1.0/operations/interrupted-op/wait" | jq -r .metadata.err)" = "Interrupted by daemon restart" ]
+  my_curl "https://${LXD_ADDR}/1.0/operations" | jq -r .metadata.failure[] | grep -q interrupted-op
+  ! lxc info interrupted || false
+  ! lxc operation delete interrupted-op || false
+}
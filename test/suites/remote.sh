@@ -61,6 +61,48 @@ test_remote_admin() {
     false
   fi
 
+  # certificates can be added as PEM through the API, renamed and removed
+  gen_cert client3
+  fingerprint=$(openssl x509 -in "${LXD_CONF}/client3.crt" -noout -fingerprint -sha256 | cut -d= -f2 | tr -d : | tr "[:upper:]" "[:lower:]")
+  jq -n --arg cert "$(cat "${LXD_CONF}/client3.crt")" '{"type": "client", "name": "client3", "certificate": $cert}' | \
+    curl --unix-socket "${LXD_DIR}/unix.socket" -X POST -d @- lxd/1.0/certificates
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" "lxd/1.0/certificates/${fingerprint}" | jq -r .metadata.name)" = "client3" ]
+  curl --unix-socket "${LXD_DIR}/unix.socket" -X PATCH -d '{"name": "renamed"}' "lxd/1.0/certificates/${fingerprint}"
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" "lxd/1.0/certificates/${fingerprint}" | jq -r .metadata.name)" = "renamed" ]
+
+  # read-only certificates can't change anything
+  curl --unix-socket "${LXD_DIR}/unix.socket" -X PATCH -d '{"read_only": true}' "lxd/1.0/certificates/${fingerprint}"
+  client3_curl() {
+    curl -k -s --cert "${LXD_CONF}/client3.crt" --key "${LXD_CONF}/client3.key" "$@"
+  }
+  [ "$(client3_curl "https://${LXD_ADDR}/1.0/containers" | jq -r .error_code)" = "0" ]
+  [ "$(client3_curl -X POST -d '{}' "https://${LXD_ADDR}/1.0/containers" | jq -r .error_code)" = "403" ]
+
+  # certificates restricted to some containers can only access those
+  curl --unix-socket "${LXD_DIR}/unix.socket" -X PATCH -d '{"read_only": false, "containers": ["foo"]}' "lxd/1.0/certificates/${fingerprint}"
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" "lxd/1.0/certificates/${fingerprint}" | jq -r .metadata.containers[0])" = "foo" ]
+  [ "$(client3_curl "https://${LXD_ADDR}/1.0" | jq -r .metadata.auth)" = "trusted" ]
+  [ "$(client3_curl "https://${LXD_ADDR}/1.0/containers" | jq -r .error_code)" = "403" ]
+  [ "$(client3_curl "https://${LXD_ADDR}/1.0/containers/bar" | jq -r .error_code)" = "403" ]
+  [ "$(client3_curl "https://${LXD_ADDR}/1.0/containers/foo" | jq -r .error_code)" = "404" ]
+  [ "$(client3_curl "https://${LXD_ADDR}/1.0/images" | jq -r .error_code)" = "403" ]
+  [ "$(client3_curl "https://${LXD_ADDR}/1.0/operations/$(uuidgen)" | jq -r .error_code)" = "403" ]
+  ensure_import_testimage
+  lxc init testimage foo
+  [ "$(client3_curl -X PATCH -d '{"config": {"raw.lxc": "lxc.aa_profile=unconfined"}}' "https://${LXD_ADDR}/1.0/containers/foo" | jq -r .error_code)" = "400" ]
+  [ "$(client3_curl -X PATCH -d '{"config": {"hooks.pre-start": "/bin/true"}}' "https://${LXD_ADDR}/1.0/containers/foo" | jq -r .error_code)" = "400" ]
+  [ "$(client3_curl -X PATCH -d '{"devices": {"host": {"type": "disk", "source": "/", "path": "/mnt"}}}' "https://${LXD_ADDR}/1.0/containers/foo" | jq -r .error_code)" = "400" ]
+  [ "$(client3_curl -X PATCH -d '{"devices": {"sda": {"type": "unix-block", "path": "/dev/sda"}}}' "https://${LXD_ADDR}/1.0/containers/foo" | jq -r .error_code)" = "400" ]
+  lxc profile create hostdisk
+  lxc profile device add hostdisk host disk source=/ path=/mnt
+  [ "$(client3_curl -X PATCH -d '{"profiles": ["default", "hostdisk"]}' "https://${LXD_ADDR}/1.0/containers/foo" | jq -r .error_code)" = "400" ]
+  lxc profile delete hostdisk
+  [ "$(client3_curl -X PATCH -d '{"config": {"limits.cpu": "1"}}' "https://${LXD_ADDR}/1.0/containers/foo" | jq -r .error_code)" = "0" ]
+  lxc delete foo
+
+  curl --unix-socket "${LXD_DIR}/unix.socket" -X DELETE "lxd/1.0/certificates/${fingerprint}"
+  [ "$(curl --unix-socket "${LXD_DIR}/unix.socket" "lxd/1.0/certificates/${fingerprint}" | jq -r .error_code)" = "404" ]
+
   # Check that we can add domains with valid certs without confirmation:
 
   # avoid default high port behind some proxies:
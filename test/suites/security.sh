@@ -53,4 +53,30 @@ test_security() {
   fi
 
   lxc delete test-unpriv --force
+
+  # Server-wide restriction of privileged containers
+  lxc config set core.privileged_containers deny
+  ! lxc init testimage test-priv -c security.privileged=true || false
+  lxc init testimage test-unpriv
+  ! lxc config set test-unpriv security.privileged true || false
+  lxc profile create priv
+  ! lxc profile set priv security.privileged true || false
+  lxc profile delete priv
+  ! lxc config set test-unpriv raw.lxc "lxc.aa_profile=unconfined" || false
+  ! lxc config set test-unpriv security.apparmor false || false
+  lxc delete test-unpriv
+
+  # Copies are checked against the config of their source
+  lxc config set core.privileged_containers allow
+  lxc init testimage test-priv -c security.privileged=true
+  lxc config set core.privileged_containers deny
+  ! lxc copy test-priv test-priv-copy || false
+  lxc delete test-priv
+
+  # Local clients are still allowed in "local" mode
+  lxc config set core.privileged_containers local
+  lxc init testimage test-priv -c security.privileged=true
+  ! lxc init testimage localhost:test-priv2 -c security.privileged=true || false
+  lxc delete test-priv
+  lxc config unset core.privileged_containers
 }
@@ -23,4 +23,48 @@ test_server_config() {
   # macaroons are also enabled
   curl --unix-socket "$LXD_DIR/unix.socket" "lxd/1.0" | jq .metadata.auth_methods | grep macaroons
   lxc config unset core.macaroon.endpoint
+
+  # a bad key rejects the whole update
+  [ "$(curl --unix-socket "$LXD_DIR/unix.socket" -X PATCH -d '{"config": {"core.proxy_http": "http://foo", "core.lxcfs": "maybe"}}' "lxd/1.0" | jq -r .error_code)" = "400" ]
+  ! lxc config get core.proxy_http | grep -q foo || false
+
+  # changing the address re-binds the listener live
+  old_addr=$(lxc config get core.https_address)
+  new_addr="127.0.0.1:$(local_tcp_port)"
+  lxc config set core.https_address "${new_addr}"
+  my_curl -f "https://${new_addr}/1.0" | jq -r .metadata.auth | grep -q untrusted
+  lxc config set core.https_address "${old_addr}"
+  ! my_curl -f "https://${new_addr}/1.0" || false
+
+  # test the audit log
+  ! lxc config set core.audit_log foo || false
+  lxc config set core.audit_log file
+  lxc config set core.trust_password sekrit
+  grep -q '"url":"/1.0"' "${LXD_DIR}/logs/audit.log"
+  grep -q '"protocol":"unix"' "${LXD_DIR}/logs/audit.log"
+  ! grep -q sekrit "${LXD_DIR}/logs/audit.log" || false
+  curl -k -s -X DELETE "https://${LXD_ADDR}/1.0/containers/audit-denied"
+  grep '"url":"/1.0/containers/audit-denied"' "${LXD_DIR}/logs/audit.log" | grep -q '"status":403'
+  lxc config unset core.trust_password
+  lxc config unset core.audit_log
+
+  # test the logging settings
+  ! lxc config set core.log_level foo || false
+  ! lxc config set core.log_targets file,foo || false
+  ! lxc config set core.log_format foo || false
+  lxc config set core.log_targets file
+  lxc config set core.log_level debug
+  lxc config set core.log_format json
+  lxc info > /dev/null
+  grep '"msg":"handling"' "${LXD_DIR}/lxd.log" | grep -q '"request":'
+  grep '"msg":"handling"' "${LXD_DIR}/lxd.log" | grep -q '"subsystem":"api"'
+  lxc config unset core.log_format
+  lxc config unset core.log_level
+  lxc config unset core.log_targets
+
+  # test the lxcfs toggle
+  ! lxc config set core.lxcfs maybe || false
+  lxc config set core.lxcfs false
+  lxc config get core.lxcfs | grep -q false
+  lxc config unset core.lxcfs
 }
@@ -204,6 +204,21 @@ snap_restore() {
 
     lxc delete -f a-b
   fi
+
+  # Copies keep their own MAC address when restoring a copied snapshot
+  lxc init testimage macsrc
+  lxc config device add macsrc eth0 nic nictype=p2p name=eth0
+  lxc start macsrc
+  lxc snapshot macsrc snap0
+  lxc stop --force macsrc
+  lxc copy macsrc maccopy
+  lxc start maccopy
+  lxc stop --force maccopy
+  hwaddr="$(lxc config get maccopy volatile.eth0.hwaddr)"
+  lxc restore maccopy snap0
+  [ "$(lxc config get maccopy volatile.eth0.hwaddr)" = "${hwaddr}" ]
+  [ "$(lxc config get macsrc volatile.eth0.hwaddr)" != "${hwaddr}" ]
+  lxc delete -f macsrc maccopy
 }
 
 restore_and_compare_fs() {
@@ -154,6 +154,12 @@ test_storage() {
     # Create dir pool.
     lxc storage create "lxdtest-$(basename "${LXD_DIR}")-pool5" dir
 
+    # Check that creating a pool with an existing name fails.
+    ! lxc storage create "lxdtest-$(basename "${LXD_DIR}")-pool5" dir
+
+    # Check that PATCH on a missing pool reports an error.
+    ! lxc query -X PATCH -d '{"config": {}}' /1.0/storage-pools/lxdtest-$(basename "${LXD_DIR}")-missing-pool
+
     # Check that we cannot create storage pools inside of ${LXD_DIR} other than ${LXD_DIR}/storage-pools/{pool_name}.
     ! lxc storage create "lxdtest-$(basename "${LXD_DIR}")-pool5_under_lxd_dir" dir source="${LXD_DIR}"
 
@@ -363,6 +369,17 @@ test_storage() {
     lxc storage volume rename "lxdtest-$(basename "${LXD_DIR}")-pool5" c11pool5 c11pool5-renamed
     lxc storage volume rename "lxdtest-$(basename "${LXD_DIR}")-pool5" c11pool5-renamed c11pool5
 
+    # Move a stopped container and its snapshots to another storage pool.
+    lxc storage create "lxdtest-$(basename "${LXD_DIR}")-pool5-move" dir
+    lxc init testimage cmovepool5 -s "lxdtest-$(basename "${LXD_DIR}")-pool5"
+    lxc snapshot cmovepool5 snap0
+    lxc move cmovepool5 --storage "lxdtest-$(basename "${LXD_DIR}")-pool5-move"
+    lxc list -c b cmovepool5 | grep "lxdtest-$(basename "${LXD_DIR}")-pool5-move"
+    lxc info cmovepool5 | grep snap0
+    ! lxc move cmovepool5 --storage "lxdtest-$(basename "${LXD_DIR}")-pool5-move" || false
+    lxc delete cmovepool5
+    lxc storage delete "lxdtest-$(basename "${LXD_DIR}")-pool5-move"
+
     if [ "$lxd_backend" = "lvm" ]; then
       lxc init testimage c10pool6 -s "lxdtest-$(basename "${LXD_DIR}")-pool6"
       lxc list -c b c10pool6 | grep "lxdtest-$(basename "${LXD_DIR}")-pool6"